@@ -18,37 +18,108 @@
 package config
 
 import (
+	"os"
+	"strings"
 	"time"
 
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
 	flag "github.com/spf13/pflag"
 )
 
+const (
+	// QueueTenantByNamespace shares workers among namespaces
+	QueueTenantByNamespace = "namespace"
+	// QueueTenantNone disables fairness among tenants
+	QueueTenantNone = "none"
+
+	// PodCacheModeFull caches full Pod objects
+	PodCacheModeFull = "full"
+	// PodCacheModePruned caches only the Pod fields used by lbcf-controller, full Pods are fetched from kube-apiserver when calling generateBackendAddr
+	PodCacheModePruned = "pruned"
+)
+
 type Config struct {
+	ConfigFile           string
+	MetricsAddress       string
+	AdmissionAddress     string
+	MetricsCrt           string
+	MetricsKey           string
+	MetricsAuth          bool
+	QueueStallPeriod     time.Duration
+	QueueTenantKey       string
+	QueueTenantMaxProc   int
 	InformerResyncPeriod time.Duration
+	PodCacheMode         string
 	MinRetryDelay        time.Duration
 	RetryDelayStep       time.Duration
 	MaxRetryDelay        time.Duration
+	RetryJitterFactor    float64
+	EventPolicy          util.EventPolicy
 	KubeConfig           string
 	ServerCrt            string
 	ServerKey            string
 	DryRun               bool
+	FeatureGates         *util.FeatureGate
 	ClientQPS            float32
 	ClientBurst          int
+	WatchNamespaces      []string
+	ObjectSelector       string
+	ControllerName       string
+	MemberClusters       []string
+
+	ShardBy             string
+	ShardIdentity       string
+	ShardLeaseNamespace string
+	ShardLeaseDuration  time.Duration
 }
 
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		FeatureGates: util.NewFeatureGate(util.DefaultFeatures),
+	}
 }
 
 func (o *Config) AddFlags(fs *flag.FlagSet) {
+	hostname, _ := os.Hostname()
+	fs.StringVar(&o.ConfigFile, "config", "", "Path to the versioned configuration file, retry delays and verbosity in the file are reloaded without restart")
+	fs.StringVar(&o.MetricsAddress, "metrics-address", ":11029", "address to serve /metrics and /healthz")
+	fs.StringVar(&o.AdmissionAddress, "admission-address", ":443", "address to serve admission webhooks, /metrics and /healthz are served on the same port if it equals to metrics-address")
+	fs.StringVar(&o.MetricsCrt, "metrics-crt", "", "Path to crt file for metrics server, metrics are served over plain HTTP if empty")
+	fs.StringVar(&o.MetricsKey, "metrics-key", "", "Path to key file for metrics server")
+	fs.BoolVar(&o.MetricsAuth, "metrics-auth", false, "If true, requests to /metrics are authenticated and authorized by kube-apiserver with TokenReview and SubjectAccessReview")
+	fs.DurationVar(&o.QueueStallPeriod, "queue-stall-period", 10*time.Minute, "/livez fails if a queue has pending items but makes no progress within queue-stall-period, 0 disables the check")
+	fs.StringVar(&o.QueueTenantKey, "queue-tenant-key", QueueTenantNone, "share workers of LoadBalancer, BackendGroup, BackendRecord and Bind queues among tenants, \"namespace\" or \"none\", fairness is disabled if none")
+	fs.IntVar(&o.QueueTenantMaxProc, "queue-tenant-max-processing", 100, "maximum number of objects of a tenant being processed at the same time, 0 means no limit")
 	fs.DurationVar(&o.InformerResyncPeriod, "informer-resync-period", 1*time.Minute, "resync period for informers")
+	fs.StringVar(&o.PodCacheMode, "pod-cache-mode", PodCacheModeFull, "\"full\" or \"pruned\", if pruned, only labels, annotations of LBCF, IPs, phase, conditions and container ports of Pods are cached to reduce memory, and full Pods are fetched when calling generateBackendAddr")
 	fs.DurationVar(&o.MinRetryDelay, "min-retry-delay", 5*time.Second, "minimum retry delay for failed webhook calls")
 	fs.DurationVar(&o.RetryDelayStep, "retry-delay-step", 10*time.Second, "the value added to retry delay for each webhook failure")
 	fs.DurationVar(&o.MaxRetryDelay, "max-retry-delay", 2*time.Minute, "maximum retry delay for failed webhook calls")
+	fs.Float64Var(&o.RetryJitterFactor, "retry-jitter-factor", 0.1, "a random delay up to retry-jitter-factor*delay is added to retry delay, 0 means no jitter")
+	fs.StringSliceVar(&o.EventPolicy.SuppressReasons, "event-suppress-reasons", nil, "comma separated reasons of Normal events that are not emitted, e.g. SuccSyncedBackendGroup,SuccEnsureLoadBalancer,SuccEnsureBackend, empty means all events are emitted")
+	fs.DurationVar(&o.EventPolicy.AggregateWindow, "event-aggregate-window", 0, "similar events of an object, i.e. events with the same reason, are aggregated in the window, 0 means the default of client-go")
+	fs.IntVar(&o.EventPolicy.AggregateMaxEvents, "event-aggregate-max-events", 0, "number of similar events of an object in event-aggregate-window before they are aggregated, 0 means the default of client-go")
+	fs.Float32Var(&o.EventPolicy.QPS, "event-qps", 0, "qps of events emitted for each object, 0 means the default of client-go")
+	fs.IntVar(&o.EventPolicy.Burst, "event-burst", 0, "burst of events emitted for each object, 0 means the default of client-go")
 	fs.StringVar(&o.KubeConfig, "kubeconfig", "", "Path to kubeconfig file with authorization information")
 	fs.StringVar(&o.ServerCrt, "server-crt", "/etc/lbcf/server.crt", "Path to crt file for admit webhook server")
 	fs.StringVar(&o.ServerKey, "server-key", "/etc/lbcf/server.key", "Path to key file for admit webhook server")
 	fs.BoolVar(&o.DryRun, "dry-run", false, "If true, only print the webhooks that would be invoked, without invoking them")
+	if o.FeatureGates == nil {
+		o.FeatureGates = util.NewFeatureGate(util.DefaultFeatures)
+	}
+	fs.Var(o.FeatureGates, "feature-gates", "comma separated key=value pairs that enable or disable features, alpha features are disabled by default, beta features are enabled by default and GA features can not be disabled. Options are:\n"+
+		strings.Join(o.FeatureGates.KnownFeatures(), "\n"))
 	fs.Float32Var(&o.ClientQPS, "client-qps", 200, "qps of lbcf client")
 	fs.IntVar(&o.ClientBurst, "client-burst", 400, "burst of lbcf client")
+	fs.StringSliceVar(&o.WatchNamespaces, "watch-namespaces", nil, "comma separated namespaces to watch, kube-system is always watched, empty means all namespaces")
+	fs.StringVar(&o.ObjectSelector, "object-selector", "", "only LoadBalancers, BackendGroups and Binds matching the label selector are handled, empty means all")
+	fs.StringSliceVar(&o.MemberClusters, "member-clusters", nil, "comma separated member clusters in format <name>=<namespace>/<secret>, Pods of member clusters can be selected by BackendGroups and Binds, the kubeconfig of a member cluster is stored in key \"kubeconfig\" of the Secret. Requires feature gate MultiClusterBackends")
+	fs.StringVar(&o.ControllerName, "controller-name", lbcfapi.DefaultControllerName, "only objects using LoadBalancerDrivers with the same spec.controllerName are handled")
+	fs.StringVar(&o.ShardBy, "shard-by", "", "split objects across active replicas by \"namespace\" or \"driver\", empty means sharding is disabled")
+	fs.StringVar(&o.ShardIdentity, "shard-identity", hostname, "identity of this replica in shard membership, must be unique among replicas")
+	fs.StringVar(&o.ShardLeaseNamespace, "shard-lease-namespace", "kube-system", "namespace of the Leases used for shard membership")
+	fs.DurationVar(&o.ShardLeaseDuration, "shard-lease-duration", 15*time.Second, "shards of a replica are reassigned if its Lease is not renewed within shard-lease-duration")
 }
@@ -0,0 +1,72 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventPolicyFlags(t *testing.T) {
+	cases := []struct {
+		name           string
+		args           []string
+		expectSuppress []string
+		expectOptions  record.CorrelatorOptions
+	}{
+		{
+			name:          "default",
+			expectOptions: record.CorrelatorOptions{},
+		},
+		{
+			name: "set",
+			args: []string{
+				"--event-suppress-reasons=SuccEnsureLoadBalancer,SuccEnsureBackend",
+				"--event-aggregate-window=1m",
+				"--event-aggregate-max-events=5",
+				"--event-qps=0.5",
+				"--event-burst=3",
+			},
+			expectSuppress: []string{"SuccEnsureLoadBalancer", "SuccEnsureBackend"},
+			expectOptions: record.CorrelatorOptions{
+				MaxEvents:            5,
+				MaxIntervalInSeconds: int(time.Minute / time.Second),
+				QPS:                  0.5,
+				BurstSize:            3,
+			},
+		},
+	}
+	for _, c := range cases {
+		cfg := NewConfig()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg.AddFlags(fs)
+		if err := fs.Parse(c.args); err != nil {
+			t.Fatalf("case %s: unexpected error: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(cfg.EventPolicy.SuppressReasons, c.expectSuppress) {
+			t.Errorf("case %s: expect suppressReasons %v, get %v", c.name, c.expectSuppress, cfg.EventPolicy.SuppressReasons)
+		}
+		if get := cfg.EventPolicy.CorrelatorOptions(); !reflect.DeepEqual(get, c.expectOptions) {
+			t.Errorf("case %s: expect %+v, get %+v", c.name, c.expectOptions, get)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// FileAPIVersion is the only supported apiVersion of configuration file
+	FileAPIVersion = "lbcf.tkestack.io/v1alpha1"
+	// FileKind is the kind of configuration file
+	FileKind = "LBCFControllerConfiguration"
+)
+
+// FileConfig is the versioned configuration file of lbcf-controller.
+//
+// Fields not set in file keep the values of command line flags, and flags set explicitly on command line
+// take precedence over the file.
+type FileConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// +optional
+	InformerResyncPeriod *metav1.Duration `json:"informerResyncPeriod,omitempty"`
+	// +optional
+	Retry FileRetryConfig `json:"retry,omitempty"`
+	// +optional
+	Client FileClientConfig `json:"client,omitempty"`
+	// +optional
+	Server FileServerConfig `json:"server,omitempty"`
+	// +optional
+	Features FileFeatureConfig `json:"features,omitempty"`
+	// Verbosity is the log level, the same as flag -v
+	// +optional
+	Verbosity *int32 `json:"verbosity,omitempty"`
+}
+
+// FileRetryConfig configures retry delays, it is reloaded without restart
+type FileRetryConfig struct {
+	// +optional
+	MinRetryDelay *metav1.Duration `json:"minRetryDelay,omitempty"`
+	// +optional
+	RetryDelayStep *metav1.Duration `json:"retryDelayStep,omitempty"`
+	// +optional
+	MaxRetryDelay *metav1.Duration `json:"maxRetryDelay,omitempty"`
+	// +optional
+	RetryJitterFactor *float64 `json:"retryJitterFactor,omitempty"`
+}
+
+// FileClientConfig configures the clients talking to kube-apiserver
+type FileClientConfig struct {
+	// +optional
+	QPS *float32 `json:"qps,omitempty"`
+	// +optional
+	Burst *int `json:"burst,omitempty"`
+}
+
+// FileServerConfig configures the servers of lbcf-controller
+type FileServerConfig struct {
+	// +optional
+	MetricsAddress *string `json:"metricsAddress,omitempty"`
+	// +optional
+	AdmissionAddress *string `json:"admissionAddress,omitempty"`
+	// +optional
+	MetricsCrt *string `json:"metricsCrt,omitempty"`
+	// +optional
+	MetricsKey *string `json:"metricsKey,omitempty"`
+	// +optional
+	MetricsAuth *bool `json:"metricsAuth,omitempty"`
+	// +optional
+	ServerCrt *string `json:"serverCrt,omitempty"`
+	// +optional
+	ServerKey *string `json:"serverKey,omitempty"`
+}
+
+// FileFeatureConfig toggles features of lbcf-controller
+type FileFeatureConfig struct {
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+	// FeatureGates enables or disables features the same as flag --feature-gates,
+	// features set by --feature-gates on command line take precedence
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// featureGatesValue encodes gates in the format of flag --feature-gates
+func featureGatesValue(gates map[string]bool) string {
+	var pairs []string
+	for name, enabled := range gates {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseFile decodes a FileConfig in YAML or JSON
+func ParseFile(data []byte) (*FileConfig, error) {
+	fileCfg := &FileConfig{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), len(data)+1).Decode(fileCfg); err != nil {
+		return nil, fmt.Errorf("decode configuration file failed: %v", err)
+	}
+	if fileCfg.APIVersion != FileAPIVersion || fileCfg.Kind != FileKind {
+		return nil, fmt.Errorf("unsupported configuration file %s/%s, expect %s/%s",
+			fileCfg.APIVersion, fileCfg.Kind, FileAPIVersion, FileKind)
+	}
+	if r := fileCfg.Retry.RetryJitterFactor; r != nil && *r < 0 {
+		return nil, fmt.Errorf("retry.retryJitterFactor must not be negative")
+	}
+	if err := util.NewFeatureGate(util.DefaultFeatures).Set(featureGatesValue(fileCfg.Features.FeatureGates)); err != nil {
+		return nil, fmt.Errorf("invalid features.featureGates: %v", err)
+	}
+	return fileCfg, nil
+}
+
+// ApplyFile returns a copy of o with values in fileCfg applied, values of flags changed in fs are kept
+func (o *Config) ApplyFile(fileCfg *FileConfig, fs *flag.FlagSet) (*Config, error) {
+	cpy := *o
+	setDuration := func(flagName string, dst *time.Duration, src *metav1.Duration) {
+		if src != nil && !fs.Changed(flagName) {
+			*dst = src.Duration
+		}
+	}
+	setString := func(flagName string, dst *string, src *string) {
+		if src != nil && !fs.Changed(flagName) {
+			*dst = *src
+		}
+	}
+	setDuration("informer-resync-period", &cpy.InformerResyncPeriod, fileCfg.InformerResyncPeriod)
+	setDuration("min-retry-delay", &cpy.MinRetryDelay, fileCfg.Retry.MinRetryDelay)
+	setDuration("retry-delay-step", &cpy.RetryDelayStep, fileCfg.Retry.RetryDelayStep)
+	setDuration("max-retry-delay", &cpy.MaxRetryDelay, fileCfg.Retry.MaxRetryDelay)
+	if v := fileCfg.Retry.RetryJitterFactor; v != nil && !fs.Changed("retry-jitter-factor") {
+		cpy.RetryJitterFactor = *v
+	}
+	if v := fileCfg.Client.QPS; v != nil && !fs.Changed("client-qps") {
+		cpy.ClientQPS = *v
+	}
+	if v := fileCfg.Client.Burst; v != nil && !fs.Changed("client-burst") {
+		cpy.ClientBurst = *v
+	}
+	setString("metrics-address", &cpy.MetricsAddress, fileCfg.Server.MetricsAddress)
+	setString("admission-address", &cpy.AdmissionAddress, fileCfg.Server.AdmissionAddress)
+	setString("metrics-crt", &cpy.MetricsCrt, fileCfg.Server.MetricsCrt)
+	setString("metrics-key", &cpy.MetricsKey, fileCfg.Server.MetricsKey)
+	if v := fileCfg.Server.MetricsAuth; v != nil && !fs.Changed("metrics-auth") {
+		cpy.MetricsAuth = *v
+	}
+	setString("server-crt", &cpy.ServerCrt, fileCfg.Server.ServerCrt)
+	setString("server-key", &cpy.ServerKey, fileCfg.Server.ServerKey)
+	if v := fileCfg.Features.DryRun; v != nil && !fs.Changed("dry-run") {
+		cpy.DryRun = *v
+	}
+	if len(fileCfg.Features.FeatureGates) > 0 {
+		// features in file are validated by ParseFile, and o.FeatureGates only contains features set on command line
+		gates := util.NewFeatureGate(util.DefaultFeatures)
+		if err := gates.Set(featureGatesValue(fileCfg.Features.FeatureGates) + "," + o.FeatureGates.String()); err != nil {
+			return nil, fmt.Errorf("invalid features.featureGates: %v", err)
+		}
+		cpy.FeatureGates = gates
+	}
+	return &cpy, nil
+}
+
+// RestartRequired returns the names of settings that differ between o and cur but can not be reloaded at runtime
+func (o *Config) RestartRequired(cur *Config) []string {
+	var ret []string
+	if o.InformerResyncPeriod != cur.InformerResyncPeriod {
+		ret = append(ret, "informerResyncPeriod")
+	}
+	if o.ClientQPS != cur.ClientQPS || o.ClientBurst != cur.ClientBurst {
+		ret = append(ret, "client")
+	}
+	if o.MetricsAddress != cur.MetricsAddress || o.AdmissionAddress != cur.AdmissionAddress ||
+		o.MetricsCrt != cur.MetricsCrt || o.MetricsKey != cur.MetricsKey || o.MetricsAuth != cur.MetricsAuth ||
+		o.ServerCrt != cur.ServerCrt || o.ServerKey != cur.ServerKey {
+		ret = append(ret, "server")
+	}
+	if o.DryRun != cur.DryRun || o.FeatureGates.String() != cur.FeatureGates.String() {
+		ret = append(ret, "features")
+	}
+	return ret
+}
+
+// WatchFile reads path every period, and calls onChange with the content if it is changed since last read.
+// The initial content is passed in as last.
+func WatchFile(path string, period time.Duration, last []byte, onChange func(data []byte), stopCh <-chan struct{}) {
+	wait.Until(func() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			klog.Errorf("read configuration file %s failed: %v", path, err)
+			return
+		}
+		if bytes.Equal(data, last) {
+			return
+		}
+		last = data
+		onChange(data)
+	}, period, stopCh)
+}
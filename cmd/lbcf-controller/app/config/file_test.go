@@ -0,0 +1,131 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	flag "github.com/spf13/pflag"
+)
+
+func TestApplyFile(t *testing.T) {
+	data := []byte(`
+apiVersion: lbcf.tkestack.io/v1alpha1
+kind: LBCFControllerConfiguration
+retry:
+  minRetryDelay: 1s
+  maxRetryDelay: 30s
+  retryJitterFactor: 0.5
+client:
+  qps: 50
+server:
+  metricsAddress: ":9090"
+features:
+  featureGates:
+    MultiClusterBackends: true
+verbosity: 4
+`)
+	fileCfg, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fileCfg.Verbosity == nil || *fileCfg.Verbosity != 4 {
+		t.Fatalf("expect verbosity 4, get %v", fileCfg.Verbosity)
+	}
+
+	cfg := NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.AddFlags(fs)
+	if err := fs.Parse([]string{"--max-retry-delay=1m"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	get, err := cfg.ApplyFile(fileCfg, fs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get.MinRetryDelay != time.Second {
+		t.Errorf("expect minRetryDelay from file, get %s", get.MinRetryDelay.String())
+	}
+	if get.MaxRetryDelay != time.Minute {
+		t.Errorf("expect maxRetryDelay from command line, get %s", get.MaxRetryDelay.String())
+	}
+	if get.RetryDelayStep != cfg.RetryDelayStep {
+		t.Errorf("expect retryDelayStep not changed, get %s", get.RetryDelayStep.String())
+	}
+	if get.RetryJitterFactor != 0.5 || get.ClientQPS != 50 || get.MetricsAddress != ":9090" {
+		t.Errorf("unexpected config %+v", *get)
+	}
+	if cfg.MinRetryDelay == time.Second {
+		t.Errorf("expect original config not modified")
+	}
+	if !get.FeatureGates.Enabled(util.MultiClusterBackends) || cfg.FeatureGates.Enabled(util.MultiClusterBackends) {
+		t.Errorf("expect feature gates from file applied to a copy, get %s", get.FeatureGates.String())
+	}
+	if expect := []string{"client", "server", "features"}; !reflect.DeepEqual(cfg.RestartRequired(get), expect) {
+		t.Errorf("expect restart required for %v, get %v", expect, cfg.RestartRequired(get))
+	}
+}
+
+func TestApplyFileFeatureGatesPrecedence(t *testing.T) {
+	fileCfg, err := ParseFile([]byte("apiVersion: lbcf.tkestack.io/v1alpha1\nkind: LBCFControllerConfiguration\nfeatures:\n  featureGates:\n    MultiClusterBackends: true\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.AddFlags(fs)
+	if err := fs.Parse([]string{"--feature-gates=MultiClusterBackends=false"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	get, err := cfg.ApplyFile(fileCfg, fs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get.FeatureGates.Enabled(util.MultiClusterBackends) {
+		t.Errorf("expect feature gates on command line take precedence")
+	}
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	cases := map[string]string{
+		"wrong-version":   "apiVersion: lbcf.tkestack.io/v1\nkind: LBCFControllerConfiguration\n",
+		"wrong-kind":      "apiVersion: lbcf.tkestack.io/v1alpha1\nkind: Config\n",
+		"negative-jitter": "apiVersion: lbcf.tkestack.io/v1alpha1\nkind: LBCFControllerConfiguration\nretry:\n  retryJitterFactor: -1\n",
+		"invalid-yaml":    "apiVersion: [",
+		"unknown-feature": "apiVersion: lbcf.tkestack.io/v1alpha1\nkind: LBCFControllerConfiguration\nfeatures:\n  featureGates:\n    Unknown: true\n",
+	}
+	for name, data := range cases {
+		if _, err := ParseFile([]byte(data)); err == nil {
+			t.Errorf("case %s, expect error", name)
+		}
+	}
+}
+
+func TestApplyFileInvalidFeatureGates(t *testing.T) {
+	cfg := NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.AddFlags(fs)
+	fileCfg := &FileConfig{Features: FileFeatureConfig{FeatureGates: map[string]bool{"unknown-feature": true}}}
+	if _, err := cfg.ApplyFile(fileCfg, fs); err == nil {
+		t.Fatalf("expect error")
+	}
+}
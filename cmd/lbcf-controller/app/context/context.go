@@ -18,6 +18,8 @@
 package context
 
 import (
+	"time"
+
 	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/config"
 	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
 	lbcfv1beta "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
@@ -26,15 +28,21 @@ import (
 	"tkestack.io/lb-controlling-framework/pkg/client-go/informers/externalversions"
 	lbcfclientv1 "tkestack.io/lb-controlling-framework/pkg/client-go/informers/externalversions/lbcf.tkestack.io/v1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/informers/externalversions/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
 
 	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	v1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/informers/internalinterfaces"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
@@ -42,26 +50,95 @@ import (
 
 func NewContext(cfg *config.Config) *Context {
 	c := &Context{
-		Cfg: cfg,
+		Cfg:          cfg,
+		FeatureGates: cfg.FeatureGates,
 	}
+	c.FeatureGates.RecordMetrics()
 	clientCfg := getClientConfigOrDie(cfg.KubeConfig, cfg.ClientQPS, cfg.ClientBurst)
 
 	c.K8sClient = kubernetes.NewForConfigOrDie(clientCfg)
 	c.LbcfClient = lbcfclientset.NewForConfigOrDie(clientCfg)
 
-	c.K8sFactory = informers.NewSharedInformerFactory(c.K8sClient, cfg.InformerResyncPeriod)
-	c.LbcfFactory = externalversions.NewSharedInformerFactory(c.LbcfClient, cfg.InformerResyncPeriod)
+	scope, err := util.NewWatchScope(cfg.WatchNamespaces, cfg.ObjectSelector)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	c.Scope = scope
+	if cfg.PodCacheMode != config.PodCacheModeFull && cfg.PodCacheMode != config.PodCacheModePruned {
+		klog.Fatalf("unknown pod-cache-mode %q", cfg.PodCacheMode)
+	}
+	klog.Infof("watch scope: %s", scope.String())
 
-	c.PodInformer = c.K8sFactory.Core().V1().Pods()
-	c.SvcInformer = c.K8sFactory.Core().V1().Services()
+	memberClusters := make(map[string]util.MemberCluster)
+	if len(cfg.MemberClusters) > 0 && !c.FeatureGates.Enabled(util.MultiClusterBackends) {
+		klog.Fatalf("member-clusters requires feature gate %s", util.MultiClusterBackends)
+	}
+	for _, raw := range cfg.MemberClusters {
+		member, err := util.ParseMemberCluster(raw)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		if _, ok := memberClusters[member.Name]; ok {
+			klog.Fatalf("duplicated member cluster %q", member.Name)
+		}
+		memberClusters[member.Name] = member
+	}
+	c.MemberClusters = util.NewMemberClusterSet()
+	if len(memberClusters) > 0 {
+		// Pods in the same namespaces are watched in member clusters
+		c.memberClusters = newMemberClusterManager(c.K8sClient, cfg, memberClusters, scope.Namespaces(), c.MemberClusters)
+	}
+
+	// Nodes are cluster-scoped
+	c.K8sFactory = informers.NewSharedInformerFactory(c.K8sClient, cfg.InformerResyncPeriod)
 	c.NodeInformer = c.K8sFactory.Core().V1().Nodes()
-	c.LBInformer = c.LbcfFactory.Lbcf().V1beta1().LoadBalancers()
-	c.LBDriverInformer = c.LbcfFactory.Lbcf().V1beta1().LoadBalancerDrivers()
-	c.BGInformer = c.LbcfFactory.Lbcf().V1beta1().BackendGroups()
-	c.BRInformer = c.LbcfFactory.Lbcf().V1beta1().BackendRecords()
-	c.BindInformer = c.LbcfFactory.Lbcf().V1().Binds()
 
-	c.EventBroadCaster = record.NewBroadcaster()
+	pods := make(map[string]cache.SharedIndexInformer)
+	services := make(map[string]cache.SharedIndexInformer)
+	lbs := make(map[string]cache.SharedIndexInformer)
+	drivers := make(map[string]cache.SharedIndexInformer)
+	groups := make(map[string]cache.SharedIndexInformer)
+	backends := make(map[string]cache.SharedIndexInformer)
+	binds := make(map[string]cache.SharedIndexInformer)
+	for _, ns := range scope.Namespaces() {
+		k8sFactory := informers.NewSharedInformerFactoryWithOptions(c.K8sClient, cfg.InformerResyncPeriod,
+			informers.WithNamespace(ns))
+		// LoadBalancerDrivers and BackendRecords are not filtered by object selector
+		lbcfFactory := externalversions.NewSharedInformerFactoryWithOptions(c.LbcfClient, cfg.InformerResyncPeriod,
+			externalversions.WithNamespace(ns))
+		selectedFactory := lbcfFactory
+		if !scope.Selector().Empty() {
+			selectedFactory = externalversions.NewSharedInformerFactoryWithOptions(c.LbcfClient, cfg.InformerResyncPeriod,
+				externalversions.WithNamespace(ns),
+				externalversions.WithTweakListOptions(func(options *metav1.ListOptions) {
+					options.LabelSelector = scope.Selector().String()
+				}))
+			c.lbcfFactories = append(c.lbcfFactories, selectedFactory)
+		}
+		c.k8sFactories = append(c.k8sFactories, k8sFactory)
+		c.lbcfFactories = append(c.lbcfFactories, lbcfFactory)
+
+		if cfg.PodCacheMode == config.PodCacheModePruned {
+			pods[ns] = k8sFactory.InformerFor(&apicorev1.Pod{}, newPrunedPodInformerFunc(ns))
+		} else {
+			pods[ns] = k8sFactory.Core().V1().Pods().Informer()
+		}
+		services[ns] = k8sFactory.Core().V1().Services().Informer()
+		drivers[ns] = lbcfFactory.Lbcf().V1beta1().LoadBalancerDrivers().Informer()
+		backends[ns] = lbcfFactory.Lbcf().V1beta1().BackendRecords().Informer()
+		lbs[ns] = selectedFactory.Lbcf().V1beta1().LoadBalancers().Informer()
+		groups[ns] = selectedFactory.Lbcf().V1beta1().BackendGroups().Informer()
+		binds[ns] = selectedFactory.Lbcf().V1().Binds().Informer()
+	}
+	c.PodInformer = &podInformer{informer: mergeInformers(pods)}
+	c.SvcInformer = &serviceInformer{informer: mergeInformers(services)}
+	c.LBInformer = &loadBalancerInformer{informer: mergeInformers(lbs)}
+	c.LBDriverInformer = &driverInformer{informer: mergeInformers(drivers)}
+	c.BGInformer = &backendGroupInformer{informer: mergeInformers(groups)}
+	c.BRInformer = &backendRecordInformer{informer: mergeInformers(backends)}
+	c.BindInformer = &bindInformer{informer: mergeInformers(binds)}
+
+	c.EventBroadCaster = record.NewBroadcasterWithCorrelatorOptions(cfg.EventPolicy.CorrelatorOptions())
 	scheme := runtime.NewScheme()
 	if err := lbcfv1beta.SchemeBuilder.AddToScheme(scheme); err != nil {
 		klog.Fatal(err.Error())
@@ -69,20 +146,29 @@ func NewContext(cfg *config.Config) *Context {
 	if err := lbcfv1.SchemeBuilder.AddToScheme(scheme); err != nil {
 		klog.Fatal(err.Error())
 	}
-	c.EventRecorder = c.EventBroadCaster.NewRecorder(scheme, apicorev1.EventSource{
+	c.EventRecorder = util.NewSuppressingEventRecorder(c.EventBroadCaster.NewRecorder(scheme, apicorev1.EventSource{
 		Component: "lbcf-controller",
-	})
+	}), cfg.EventPolicy.SuppressReasons)
 	return c
 }
 
 type Context struct {
 	Cfg *config.Config
 
+	// FeatureGates records whether features are enabled, it is shared with Cfg
+	FeatureGates *util.FeatureGate
+
 	K8sClient  *kubernetes.Clientset
 	LbcfClient *lbcfclient.Clientset
 
-	K8sFactory  informers.SharedInformerFactory
-	LbcfFactory externalversions.SharedInformerFactory
+	// Scope is the namespaces and labels of watched objects
+	Scope *util.WatchScope
+
+	// K8sFactory is the cluster-wide factory for cluster-scoped resources
+	K8sFactory informers.SharedInformerFactory
+	// factories of namespaced resources, one for each watched namespace
+	k8sFactories  []informers.SharedInformerFactory
+	lbcfFactories []externalversions.SharedInformerFactory
 
 	PodInformer      v1.PodInformer
 	SvcInformer      v1.ServiceInformer
@@ -93,19 +179,95 @@ type Context struct {
 	BRInformer       v1beta1.BackendRecordInformer
 	BindInformer     lbcfclientv1.BindInformer
 
+	// MemberClusters are the clusters whose Pods can be selected by BackendGroups and Binds, only ready ones are included
+	MemberClusters *util.MemberClusterSet
+	// memberClusters is nil if no member cluster is configured
+	memberClusters *memberClusterManager
+
 	EventBroadCaster record.EventBroadcaster
 	EventRecorder    record.EventRecorder
 }
 
+// MemberClusterNames returns names of the configured member clusters, including those not ready
+func (c *Context) MemberClusterNames() sets.String {
+	ret := sets.NewString()
+	if c.memberClusters != nil {
+		for name := range c.memberClusters.clusters {
+			ret.Insert(name)
+		}
+	}
+	return ret
+}
+
+// AddMemberPodEventHandler adds the handler returned by newHandler to Pod informers of member clusters,
+// including member clusters that become ready later
+func (c *Context) AddMemberPodEventHandler(newHandler func(cluster string) cache.ResourceEventHandler) {
+	if c.memberClusters != nil {
+		c.memberClusters.addEventHandler(newHandler)
+	}
+}
+
 func (c *Context) Start() {
 	c.K8sFactory.Start(wait.NeverStop)
-	c.LbcfFactory.Start(wait.NeverStop)
+	for _, factory := range c.k8sFactories {
+		factory.Start(wait.NeverStop)
+	}
+	for _, factory := range c.lbcfFactories {
+		factory.Start(wait.NeverStop)
+	}
+	if c.memberClusters != nil {
+		c.memberClusters.start()
+	}
 	c.EventBroadCaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: c.K8sClient.CoreV1().Events("")})
 }
 
 func (c *Context) WaitForCacheSync() {
 	c.K8sFactory.WaitForCacheSync(wait.NeverStop)
-	c.LbcfFactory.WaitForCacheSync(wait.NeverStop)
+	for _, factory := range c.k8sFactories {
+		factory.WaitForCacheSync(wait.NeverStop)
+	}
+	for _, factory := range c.lbcfFactories {
+		factory.WaitForCacheSync(wait.NeverStop)
+	}
+	if c.memberClusters != nil {
+		c.memberClusters.waitForCacheSync()
+	}
+}
+
+// HasSynced returns true if all informers have synced.
+// Member clusters are not checked, since they are used only after synced and may never be ready
+func (c *Context) HasSynced() bool {
+	for _, synced := range []cache.InformerSynced{
+		c.PodInformer.Informer().HasSynced,
+		c.SvcInformer.Informer().HasSynced,
+		c.NodeInformer.Informer().HasSynced,
+		c.LBInformer.Informer().HasSynced,
+		c.LBDriverInformer.Informer().HasSynced,
+		c.BGInformer.Informer().HasSynced,
+		c.BRInformer.Informer().HasSynced,
+		c.BindInformer.Informer().HasSynced,
+	} {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// newPrunedPodInformerFunc returns a function that creates Pod informers caching Pods pruned by util.PrunePod
+func newPrunedPodInformerFunc(namespace string) internalinterfaces.NewInformerFunc {
+	return func(client kubernetes.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Pods(namespace).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Pods(namespace).Watch(options)
+			},
+		}
+		return cache.NewSharedIndexInformer(util.NewPrunedPodListWatch(lw), &apicorev1.Pod{}, resyncPeriod,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
 }
 
 func (c *Context) IsDryRun() bool {
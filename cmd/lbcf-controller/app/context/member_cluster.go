@@ -0,0 +1,242 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package context
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/config"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	v1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+// memberClusterManager watches the kubeconfig Secrets of member clusters.
+// When the kubeconfig of a member cluster changes, Pod informers of the member cluster are rebuilt, and the member cluster
+// in MemberClusterSet is replaced after the new informers are synced.
+// Member clusters whose Secret is missing or invalid are logged and skipped,
+// objects selecting their Pods are retried without changing existing BackendRecords.
+type memberClusterManager struct {
+	clusters     map[string]util.MemberCluster
+	namespaces   []string
+	podCacheMode string
+	qps          float32
+	burst        int
+	resyncPeriod time.Duration
+
+	// set contains the member clusters that are ready
+	set             *util.MemberClusterSet
+	secretFactories []informers.SharedInformerFactory
+
+	mu sync.Mutex
+	// active are member clusters in set, pending are member clusters whose informers are not synced yet
+	active   map[string]*memberClusterInformer
+	pending  map[string]*memberClusterInformer
+	handlers []func(cluster string) cache.ResourceEventHandler
+}
+
+// memberClusterInformer is the Pod informer of a member cluster built from kubeconfig
+type memberClusterInformer struct {
+	kubeconfig  []byte
+	client      kubernetes.Interface
+	podInformer v1.PodInformer
+	stopCh      chan struct{}
+}
+
+func newMemberClusterManager(
+	client kubernetes.Interface,
+	cfg *config.Config,
+	clusters map[string]util.MemberCluster,
+	namespaces []string,
+	set *util.MemberClusterSet) *memberClusterManager {
+	m := &memberClusterManager{
+		clusters:     clusters,
+		namespaces:   namespaces,
+		podCacheMode: cfg.PodCacheMode,
+		qps:          cfg.ClientQPS,
+		burst:        cfg.ClientBurst,
+		resyncPeriod: cfg.InformerResyncPeriod,
+		set:          set,
+		active:       make(map[string]*memberClusterInformer),
+		pending:      make(map[string]*memberClusterInformer),
+	}
+	for name, member := range clusters {
+		cluster := name
+		secretName := member.SecretName
+		factory := informers.NewSharedInformerFactoryWithOptions(client, cfg.InformerResyncPeriod,
+			informers.WithNamespace(member.SecretNamespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", secretName).String()
+			}))
+		factory.Core().V1().Secrets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				m.update(cluster, obj.(*apicorev1.Secret))
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				m.update(cluster, cur.(*apicorev1.Secret))
+			},
+			DeleteFunc: func(obj interface{}) {
+				m.remove(cluster)
+			},
+		})
+		m.secretFactories = append(m.secretFactories, factory)
+	}
+	return m
+}
+
+func (m *memberClusterManager) start() {
+	for _, factory := range m.secretFactories {
+		factory.Start(wait.NeverStop)
+	}
+}
+
+// waitForCacheSync waits for the Secrets, member clusters are not waited since they may never be ready
+func (m *memberClusterManager) waitForCacheSync() {
+	for _, factory := range m.secretFactories {
+		factory.WaitForCacheSync(wait.NeverStop)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, member := range m.clusters {
+		if m.active[name] == nil && m.pending[name] == nil {
+			klog.Warningf("member cluster %s is skipped until a valid kubeconfig is stored in Secret %s/%s",
+				name, member.SecretNamespace, member.SecretName)
+		}
+	}
+}
+
+// addEventHandler adds the handler returned by newHandler to Pod informers of member clusters,
+// including member clusters that become ready later
+func (m *memberClusterManager) addEventHandler(newHandler func(cluster string) cache.ResourceEventHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, newHandler)
+	for name, member := range m.active {
+		member.podInformer.Informer().AddEventHandlerWithResyncPeriod(newHandler(name), m.resyncPeriod)
+	}
+}
+
+func (m *memberClusterManager) update(name string, secret *apicorev1.Secret) {
+	data, ok := secret.Data[util.MemberClusterKubeConfigKey]
+	if !ok {
+		klog.Errorf("skip member cluster %s: key %q not found in Secret %s/%s",
+			name, util.MemberClusterKubeConfigKey, secret.Namespace, secret.Name)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := m.pending[name]
+	if latest == nil {
+		latest = m.active[name]
+	}
+	if latest != nil && bytes.Equal(latest.kubeconfig, data) {
+		return
+	}
+	member, err := m.newInformer(data)
+	if err != nil {
+		klog.Errorf("skip member cluster %s: %v", name, err)
+		return
+	}
+	if p := m.pending[name]; p != nil {
+		close(p.stopCh)
+	}
+	m.pending[name] = member
+	klog.Infof("member cluster %s uses kubeconfig in Secret %s/%s, waiting for cache sync", name, secret.Namespace, secret.Name)
+	go m.activate(name, member)
+}
+
+// activate replaces member cluster name with member after the informers of member are synced
+func (m *memberClusterManager) activate(name string, member *memberClusterInformer) {
+	if !cache.WaitForCacheSync(member.stopCh, member.podInformer.Informer().HasSynced) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[name] != member {
+		return
+	}
+	delete(m.pending, name)
+	if old := m.active[name]; old != nil {
+		close(old.stopCh)
+	}
+	m.active[name] = member
+	m.set.Set(name, member.client, member.podInformer.Lister())
+	// existing Pods are replayed as add events, so that objects selecting them are synced
+	for _, newHandler := range m.handlers {
+		member.podInformer.Informer().AddEventHandlerWithResyncPeriod(newHandler(name), m.resyncPeriod)
+	}
+	klog.Infof("member cluster %s is ready", name)
+}
+
+func (m *memberClusterManager) remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range []*memberClusterInformer{m.pending[name], m.active[name]} {
+		if member != nil {
+			close(member.stopCh)
+		}
+	}
+	delete(m.pending, name)
+	delete(m.active, name)
+	m.set.Remove(name)
+	klog.Warningf("member cluster %s is skipped since Secret %s/%s is deleted",
+		name, m.clusters[name].SecretNamespace, m.clusters[name].SecretName)
+}
+
+// newInformer creates a client with kubeconfig and starts Pod informers in watched namespaces
+func (m *memberClusterManager) newInformer(kubeconfig []byte) (*memberClusterInformer, error) {
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	cfg.QPS = m.qps
+	cfg.Burst = m.burst
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create client failed: %v", err)
+	}
+	stopCh := make(chan struct{})
+	pods := make(map[string]cache.SharedIndexInformer)
+	for _, ns := range m.namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, m.resyncPeriod, informers.WithNamespace(ns))
+		if m.podCacheMode == config.PodCacheModePruned {
+			pods[ns] = factory.InformerFor(&apicorev1.Pod{}, newPrunedPodInformerFunc(ns))
+		} else {
+			pods[ns] = factory.Core().V1().Pods().Informer()
+		}
+		factory.Start(stopCh)
+	}
+	return &memberClusterInformer{
+		kubeconfig:  kubeconfig,
+		client:      client,
+		podInformer: &podInformer{informer: mergeInformers(pods)},
+		stopCh:      stopCh,
+	}, nil
+}
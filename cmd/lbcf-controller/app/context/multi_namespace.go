@@ -0,0 +1,316 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package context
+
+import (
+	"fmt"
+	"time"
+
+	lbcflisterv1 "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1"
+	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// mergeInformers returns an informer that watches the same resource in all the given namespaces
+func mergeInformers(informers map[string]cache.SharedIndexInformer) cache.SharedIndexInformer {
+	if len(informers) == 1 {
+		for _, informer := range informers {
+			return informer
+		}
+	}
+	return &multiNamespaceInformer{informers: informers}
+}
+
+// multiNamespaceInformer merges informers of the same resource in different namespaces.
+// The underlying informers are started by their factories, so Run only waits for stopCh.
+type multiNamespaceInformer struct {
+	informers map[string]cache.SharedIndexInformer
+}
+
+var _ cache.SharedIndexInformer = &multiNamespaceInformer{}
+
+func (m *multiNamespaceInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, informer := range m.informers {
+		informer.AddEventHandler(handler)
+	}
+}
+
+func (m *multiNamespaceInformer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range m.informers {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+func (m *multiNamespaceInformer) GetStore() cache.Store {
+	return m.GetIndexer()
+}
+
+func (m *multiNamespaceInformer) GetController() cache.Controller {
+	return nil
+}
+
+func (m *multiNamespaceInformer) Run(stopCh <-chan struct{}) {
+	<-stopCh
+}
+
+func (m *multiNamespaceInformer) HasSynced() bool {
+	for _, informer := range m.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *multiNamespaceInformer) LastSyncResourceVersion() string {
+	return ""
+}
+
+func (m *multiNamespaceInformer) AddIndexers(indexers cache.Indexers) error {
+	for _, informer := range m.informers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiNamespaceInformer) GetIndexer() cache.Indexer {
+	indexers := make(map[string]cache.Indexer)
+	for ns, informer := range m.informers {
+		indexers[ns] = informer.GetIndexer()
+	}
+	return &multiNamespaceIndexer{indexers: indexers}
+}
+
+// multiNamespaceIndexer is a read-only indexer that routes namespaced queries to the indexer of that namespace,
+// and merges results of other queries
+type multiNamespaceIndexer struct {
+	indexers map[string]cache.Indexer
+}
+
+var _ cache.Indexer = &multiNamespaceIndexer{}
+
+var errReadOnlyIndexer = fmt.Errorf("multiNamespaceIndexer is read-only")
+
+func (m *multiNamespaceIndexer) Add(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m *multiNamespaceIndexer) Update(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m *multiNamespaceIndexer) Delete(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m *multiNamespaceIndexer) Replace(list []interface{}, resourceVersion string) error {
+	return errReadOnlyIndexer
+}
+
+func (m *multiNamespaceIndexer) Resync() error {
+	return errReadOnlyIndexer
+}
+
+func (m *multiNamespaceIndexer) List() []interface{} {
+	var ret []interface{}
+	for _, indexer := range m.indexers {
+		ret = append(ret, indexer.List()...)
+	}
+	return ret
+}
+
+func (m *multiNamespaceIndexer) ListKeys() []string {
+	var ret []string
+	for _, indexer := range m.indexers {
+		ret = append(ret, indexer.ListKeys()...)
+	}
+	return ret
+}
+
+func (m *multiNamespaceIndexer) Get(obj interface{}) (item interface{}, exists bool, err error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, ok := m.indexers[accessor.GetNamespace()]
+	if !ok {
+		return nil, false, nil
+	}
+	return indexer.Get(obj)
+}
+
+func (m *multiNamespaceIndexer) GetByKey(key string) (item interface{}, exists bool, err error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, ok := m.indexers[namespace]
+	if !ok {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(key)
+}
+
+func (m *multiNamespaceIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	if indexName == cache.NamespaceIndex {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		indexer, ok := m.indexers[accessor.GetNamespace()]
+		if !ok {
+			return nil, nil
+		}
+		return indexer.Index(indexName, obj)
+	}
+	var ret []interface{}
+	for _, indexer := range m.indexers {
+		items, err := indexer.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, items...)
+	}
+	return ret, nil
+}
+
+func (m *multiNamespaceIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	if indexName == cache.NamespaceIndex {
+		indexer, ok := m.indexers[indexedValue]
+		if !ok {
+			return nil, nil
+		}
+		return indexer.IndexKeys(indexName, indexedValue)
+	}
+	var ret []string
+	for _, indexer := range m.indexers {
+		keys, err := indexer.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, keys...)
+	}
+	return ret, nil
+}
+
+func (m *multiNamespaceIndexer) ListIndexFuncValues(indexName string) []string {
+	values := sets.NewString()
+	for _, indexer := range m.indexers {
+		values.Insert(indexer.ListIndexFuncValues(indexName)...)
+	}
+	return values.List()
+}
+
+func (m *multiNamespaceIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	if indexName == cache.NamespaceIndex {
+		indexer, ok := m.indexers[indexedValue]
+		if !ok {
+			return nil, nil
+		}
+		return indexer.ByIndex(indexName, indexedValue)
+	}
+	var ret []interface{}
+	for _, indexer := range m.indexers {
+		items, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, items...)
+	}
+	return ret, nil
+}
+
+func (m *multiNamespaceIndexer) GetIndexers() cache.Indexers {
+	for _, indexer := range m.indexers {
+		return indexer.GetIndexers()
+	}
+	return cache.Indexers{}
+}
+
+func (m *multiNamespaceIndexer) AddIndexers(newIndexers cache.Indexers) error {
+	for _, indexer := range m.indexers {
+		if err := indexer.AddIndexers(newIndexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typed informers backed by merged informers
+
+type podInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *podInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *podInformer) Lister() corelisters.PodLister {
+	return corelisters.NewPodLister(i.informer.GetIndexer())
+}
+
+type serviceInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *serviceInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *serviceInformer) Lister() corelisters.ServiceLister {
+	return corelisters.NewServiceLister(i.informer.GetIndexer())
+}
+
+type loadBalancerInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *loadBalancerInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *loadBalancerInformer) Lister() lbcflister.LoadBalancerLister {
+	return lbcflister.NewLoadBalancerLister(i.informer.GetIndexer())
+}
+
+type driverInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *driverInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *driverInformer) Lister() lbcflister.LoadBalancerDriverLister {
+	return lbcflister.NewLoadBalancerDriverLister(i.informer.GetIndexer())
+}
+
+type backendGroupInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *backendGroupInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *backendGroupInformer) Lister() lbcflister.BackendGroupLister {
+	return lbcflister.NewBackendGroupLister(i.informer.GetIndexer())
+}
+
+type backendRecordInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *backendRecordInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *backendRecordInformer) Lister() lbcflister.BackendRecordLister {
+	return lbcflister.NewBackendRecordLister(i.informer.GetIndexer())
+}
+
+type bindInformer struct{ informer cache.SharedIndexInformer }
+
+func (i *bindInformer) Informer() cache.SharedIndexInformer { return i.informer }
+
+func (i *bindInformer) Lister() lbcflisterv1.BindLister {
+	return lbcflisterv1.NewBindLister(i.informer.GetIndexer())
+}
@@ -0,0 +1,171 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package context
+
+import (
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const indexPodByNode = "node"
+
+func newFakePodInformer(pods ...*v1.Pod) cache.SharedIndexInformer {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Pod{}, 0, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		indexPodByNode: func(obj interface{}) ([]string, error) {
+			return []string{obj.(*v1.Pod).Spec.NodeName}, nil
+		},
+	})
+	for _, pod := range pods {
+		informer.GetIndexer().Add(pod)
+	}
+	return informer
+}
+
+func newFakePod(namespace, name, node string, podLabels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec:       v1.PodSpec{NodeName: node},
+	}
+}
+
+func podNames(objs []interface{}) []string {
+	var ret []string
+	for _, obj := range objs {
+		ret = append(ret, obj.(*v1.Pod).Name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestMergeInformersSingleNamespace(t *testing.T) {
+	informer := newFakePodInformer()
+	if get := mergeInformers(map[string]cache.SharedIndexInformer{"ns-a": informer}); get != informer {
+		t.Fatalf("expect informer not wrapped for a single namespace")
+	}
+}
+
+func TestMultiNamespaceIndexerRouting(t *testing.T) {
+	podA := newFakePod("ns-a", "pod-a", "node-1", nil)
+	podB := newFakePod("ns-b", "pod-b", "node-1", nil)
+	informer := mergeInformers(map[string]cache.SharedIndexInformer{
+		"ns-a": newFakePodInformer(podA),
+		"ns-b": newFakePodInformer(podB),
+	})
+	indexer := informer.GetIndexer()
+
+	if _, exists, err := indexer.GetByKey("ns-a/pod-a"); err != nil || !exists {
+		t.Errorf("expect ns-a/pod-a found, get %v, %v", exists, err)
+	}
+	if _, exists, err := indexer.GetByKey("ns-a/pod-b"); err != nil || exists {
+		t.Errorf("expect ns-a/pod-b not found, get %v, %v", exists, err)
+	}
+	if _, exists, err := indexer.GetByKey("ns-c/pod-a"); err != nil || exists {
+		t.Errorf("expect pod in unwatched namespace not found, get %v, %v", exists, err)
+	}
+	if _, exists, err := indexer.Get(podB); err != nil || !exists {
+		t.Errorf("expect ns-b/pod-b found, get %v, %v", exists, err)
+	}
+	if get := podNames(indexer.List()); len(get) != 2 {
+		t.Errorf("expect pods in all namespaces listed, get %v", get)
+	}
+	objs, err := indexer.ByIndex(cache.NamespaceIndex, "ns-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get := podNames(objs); len(get) != 1 || get[0] != "pod-b" {
+		t.Errorf("expect only pod-b in ns-b, get %v", get)
+	}
+	if objs, err := indexer.ByIndex(cache.NamespaceIndex, "ns-c"); err != nil || len(objs) != 0 {
+		t.Errorf("expect nothing in unwatched namespace, get %v, %v", podNames(objs), err)
+	}
+	pods, err := (&podInformer{informer: informer}).Lister().Pods("ns-a").List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "pod-a" {
+		t.Errorf("expect only pod-a listed in ns-a, get %d pods", len(pods))
+	}
+	if err := indexer.Add(newFakePod("ns-a", "pod-c", "", nil)); err != errReadOnlyIndexer {
+		t.Errorf("expect read-only indexer, get %v", err)
+	}
+}
+
+func TestMultiNamespaceIndexerByIndex(t *testing.T) {
+	informer := mergeInformers(map[string]cache.SharedIndexInformer{
+		"ns-a": newFakePodInformer(newFakePod("ns-a", "pod-a", "node-1", nil), newFakePod("ns-a", "pod-c", "node-2", nil)),
+		"ns-b": newFakePodInformer(newFakePod("ns-b", "pod-b", "node-1", nil)),
+	})
+	indexer := informer.GetIndexer()
+
+	objs, err := indexer.ByIndex(indexPodByNode, "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get := podNames(objs); len(get) != 2 || get[0] != "pod-a" || get[1] != "pod-b" {
+		t.Errorf("expect pods on node-1 in all namespaces, get %v", get)
+	}
+	keys, err := indexer.IndexKeys(indexPodByNode, "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "ns-a/pod-a" || keys[1] != "ns-b/pod-b" {
+		t.Errorf("expect keys of pods on node-1 in all namespaces, get %v", keys)
+	}
+	if get := indexer.ListIndexFuncValues(indexPodByNode); len(get) != 2 || get[0] != "node-1" || get[1] != "node-2" {
+		t.Errorf("expect index values merged without duplicates, get %v", get)
+	}
+	if _, err := indexer.ByIndex("not-exist", "node-1"); err == nil {
+		t.Errorf("expect error for unknown index")
+	}
+}
+
+func TestMultiNamespaceInformerAddIndexers(t *testing.T) {
+	// indexers are added before informers are started, i.e. before any object is stored
+	informers := map[string]cache.SharedIndexInformer{
+		"ns-a": newFakePodInformer(),
+		"ns-b": newFakePodInformer(),
+	}
+	informer := mergeInformers(informers)
+	if err := informer.AddIndexers(cache.Indexers{
+		"app": func(obj interface{}) ([]string, error) {
+			return []string{obj.(*v1.Pod).Labels["app"]}, nil
+		},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for ns, i := range informers {
+		if _, ok := i.GetIndexer().GetIndexers()["app"]; !ok {
+			t.Errorf("expect indexer added to informer of namespace %s", ns)
+		}
+		i.GetIndexer().Add(newFakePod(ns, "pod-"+ns, "", map[string]string{"app": "web"}))
+	}
+	objs, err := informer.GetIndexer().ByIndex("app", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get := podNames(objs); len(get) != 2 {
+		t.Errorf("expect pods in all namespaces indexed, get %v", get)
+	}
+}
@@ -19,13 +19,20 @@ package app
 
 import (
 	goflag "flag"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/config"
 	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/context"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/admission"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
 	"tkestack.io/lb-controlling-framework/pkg/version"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -35,43 +42,172 @@ import (
 	"k8s.io/klog"
 )
 
+// configReloadPeriod is the interval to check changes of configuration file
+const configReloadPeriod = 10 * time.Second
+
 func NewServer() *cobra.Command {
 	cfg := config.NewConfig()
+	fs := goflag.NewFlagSet(os.Args[0], goflag.ExitOnError)
+	klog.InitFlags(fs)
 	rootCmd := &cobra.Command{
 		Use: "lbcf-controller",
 		Run: func(cmd *cobra.Command, args []string) {
 			version.PrintAndExitIfRequested()
-			printFlags(cmd.Flags())
+
+			flagCfg := *cfg
+			var configData []byte
+			if cfg.ConfigFile != "" {
+				data, err := ioutil.ReadFile(cfg.ConfigFile)
+				if err != nil {
+					klog.Fatalf("read configuration file %s failed: %v", cfg.ConfigFile, err)
+				}
+				fileCfg, err := config.ParseFile(data)
+				if err != nil {
+					klog.Fatal(err)
+				}
+				fileAppliedCfg, err := flagCfg.ApplyFile(fileCfg, cmd.Flags())
+				if err != nil {
+					klog.Fatalf("apply configuration file %s failed: %v", cfg.ConfigFile, err)
+				}
+				*cfg = *fileAppliedCfg
+				setVerbosity(fs, fileCfg, cmd.Flags())
+				configData = data
+				klog.Infof("Using configuration file %s: %+v", cfg.ConfigFile, *cfg)
+			}
+			// feature gates are printed after the configuration file is applied
+			printFlags(cmd.Flags(), cfg.FeatureGates)
 
 			ctx := context.NewContext(cfg)
 			admissionWebhookServer := admission.NewWebhookServer(ctx, cfg.ServerCrt, cfg.ServerKey)
 			lbcf := lbcfcontroller.NewController(ctx)
+			// drivers push notifications to the same TLS server as admission webhooks
+			http.Handle(lbcfcontroller.NotificationPath, lbcf.NotificationHandler())
 
 			ctx.Start()
 			admissionWebhookServer.Start()
 			lbcf.Start()
 
-			mux := http.NewServeMux()
-			mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-				w.Write([]byte("ok"))
-			})
-			mux.Handle("/metrics", promhttp.Handler())
-			go http.ListenAndServe(":11029", mux)
+			if cfg.ConfigFile != "" {
+				go config.WatchFile(cfg.ConfigFile, configReloadPeriod, configData, func(data []byte) {
+					reloadConfig(data, cfg, &flagCfg, cmd.Flags(), fs, lbcf)
+				}, wait.NeverStop)
+			}
 
-			<-wait.NeverStop
+			handlers := healthHandlers(ctx, admissionWebhookServer, lbcf, cfg.QueueStallPeriod)
+			// the state of queues is always authenticated since it contains names of objects in all namespaces
+			handlers[lbcfcontroller.DebugPath] = util.WithDelegatedAuth(ctx.K8sClient, lbcf.DebugHandler())
+			serveMetrics(cfg, ctx, handlers)
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
+			sig := <-sigCh
+			klog.Infof("received signal %s, shutting down", sig.String())
+			lbcf.Stop()
 		},
 	}
 
-	fs := goflag.NewFlagSet(os.Args[0], goflag.ExitOnError)
-	klog.InitFlags(fs)
 	rootCmd.Flags().AddGoFlagSet(fs)
 	cfg.AddFlags(rootCmd.Flags())
 	return rootCmd
 }
 
-func printFlags(fs *pflag.FlagSet) {
+// healthHandlers returns handlers of /healthz, /readyz and /livez
+func healthHandlers(ctx *context.Context, admissionServer *admission.Server, lbcf *lbcfcontroller.Controller, stallPeriod time.Duration) map[string]http.Handler {
+	readyChecks := []util.HealthCheck{
+		{
+			Name: "informer-sync",
+			Check: func() error {
+				if !ctx.HasSynced() {
+					return fmt.Errorf("informers not synced")
+				}
+				return nil
+			},
+		},
+	}
+	readyChecks = append(readyChecks, admissionServer.ReadinessChecks()...)
+	readyChecks = append(readyChecks, lbcf.ReadinessChecks()...)
+	liveChecks := append([]util.HealthCheck{
+		{
+			Name:  "ping",
+			Check: func() error { return nil },
+		},
+	}, lbcf.LivenessChecks(stallPeriod)...)
+
+	return map[string]http.Handler{
+		"/healthz": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}),
+		"/readyz": util.HealthHandler("readyz", readyChecks),
+		"/livez":  util.HealthHandler("livez", liveChecks),
+	}
+}
+
+// serveMetrics serves /metrics and handlers, e.g. health endpoints, on cfg.MetricsAddress.
+// If cfg.MetricsAddress is the same as cfg.AdmissionAddress, they are served by the admission webhook server instead.
+func serveMetrics(cfg *config.Config, ctx *context.Context, handlers map[string]http.Handler) {
+	if (cfg.MetricsCrt == "") != (cfg.MetricsKey == "") {
+		klog.Fatalf("metrics-crt and metrics-key must be specified together")
+	}
+	var metricsHandler http.Handler = promhttp.Handler()
+	if cfg.MetricsAuth {
+		metricsHandler = util.WithDelegatedAuth(ctx.K8sClient, metricsHandler)
+	}
+	if cfg.MetricsAddress == cfg.AdmissionAddress {
+		http.Handle("/metrics", metricsHandler)
+		for path, handler := range handlers {
+			http.Handle(path, handler)
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	go func() {
+		if cfg.MetricsCrt != "" {
+			klog.Fatal(http.ListenAndServeTLS(cfg.MetricsAddress, cfg.MetricsCrt, cfg.MetricsKey, mux))
+		}
+		klog.Fatal(http.ListenAndServe(cfg.MetricsAddress, mux))
+	}()
+}
+
+// reloadConfig applies settings that can be changed at runtime, i.e. retry delays and verbosity
+func reloadConfig(data []byte, cfg *config.Config, flagCfg *config.Config, fs *pflag.FlagSet, klogFlags *goflag.FlagSet, lbcf *lbcfcontroller.Controller) {
+	fileCfg, err := config.ParseFile(data)
+	if err != nil {
+		klog.Errorf("reload configuration file %s failed, keep the current configuration: %v", cfg.ConfigFile, err)
+		return
+	}
+	newCfg, err := flagCfg.ApplyFile(fileCfg, fs)
+	if err != nil {
+		klog.Errorf("reload configuration file %s failed, keep the current configuration: %v", cfg.ConfigFile, err)
+		return
+	}
+	if changed := cfg.RestartRequired(newCfg); len(changed) > 0 {
+		klog.Warningf("%v changed in configuration file %s, restart lbcf-controller to take effect", changed, cfg.ConfigFile)
+	}
+	lbcf.SetRetryDelay(newCfg.RetryJitterFactor, newCfg.MinRetryDelay, newCfg.RetryDelayStep, newCfg.MaxRetryDelay)
+	setVerbosity(klogFlags, fileCfg, fs)
+}
+
+// setVerbosity sets klog verbosity to the value in fileCfg, unless -v is set on command line
+func setVerbosity(klogFlags *goflag.FlagSet, fileCfg *config.FileConfig, fs *pflag.FlagSet) {
+	if fileCfg.Verbosity == nil || fs.Changed("v") {
+		return
+	}
+	if err := klogFlags.Set("v", strconv.Itoa(int(*fileCfg.Verbosity))); err != nil {
+		klog.Errorf("set verbosity failed: %v", err)
+		return
+	}
+	klog.Infof("verbosity set to %d", *fileCfg.Verbosity)
+}
+
+func printFlags(fs *pflag.FlagSet, gates *util.FeatureGate) {
 	klog.Infof("Using flags:")
 	fs.VisitAll(func(flag *pflag.Flag) {
 		klog.Infof("\t%s: %s", flag.Name, flag.Value.String())
 	})
+	klog.Infof("Enabled feature gates: %v", gates.EnabledFeatures())
 }
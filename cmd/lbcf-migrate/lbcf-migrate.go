@@ -0,0 +1,88 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	lbcfclientset "tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned"
+	"tkestack.io/lb-controlling-framework/pkg/migration"
+	"tkestack.io/lb-controlling-framework/pkg/version"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func main() {
+	if err := newCommand().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newCommand() *cobra.Command {
+	var kubeConfig, namespace, backendGroup string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "lbcf-migrate",
+		Short: "Migrate v1beta1 BackendGroups and LoadBalancers to v1 Binds without deregistering backends or recreating load balancers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version.PrintAndExitIfRequested()
+			if backendGroup != "" && namespace == "" {
+				return fmt.Errorf("--namespace is required if --backend-group is set")
+			}
+			cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+			if err != nil {
+				return err
+			}
+			k8sClient, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return err
+			}
+			lbcfClient, err := lbcfclientset.NewForConfig(cfg)
+			if err != nil {
+				return err
+			}
+
+			results, err := migration.NewMigrator(k8sClient, lbcfClient, dryRun, os.Stdout).Run(namespace, backendGroup)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, r := range results {
+				if r.Skipped != "" {
+					fmt.Printf("SKIPPED BackendGroup %s/%s: %s\n", r.Namespace, r.BackendGroup, r.Skipped)
+				} else if r.Err != nil {
+					failed++
+					fmt.Printf("FAILED BackendGroup %s/%s: %v\n", r.Namespace, r.BackendGroup, r.Err)
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d BackendGroups failed, run again to resume", failed, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&kubeConfig, "kubeconfig", "", "Path to kubeconfig file, in-cluster configuration is used if not set")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace of BackendGroups to migrate, all namespaces if not set")
+	cmd.Flags().StringVar(&backendGroup, "backend-group", "", "Name of the BackendGroup to migrate, requires --namespace")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the converted Binds and the planned actions without changing anything")
+	return cmd
+}
@@ -47,9 +47,33 @@ func ConvertEnsurePolicy(ensurePolicy *v1.EnsurePolicyConfig) *v1beta1.EnsurePol
 			Duration: time.Duration(*ensurePolicy.ResyncPeriodInSeconds) * time.Second,
 		}
 	}
+	var retryPolicy *v1beta1.RetryPolicyConfig
+	if ensurePolicy.RetryPolicy != nil {
+		retryPolicy = &v1beta1.RetryPolicyConfig{
+			MaxAttempts: ensurePolicy.RetryPolicy.MaxAttempts,
+		}
+	}
 	return &v1beta1.EnsurePolicyConfig{
-		Policy:    policy,
-		MinPeriod: period,
+		Policy:      policy,
+		MinPeriod:   period,
+		RetryPolicy: retryPolicy,
+	}
+}
+
+// ConvertDrainPolicy converts DrainPolicyConfig of v1 to v1beta1
+func ConvertDrainPolicy(drainPolicy *v1.DrainPolicyConfig) *v1beta1.DrainPolicyConfig {
+	if drainPolicy == nil {
+		return nil
+	}
+	method := v1beta1.DrainByWeight
+	if drainPolicy.Method == v1.DrainByWebhook {
+		method = v1beta1.DrainByWebhook
+	}
+	return &v1beta1.DrainPolicyConfig{
+		Method: method,
+		Period: v1beta1.Duration{
+			Duration: time.Duration(drainPolicy.PeriodInSeconds) * time.Second,
+		},
 	}
 }
 
@@ -73,6 +97,16 @@ func IsLoadBalancerReady(status v1.TargetLoadBalancerStatus) bool {
 	return false
 }
 
+// IsLoadBalancerFailed returns true if the Failed condition is True
+func IsLoadBalancerFailed(status v1.TargetLoadBalancerStatus) bool {
+	for _, cond := range status.Conditions {
+		if cond.Type == v1.LBFailed && cond.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
 // AddOrUpdateLBCondition is an helper function to add specific LoadBalancer condition.
 // If a condition with same type exists, the existing one will be overwritten, otherwise, a new condition will be inserted.
 func AddOrUpdateLBCondition(
@@ -103,5 +137,14 @@ func NeedUpdateRecord(curObj *v1beta1.BackendRecord, expectObj *v1beta1.BackendR
 	if !reflect.DeepEqual(curObj.Spec.EnsurePolicy, expectObj.Spec.EnsurePolicy) {
 		return true
 	}
+	if !reflect.DeepEqual(curObj.Spec.Weight, expectObj.Spec.Weight) {
+		return true
+	}
+	if !reflect.DeepEqual(curObj.Spec.DrainPolicy, expectObj.Spec.DrainPolicy) {
+		return true
+	}
+	if retryAt, ok := expectObj.Annotations[v1beta1.AnnotationRetryAt]; ok && curObj.Annotations[v1beta1.AnnotationRetryAt] != retryAt {
+		return true
+	}
 	return false
 }
@@ -36,12 +36,27 @@ const (
 	LabelLBName         = "lbcf.tkestack.io/lb"
 	LabelBindName       = "lbcf.tkestack.io/bind"
 	LabelPodName        = "lbcf.tkestack.io/pod"
+	LabelServiceName    = "lbcf.tkestack.io/service"
 
 	// LoadBalancers and BackendGroups with label do-not-delete are not allowed to be deleted
 	LabelDoNotDelete = "lbcf.tkestack.io/do-not-delete"
 
 	FinalizerDeleteLB          = "lbcf.tkestack.io/delete-load-loadbalancer"
 	FinalizerDeregisterBackend = "lbcf.tkestack.io/deregister-backend"
+
+	// Setting this annotation to a new value (e.g. a timestamp) retries the object immediately
+	AnnotationRetryAt = "lbcf.tkestack.io/retry-at"
+	// Pods with this annotation are registered with the weight in its value instead of the weight in Bind
+	AnnotationBackendWeight = "lbcf.tkestack.io/backend-weight"
+
+	// Binds with this annotation are not synced, it is set by lbcf-migrate to the name of the BackendGroup being migrated
+	AnnotationMigratingFrom = "lbcf.tkestack.io/migrating-from"
+	// BackendRecords adopted from BackendGroups keep their names,
+	// this annotation is the name generated by the Bind for the same backend
+	AnnotationBindRecordName = "lbcf.tkestack.io/bind-record-name"
+
+	// LocalClusterName refers to the cluster where lbcf-controller runs in pods.clusters
+	LocalClusterName = "local"
 )
 
 // +genclient
@@ -66,14 +81,23 @@ const (
 
 type BindSpec struct {
 	LoadBalancers []TargetLoadBalancer `json:"loadBalancers"`
-	Pods          PodBackend           `json:"pods"`
-	Parameters    map[string]string    `json:"parameters"`
+	// Exactly one of Pods, Service and Static is set
+	// +optional
+	Pods *PodBackend `json:"pods,omitempty"`
+	// +optional
+	Service *ServiceBackend `json:"service,omitempty"`
+	// +optional
+	Static     []string          `json:"static,omitempty"`
+	Parameters map[string]string `json:"parameters"`
 	// +optional
 	DeregisterPolicy *DeregPolicy `json:"deregisterPolicy"`
 	// +optional
 	DeregisterWebhook *DeregisterWebhookSpec `json:"deregisterWebhook"`
 	// +optional
 	EnsurePolicy *EnsurePolicyConfig `json:"ensurePolicy,omitempty"`
+	// DrainPolicy drains backends before they are deregistered, backends are deregistered immediately if not set
+	// +optional
+	DrainPolicy *DrainPolicyConfig `json:"drainPolicy,omitempty"`
 }
 
 type TargetLoadBalancer struct {
@@ -96,6 +120,9 @@ type BindList struct {
 
 type BindStatus struct {
 	LoadBalancerStatuses []TargetLoadBalancerStatus `json:"loadBalancerStatuses"`
+	// LastRetryAt is the last processed value of annotation lbcf.tkestack.io/retry-at
+	// +optional
+	LastRetryAt string `json:"lastRetryAt,omitempty"`
 }
 
 type TargetLoadBalancerStatus struct {
@@ -107,6 +134,21 @@ type TargetLoadBalancerStatus struct {
 	// Retry after if Status is not True
 	RetryAfter metav1.Time                   `json:"retryAfter,omitempty"`
 	Conditions []TargetLoadBalancerCondition `json:"conditions,omitempty"`
+	// +optional
+	RunningOperation *RunningOperation `json:"runningOperation,omitempty"`
+	// FailedAttempts is the number of consecutive failed operations on the load balancer,
+	// it is reset when the Bind is modified or annotation lbcf.tkestack.io/retry-at is changed
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// ObservedGeneration is the generation of the Bind when FailedAttempts is recorded
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// RunningOperation records the webhook that responded with status Running and when it started
+type RunningOperation struct {
+	Webhook   string      `json:"webhook"`
+	StartTime metav1.Time `json:"startTime"`
 }
 
 type TargetLoadBalancerCondition struct {
@@ -128,6 +170,8 @@ type LoadBalancerConditionType string
 const (
 	LBCreated LoadBalancerConditionType = "Created"
 	LBReady   LoadBalancerConditionType = "Ready"
+	// LBFailed is True once the failed attempts reach maxAttempts in retryPolicy, the load balancer is not retried anymore
+	LBFailed LoadBalancerConditionType = "Failed"
 )
 
 type deregFailurePolicy string
@@ -150,6 +194,26 @@ type PodBackend struct {
 	ByLabel *SelectPodByLabel `json:"byLabel,omitempty"`
 	// +optional
 	ByName []string `json:"byName,omitempty"`
+	// Clusters are the names of clusters where Pods are selected, LocalClusterName refers to the local cluster.
+	// Only Pods in the local cluster are selected if empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Weight of the backends, the driver decides the weight if not set.
+	// It is overridden by annotation lbcf.tkestack.io/backend-weight of Pods
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// ServiceBackend selects the NodePort of a Service on Nodes matching NodeSelector
+type ServiceBackend struct {
+	Name string `json:"name"`
+	// Port is the Service port, not the NodePort
+	Port PortSelector `json:"port"`
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Weight of the backends, the driver decides the weight if not set
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
 }
 
 type PortSelector struct {
@@ -197,12 +261,14 @@ func (d Duration) MarshalJSON() ([]byte, error) {
 type ConditionReason string
 
 const (
-	ReasonCreating        ConditionReason = "Creating"
-	ReasonCreateFailed    ConditionReason = "CreateFailed"
-	ReasonEnsuring        ConditionReason = "Ensuring"
-	ReasonEnsureFailed    ConditionReason = "EnsureFailed"
-	ReasonWebhookError    ConditionReason = "WebhookError"
-	ReasonInvalidResponse ConditionReason = "InvalidResponse"
+	ReasonCreating           ConditionReason = "Creating"
+	ReasonCreateFailed       ConditionReason = "CreateFailed"
+	ReasonEnsuring           ConditionReason = "Ensuring"
+	ReasonEnsureFailed       ConditionReason = "EnsureFailed"
+	ReasonWebhookError       ConditionReason = "WebhookError"
+	ReasonInvalidResponse    ConditionReason = "InvalidResponse"
+	ReasonTimeout            ConditionReason = "Timeout"
+	ReasonRetryLimitExceeded ConditionReason = "RetryLimitExceeded"
 )
 
 func (c ConditionReason) String() string {
@@ -220,4 +286,29 @@ type EnsurePolicyConfig struct {
 	Policy EnsurePolicyType `json:"policy"`
 	// +optional
 	ResyncPeriodInSeconds *int32 `json:"resyncPeriodInSeconds,omitempty"`
+	// +optional
+	RetryPolicy *RetryPolicyConfig `json:"retryPolicy,omitempty"`
+}
+
+// RetryPolicyConfig limits how many times a failed operation is retried
+type RetryPolicyConfig struct {
+	// MaxAttempts is the number of failed attempts after which the target load balancer is marked with condition Failed
+	// in its TargetLoadBalancerStatus and no longer retried, 0 means no limit
+	MaxAttempts int32 `json:"maxAttempts"`
+}
+
+type DrainMethod string
+
+const (
+	// DrainByWeight drains backends by calling ensureBackend with weight 0
+	DrainByWeight DrainMethod = "Weight"
+	// DrainByWebhook drains backends by calling webhook drainBackend
+	DrainByWebhook DrainMethod = "Webhook"
+)
+
+// DrainPolicyConfig configures how backends are drained before they are deregistered
+type DrainPolicyConfig struct {
+	Method DrainMethod `json:"method"`
+	// PeriodInSeconds is how long to wait after the backend is drained before it is deregistered
+	PeriodInSeconds int32 `json:"periodInSeconds"`
 }
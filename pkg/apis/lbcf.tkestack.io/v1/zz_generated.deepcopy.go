@@ -97,7 +97,21 @@ func (in *BindSpec) DeepCopyInto(out *BindSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	in.Pods.DeepCopyInto(&out.Pods)
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(PodBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Static != nil {
+		in, out := &in.Static, &out.Static
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Parameters != nil {
 		in, out := &in.Parameters, &out.Parameters
 		*out = make(map[string]string, len(*in))
@@ -120,6 +134,11 @@ func (in *BindSpec) DeepCopyInto(out *BindSpec) {
 		*out = new(EnsurePolicyConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DrainPolicy != nil {
+		in, out := &in.DrainPolicy, &out.DrainPolicy
+		*out = new(DrainPolicyConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -177,6 +196,22 @@ func (in *DeregisterWebhookSpec) DeepCopy() *DeregisterWebhookSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainPolicyConfig) DeepCopyInto(out *DrainPolicyConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainPolicyConfig.
+func (in *DrainPolicyConfig) DeepCopy() *DrainPolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainPolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Duration) DeepCopyInto(out *Duration) {
 	*out = *in
@@ -201,6 +236,11 @@ func (in *EnsurePolicyConfig) DeepCopyInto(out *EnsurePolicyConfig) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicyConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -232,6 +272,16 @@ func (in *PodBackend) DeepCopyInto(out *PodBackend) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -261,6 +311,39 @@ func (in *PortSelector) DeepCopy() *PortSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicyConfig) DeepCopyInto(out *RetryPolicyConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicyConfig.
+func (in *RetryPolicyConfig) DeepCopy() *RetryPolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunningOperation) DeepCopyInto(out *RunningOperation) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunningOperation.
+func (in *RunningOperation) DeepCopy() *RunningOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(RunningOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelectPodByLabel) DeepCopyInto(out *SelectPodByLabel) {
 	*out = *in
@@ -289,6 +372,35 @@ func (in *SelectPodByLabel) DeepCopy() *SelectPodByLabel {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceBackend) DeepCopyInto(out *ServiceBackend) {
+	*out = *in
+	out.Port = in.Port
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceBackend.
+func (in *ServiceBackend) DeepCopy() *ServiceBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetLoadBalancer) DeepCopyInto(out *TargetLoadBalancer) {
 	*out = *in
@@ -366,6 +478,11 @@ func (in *TargetLoadBalancerStatus) DeepCopyInto(out *TargetLoadBalancerStatus)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RunningOperation != nil {
+		in, out := &in.RunningOperation, &out.RunningOperation
+		*out = new(RunningOperation)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
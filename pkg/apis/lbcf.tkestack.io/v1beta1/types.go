@@ -34,6 +34,9 @@ const (
 
 	SystemDriverPrefix = "lbcf-"
 
+	// DefaultControllerName is the controller name of LoadBalancerDrivers that have no controllerName specified
+	DefaultControllerName = "lbcf.tkestack.io/lbcf-controller"
+
 	// labels of LoadBalancerDriver
 	DriverDrainingLabel = "lbcf.tkestack.io/driver-draining"
 	LabelDriverName     = "lbcf.tkestack.io/lb-driver"
@@ -53,6 +56,13 @@ const (
 
 	// Control admission webhook validation policy
 	AnnotationValidationPolicy = "lbcf.tkestack.io/validation-policy"
+	// Setting this annotation to a new value (e.g. a timestamp) retries the object immediately and resets failed attempts
+	AnnotationRetryAt = "lbcf.tkestack.io/retry-at"
+	// Pods with this annotation are registered with the weight in its value instead of the weight in BackendGroup
+	AnnotationBackendWeight = "lbcf.tkestack.io/backend-weight"
+
+	// LocalClusterName refers to the cluster where lbcf-controller runs in pods.clusters
+	LocalClusterName = "local"
 )
 
 // +genclient
@@ -92,6 +102,10 @@ type LoadBalancerList struct {
 type LoadBalancerStatus struct {
 	LBInfo     map[string]string       `json:"lbInfo"`
 	Conditions []LoadBalancerCondition `json:"conditions"`
+	// +optional
+	RunningOperation *RunningOperation `json:"runningOperation,omitempty"`
+
+	RetryStatus `json:",inline"`
 }
 
 type LoadBalancerCondition struct {
@@ -116,6 +130,9 @@ type LoadBalancerConditionType string
 const (
 	LBCreated          LoadBalancerConditionType = "Created"
 	LBAttributesSynced LoadBalancerConditionType = "AttributesSynced"
+	LBFailed           LoadBalancerConditionType = "Failed"
+	// LBDeleted is False with reason Timeout if deleteLoadBalancer keeps running longer than maxOperationDuration
+	LBDeleted LoadBalancerConditionType = "Deleted"
 )
 
 // +genclient
@@ -157,6 +174,9 @@ type BackendGroupSpec struct {
 	Parameters map[string]string `json:"parameters,omitempty"`
 	// +optional
 	EnsurePolicy *EnsurePolicyConfig `json:"ensurePolicy,omitempty"`
+	// DrainPolicy drains backends before they are deregistered, backends are deregistered immediately if not set
+	// +optional
+	DrainPolicy *DrainPolicyConfig `json:"drainPolicy,omitempty"`
 }
 
 func (bgSpec BackendGroupSpec) GetLoadBalancers() []string {
@@ -187,6 +207,9 @@ type ServiceBackend struct {
 	Port PortSelector `json:"port,omitempty"`
 	// +optional
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Weight of the backends, the driver decides the weight if not set
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
 }
 
 type PodBackend struct {
@@ -198,6 +221,14 @@ type PodBackend struct {
 	ByLabel *SelectPodByLabel `json:"byLabel,omitempty"`
 	// +optional
 	ByName []string `json:"byName,omitempty"`
+	// Clusters are the names of clusters where Pods are selected, LocalClusterName refers to the local cluster.
+	// Only Pods in the local cluster are selected if empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Weight of the backends, the driver decides the weight if not set.
+	// It is overridden by annotation lbcf.tkestack.io/backend-weight of Pods
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
 }
 
 func (pb PodBackend) GetPortSelectors() []PortSelector {
@@ -234,6 +265,9 @@ type SelectPodByLabel struct {
 type BackendGroupStatus struct {
 	Backends           int32 `json:"backends"`
 	RegisteredBackends int32 `json:"registeredBackends"`
+	// LastRetryAt is the last processed value of annotation lbcf.tkestack.io/retry-at
+	// +optional
+	LastRetryAt string `json:"lastRetryAt,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -271,12 +305,28 @@ type LoadBalancerDriverSpec struct {
 	AcceptDryRunCall bool   `json:"acceptDryRunCall"`
 	// +optional
 	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+	// MaxOperationDuration is the longest time an operation is allowed to stay in Running status,
+	// 0 means no limit. It can be overridden by WebhookConfig.MaxOperationDuration
+	// +optional
+	MaxOperationDuration *Duration `json:"maxOperationDuration,omitempty"`
+	// RetryPolicy is used for objects that don't have retryPolicy configured in ensurePolicy
+	// +optional
+	RetryPolicy *RetryPolicyConfig `json:"retryPolicy,omitempty"`
+	// RetryDelay overrides the retry delay flags of lbcf-controller for objects using this driver
+	// +optional
+	RetryDelay *RetryDelayConfig `json:"retryDelay,omitempty"`
+	// ControllerName is the name of the lbcf-controller installation that handles objects using this driver,
+	// default to DefaultControllerName
+	// +optional
+	ControllerName string `json:"controllerName,omitempty"`
 }
 
 type WebhookConfig struct {
 	Name string `json:"name"`
 	// +optional
 	Timeout Duration `json:"timeout,omitempty"`
+	// +optional
+	MaxOperationDuration *Duration `json:"maxOperationDuration,omitempty"`
 }
 
 type LoadBalancerDriverConditionType string
@@ -343,11 +393,19 @@ type BackendRecordSpec struct {
 	StaticAddr *string `json:"staticAddr,omitempty"`
 	// +optional
 	EnsurePolicy *EnsurePolicyConfig `json:"ensurePolicy,omitempty"`
+	// Weight is passed to ensureBackend, the driver decides the weight if not set
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+	// +optional
+	DrainPolicy *DrainPolicyConfig `json:"drainPolicy,omitempty"`
 }
 
 type PodBackendRecord struct {
 	Name string       `json:"name"`
 	Port PortSelector `json:"port"`
+	// Cluster is the member cluster where the Pod runs, empty means the local cluster
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
 }
 
 type ServiceBackendRecord struct {
@@ -460,12 +518,33 @@ type BackendRecordStatus struct {
 	BackendAddr  string                   `json:"backendAddr"`
 	InjectedInfo map[string]string        `json:"injectedInfo"`
 	Conditions   []BackendRecordCondition `json:"conditions"`
+	// +optional
+	RunningOperation *RunningOperation `json:"runningOperation,omitempty"`
+	// DrainStartTime is when the backend is drained, it is deregistered once the drain period is over
+	// +optional
+	DrainStartTime *metav1.Time `json:"drainStartTime,omitempty"`
+
+	RetryStatus `json:",inline"`
+}
+
+// RunningOperation records the webhook that responded with status Running and when it started
+type RunningOperation struct {
+	Webhook   string      `json:"webhook"`
+	StartTime metav1.Time `json:"startTime"`
 }
 
 type BackendRecordConditionType string
 
 const (
 	BackendRegistered BackendRecordConditionType = "Registered"
+	BackendFailed     BackendRecordConditionType = "Failed"
+	// BackendDrained is True once the backend is drained, and False if draining failed
+	BackendDrained BackendRecordConditionType = "Drained"
+	// BackendAddrGenerated is False with reason Timeout if generateBackendAddr keeps running longer than maxOperationDuration,
+	// and True once the address is generated afterwards
+	BackendAddrGenerated BackendRecordConditionType = "AddrGenerated"
+	// BackendDeregistered is False with reason Timeout if deregisterBackend keeps running longer than maxOperationDuration
+	BackendDeregistered BackendRecordConditionType = "Deregistered"
 )
 
 type BackendRecordCondition struct {
@@ -533,6 +612,8 @@ const (
 	ReasonOperationInProgress ConditionReason = "OperationInProgres"
 	ReasonOperationFailed     ConditionReason = "OperationFailed"
 	ReasonInvalidResponse     ConditionReason = "InvalidResponse"
+	ReasonOperationTimeout    ConditionReason = "Timeout"
+	ReasonRetryLimitExceeded  ConditionReason = "RetryLimitExceeded"
 )
 
 func (c ConditionReason) String() string {
@@ -550,4 +631,56 @@ type EnsurePolicyConfig struct {
 	Policy EnsurePolicyType `json:"policy"`
 	// +optional
 	MinPeriod *Duration `json:"minPeriod,omitempty"`
+	// +optional
+	RetryPolicy *RetryPolicyConfig `json:"retryPolicy,omitempty"`
+}
+
+// RetryPolicyConfig limits how many times a failed operation is retried
+type RetryPolicyConfig struct {
+	// MaxAttempts is the number of failed attempts after which the object is marked as Failed and no longer retried,
+	// 0 means no limit
+	MaxAttempts int32 `json:"maxAttempts"`
+}
+
+type DrainMethod string
+
+const (
+	// DrainByWeight drains backends by calling ensureBackend with weight 0
+	DrainByWeight DrainMethod = "Weight"
+	// DrainByWebhook drains backends by calling webhook drainBackend
+	DrainByWebhook DrainMethod = "Webhook"
+)
+
+// DrainPolicyConfig configures how backends are drained before they are deregistered
+type DrainPolicyConfig struct {
+	Method DrainMethod `json:"method"`
+	// Period is how long to wait after the backend is drained before it is deregistered
+	Period Duration `json:"period"`
+}
+
+// RetryDelayConfig configures the exponential backoff between retries, unset fields fall back to
+// flags --min-retry-delay, --retry-delay-step and --max-retry-delay
+type RetryDelayConfig struct {
+	// +optional
+	MinDelay *Duration `json:"minDelay,omitempty"`
+	// +optional
+	Step *Duration `json:"step,omitempty"`
+	// +optional
+	MaxDelay *Duration `json:"maxDelay,omitempty"`
+}
+
+// RetryStatus records failed attempts of the current operation
+type RetryStatus struct {
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// ObservedGeneration is the generation of the object when FailedAttempts is recorded
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastRetryAt is the last processed value of annotation lbcf.tkestack.io/retry-at
+	// +optional
+	LastRetryAt string `json:"lastRetryAt,omitempty"`
+	// RetryAfter is the earliest time the failed operation is retried,
+	// it is used to restore the retry delay when lbcf-controller restarts
+	// +optional
+	RetryAfter *metav1.Time `json:"retryAfter,omitempty"`
 }
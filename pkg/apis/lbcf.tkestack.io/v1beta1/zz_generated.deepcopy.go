@@ -137,6 +137,11 @@ func (in *BackendGroupSpec) DeepCopyInto(out *BackendGroupSpec) {
 		*out = new(EnsurePolicyConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DrainPolicy != nil {
+		in, out := &in.DrainPolicy, &out.DrainPolicy
+		*out = new(DrainPolicyConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -288,6 +293,16 @@ func (in *BackendRecordSpec) DeepCopyInto(out *BackendRecordSpec) {
 		*out = new(EnsurePolicyConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DrainPolicy != nil {
+		in, out := &in.DrainPolicy, &out.DrainPolicy
+		*out = new(DrainPolicyConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -318,6 +333,16 @@ func (in *BackendRecordStatus) DeepCopyInto(out *BackendRecordStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RunningOperation != nil {
+		in, out := &in.RunningOperation, &out.RunningOperation
+		*out = new(RunningOperation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DrainStartTime != nil {
+		in, out := &in.DrainStartTime, &out.DrainStartTime
+		*out = (*in).DeepCopy()
+	}
+	in.RetryStatus.DeepCopyInto(&out.RetryStatus)
 	return
 }
 
@@ -352,6 +377,23 @@ func (in *DeregisterWebhookSpec) DeepCopy() *DeregisterWebhookSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainPolicyConfig) DeepCopyInto(out *DrainPolicyConfig) {
+	*out = *in
+	out.Period = in.Period
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainPolicyConfig.
+func (in *DrainPolicyConfig) DeepCopy() *DrainPolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainPolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Duration) DeepCopyInto(out *Duration) {
 	*out = *in
@@ -376,6 +418,11 @@ func (in *EnsurePolicyConfig) DeepCopyInto(out *EnsurePolicyConfig) {
 		*out = new(Duration)
 		**out = **in
 	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicyConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -534,7 +581,24 @@ func (in *LoadBalancerDriverSpec) DeepCopyInto(out *LoadBalancerDriverSpec) {
 	if in.Webhooks != nil {
 		in, out := &in.Webhooks, &out.Webhooks
 		*out = make([]WebhookConfig, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaxOperationDuration != nil {
+		in, out := &in.MaxOperationDuration, &out.MaxOperationDuration
+		*out = new(Duration)
+		**out = **in
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicyConfig)
+		**out = **in
+	}
+	if in.RetryDelay != nil {
+		in, out := &in.RetryDelay, &out.RetryDelay
+		*out = new(RetryDelayConfig)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -662,6 +726,12 @@ func (in *LoadBalancerStatus) DeepCopyInto(out *LoadBalancerStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RunningOperation != nil {
+		in, out := &in.RunningOperation, &out.RunningOperation
+		*out = new(RunningOperation)
+		(*in).DeepCopyInto(*out)
+	}
+	in.RetryStatus.DeepCopyInto(&out.RetryStatus)
 	return
 }
 
@@ -716,6 +786,16 @@ func (in *PodBackend) DeepCopyInto(out *PodBackend) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -767,6 +847,90 @@ func (in *PortSelector) DeepCopy() *PortSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryDelayConfig) DeepCopyInto(out *RetryDelayConfig) {
+	*out = *in
+	if in.MinDelay != nil {
+		in, out := &in.MinDelay, &out.MinDelay
+		*out = new(Duration)
+		**out = **in
+	}
+	if in.Step != nil {
+		in, out := &in.Step, &out.Step
+		*out = new(Duration)
+		**out = **in
+	}
+	if in.MaxDelay != nil {
+		in, out := &in.MaxDelay, &out.MaxDelay
+		*out = new(Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryDelayConfig.
+func (in *RetryDelayConfig) DeepCopy() *RetryDelayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryDelayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicyConfig) DeepCopyInto(out *RetryPolicyConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicyConfig.
+func (in *RetryPolicyConfig) DeepCopy() *RetryPolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryStatus) DeepCopyInto(out *RetryStatus) {
+	*out = *in
+	if in.RetryAfter != nil {
+		in, out := &in.RetryAfter, &out.RetryAfter
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryStatus.
+func (in *RetryStatus) DeepCopy() *RetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunningOperation) DeepCopyInto(out *RunningOperation) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunningOperation.
+func (in *RunningOperation) DeepCopy() *RunningOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(RunningOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelectPodByLabel) DeepCopyInto(out *SelectPodByLabel) {
 	*out = *in
@@ -806,6 +970,11 @@ func (in *ServiceBackend) DeepCopyInto(out *ServiceBackend) {
 			(*out)[key] = val
 		}
 	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -857,6 +1026,11 @@ func (in *ServicePort) DeepCopy() *ServicePort {
 func (in *WebhookConfig) DeepCopyInto(out *WebhookConfig) {
 	*out = *in
 	out.Timeout = in.Timeout
+	if in.MaxOperationDuration != nil {
+		in, out := &in.MaxOperationDuration, &out.MaxOperationDuration
+		*out = new(Duration)
+		**out = **in
+	}
 	return
 }
 
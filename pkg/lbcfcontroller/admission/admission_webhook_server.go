@@ -18,6 +18,7 @@
 package admission
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 
@@ -49,6 +50,19 @@ type Server struct {
 	keyFile      string
 }
 
+// ReadinessChecks returns the checks of the server served by /readyz
+func (s *Server) ReadinessChecks() []util.HealthCheck {
+	return []util.HealthCheck{
+		{
+			Name: "admission-cert",
+			Check: func() error {
+				_, err := tls.LoadX509KeyPair(s.crtFile, s.keyFile)
+				return err
+			},
+		},
+	}
+}
+
 // Start starts the server in a new goroutine
 func (s *Server) Start() {
 	ws := new(restful.WebService)
@@ -76,7 +90,7 @@ func (s *Server) Start() {
 
 	go func() {
 		s.context.WaitForCacheSync()
-		klog.Fatal(http.ListenAndServeTLS(":443", s.crtFile, s.keyFile, nil))
+		klog.Fatal(http.ListenAndServeTLS(s.context.Cfg.AdmissionAddress, s.crtFile, s.keyFile, nil))
 	}()
 }
 
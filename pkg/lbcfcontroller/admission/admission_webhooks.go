@@ -35,6 +35,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 )
 
@@ -78,8 +80,12 @@ func NewAdmitter(ctx *context.Context, invoker util.WebhookInvoker) Webhook {
 		driverLister:   ctx.LBDriverInformer.Lister(),
 		backendLister:  ctx.BRInformer.Lister(),
 		bgLister:       ctx.BGInformer.Lister(),
+		scope:          ctx.Scope,
+		class:          util.NewControllerClass(ctx.Cfg.ControllerName, ctx.LBDriverInformer.Lister(), ctx.LBInformer.Lister()),
 		webhookInvoker: invoker,
 		dryRun:         ctx.IsDryRun(),
+		featureGates:   ctx.FeatureGates,
+		memberClusters: ctx.MemberClusterNames(),
 	}
 }
 
@@ -90,8 +96,17 @@ type Admitter struct {
 	bgLister      lbcflister.BackendGroupLister
 	backendLister lbcflister.BackendRecordLister
 
+	// scope is nil if all objects are handled
+	scope *util.WatchScope
+	// class is nil in tests, objects of all drivers are handled
+	class *util.ControllerClass
+
 	webhookInvoker util.WebhookInvoker
 	dryRun         bool
+	// featureGates is nil in tests, default values of features are used
+	featureGates *util.FeatureGate
+	// memberClusters are names of member clusters whose Pods can be selected
+	memberClusters sets.String
 }
 
 // MutateLB implements MutatingWebHook for LoadBalancer
@@ -101,6 +116,9 @@ func (a *Admitter) MutateLB(ar *admission.AdmissionReview) *admission.AdmissionR
 	if err != nil {
 		return toAdmissionResponse(err)
 	}
+	if !a.ownedByClass(obj) {
+		return toAdmissionResponse(nil)
+	}
 	var patches []Patch
 	patches = append(patches, addFinalizer(len(obj.Finalizers) == 0, lbcfapi.FinalizerDeleteLB))
 	p, err := json.Marshal(patches)
@@ -123,6 +141,9 @@ func (a *Admitter) MutateDriver(ar *admission.AdmissionReview) *admission.Admiss
 	if err != nil {
 		return toAdmissionResponse(err)
 	}
+	if !a.ownedByClass(obj) {
+		return toAdmissionResponse(nil)
+	}
 
 	dPatch := &driverPatch{obj: obj}
 	dPatch.setWebhook()
@@ -147,6 +168,9 @@ func (a *Admitter) MutateBackendGroup(ar *admission.AdmissionReview) *admission.
 	if err != nil {
 		return toAdmissionResponse(err)
 	}
+	if !a.ownedByClass(obj) {
+		return toAdmissionResponse(nil)
+	}
 
 	bgPatch := &backendGroupPatch{obj: obj}
 	bgPatch.addLabel()
@@ -174,6 +198,9 @@ func (a *Admitter) MutateBind(ar *admission.AdmissionReview) *admission.Admissio
 	if err != nil {
 		return toAdmissionResponse(err)
 	}
+	if !a.ownedByClass(obj) {
+		return toAdmissionResponse(nil)
+	}
 	var patches []Patch
 	patches = append(patches, addFinalizer(len(obj.Finalizers) == 0, v1.FinalizerDeleteLB))
 	p, err := json.Marshal(patches)
@@ -196,6 +223,12 @@ func (a *Admitter) ValidateLoadBalancerCreate(ar *admission.AdmissionReview) *ad
 		return toAdmissionResponse(fmt.Errorf("decode LoadBalancer failed: %v", err))
 	}
 
+	if !a.ownedByClass(lb) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(lb, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	errList := ValidateLoadBalancer(lb, true)
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
@@ -250,6 +283,12 @@ func (a *Admitter) ValidateLoadBalancerUpdate(ar *admission.AdmissionReview) *ad
 	if curObj.DeletionTimestamp != nil {
 		return toAdmissionResponse(nil)
 	}
+	if !a.ownedByClass(curObj) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(curObj, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	if allowed, msg := LBUpdatedFieldsAllowed(curObj, oldObj); !allowed {
 		return toAdmissionResponse(fmt.Errorf(msg))
 	}
@@ -301,6 +340,9 @@ func (a *Admitter) ValidateLoadBalancerDelete(ar *admission.AdmissionReview) *ad
 		}
 		return toAdmissionResponse(nil)
 	}
+	if !a.ownedByClass(lb) {
+		return toAdmissionResponse(nil)
+	}
 	if _, ok := lb.Labels[lbcfapi.LabelDoNotDelete]; ok {
 		return toAdmissionResponse(
 			fmt.Errorf("LoadBalancer with label %s is not allowed to be deleted, delete the label first if you know what you are doing",
@@ -321,6 +363,12 @@ func (a *Admitter) ValidateDriverCreate(ar *admission.AdmissionReview) *admissio
 		return toAdmissionResponse(fmt.Errorf("decode LoadBalancerDriver failed: %v", err))
 	}
 
+	if !a.ownedByClass(d) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(d, false); err != nil {
+		return toAdmissionResponse(err)
+	}
 	errList := ValidateLoadBalancerDriver(d)
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
@@ -347,6 +395,12 @@ func (a *Admitter) ValidateDriverUpdate(ar *admission.AdmissionReview) *admissio
 		return toAdmissionResponse(nil)
 	}
 
+	if !a.ownedByClass(curObj) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(curObj, false); err != nil {
+		return toAdmissionResponse(err)
+	}
 	if allowed, msg := DriverUpdatedFieldsAllowed(curObj, oldObj); !allowed {
 		return toAdmissionResponse(fmt.Errorf(msg))
 	}
@@ -370,6 +424,9 @@ func (a *Admitter) ValidateDriverDelete(ar *admission.AdmissionReview) *admissio
 		}
 		return toAdmissionResponse(fmt.Errorf("retrieve LoadBalancerDriver %s/%s failed: %v", ar.Request.Namespace, ar.Request.Name, err))
 	}
+	if !a.ownedByClass(driver) {
+		return toAdmissionResponse(nil)
+	}
 	if !util.IsDriverDraining(driver) {
 		return toAdmissionResponse(fmt.Errorf("LoadBalancerDriver must be label with %s:\"true\" before delete", lbcfapi.DriverDrainingLabel))
 	}
@@ -400,10 +457,24 @@ func (a *Admitter) ValidateBackendGroupCreate(ar *admission.AdmissionReview) *ad
 	if err := json.Unmarshal(ar.Request.Object.Raw, bg); err != nil {
 		return toAdmissionResponse(fmt.Errorf("decode BackendGroup failed: %v", err))
 	}
+	if !a.ownedByClass(bg) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(bg, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	errList := ValidateBackendGroup(bg)
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
 	}
+	if bg.Spec.Pods != nil {
+		if err := a.validateClusters(bg.Spec.Pods.Clusters); err != nil {
+			return toAdmissionResponse(err)
+		}
+	}
+	if err := a.validateSingleController(a.driversOfBackendGroup(bg)); err != nil {
+		return toAdmissionResponse(err)
+	}
 	for _, lb := range bg.Spec.GetLoadBalancers() {
 		if err := a.validateBackendGroupCreate(bg, lb); err != nil {
 			return toAdmissionResponse(err)
@@ -430,6 +501,12 @@ func (a *Admitter) ValidateBackendGroupUpdate(ar *admission.AdmissionReview) *ad
 		return toAdmissionResponse(nil)
 	}
 
+	if !a.ownedByClass(curObj) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(curObj, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	if allowed, msg := BackendGroupUpdateFieldsAllowed(curObj, oldObj); !allowed {
 		return toAdmissionResponse(fmt.Errorf(msg))
 	}
@@ -438,7 +515,15 @@ func (a *Admitter) ValidateBackendGroupUpdate(ar *admission.AdmissionReview) *ad
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
 	}
+	if curObj.Spec.Pods != nil {
+		if err := a.validateClusters(curObj.Spec.Pods.Clusters); err != nil {
+			return toAdmissionResponse(err)
+		}
+	}
 
+	if err := a.validateSingleController(a.driversOfBackendGroup(curObj)); err != nil {
+		return toAdmissionResponse(err)
+	}
 	for _, lb := range curObj.Spec.GetLoadBalancers() {
 		if err := a.validateBackendGroupUpdate(oldObj, curObj, lb); err != nil {
 			return toAdmissionResponse(err)
@@ -460,6 +545,9 @@ func (a *Admitter) ValidateBackendGroupDelete(ar *admission.AdmissionReview) *ad
 		}
 		return toAdmissionResponse(nil)
 	}
+	if !a.ownedByClass(bg) {
+		return toAdmissionResponse(nil)
+	}
 	if _, ok := bg.Labels[lbcfapi.LabelDoNotDelete]; ok {
 		return toAdmissionResponse(
 			fmt.Errorf("BackendGroup with label %s is not allowed to be deleted, delete the label first if you know what you are doing",
@@ -478,10 +566,24 @@ func (a *Admitter) ValidateBindCreate(ar *admission.AdmissionReview) *admission.
 	if err := json.Unmarshal(ar.Request.Object.Raw, bind); err != nil {
 		return toAdmissionResponse(fmt.Errorf("decode Bind failed: %v", err))
 	}
+	if !a.ownedByClass(bind) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(bind, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	errList := ValidateBind(bind)
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
 	}
+	if bind.Spec.Pods != nil {
+		if err := a.validateClusters(bind.Spec.Pods.Clusters); err != nil {
+			return toAdmissionResponse(err)
+		}
+	}
+	if err := a.validateSingleController(driversOfBind(bind)); err != nil {
+		return toAdmissionResponse(err)
+	}
 	if a.dryRun {
 		return dryRunResponse()
 	}
@@ -502,6 +604,12 @@ func (a *Admitter) ValidateBindUpdate(ar *admission.AdmissionReview) *admission.
 	if curObj.DeletionTimestamp != nil {
 		return toAdmissionResponse(nil)
 	}
+	if !a.ownedByClass(curObj) {
+		return toAdmissionResponse(nil)
+	}
+	if err := a.validateScope(curObj, true); err != nil {
+		return toAdmissionResponse(err)
+	}
 	if reflect.DeepEqual(oldObj.Spec, curObj.Spec) {
 		return toAdmissionResponse(nil)
 	}
@@ -509,14 +617,106 @@ func (a *Admitter) ValidateBindUpdate(ar *admission.AdmissionReview) *admission.
 	if len(errList) > 0 {
 		return toAdmissionResponse(fmt.Errorf("%s", errList.ToAggregate().Error()))
 	}
+	if curObj.Spec.Pods != nil {
+		if err := a.validateClusters(curObj.Spec.Pods.Clusters); err != nil {
+			return toAdmissionResponse(err)
+		}
+	}
+	if err := a.validateSingleController(driversOfBind(curObj)); err != nil {
+		return toAdmissionResponse(err)
+	}
 	return toAdmissionResponse(a.validateBindByDriver(oldObj, curObj))
 }
 
+// validateClusters checks that clusters in spec.pods.clusters are the local cluster or configured member clusters
+func (a *Admitter) validateClusters(clusters []string) error {
+	if len(clusters) == 0 {
+		return nil
+	}
+	if !a.featureGates.Enabled(util.MultiClusterBackends) {
+		return fmt.Errorf("spec.pods.clusters requires feature gate %s", util.MultiClusterBackends)
+	}
+	seen := sets.NewString()
+	for _, cluster := range clusters {
+		if cluster != lbcfapi.LocalClusterName && !a.memberClusters.Has(cluster) {
+			return fmt.Errorf("spec.pods.clusters: unknown cluster %q", cluster)
+		}
+		if seen.Has(cluster) {
+			return fmt.Errorf("spec.pods.clusters: duplicated cluster %q", cluster)
+		}
+		seen.Insert(cluster)
+	}
+	return nil
+}
+
 // ValidateBindDelete implements ValidatingWebHook for Bind deleting
 func (a *Admitter) ValidateBindDelete(ar *admission.AdmissionReview) *admission.AdmissionResponse {
 	return toAdmissionResponse(nil)
 }
 
+// ownedByClass returns false if obj uses drivers of another controller, such objects are left to that controller
+func (a *Admitter) ownedByClass(obj interface{}) bool {
+	return a.class == nil || a.class.Owns(obj)
+}
+
+// validateSingleController rejects objects using drivers of different controllers,
+// drivers are keys in the form of namespace/name
+func (a *Admitter) validateSingleController(drivers sets.String) error {
+	controllers := sets.NewString()
+	for key := range drivers {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		driver, err := a.driverLister.LoadBalancerDrivers(namespace).Get(name)
+		if err != nil || driver == nil {
+			continue
+		}
+		controllers.Insert(util.ControllerNameOfDriver(driver))
+	}
+	if controllers.Len() > 1 {
+		return fmt.Errorf("drivers of different controllers are not allowed to be used together, controllers: %v", controllers.List())
+	}
+	return nil
+}
+
+func (a *Admitter) driversOfBackendGroup(bg *lbcfapi.BackendGroup) sets.String {
+	drivers := sets.NewString()
+	for _, lbName := range bg.Spec.GetLoadBalancers() {
+		lb, err := a.lbLister.LoadBalancers(util.NamespaceOfSharedObj(lbName, bg.Namespace)).Get(lbName)
+		if err != nil || lb == nil {
+			continue
+		}
+		drivers.Insert(util.NamespacedNameKeyFunc(util.NamespaceOfSharedObj(lb.Spec.LBDriver, lb.Namespace), lb.Spec.LBDriver))
+	}
+	return drivers
+}
+
+func driversOfBind(bind *v1.Bind) sets.String {
+	drivers := sets.NewString()
+	for _, lb := range bind.Spec.LoadBalancers {
+		drivers.Insert(util.NamespacedNameKeyFunc(util.NamespaceOfSharedObj(lb.Driver, bind.Namespace), lb.Driver))
+	}
+	return drivers
+}
+
+// validateScope rejects objects that are not handled by lbcf-controller,
+// the object selector is checked only if useSelector is true
+func (a *Admitter) validateScope(obj metav1.Object, useSelector bool) error {
+	if a.scope == nil {
+		return nil
+	}
+	if !a.scope.ContainsNamespace(obj.GetNamespace()) {
+		return fmt.Errorf("namespace %q is not watched by lbcf-controller, watched namespaces: %v",
+			obj.GetNamespace(), a.scope.Namespaces())
+	}
+	if useSelector && !a.scope.Contains(obj) {
+		return fmt.Errorf("labels of %s/%s do not match object selector %q of lbcf-controller",
+			obj.GetNamespace(), obj.GetName(), a.scope.Selector().String())
+	}
+	return nil
+}
+
 func (a *Admitter) validateBindByDriver(oldBind, curbind *v1.Bind) error {
 	wg := sync.WaitGroup{}
 	resultChan := make(chan error, len(curbind.Spec.LoadBalancers)*2)
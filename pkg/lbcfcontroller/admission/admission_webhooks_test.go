@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	v1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
@@ -33,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 func TestAdmitter_MutateLB(t *testing.T) {
@@ -824,6 +826,12 @@ func TestAdmitter_ValidateDriverCreate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -889,6 +897,12 @@ func TestAdmitter_ValidateDriverCreate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -989,6 +1003,12 @@ func TestAdmitter_ValidateDriverCreate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -1193,6 +1213,12 @@ func TestAdmitter_ValidateDriverUpdate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -1253,6 +1279,12 @@ func TestAdmitter_ValidateDriverUpdate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -1317,6 +1349,12 @@ func TestAdmitter_ValidateDriverUpdate(t *testing.T) {
 								Duration: 15 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 15 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -1509,6 +1547,104 @@ func TestAdmitter_ValidateLoadBalancerCreate_DriverDraining(t *testing.T) {
 	}
 }
 
+func TestAdmitter_ValidateLoadBalancerCreate_OtherController(t *testing.T) {
+	driverLister := &alwaysSuccDriverLister{
+		get: &lbcfapi.LoadBalancerDriver{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-driver",
+			},
+			Spec: lbcfapi.LoadBalancerDriverSpec{
+				ControllerName: "canary",
+			},
+		},
+	}
+	lb := &lbcfapi.LoadBalancer{
+		Spec: lbcfapi.LoadBalancerSpec{
+			LBDriver: "test-driver",
+		},
+	}
+	raw, _ := json.Marshal(lb)
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Object: runtime.RawExtension{
+				Raw: raw,
+			},
+		},
+	}
+
+	// skipped by the default controller
+	a := fakeAdmitter(&alwaysSuccLBLister{}, driverLister, nil, &alwaysSuccBackendLister{}, &fakeFailInvoker{})
+	a.(*Admitter).class = util.NewControllerClass("", driverLister, &alwaysSuccLBLister{})
+	if resp := a.ValidateLoadBalancerCreate(ar); !resp.Allowed {
+		t.Fatalf("expect allow")
+	}
+	if resp := a.MutateLB(ar); !resp.Allowed || len(resp.Patch) > 0 {
+		t.Fatalf("expect allowed without patch, get %+v", resp)
+	}
+
+	// validated by the canary controller
+	a.(*Admitter).class = util.NewControllerClass("canary", driverLister, &alwaysSuccLBLister{})
+	if resp := a.ValidateLoadBalancerCreate(ar); resp.Allowed {
+		t.Fatalf("expect not allow")
+	}
+}
+
+func TestAdmitter_ValidateBindCreate_MixedControllers(t *testing.T) {
+	driverLister := &driverListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancerDriver{
+			"default-driver": {
+				ObjectMeta: metav1.ObjectMeta{Name: "default-driver"},
+			},
+			"canary-driver": {
+				ObjectMeta: metav1.ObjectMeta{Name: "canary-driver"},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					ControllerName: "canary",
+				},
+			},
+		},
+	}
+	bind := &v1.Bind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bind",
+			Namespace: "default",
+		},
+		Spec: v1.BindSpec{
+			LoadBalancers: []v1.TargetLoadBalancer{
+				{
+					Name:   "lb-a",
+					Driver: "default-driver",
+				},
+				{
+					Name:   "lb-b",
+					Driver: "canary-driver",
+				},
+			},
+			Pods: &v1.PodBackend{
+				Ports: []v1.PortSelector{
+					{
+						Port:     80,
+						Protocol: "TCP",
+					},
+				},
+				ByName: []string{"pod-0"},
+			},
+		},
+	}
+	raw, _ := json.Marshal(bind)
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Object: runtime.RawExtension{
+				Raw: raw,
+			},
+		},
+	}
+	a := fakeAdmitter(&alwaysSuccLBLister{}, driverLister, nil, &alwaysSuccBackendLister{}, &fakeSuccInvoker{})
+	a.(*Admitter).class = util.NewControllerClass("", driverLister, &alwaysSuccLBLister{})
+	if resp := a.ValidateBindCreate(ar); resp.Allowed {
+		t.Fatalf("expect not allow")
+	}
+}
+
 func TestAdmitter_ValidateLoadBalancerCreate_DriverDeleting(t *testing.T) {
 	a := fakeAdmitter(&alwaysSuccLBLister{}, deletingDriverLister(), nil, &alwaysSuccBackendLister{}, &fakeSuccInvoker{})
 	lb := &lbcfapi.LoadBalancer{
@@ -1825,6 +1961,99 @@ func TestAdmitter_ValidateBackendGroupCreate(t *testing.T) {
 	}
 }
 
+func TestAdmitter_ValidateBackendGroupCreate_OutOfScope(t *testing.T) {
+	scope, err := util.NewWatchScope([]string{"default"}, "tenant=a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type testCase struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		allowed   bool
+	}
+	cases := []testCase{
+		{
+			name:      "in-scope",
+			namespace: "default",
+			labels:    map[string]string{"tenant": "a"},
+			allowed:   true,
+		},
+		{
+			name:      "kube-system-always-watched",
+			namespace: "kube-system",
+			labels:    map[string]string{"tenant": "a"},
+			allowed:   true,
+		},
+		{
+			name:      "namespace-not-watched",
+			namespace: "other",
+			labels:    map[string]string{"tenant": "a"},
+		},
+		{
+			name:      "label-not-match",
+			namespace: "default",
+			labels:    map[string]string{"tenant": "b"},
+		},
+	}
+	for _, c := range cases {
+		group := &lbcfapi.BackendGroup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "bg",
+				Namespace: c.namespace,
+				Labels:    c.labels,
+			},
+			Spec: lbcfapi.BackendGroupSpec{
+				LoadBalancers: []string{"test-lb"},
+				Pods: &lbcfapi.PodBackend{
+					Ports: []lbcfapi.PortSelector{
+						{
+							Port:     80,
+							Protocol: "TCP",
+						},
+					},
+					ByName: []string{"pod-0"},
+				},
+			},
+		}
+		raw, _ := json.Marshal(group)
+		ar := &v1beta1.AdmissionReview{
+			Request: &v1beta1.AdmissionRequest{
+				Object: runtime.RawExtension{
+					Raw: raw,
+				},
+			},
+		}
+		a := fakeAdmitter(
+			&alwaysSuccLBLister{
+				get: &lbcfapi.LoadBalancer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-lb",
+						Namespace: group.Namespace,
+					},
+					Spec: lbcfapi.LoadBalancerSpec{
+						LBDriver: "test-driver",
+					},
+				},
+			},
+			&alwaysSuccDriverLister{
+				get: &lbcfapi.LoadBalancerDriver{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-driver",
+						Namespace: group.Namespace,
+					},
+				},
+			},
+			nil,
+			&alwaysSuccBackendLister{}, &fakeSuccInvoker{})
+		a.(*Admitter).scope = scope
+		resp := a.ValidateBackendGroupCreate(ar)
+		if resp.Allowed != c.allowed {
+			t.Errorf("case %s, expect allowed %v, get %v, msg: %v", c.name, c.allowed, resp.Allowed, resp.Result)
+		}
+	}
+}
+
 func TestAdmitter_ValidateBackendGroupCreate_InvalidGroup(t *testing.T) {
 	group := &lbcfapi.BackendGroup{
 		Spec: lbcfapi.BackendGroupSpec{
@@ -2375,6 +2604,29 @@ func (l *alwaysSuccBackendGroupLister) BackendGroups(namespace string) lbcfliste
 	return l
 }
 
+type driverListerWithStore struct {
+	store map[string]*lbcfapi.LoadBalancerDriver
+}
+
+func (l *driverListerWithStore) Get(name string) (*lbcfapi.LoadBalancerDriver, error) {
+	driver, ok := l.store[name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{}, name)
+	}
+	return driver, nil
+}
+
+func (l *driverListerWithStore) List(selector labels.Selector) (ret []*lbcfapi.LoadBalancerDriver, err error) {
+	for _, driver := range l.store {
+		ret = append(ret, driver)
+	}
+	return
+}
+
+func (l *driverListerWithStore) LoadBalancerDrivers(namespace string) lbcflister.LoadBalancerDriverNamespaceLister {
+	return l
+}
+
 type notfoundDriverLister struct {
 }
 
@@ -2420,6 +2672,12 @@ func (l *notfoundBackendLister) BackendRecords(namespace string) lbcflister.Back
 
 type fakeSuccInvoker struct{}
 
+func (c *fakeSuccInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: true,
+	}, nil
+}
+
 func (c *fakeSuccInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2500,8 +2758,18 @@ func (c *fakeSuccInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDri
 		}}, nil
 }
 
+func (c *fakeSuccInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 type fakeFailInvoker struct{}
 
+func (c *fakeFailInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: false,
+	}, nil
+}
+
 func (c *fakeFailInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2581,6 +2849,10 @@ func (c *fakeFailInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDri
 		}}, nil
 }
 
+func (c *fakeFailInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 func drainingDriverLister() lbcflister.LoadBalancerDriverLister {
 	return &alwaysSuccDriverLister{
 		get: &lbcfapi.LoadBalancerDriver{
@@ -2628,3 +2900,35 @@ func toStringPtr(s string) *string {
 func int32Ptr(i int32) *int32 {
 	return &i
 }
+
+func TestAdmitter_ValidateClusters(t *testing.T) {
+	gates := util.NewFeatureGate(util.DefaultFeatures)
+	a := &Admitter{
+		featureGates:   gates,
+		memberClusters: sets.NewString("member"),
+	}
+	if err := a.validateClusters(nil); err != nil {
+		t.Fatalf("expect no clusters allowed without feature gate, get %v", err)
+	}
+	if err := a.validateClusters([]string{"member"}); err == nil {
+		t.Fatalf("expect error when feature gate %s is disabled", util.MultiClusterBackends)
+	}
+
+	if err := gates.Set(string(util.MultiClusterBackends) + "=true"); err != nil {
+		t.Fatalf("set feature gate: %v", err)
+	}
+	cases := []struct {
+		clusters []string
+		valid    bool
+	}{
+		{[]string{lbcfapi.LocalClusterName, "member"}, true},
+		{[]string{"member"}, true},
+		{[]string{"unknown"}, false},
+		{[]string{"member", "member"}, false},
+	}
+	for _, c := range cases {
+		if err := a.validateClusters(c.clusters); (err == nil) != c.valid {
+			t.Errorf("clusters %v, expect valid %v, get %v", c.clusters, c.valid, err)
+		}
+	}
+}
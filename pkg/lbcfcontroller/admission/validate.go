@@ -50,6 +50,13 @@ func ValidateLoadBalancerDriver(raw *lbcfapi.LoadBalancerDriver) field.ErrorList
 	allErrs = append(allErrs, validateDriverType(raw.Spec.DriverType, field.NewPath("spec").Child("driverType"))...)
 	allErrs = append(allErrs, validateDriverURL(raw.Spec.URL, field.NewPath("spec").Child("url"))...)
 	allErrs = append(allErrs, validateDriverWebhooks(raw.Spec.Webhooks, field.NewPath("spec").Child("webhooks"))...)
+	allErrs = append(allErrs, validateMaxOperationDuration(raw.Spec.MaxOperationDuration, field.NewPath("spec").Child("maxOperationDuration"))...)
+	if raw.Spec.RetryPolicy != nil {
+		allErrs = append(allErrs, validateMaxAttempts(raw.Spec.RetryPolicy.MaxAttempts, field.NewPath("spec").Child("retryPolicy", "maxAttempts"))...)
+	}
+	if raw.Spec.RetryDelay != nil {
+		allErrs = append(allErrs, validateRetryDelay(raw.Spec.RetryDelay, field.NewPath("spec").Child("retryDelay"))...)
+	}
 	return allErrs
 }
 
@@ -89,6 +96,9 @@ func ValidateBackendGroup(raw *lbcfapi.BackendGroup) field.ErrorList {
 		allErrs = append(allErrs, validateDeregisterPolicy(*raw.Spec.DeregisterPolicy, field.NewPath("spec").Child("deregisterPolicy"))...)
 		allErrs = append(allErrs, validateDeregWebhookSpec(raw.Spec.DeregisterWebhook, *raw.Spec.DeregisterPolicy, field.NewPath("spec").Child("deregisterWebhook"))...)
 	}
+	if raw.Spec.DrainPolicy != nil {
+		allErrs = append(allErrs, validateDrainPolicy(*raw.Spec.DrainPolicy, field.NewPath("spec").Child("drainPolicy"))...)
+	}
 	allErrs = append(allErrs, validateBackends(&raw.Spec, field.NewPath("spec"))...)
 	return allErrs
 }
@@ -139,60 +149,8 @@ func ValidateBind(bind *lbcfapiv1.Bind) field.ErrorList {
 		}
 	}
 
-	// validate pods
-	if bind.Spec.Pods.ByLabel == nil && len(bind.Spec.Pods.ByName) == 0 {
-		allErrs = append(allErrs,
-			field.Required(
-				field.NewPath("spec").
-					Child("pods", "byLabel"),
-				"one of byLabel and byName must be set"))
-	}
-
-	// validate pods.byLabel
-	if bind.Spec.Pods.ByLabel != nil {
-		if len(bind.Spec.Pods.ByLabel.Selector) == 0 {
-			allErrs = append(allErrs,
-				field.Required(
-					field.NewPath("spec").
-						Child("pods", "byLabel", "selector"),
-					"selector must be set if byLabel is used"))
-		}
-	}
-
-	// validate pods.ports
-	if len(bind.Spec.Pods.Ports) == 0 {
-		allErrs = append(allErrs,
-			field.Required(
-				field.NewPath("spec").
-					Child("pods", "ports"),
-				"ports must be set for pods"))
-	}
-	for i, port := range bind.Spec.Pods.Ports {
-		if port.Port == 0 {
-			allErrs = append(allErrs,
-				field.Invalid(
-					field.NewPath("spec").
-						Child("pods", fmt.Sprintf("ports[%d]", i), "port"),
-					port.Port,
-					"port must not be 0"))
-		}
-		if strings.TrimSpace(port.Protocol) == "" {
-			allErrs = append(allErrs,
-				field.Required(
-					field.NewPath("spec").
-						Child("pods", fmt.Sprintf("ports[%d]", i), "protocol"),
-					"protocol in port must be set, supported protocol: tcp, udp"))
-		}
-		protocol := strings.ToLower(port.Protocol)
-		if protocol != "tcp" && protocol != "udp" {
-			allErrs = append(allErrs,
-				field.Invalid(
-					field.NewPath("spec").
-						Child("pods", fmt.Sprintf("ports[%d]", i), "protocol"),
-					port.Protocol,
-					fmt.Sprintf("supported protocol: tcp, udp")))
-		}
-	}
+	// validate backends
+	allErrs = append(allErrs, validateBindBackends(bind.Spec, field.NewPath("spec"))...)
 
 	// validate deregisterPolicy
 	if bind.Spec.DeregisterPolicy != nil {
@@ -273,6 +231,174 @@ func ValidateBind(bind *lbcfapiv1.Bind) field.ErrorList {
 					*bind.Spec.EnsurePolicy.ResyncPeriodInSeconds,
 					"resyncPeriodInSeconds must be greater than or equal to 10"))
 		}
+		if bind.Spec.EnsurePolicy.RetryPolicy != nil {
+			allErrs = append(allErrs, validateMaxAttempts(bind.Spec.EnsurePolicy.RetryPolicy.MaxAttempts,
+				field.NewPath("spec").Child("ensurePolicy", "retryPolicy", "maxAttempts"))...)
+		}
+	}
+
+	// validate drainPolicy
+	if bind.Spec.DrainPolicy != nil {
+		availableMethods := sets.NewString(
+			string(lbcfapiv1.DrainByWeight),
+			string(lbcfapiv1.DrainByWebhook))
+		if !availableMethods.Has(string(bind.Spec.DrainPolicy.Method)) {
+			allErrs = append(allErrs,
+				field.Invalid(
+					field.NewPath("spec").
+						Child("drainPolicy", "method"),
+					bind.Spec.DrainPolicy.Method,
+					fmt.Sprintf("supported method: %s", strings.Join(availableMethods.List(), ","))))
+		}
+		if bind.Spec.DrainPolicy.PeriodInSeconds <= 0 {
+			allErrs = append(allErrs,
+				field.Invalid(
+					field.NewPath("spec").
+						Child("drainPolicy", "periodInSeconds"),
+					bind.Spec.DrainPolicy.PeriodInSeconds,
+					"periodInSeconds must be greater than 0"))
+		}
+	}
+	return allErrs
+}
+
+// validateBindBackends checks that exactly one of pods, service and static is set in spec
+func validateBindBackends(spec lbcfapiv1.BindSpec, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	var sources []string
+	if spec.Pods != nil {
+		sources = append(sources, "pods")
+	}
+	if spec.Service != nil {
+		sources = append(sources, "service")
+	}
+	if spec.Static != nil {
+		sources = append(sources, "static")
+	}
+	if len(sources) == 0 {
+		allErrs = append(allErrs,
+			field.Required(
+				path.Child("pods"),
+				"one of pods, service and static must be set"))
+		return allErrs
+	} else if len(sources) > 1 {
+		allErrs = append(allErrs,
+			field.Invalid(
+				path.Child(sources[1]),
+				strings.Join(sources, ","),
+				"only one of pods, service and static is allowed"))
+		return allErrs
+	}
+
+	switch {
+	case spec.Pods != nil:
+		allErrs = append(allErrs, validateBindPodBackend(spec.Pods, path.Child("pods"))...)
+	case spec.Service != nil:
+		allErrs = append(allErrs, validateBindServiceBackend(spec.Service, path.Child("service"))...)
+	default:
+		allErrs = append(allErrs, validateBindStaticBackend(spec.Static, path.Child("static"))...)
+	}
+	return allErrs
+}
+
+func validateBindPodBackend(pods *lbcfapiv1.PodBackend, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if pods.ByLabel == nil && len(pods.ByName) == 0 {
+		allErrs = append(allErrs,
+			field.Required(
+				path.Child("byLabel"),
+				"one of byLabel and byName must be set"))
+	}
+
+	// validate pods.byLabel
+	if pods.ByLabel != nil {
+		if len(pods.ByLabel.Selector) == 0 {
+			allErrs = append(allErrs,
+				field.Required(
+					path.Child("byLabel", "selector"),
+					"selector must be set if byLabel is used"))
+		}
+	}
+
+	// validate pods.ports
+	if len(pods.Ports) == 0 {
+		allErrs = append(allErrs,
+			field.Required(
+				path.Child("ports"),
+				"ports must be set for pods"))
+	}
+	for i, port := range pods.Ports {
+		allErrs = append(allErrs, validateBindPortSelector(port, path.Child(fmt.Sprintf("ports[%d]", i)))...)
+	}
+	allErrs = append(allErrs, validateWeight(pods.Weight, path.Child("weight"))...)
+	return allErrs
+}
+
+func validateBindServiceBackend(svc *lbcfapiv1.ServiceBackend, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if strings.TrimSpace(svc.Name) == "" {
+		allErrs = append(allErrs,
+			field.Required(
+				path.Child("name"),
+				"name of service must be set"))
+	}
+	allErrs = append(allErrs, validateBindPortSelector(svc.Port, path.Child("port"))...)
+	allErrs = append(allErrs, validateLabelSelector(svc.NodeSelector, path.Child("nodeSelector"))...)
+	allErrs = append(allErrs, validateWeight(svc.Weight, path.Child("weight"))...)
+	return allErrs
+}
+
+func validateBindStaticBackend(static []string, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(static) == 0 {
+		allErrs = append(allErrs,
+			field.Required(
+				path,
+				"static must not be empty"))
+	}
+	addrs := sets.NewString()
+	for i, addr := range static {
+		if strings.TrimSpace(addr) == "" {
+			allErrs = append(allErrs,
+				field.Required(
+					path.Child(fmt.Sprintf("[%d]", i)),
+					"static address must not be empty"))
+			continue
+		}
+		if addrs.Has(addr) {
+			allErrs = append(allErrs,
+				field.Duplicate(
+					path.Child(fmt.Sprintf("[%d]", i)),
+					addr))
+			continue
+		}
+		addrs.Insert(addr)
+	}
+	return allErrs
+}
+
+func validateBindPortSelector(port lbcfapiv1.PortSelector, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if port.Port == 0 {
+		allErrs = append(allErrs,
+			field.Invalid(
+				path.Child("port"),
+				port.Port,
+				"port must not be 0"))
+	}
+	if strings.TrimSpace(port.Protocol) == "" {
+		allErrs = append(allErrs,
+			field.Required(
+				path.Child("protocol"),
+				"protocol in port must be set, supported protocol: tcp, udp"))
+	}
+	protocol := strings.ToLower(port.Protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		allErrs = append(allErrs,
+			field.Invalid(
+				path.Child("protocol"),
+				port.Protocol,
+				fmt.Sprintf("supported protocol: tcp, udp")))
 	}
 	return allErrs
 }
@@ -285,6 +411,9 @@ func DriverUpdatedFieldsAllowed(cur *lbcfapi.LoadBalancerDriver, old *lbcfapi.Lo
 	if old.Spec.DriverType != cur.Spec.DriverType {
 		return false, "updating driverType is prohibited"
 	}
+	if util.ControllerNameOfDriver(old) != util.ControllerNameOfDriver(cur) {
+		return false, "updating controllerName is prohibited"
+	}
 	return true, ""
 }
 
@@ -321,6 +450,51 @@ func validateEnsurePolicy(raw lbcfapi.EnsurePolicyConfig, path *field.Path) fiel
 			}
 		}
 	}
+	if raw.RetryPolicy != nil {
+		allErrs = append(allErrs, validateMaxAttempts(raw.RetryPolicy.MaxAttempts, path.Child("retryPolicy", "maxAttempts"))...)
+	}
+	return allErrs
+}
+
+func validateRetryDelay(raw *lbcfapi.RetryDelayConfig, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	names := []string{"minDelay", "step", "maxDelay"}
+	for i, d := range []*lbcfapi.Duration{raw.MinDelay, raw.Step, raw.MaxDelay} {
+		if d != nil && d.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child(names[i]), d.Duration.String(), fmt.Sprintf("%s must not be negative", names[i])))
+		}
+	}
+	if raw.MinDelay != nil && raw.MaxDelay != nil && raw.MaxDelay.Duration > 0 && raw.MinDelay.Duration > raw.MaxDelay.Duration {
+		allErrs = append(allErrs, field.Invalid(path.Child("minDelay"), raw.MinDelay.Duration.String(), "minDelay must not be greater than maxDelay"))
+	}
+	return allErrs
+}
+
+func validateDrainPolicy(raw lbcfapi.DrainPolicyConfig, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if raw.Method != lbcfapi.DrainByWeight && raw.Method != lbcfapi.DrainByWebhook {
+		allErrs = append(allErrs, field.Invalid(path.Child("method"), raw.Method, fmt.Sprintf("method must be one of [%s, %s]",
+			lbcfapi.DrainByWeight, lbcfapi.DrainByWebhook)))
+	}
+	if raw.Period.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("period"), raw.Period.Duration.String(), "period must be greater than 0"))
+	}
+	return allErrs
+}
+
+func validateWeight(raw *int32, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if raw != nil && *raw < 0 {
+		allErrs = append(allErrs, field.Invalid(path, *raw, "weight must not be negative"))
+	}
+	return allErrs
+}
+
+func validateMaxAttempts(raw int32, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if raw < 0 {
+		allErrs = append(allErrs, field.Invalid(path, raw, "maxAttempts must not be negative"))
+	}
 	return allErrs
 }
 
@@ -394,26 +568,25 @@ func validateDriverURL(raw string, path *field.Path) field.ErrorList {
 
 func validateDriverWebhooks(raw []lbcfapi.WebhookConfig, path *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
-	var supported []string
-	for known := range webhooks.KnownWebhooks {
-		supported = append(supported, known)
-	}
+	supported := webhooks.KnownWebhooks.Union(webhooks.OptionalWebhooks)
 
 	hasWebhook := make(map[string]lbcfapi.WebhookConfig)
 	for _, wh := range raw {
 		hasWebhook[wh.Name] = wh
-		if _, ok := webhooks.KnownWebhooks[wh.Name]; !ok {
-			allErrs = append(allErrs, field.NotSupported(path.Child(wh.Name).Child("name"), wh.Name, supported))
+		if !supported.Has(wh.Name) {
+			allErrs = append(allErrs, field.NotSupported(path.Child(wh.Name).Child("name"), wh.Name, supported.List()))
 		}
 	}
 	if len(allErrs) > 0 {
 		return allErrs
 	}
 
-	for known := range webhooks.KnownWebhooks {
+	for _, known := range supported.List() {
 		wh, ok := hasWebhook[known]
 		if !ok {
-			allErrs = append(allErrs, field.Required(path.Child(known), fmt.Sprintf("webhook %s must be configured", known)))
+			if !webhooks.OptionalWebhooks.Has(known) {
+				allErrs = append(allErrs, field.Required(path.Child(known), fmt.Sprintf("webhook %s must be configured", known)))
+			}
 			continue
 		}
 		if wh.Timeout.Nanoseconds() > (1 * time.Minute).Nanoseconds() {
@@ -421,6 +594,15 @@ func validateDriverWebhooks(raw []lbcfapi.WebhookConfig, path *field.Path) field
 		} else if wh.Timeout.Duration == 0 {
 			allErrs = append(allErrs, field.Invalid(path.Child(known).Child("timeout"), wh.Timeout, fmt.Sprintf("webhook %s invalid, timeout of must be specified", wh.Name)))
 		}
+		allErrs = append(allErrs, validateMaxOperationDuration(wh.MaxOperationDuration, path.Child(known).Child("maxOperationDuration"))...)
+	}
+	return allErrs
+}
+
+func validateMaxOperationDuration(raw *lbcfapi.Duration, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if raw != nil && raw.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path, raw.Duration.String(), "maxOperationDuration must not be negative"))
 	}
 	return allErrs
 }
@@ -462,6 +644,7 @@ func validateServiceBackend(raw *lbcfapi.ServiceBackend, path *field.Path) field
 	allErrs := field.ErrorList{}
 	allErrs = append(allErrs, validatePortSelector(raw.Port, path.Child("port"))...)
 	allErrs = append(allErrs, validateLabelSelector(raw.NodeSelector, path.Child("nodeSelector"))...)
+	allErrs = append(allErrs, validateWeight(raw.Weight, path.Child("weight"))...)
 	return allErrs
 }
 
@@ -473,6 +656,7 @@ func validatePodBackend(raw *lbcfapi.PodBackend, path *field.Path) field.ErrorLi
 	for i, port := range raw.Ports {
 		allErrs = append(allErrs, validatePortSelector(port, path.Child(fmt.Sprintf("ports[%d]", i)))...)
 	}
+	allErrs = append(allErrs, validateWeight(raw.Weight, path.Child("weight"))...)
 	if raw.ByLabel != nil {
 		if raw.ByName != nil {
 			allErrs = append(allErrs, field.Invalid(path.Child("byName"), raw.ByName, "only one of \"byLabel, byName\" is allowed"))
@@ -22,9 +22,11 @@ import (
 
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
 
+	lbcfapiv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 func TestValidateLoadBalancerDriver(t *testing.T) {
@@ -93,6 +95,12 @@ func TestValidateLoadBalancerDriver(t *testing.T) {
 								Duration: 10 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 10 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -157,6 +165,12 @@ func TestValidateLoadBalancerDriver(t *testing.T) {
 								Duration: 10 * time.Second,
 							},
 						},
+						{
+							Name: webhooks.Healthz,
+							Timeout: lbcfapi.Duration{
+								Duration: 10 * time.Second,
+							},
+						},
 					},
 				},
 			},
@@ -290,6 +304,55 @@ func TestValidateLoadBalancerDriver(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "invalid-negative-max-operation-duration",
+			driver: &lbcfapi.LoadBalancerDriver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lbcf-driver",
+					Namespace: "kube-system",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					DriverType: string(lbcfapi.WebhookDriver),
+					URL:        "http://1.1.1.1:80",
+					MaxOperationDuration: &lbcfapi.Duration{
+						Duration: -time.Minute,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid-negative-max-attempts",
+			driver: &lbcfapi.LoadBalancerDriver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lbcf-driver",
+					Namespace: "kube-system",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					DriverType: string(lbcfapi.WebhookDriver),
+					URL:        "http://1.1.1.1:80",
+					RetryPolicy: &lbcfapi.RetryPolicyConfig{
+						MaxAttempts: -1,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid-retry-delay",
+			driver: &lbcfapi.LoadBalancerDriver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lbcf-driver",
+					Namespace: "kube-system",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					DriverType: string(lbcfapi.WebhookDriver),
+					URL:        "http://1.1.1.1:80",
+					RetryDelay: &lbcfapi.RetryDelayConfig{
+						MinDelay: &lbcfapi.Duration{Duration: time.Minute},
+						MaxDelay: &lbcfapi.Duration{Duration: time.Second},
+					},
+				},
+			},
+		},
 	}
 	for _, c := range cases {
 		err := ValidateLoadBalancerDriver(c.driver)
@@ -301,6 +364,66 @@ func TestValidateLoadBalancerDriver(t *testing.T) {
 	}
 }
 
+func TestValidateDriverOptionalWebhooks(t *testing.T) {
+	required := func() []lbcfapi.WebhookConfig {
+		var hooks []lbcfapi.WebhookConfig
+		for _, name := range webhooks.KnownWebhooks.List() {
+			hooks = append(hooks, lbcfapi.WebhookConfig{
+				Name:    name,
+				Timeout: lbcfapi.Duration{Duration: 10 * time.Second},
+			})
+		}
+		return hooks
+	}
+	cases := []struct {
+		name        string
+		optional    []lbcfapi.WebhookConfig
+		expectValid bool
+	}{
+		{
+			name:        "optional-not-configured",
+			expectValid: true,
+		},
+		{
+			name: "drain-backend-configured",
+			optional: []lbcfapi.WebhookConfig{
+				{
+					Name:                 webhooks.DrainBackend,
+					Timeout:              lbcfapi.Duration{Duration: 30 * time.Second},
+					MaxOperationDuration: &lbcfapi.Duration{Duration: time.Hour},
+				},
+			},
+			expectValid: true,
+		},
+		{
+			name: "drain-backend-without-timeout",
+			optional: []lbcfapi.WebhookConfig{
+				{
+					Name: webhooks.DrainBackend,
+				},
+			},
+		},
+		{
+			name: "drain-backend-negative-max-operation-duration",
+			optional: []lbcfapi.WebhookConfig{
+				{
+					Name:                 webhooks.DrainBackend,
+					Timeout:              lbcfapi.Duration{Duration: 30 * time.Second},
+					MaxOperationDuration: &lbcfapi.Duration{Duration: -time.Hour},
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		errList := validateDriverWebhooks(append(required(), c.optional...), field.NewPath("spec").Child("webhooks"))
+		if c.expectValid && len(errList) > 0 {
+			t.Errorf("case %s: expect valid, get %v", c.name, errList.ToAggregate())
+		} else if !c.expectValid && len(errList) == 0 {
+			t.Errorf("case %s: expect invalid", c.name)
+		}
+	}
+}
+
 func TestValidateLoadBalancer(t *testing.T) {
 	type testCase struct {
 		name        string
@@ -1043,6 +1166,49 @@ func TestValidateBackendGroup(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "valid-drain-policy",
+			group: &lbcfapi.BackendGroup{
+				Spec: lbcfapi.BackendGroupSpec{
+					LoadBalancers: []string{"test-lb"},
+					Service: &lbcfapi.ServiceBackend{
+						Name: "svc-name",
+						Port: lbcfapi.PortSelector{
+							Port:     80,
+							Protocol: tcp,
+						},
+					},
+					DrainPolicy: &lbcfapi.DrainPolicyConfig{
+						Method: lbcfapi.DrainByWebhook,
+						Period: lbcfapi.Duration{
+							Duration: 30 * time.Second,
+						},
+					},
+				},
+			},
+			expectValid: true,
+		},
+		{
+			name: "invalid-drain-policy-negative-period",
+			group: &lbcfapi.BackendGroup{
+				Spec: lbcfapi.BackendGroupSpec{
+					LoadBalancers: []string{"test-lb"},
+					Service: &lbcfapi.ServiceBackend{
+						Name: "svc-name",
+						Port: lbcfapi.PortSelector{
+							Port:     80,
+							Protocol: tcp,
+						},
+					},
+					DrainPolicy: &lbcfapi.DrainPolicyConfig{
+						Method: lbcfapi.DrainByWeight,
+						Period: lbcfapi.Duration{
+							Duration: -30 * time.Second,
+						},
+					},
+				},
+			},
+		},
 	}
 	for _, c := range cases {
 		err := ValidateBackendGroup(c.group)
@@ -1173,6 +1339,30 @@ func TestDriverUpdatedFieldsAllowed(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "invalid-change-controller-name",
+			old: &lbcfapi.LoadBalancerDriver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-driver",
+					Namespace: "default",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					DriverType: string(lbcfapi.WebhookDriver),
+					URL:        "http://1.1.1.1:80",
+				},
+			},
+			cur: &lbcfapi.LoadBalancerDriver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-driver",
+					Namespace: "default",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					DriverType:     string(lbcfapi.WebhookDriver),
+					URL:            "http://1.1.1.1:80",
+					ControllerName: "canary",
+				},
+			},
+		},
 	}
 	for _, c := range cases {
 		if get, _ := DriverUpdatedFieldsAllowed(c.cur, c.old); get != c.expectValid {
@@ -1420,3 +1610,152 @@ func TestBackendGroupUpdateFieldsAllowed(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateBindBackends(t *testing.T) {
+	newBind := func(modify func(spec *lbcfapiv1.BindSpec)) *lbcfapiv1.Bind {
+		bind := &lbcfapiv1.Bind{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "bind",
+				Namespace: "default",
+			},
+			Spec: lbcfapiv1.BindSpec{
+				LoadBalancers: []lbcfapiv1.TargetLoadBalancer{
+					{
+						Name:   "lb",
+						Driver: "driver",
+						Spec:   map[string]string{"id": "lb-1"},
+					},
+				},
+			},
+		}
+		modify(&bind.Spec)
+		return bind
+	}
+	pods := &lbcfapiv1.PodBackend{
+		Ports:   []lbcfapiv1.PortSelector{{Port: 80, Protocol: "TCP"}},
+		ByLabel: &lbcfapiv1.SelectPodByLabel{Selector: map[string]string{"app": "web"}},
+	}
+	svc := &lbcfapiv1.ServiceBackend{
+		Name: "web",
+		Port: lbcfapiv1.PortSelector{Port: 80, Protocol: "TCP"},
+	}
+	cases := []struct {
+		name        string
+		bind        *lbcfapiv1.Bind
+		expectValid bool
+	}{
+		{
+			name:        "pods",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) { spec.Pods = pods }),
+			expectValid: true,
+		},
+		{
+			name:        "service",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) { spec.Service = svc }),
+			expectValid: true,
+		},
+		{
+			name:        "static",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) { spec.Static = []string{"1.1.1.1:80"} }),
+			expectValid: true,
+		},
+		{
+			name:        "no-backend",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) {}),
+			expectValid: false,
+		},
+		{
+			name: "pods-and-service",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods
+				spec.Service = svc
+			}),
+			expectValid: false,
+		},
+		{
+			name: "service-without-name",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Service = svc.DeepCopy()
+				spec.Service.Name = ""
+			}),
+			expectValid: false,
+		},
+		{
+			name: "service-invalid-protocol",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Service = svc.DeepCopy()
+				spec.Service.Port.Protocol = "SCTP"
+			}),
+			expectValid: false,
+		},
+		{
+			name: "pods-weight",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods.DeepCopy()
+				weight := int32(0)
+				spec.Pods.Weight = &weight
+			}),
+			expectValid: true,
+		},
+		{
+			name: "pods-negative-weight",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods.DeepCopy()
+				weight := int32(-1)
+				spec.Pods.Weight = &weight
+			}),
+			expectValid: false,
+		},
+		{
+			name: "service-negative-weight",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Service = svc.DeepCopy()
+				weight := int32(-1)
+				spec.Service.Weight = &weight
+			}),
+			expectValid: false,
+		},
+		{
+			name: "pods-drain-policy",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods
+				spec.DrainPolicy = &lbcfapiv1.DrainPolicyConfig{Method: lbcfapiv1.DrainByWeight, PeriodInSeconds: 30}
+			}),
+			expectValid: true,
+		},
+		{
+			name: "drain-policy-invalid-method",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods
+				spec.DrainPolicy = &lbcfapiv1.DrainPolicyConfig{Method: "Unknown", PeriodInSeconds: 30}
+			}),
+			expectValid: false,
+		},
+		{
+			name: "drain-policy-zero-period",
+			bind: newBind(func(spec *lbcfapiv1.BindSpec) {
+				spec.Pods = pods
+				spec.DrainPolicy = &lbcfapiv1.DrainPolicyConfig{Method: lbcfapiv1.DrainByWebhook}
+			}),
+			expectValid: false,
+		},
+		{
+			name:        "static-empty",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) { spec.Static = []string{} }),
+			expectValid: false,
+		},
+		{
+			name:        "static-duplicated",
+			bind:        newBind(func(spec *lbcfapiv1.BindSpec) { spec.Static = []string{"1.1.1.1:80", "1.1.1.1:80"} }),
+			expectValid: false,
+		},
+	}
+	for _, c := range cases {
+		errList := ValidateBind(c.bind)
+		if c.expectValid && len(errList) > 0 {
+			t.Errorf("case %s: expect valid, get %v", c.name, errList.ToAggregate())
+		} else if !c.expectValid && len(errList) == 0 {
+			t.Errorf("case %s: expect invalid", c.name)
+		}
+	}
+}
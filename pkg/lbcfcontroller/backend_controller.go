@@ -27,13 +27,13 @@ import (
 	"tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
-	"tkestack.io/lb-controlling-framework/pkg/metrics"
 
 	apicore "k8s.io/api/core/v1"
 	apicorev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	corev1 "k8s.io/client-go/listers/core/v1"
@@ -78,6 +78,17 @@ type backendController struct {
 	inProgressDeleting *sync.Map
 	webhookInvoker     util.WebhookInvoker
 	dryRun             bool
+
+	// retryDelay returns the delay before a failed BackendRecord is retried, RetryAfter is not recorded if it is nil
+	retryDelay func(key string, minDelay time.Duration) time.Duration
+
+	// getFullPod returns the full Pod in cluster from kube-apiserver, it is set if cached Pods are pruned
+	getFullPod func(cluster, namespace, name string) (*apicorev1.Pod, error)
+
+	// podIndexer indexes BackendRecords by util.IndexBackendRecordByPod, all BackendRecords in namespace are checked if nil
+	podIndexer cache.Indexer
+	// memberPodListers are PodListers of member clusters that are ready, it is nil if no member cluster is configured
+	memberPodListers *util.MemberClusterSet
 }
 
 func (c *backendController) syncBackendRecord(key string) *util.SyncResult {
@@ -93,18 +104,36 @@ func (c *backendController) syncBackendRecord(key string) *util.SyncResult {
 		return util.ErrorResult(err)
 	}
 
-	if backend.DeletionTimestamp != nil {
-		if !util.HasFinalizer(backend.Finalizers, lbcfapi.FinalizerDeregisterBackend) {
-			c.removeDeletingRecord(backend)
-			return util.FinishedResult()
-		}
-		return c.deregisterBackend(backend)
+	if backend.DeletionTimestamp != nil && !util.HasFinalizer(backend.Finalizers, lbcfapi.FinalizerDeregisterBackend) {
+		c.removeDeletingRecord(backend)
+		return util.FinishedResult()
+	}
+	if util.BackendRetryExhausted(backend) {
+		klog.Infof("BackendRecord %s is not retried because retry limit is exceeded", key)
+		return util.FinishedResult()
 	}
 
-	if backend.Status.BackendAddr == "" {
-		return c.generateBackendAddr(backend)
+	var result *util.SyncResult
+	if backend.DeletionTimestamp != nil {
+		result = c.deregisterBackend(backend)
+	} else if backend.Status.BackendAddr == "" {
+		result = c.generateBackendAddr(backend)
+	} else {
+		result = c.ensureBackend(backend)
+	}
+	if c.dryRun {
+		return result
+	}
+	if result.IsWebhookFailed() {
+		return c.recordFailure(backend, result)
 	}
-	return c.ensureBackend(backend)
+	retryTriggered := backend.Annotations[lbcfapi.AnnotationRetryAt] != backend.Status.LastRetryAt
+	if !result.IsFailed() && (retryTriggered || (!result.IsRunning() && backend.Status.FailedAttempts > 0)) {
+		if err := c.resetFailure(backend); err != nil {
+			return util.ErrorResult(err)
+		}
+	}
+	return result
 }
 
 func (c *backendController) generateBackendAddr(backend *lbcfapi.BackendRecord) *util.SyncResult {
@@ -139,22 +168,29 @@ func (c *backendController) generateBackendAddr(backend *lbcfapi.BackendRecord)
 	case webhooks.StatusSucc:
 		cpy := backend.DeepCopy()
 		cpy.Status.BackendAddr = rsp.BackendAddr
-		start := time.Now()
-		_, err := c.client.LbcfV1beta1().BackendRecords(cpy.Namespace).UpdateStatus(cpy)
-		metrics.K8SOpLatencyObserve("BackendRecord", metrics.OpUpdateStatus, time.Since(start))
-		if err != nil {
+		cpy.Status.RunningOperation = nil
+		if util.GetBackendRecordCondition(&cpy.Status, lbcfapi.BackendAddrGenerated) != nil {
+			// a previous timeout is recorded in condition AddrGenerated
+			util.AddBackendCondition(&cpy.Status, lbcfapi.BackendRecordCondition{
+				Type:               lbcfapi.BackendAddrGenerated,
+				Status:             lbcfapi.ConditionTrue,
+				LastTransitionTime: v1.Now(),
+			})
+		}
+		if err := c.updateStatus(backend, cpy); err != nil {
 			c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedGenerateAddr", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
 		c.eventRecorder.Eventf(backend, apicore.EventTypeNormal, "SuccGenerateAddr", "addr: %s", rsp.BackendAddr)
 		return util.FinishedResult()
 	case webhooks.StatusFail:
+		if err := util.ClearRunningOperation(c.runningOperationObject(backend, "")); err != nil {
+			return util.ErrorResult(err)
+		}
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedGenerateAddr", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(backend, apicore.EventTypeNormal, "RunningGenerateAddr", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(backend, lbcfapi.BackendAddrGenerated), driver, webhooks.GenerateBackendAddr, "RunningGenerateAddr", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "InvalidGenerateAddr", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
@@ -183,6 +219,7 @@ func (c *backendController) ensureBackend(backend *lbcfapi.BackendRecord) *util.
 		BackendAddr:  backend.Status.BackendAddr,
 		Parameters:   backend.Spec.Parameters,
 		InjectedInfo: backend.Status.InjectedInfo,
+		Weight:       backend.Spec.Weight,
 		DryRun:       c.dryRun,
 	}
 	if c.dryRun {
@@ -203,7 +240,9 @@ func (c *backendController) ensureBackend(backend *lbcfapi.BackendRecord) *util.
 
 	switch rsp.Status {
 	case webhooks.StatusSucc:
+		old := backend
 		backend = backend.DeepCopy()
+		backend.Status.RunningOperation = nil
 		if len(rsp.InjectedInfo) > 0 {
 			backend.Status.InjectedInfo = rsp.InjectedInfo
 		}
@@ -213,10 +252,7 @@ func (c *backendController) ensureBackend(backend *lbcfapi.BackendRecord) *util.
 			LastTransitionTime: v1.Now(),
 			Message:            rsp.Msg,
 		})
-		start := time.Now()
-		_, err := c.client.LbcfV1beta1().BackendRecords(backend.Namespace).UpdateStatus(backend)
-		metrics.K8SOpLatencyObserve("BackendRecord", metrics.OpUpdateStatus, time.Since(start))
-		if err != nil {
+		if err := c.updateStatus(old, backend); err != nil {
 			c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedEnsureBackend", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
@@ -226,7 +262,9 @@ func (c *backendController) ensureBackend(backend *lbcfapi.BackendRecord) *util.
 		}
 		return util.FinishedResult()
 	case webhooks.StatusFail:
+		old := backend
 		backend = backend.DeepCopy()
+		backend.Status.RunningOperation = nil
 		util.AddBackendCondition(&backend.Status, lbcfapi.BackendRecordCondition{
 			Type:               lbcfapi.BackendRegistered,
 			Status:             lbcfapi.ConditionFalse,
@@ -234,19 +272,14 @@ func (c *backendController) ensureBackend(backend *lbcfapi.BackendRecord) *util.
 			Reason:             lbcfapi.ReasonOperationFailed.String(),
 			Message:            rsp.Msg,
 		})
-		start := time.Now()
-		_, err := c.client.LbcfV1beta1().BackendRecords(backend.Namespace).UpdateStatus(backend)
-		metrics.K8SOpLatencyObserve("BackendRecord", metrics.OpUpdateStatus, time.Since(start))
-		if err != nil {
+		if err := c.updateStatus(old, backend); err != nil {
 			c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedEnsureBackend", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedEnsureBackend", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(backend, apicore.EventTypeNormal, "RunningEnsureBackend", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(backend, lbcfapi.BackendRegistered), driver, webhooks.EnsureBackend, "RunningEnsureBackend", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "InvalidEnsureBackend", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
@@ -267,6 +300,11 @@ func (c *backendController) deregisterBackend(backend *lbcfapi.BackendRecord) *u
 	if err != nil {
 		return util.ErrorResult(fmt.Errorf("retrieve driver %q for BackendRecord %s failed: %v", backend.Spec.LBDriver, backend.Name, err))
 	}
+	if backend.Spec.DrainPolicy != nil && util.BackendRegistered(backend) {
+		if result := c.drainBackend(backend, driver); result != nil {
+			return result
+		}
+	}
 	req := &webhooks.BackendOperationRequest{
 		RequestForRetryHooks: webhooks.RequestForRetryHooks{
 			RecordID: fmt.Sprintf("deregisterBackend(%s)", backend.UID),
@@ -298,30 +336,212 @@ func (c *backendController) deregisterBackend(backend *lbcfapi.BackendRecord) *u
 	case webhooks.StatusSucc:
 		return c.removeFinalizer(backend)
 	case webhooks.StatusFail:
+		if err := util.ClearRunningOperation(c.runningOperationObject(backend, "")); err != nil {
+			return util.ErrorResult(err)
+		}
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedDeregister", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(backend, apicore.EventTypeNormal, "RunningDeregister", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(backend, lbcfapi.BackendDeregistered), driver, webhooks.DeregBackend, "RunningDeregister", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "InvalidDeregister", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
 	}
 }
 
+// drainBackend stops new connections to backend by calling ensureBackend with weight 0 or webhook drainBackend,
+// and waits for the drain period. A nil result is returned once the drain period is over.
+func (c *backendController) drainBackend(backend *lbcfapi.BackendRecord, driver *lbcfapi.LoadBalancerDriver) *util.SyncResult {
+	policy := backend.Spec.DrainPolicy
+	if start := backend.Status.DrainStartTime; start != nil {
+		if remaining := policy.Period.Duration - time.Since(start.Time); remaining > 0 {
+			return util.AsyncResult(remaining)
+		}
+		return nil
+	}
+
+	webhookName := webhooks.EnsureBackend
+	if policy.Method == lbcfapi.DrainByWebhook {
+		webhookName = webhooks.DrainBackend
+	}
+	req := &webhooks.BackendOperationRequest{
+		RequestForRetryHooks: webhooks.RequestForRetryHooks{
+			RecordID: fmt.Sprintf("drainBackend(%s)", backend.UID),
+			RetryID:  string(uuid.NewUUID()),
+		},
+		LBInfo:       backend.Spec.LBInfo,
+		BackendAddr:  backend.Status.BackendAddr,
+		Parameters:   backend.Spec.Parameters,
+		InjectedInfo: backend.Status.InjectedInfo,
+		DryRun:       c.dryRun,
+	}
+	// backends are deregistered without waiting in dry-run mode
+	if c.dryRun {
+		klog.Infof("[dry-run] webhook: %s, BackendRecord: %s/%s", webhookName, backend.Namespace, backend.Name)
+		return nil
+	}
+	var rsp *webhooks.BackendOperationResponse
+	var err error
+	if webhookName == webhooks.DrainBackend {
+		rsp, err = c.webhookInvoker.CallDrainBackend(driver, req)
+	} else {
+		weight := int32(0)
+		req.Weight = &weight
+		rsp, err = c.webhookInvoker.CallEnsureBackend(driver, req)
+	}
+	if err != nil {
+		return util.ErrorResult(err)
+	}
+
+	switch rsp.Status {
+	case webhooks.StatusSucc:
+		old := backend
+		backend = backend.DeepCopy()
+		now := v1.Now()
+		backend.Status.RunningOperation = nil
+		backend.Status.DrainStartTime = &now
+		if len(rsp.InjectedInfo) > 0 {
+			backend.Status.InjectedInfo = rsp.InjectedInfo
+		}
+		util.AddBackendCondition(&backend.Status, lbcfapi.BackendRecordCondition{
+			Type:               lbcfapi.BackendDrained,
+			Status:             lbcfapi.ConditionTrue,
+			LastTransitionTime: now,
+			Message:            fmt.Sprintf("deregister after %s", policy.Period.Duration),
+		})
+		if err := c.updateStatus(old, backend); err != nil {
+			c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedDrainBackend", "update status failed: %v", err)
+			return util.ErrorResult(err)
+		}
+		c.eventRecorder.Eventf(backend, apicore.EventTypeNormal, "SuccDrainBackend", "Successfully drained backend, deregister after %s", policy.Period.Duration)
+		return util.AsyncResult(policy.Period.Duration)
+	case webhooks.StatusFail:
+		old := backend
+		backend = backend.DeepCopy()
+		backend.Status.RunningOperation = nil
+		util.AddBackendCondition(&backend.Status, lbcfapi.BackendRecordCondition{
+			Type:               lbcfapi.BackendDrained,
+			Status:             lbcfapi.ConditionFalse,
+			LastTransitionTime: v1.Now(),
+			Reason:             lbcfapi.ReasonOperationFailed.String(),
+			Message:            rsp.Msg,
+		})
+		if err := c.updateStatus(old, backend); err != nil {
+			c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedDrainBackend", "update status failed: %v", err)
+			return util.ErrorResult(err)
+		}
+		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "FailedDrainBackend", "msg: %s", rsp.Msg)
+		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
+	case webhooks.StatusRunning:
+		return util.HandleRunningOperation(c.runningOperationObject(backend, lbcfapi.BackendDrained), driver, webhookName, "RunningDrainBackend", rsp.ResponseForFailRetryHooks, c.eventRecorder)
+	default:
+		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "InvalidDrainBackend", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
+		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
+	}
+}
+
 func (c *backendController) removeFinalizer(backend *lbcfapi.BackendRecord) *util.SyncResult {
 	c.removeDeletingRecord(backend)
 
-	backend = backend.DeepCopy()
-	backend.Finalizers = util.RemoveFinalizer(backend.Finalizers, lbcfapi.FinalizerDeregisterBackend)
-	_, err := c.client.LbcfV1beta1().BackendRecords(backend.Namespace).Update(backend)
+	err := util.PatchRemoveFinalizer("BackendRecord", backend.Finalizers, lbcfapi.FinalizerDeregisterBackend, func(pt types.PatchType, data []byte) error {
+		_, err := c.client.LbcfV1beta1().BackendRecords(backend.Namespace).Patch(backend.Name, pt, data)
+		return err
+	})
 	if err != nil {
 		return util.ErrorResult(err)
 	}
 	return util.FinishedResult()
 }
 
+// runningOperationObject returns backend for util.HandleRunningOperation and util.ClearRunningOperation,
+// condType is set to False with reason Timeout if the operation times out
+func (c *backendController) runningOperationObject(backend *lbcfapi.BackendRecord, condType lbcfapi.BackendRecordConditionType) *util.RunningOperationObject {
+	cpy := backend.DeepCopy()
+	return &util.RunningOperationObject{
+		Object:           backend,
+		RunningOperation: backend.Status.RunningOperation,
+		SetRunningOperation: func(op *lbcfapi.RunningOperation) {
+			cpy.Status.RunningOperation = op
+		},
+		SetTimeout: func(msg string) {
+			util.AddBackendCondition(&cpy.Status, lbcfapi.BackendRecordCondition{
+				Type:               condType,
+				Status:             lbcfapi.ConditionFalse,
+				LastTransitionTime: v1.Now(),
+				Reason:             lbcfapi.ReasonOperationTimeout.String(),
+				Message:            msg,
+			})
+		},
+		Update: func() error {
+			return c.updateStatus(backend, cpy)
+		},
+	}
+}
+
+// recordFailure increases the failed attempts of backend. The BackendRecord is marked as Failed and will not be retried
+// once the failed attempts reach the maxAttempts configured in retryPolicy
+func (c *backendController) recordFailure(backend *lbcfapi.BackendRecord, result *util.SyncResult) *util.SyncResult {
+	var driver *lbcfapi.LoadBalancerDriver
+	if d, err := c.driverLister.LoadBalancerDrivers(util.NamespaceOfSharedObj(backend.Spec.LBDriver, backend.Namespace)).Get(backend.Spec.LBDriver); err == nil {
+		driver = d
+	}
+	maxAttempts := util.GetMaxAttempts(backend.Spec.EnsurePolicy, driver)
+	var retryDelay time.Duration
+	if c.retryDelay != nil {
+		key, _ := cache.MetaNamespaceKeyFunc(backend)
+		retryDelay = c.retryDelay(key, result.GetNextRun())
+	}
+	exceeded, err := util.RecordFailure(c.retryStatusObject(backend), maxAttempts, retryDelay, result.GetFailReason())
+	if err != nil {
+		return util.ErrorResult(err)
+	}
+	if exceeded {
+		c.eventRecorder.Eventf(backend, apicore.EventTypeWarning, "RetryLimitExceeded", "failed %d times, last error: %s", maxAttempts, result.GetFailReason())
+		return util.FinishedResult()
+	}
+	return result
+}
+
+// resetFailure clears the failed attempts recorded in status of backend and records the processed retry-at annotation
+func (c *backendController) resetFailure(backend *lbcfapi.BackendRecord) error {
+	return util.ResetFailure(c.retryStatusObject(backend))
+}
+
+// retryStatusObject returns a function that reads the latest backend for counting failed attempts in its status
+func (c *backendController) retryStatusObject(backend *lbcfapi.BackendRecord) func() (*util.RetryStatusObject, error) {
+	return func() (*util.RetryStatusObject, error) {
+		fetched, err := c.client.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		latest := fetched.DeepCopy()
+		return &util.RetryStatusObject{
+			Object:      latest,
+			RetryStatus: &latest.Status.RetryStatus,
+			IsFailed: func() bool {
+				cond := util.GetBackendRecordCondition(&latest.Status, lbcfapi.BackendFailed)
+				return cond != nil && cond.Status == lbcfapi.ConditionTrue
+			},
+			SetFailed: func(failed bool, msg string) {
+				cond := lbcfapi.BackendRecordCondition{
+					Type:               lbcfapi.BackendFailed,
+					Status:             lbcfapi.ConditionFalse,
+					LastTransitionTime: v1.Now(),
+				}
+				if failed {
+					cond.Status = lbcfapi.ConditionTrue
+					cond.Reason = lbcfapi.ReasonRetryLimitExceeded.String()
+					cond.Message = msg
+				}
+				util.AddBackendCondition(&latest.Status, cond)
+			},
+			Update: func() error {
+				return c.updateStatus(fetched, latest)
+			},
+		}, nil
+	}
+}
+
 func (c *backendController) storeDeletingBackend(backend *lbcfapi.BackendRecord) {
 	key := fmt.Sprintf("%s|%s", backend.Spec.LBInfo, backend.Status.BackendAddr)
 	value := util.NamespacedNameKeyFunc(backend.Namespace, backend.Name)
@@ -360,12 +580,22 @@ func (c *backendController) sameAddrDeleting(backend *lbcfapi.BackendRecord) (st
 }
 
 func (c *backendController) generatePodAddr(backend *lbcfapi.BackendRecord, driver *lbcfapi.LoadBalancerDriver) (*webhooks.GenerateBackendAddrResponse, error) {
-	pod, err := c.podLister.Pods(backend.Namespace).Get(backend.Spec.PodBackendInfo.Name)
+	cluster := backend.Spec.PodBackendInfo.Cluster
+	podLister, err := util.ClusterPodLister(c.podLister, c.memberPodListers, cluster)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := podLister.Pods(backend.Namespace).Get(backend.Spec.PodBackendInfo.Name)
 	if err != nil {
 		return nil, err
 	} else if !util.PodAvailable(pod) {
 		return nil, fmt.Errorf("pod %s is not ready, try later", pod.Name)
 	}
+	if c.getFullPod != nil {
+		if pod, err = c.getFullPod(cluster, pod.Namespace, pod.Name); err != nil {
+			return nil, fmt.Errorf("get pod %s failed: %v", backend.Spec.PodBackendInfo.Name, err)
+		}
+	}
 	req := &webhooks.GenerateBackendAddrRequest{
 		RequestForRetryHooks: webhooks.RequestForRetryHooks{
 			RecordID: fmt.Sprintf("generateBackendAddr(%s)", backend.UID),
@@ -374,8 +604,9 @@ func (c *backendController) generatePodAddr(backend *lbcfapi.BackendRecord, driv
 		LBInfo:       backend.Spec.LBInfo,
 		LBAttributes: backend.Spec.LBAttributes,
 		PodBackend: &webhooks.PodBackendInGenerateAddrRequest{
-			Pod:  *pod,
-			Port: backend.Spec.PodBackendInfo.Port,
+			Pod:     *pod,
+			Port:    backend.Spec.PodBackendInfo.Port,
+			Cluster: cluster,
 		},
 		DryRun: c.dryRun,
 	}
@@ -431,6 +662,20 @@ func (c *backendController) generateStaticAddr(backend *lbcfapi.BackendRecord) (
 }
 
 func (c *backendController) listRelatedBackendRecordsForPod(pod *apicorev1.Pod) sets.String {
+	if c.podIndexer != nil {
+		objs, err := c.podIndexer.ByIndex(util.IndexBackendRecordByPod, util.PodNameIndexValue(pod))
+		if err != nil {
+			klog.Errorf("list related BackendRecord for pod %s/%s by index failed: %v", pod.Namespace, pod.Name, err)
+			return nil
+		}
+		ret := sets.NewString()
+		for _, obj := range objs {
+			if br, ok := obj.(*lbcfapi.BackendRecord); ok {
+				ret.Insert(util.NamespacedNameKeyFunc(br.Namespace, br.Name))
+			}
+		}
+		return ret
+	}
 	brList, err := c.brLister.BackendRecords(pod.Namespace).List(labels.Everything())
 	if err != nil {
 		klog.Errorf("list related BackendRecord for pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
@@ -451,3 +696,18 @@ func (c *backendController) listRelatedBackendRecordsForPod(pod *apicorev1.Pod)
 	}
 	return ret
 }
+
+// updateStatus sends the changes from old.Status to cur.Status as a merge patch guarded by the resourceVersion of old,
+// a conflict is retried against the latest BackendRecord only if its status is not modified concurrently
+func (c *backendController) updateStatus(old, cur *lbcfapi.BackendRecord) error {
+	return util.PatchStatus("BackendRecord", old.ResourceVersion, old.Status, cur.Status, func(pt types.PatchType, data []byte) error {
+		_, err := c.client.LbcfV1beta1().BackendRecords(cur.Namespace).Patch(cur.Name, pt, data, "status")
+		return err
+	}, func() (string, interface{}, error) {
+		latest, err := c.client.LbcfV1beta1().BackendRecords(cur.Namespace).Get(cur.Name, v1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		return latest.ResourceVersion, latest.Status, nil
+	})
+}
@@ -17,16 +17,21 @@
 package lbcfcontroller
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned/fake"
+	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
 
 	v12 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -432,11 +437,64 @@ func TestBackendEnsureRunning(t *testing.T) {
 		t.Fatalf("expect reason RunningEnsureBackend, get %s", reason)
 	}
 	get, _ := fakeClient.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
-	if get := util.GetBackendRecordCondition(&get.Status, lbcfapi.BackendRegistered); !reflect.DeepEqual(*get, ensureCondition) {
+	if get := util.GetBackendRecordCondition(&get.Status, lbcfapi.BackendRegistered); !apiequality.Semantic.DeepEqual(*get, ensureCondition) {
 		t.Errorf("expect condition not changed, get %v", get)
 	}
 }
 
+func TestBackendEnsureRunningTimeout(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
+	backends := util.ConstructPodBackendRecord(lb, bg, newFakePod("", "pod-0", nil, true, false))
+	backend := backends[0]
+	backend.Status.BackendAddr = "fake.addr.com:1234"
+	backend.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.EnsureBackend,
+		StartTime: v1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	driver := newFakeDriver("", "driver")
+	driver.Spec.Webhooks = []lbcfapi.WebhookConfig{
+		{
+			Name:                 webhooks.EnsureBackend,
+			MaxOperationDuration: &lbcfapi.Duration{Duration: time.Minute},
+		},
+	}
+	fakeClient := fake.NewSimpleClientset(backend)
+	store := make(map[string]string)
+	ctrl := newBackendController(
+		fakeClient,
+		&fakeBackendLister{
+			get: backend,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakePodLister{
+			get: newFakePod("", "pod=0", nil, true, false),
+		},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{store: store},
+		&fakeRunningInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	resp := ctrl.syncBackendRecord(key)
+	if !resp.IsFailed() {
+		t.Fatalf("expect failed result, get %#v", resp)
+	}
+	if reason := store[backend.Name]; reason != "OperationTimeout" {
+		t.Fatalf("expect reason OperationTimeout, get %s", reason)
+	}
+	get, _ := fakeClient.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
+	if get.Status.RunningOperation != nil {
+		t.Errorf("expect nil runningOperation, get %+v", get.Status.RunningOperation)
+	}
+	cond := util.GetBackendRecordCondition(&get.Status, lbcfapi.BackendRegistered)
+	if cond == nil || cond.Status != lbcfapi.ConditionFalse || cond.Reason != lbcfapi.ReasonOperationTimeout.String() {
+		t.Errorf("expect condition Registered=False with reason Timeout, get %+v", cond)
+	}
+}
+
 func TestBackendEnsureRerun(t *testing.T) {
 	lb := newFakeLoadBalancer("", "lb", nil, nil)
 	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
@@ -899,3 +957,350 @@ func TestBackendSameAddrOperation(t *testing.T) {
 	}
 	delete(store, newBackend.Name)
 }
+
+func TestBackendListRelatedBackendRecordsForPodByIndex(t *testing.T) {
+	pod := newFakePod("ns", "pod-1", nil, true, false)
+	scanCtrl, indexCtrl := newPodIndexTestBackendControllers(2, 3)
+	expect := scanCtrl.listRelatedBackendRecordsForPod(pod)
+	if !expect.Equal(sets.NewString("ns/br-1-0", "ns/br-1-1", "ns/br-1-2")) {
+		t.Fatalf("unexpected BackendRecords listed without index: %v", expect.List())
+	}
+	if get := indexCtrl.listRelatedBackendRecordsForPod(pod); !get.Equal(expect) {
+		t.Errorf("expect %v, get %v", expect.List(), get.List())
+	}
+}
+
+func BenchmarkListRelatedBackendRecordsForPod(b *testing.B) {
+	// 5000 pods in one namespace, each has 2 BackendRecords
+	pod := newFakePod("ns", "pod-100", nil, true, false)
+	scanCtrl, indexCtrl := newPodIndexTestBackendControllers(5000, 2)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanCtrl.listRelatedBackendRecordsForPod(pod)
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexCtrl.listRelatedBackendRecordsForPod(pod)
+		}
+	})
+}
+
+// newPodIndexTestBackendControllers returns a controller listing all BackendRecords and a controller using podIndexer.
+// BackendRecords named br-<pod index>-<record index> are created for pods in namespace "ns" named pod-<pod index>
+func newPodIndexTestBackendControllers(pods int, recordsPerPod int) (*backendController, *backendController) {
+	var records []interface{}
+	for i := 0; i < pods; i++ {
+		for j := 0; j < recordsPerPod; j++ {
+			br := newFakeBackendRecord("ns", fmt.Sprintf("br-%d-%d", i, j))
+			br.Spec.PodBackendInfo = &lbcfapi.PodBackendRecord{Name: fmt.Sprintf("pod-%d", i)}
+			records = append(records, br)
+		}
+	}
+	indexer := newPodIndexTestIndexer(util.IndexBackendRecordByPod, util.BackendRecordPodIndexFunc, records...)
+	newCtrl := func() *backendController {
+		return newBackendController(fake.NewSimpleClientset(), lbcflister.NewBackendRecordLister(indexer),
+			&fakeDriverLister{}, &fakePodLister{}, &fakeSvcListerWithStore{}, &fakeNodeListerWithStore{},
+			&fakeEventRecorder{}, nil, false)
+	}
+	indexCtrl := newCtrl()
+	indexCtrl.podIndexer = indexer
+	return newCtrl(), indexCtrl
+}
+
+func TestBackendGenerateAddrWithFullPod(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
+	fullPod := newFakePod("", "pod-0", nil, true, false)
+	fullPod.Spec.Containers = []v12.Container{{Name: "c", Image: "image"}}
+	backend := util.ConstructPodBackendRecord(lb, bg, fullPod)[0]
+	fakeClient := fake.NewSimpleClientset(backend)
+	invoker := &fakeRecordGenerateAddrInvoker{}
+	ctrl := newBackendController(
+		fakeClient,
+		&fakeBackendLister{
+			get: backend,
+		},
+		&fakeDriverLister{
+			get: newFakeDriver("", "driver"),
+		},
+		&fakePodLister{
+			get: util.PrunePod(fullPod),
+		},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{store: make(map[string]string)},
+		invoker,
+		false)
+	ctrl.getFullPod = func(cluster, namespace, name string) (*v12.Pod, error) {
+		return fullPod, nil
+	}
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	if resp := ctrl.syncBackendRecord(key); !resp.IsFinished() {
+		t.Fatalf("expect succ result, get %#v, err: %v", resp, resp.GetFailReason())
+	}
+	if invoker.req == nil {
+		t.Fatalf("expect generateBackendAddr called")
+	} else if !reflect.DeepEqual(invoker.req.PodBackend.Pod, *fullPod) {
+		t.Errorf("expect full pod in request, get %+v", invoker.req.PodBackend.Pod)
+	}
+
+	ctrl.getFullPod = func(cluster, namespace, name string) (*v12.Pod, error) {
+		return nil, fmt.Errorf("fake error")
+	}
+	if resp := ctrl.syncBackendRecord(key); !resp.IsFailed() {
+		t.Errorf("expect error result, get %#v", resp)
+	}
+}
+
+type fakeRecordGenerateAddrInvoker struct {
+	fakeSuccInvoker
+	req *webhooks.GenerateBackendAddrRequest
+}
+
+func (c *fakeRecordGenerateAddrInvoker) CallGenerateBackendAddr(driver *lbcfapi.LoadBalancerDriver, req *webhooks.GenerateBackendAddrRequest) (*webhooks.GenerateBackendAddrResponse, error) {
+	c.req = req
+	return c.fakeSuccInvoker.CallGenerateBackendAddr(driver, req)
+}
+
+func TestBackendEnsureWithWeight(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
+	weight := int32(10)
+	bg.Spec.Pods.Weight = &weight
+	pod := newFakePod("", "pod-0", nil, true, false)
+	pod.Annotations = map[string]string{lbcfapi.AnnotationBackendWeight: "20"}
+	backend := util.ConstructPodBackendRecord(lb, bg, pod)[0]
+	backend.Status.BackendAddr = "fake.addr.com:1234"
+	invoker := &fakeRecordEnsureBackendInvoker{}
+	ctrl := newBackendController(
+		fake.NewSimpleClientset(backend),
+		&fakeBackendLister{
+			get: backend,
+		},
+		&fakeDriverLister{
+			get: newFakeDriver("", "driver"),
+		},
+		&fakePodLister{
+			get: pod,
+		},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{store: make(map[string]string)},
+		invoker,
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	if resp := ctrl.syncBackendRecord(key); !resp.IsFinished() {
+		t.Fatalf("expect succ result, get %#v, err: %v", resp, resp.GetFailReason())
+	}
+	if invoker.req == nil {
+		t.Fatalf("expect ensureBackend called")
+	} else if invoker.req.Weight == nil || *invoker.req.Weight != 20 {
+		t.Errorf("expect weight 20 from annotation, get %v", invoker.req.Weight)
+	}
+}
+
+type fakeRecordEnsureBackendInvoker struct {
+	fakeSuccInvoker
+	req *webhooks.BackendOperationRequest
+}
+
+func (c *fakeRecordEnsureBackendInvoker) CallEnsureBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	c.req = req
+	return c.fakeSuccInvoker.CallEnsureBackend(driver, req)
+}
+
+func newFakeDrainingBackend(policy *lbcfapi.DrainPolicyConfig) *lbcfapi.BackendRecord {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
+	bg.Spec.DrainPolicy = policy
+	ts := v1.Time{Time: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)}
+	backend := util.ConstructPodBackendRecord(lb, bg, newFakePod("", "pod-0", nil, true, false))[0]
+	backend.DeletionTimestamp = &ts
+	backend.Finalizers = []string{lbcfapi.FinalizerDeregisterBackend}
+	backend.Status.BackendAddr = "fake.addr.com:1234"
+	backend.Status.Conditions = []lbcfapi.BackendRecordCondition{
+		{
+			Type:               lbcfapi.BackendRegistered,
+			Status:             lbcfapi.ConditionTrue,
+			LastTransitionTime: ts,
+		},
+	}
+	return backend
+}
+
+func newFakeDrainBackendController(backend *lbcfapi.BackendRecord, invoker util.WebhookInvoker) (*backendController, *fake.Clientset) {
+	fakeClient := fake.NewSimpleClientset(backend)
+	ctrl := newBackendController(
+		fakeClient,
+		&fakeBackendLister{
+			get: backend,
+		},
+		&fakeDriverLister{
+			get: newFakeDriver("", "driver"),
+		},
+		&fakePodLister{
+			get: newFakePod("", "pod-0", nil, true, false),
+		},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{store: make(map[string]string)},
+		invoker,
+		false)
+	return ctrl, fakeClient
+}
+
+func TestBackendDrainByWeight(t *testing.T) {
+	backend := newFakeDrainingBackend(&lbcfapi.DrainPolicyConfig{
+		Method: lbcfapi.DrainByWeight,
+		Period: lbcfapi.Duration{Duration: 30 * time.Second},
+	})
+	invoker := &fakeRecordDrainInvoker{}
+	ctrl, fakeClient := newFakeDrainBackendController(backend, invoker)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	resp := ctrl.syncBackendRecord(key)
+	if !resp.IsRunning() {
+		t.Fatalf("expect running result, get %s, err: %v", resp, resp.GetFailReason())
+	} else if resp.GetNextRun() != 30*time.Second {
+		t.Errorf("expect next run after 30s, get %v", resp.GetNextRun())
+	}
+	if invoker.ensureReq == nil {
+		t.Fatalf("expect ensureBackend called")
+	} else if invoker.ensureReq.Weight == nil || *invoker.ensureReq.Weight != 0 {
+		t.Errorf("expect weight 0, get %v", invoker.ensureReq.Weight)
+	}
+	if invoker.drainReq != nil || invoker.deregReq != nil {
+		t.Errorf("expect only ensureBackend called")
+	}
+	get, _ := fakeClient.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
+	if get.Status.DrainStartTime == nil {
+		t.Errorf("expect drainStartTime set")
+	}
+	if cond := util.GetBackendRecordCondition(&get.Status, lbcfapi.BackendDrained); cond == nil || cond.Status != lbcfapi.ConditionTrue {
+		t.Errorf("expect Drained condition True, get %#v", cond)
+	}
+	if len(get.Finalizers) == 0 {
+		t.Errorf("expect finalizer kept while draining")
+	}
+}
+
+func TestBackendDrainByWebhook(t *testing.T) {
+	backend := newFakeDrainingBackend(&lbcfapi.DrainPolicyConfig{
+		Method: lbcfapi.DrainByWebhook,
+		Period: lbcfapi.Duration{Duration: 30 * time.Second},
+	})
+	invoker := &fakeRecordDrainInvoker{}
+	ctrl, _ := newFakeDrainBackendController(backend, invoker)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	if resp := ctrl.syncBackendRecord(key); !resp.IsRunning() {
+		t.Fatalf("expect running result, get %s, err: %v", resp, resp.GetFailReason())
+	}
+	if invoker.drainReq == nil {
+		t.Fatalf("expect drainBackend called")
+	}
+	if invoker.ensureReq != nil || invoker.deregReq != nil {
+		t.Errorf("expect only drainBackend called")
+	}
+}
+
+func TestBackendDrainPeriodOver(t *testing.T) {
+	backend := newFakeDrainingBackend(&lbcfapi.DrainPolicyConfig{
+		Method: lbcfapi.DrainByWeight,
+		Period: lbcfapi.Duration{Duration: 30 * time.Second},
+	})
+	start := v1.NewTime(time.Now().Add(-time.Minute))
+	backend.Status.DrainStartTime = &start
+	invoker := &fakeRecordDrainInvoker{}
+	ctrl, fakeClient := newFakeDrainBackendController(backend, invoker)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	if resp := ctrl.syncBackendRecord(key); !resp.IsFinished() {
+		t.Fatalf("expect succ result, get %s, err: %v", resp, resp.GetFailReason())
+	}
+	if invoker.deregReq == nil {
+		t.Fatalf("expect deregisterBackend called")
+	}
+	if invoker.ensureReq != nil || invoker.drainReq != nil {
+		t.Errorf("expect backend not drained again")
+	}
+	get, _ := fakeClient.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
+	if len(get.Finalizers) != 0 {
+		t.Fatalf("expect empty finalizer, get %#v", get.Finalizers)
+	}
+}
+
+type fakeRecordDrainInvoker struct {
+	fakeSuccInvoker
+	ensureReq *webhooks.BackendOperationRequest
+	drainReq  *webhooks.BackendOperationRequest
+	deregReq  *webhooks.BackendOperationRequest
+}
+
+func (c *fakeRecordDrainInvoker) CallEnsureBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	c.ensureReq = req
+	return c.fakeSuccInvoker.CallEnsureBackend(driver, req)
+}
+
+func (c *fakeRecordDrainInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	c.drainReq = req
+	return c.fakeSuccInvoker.CallDrainBackend(driver, req)
+}
+
+func (c *fakeRecordDrainInvoker) CallDeregisterBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	c.deregReq = req
+	return c.fakeSuccInvoker.CallDeregisterBackend(driver, req)
+}
+
+func TestBackendDeregisterRunningTimeout(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	bg := newFakeBackendGroupOfPods("", "group", lb.Name, 80, "tcp", nil, nil, []string{"pod-0"})
+	backends := util.ConstructPodBackendRecord(lb, bg, newFakePod("", "pod-0", nil, true, false))
+	backend := backends[0]
+	timestamp := v1.Now()
+	backend.DeletionTimestamp = &timestamp
+	backend.Finalizers = []string{lbcfapi.FinalizerDeregisterBackend}
+	backend.Status.BackendAddr = "fake.addr.com:1234"
+	backend.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.DeregBackend,
+		StartTime: v1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	driver := newFakeDriver("", "driver")
+	driver.Spec.Webhooks = []lbcfapi.WebhookConfig{
+		{
+			Name:                 webhooks.DeregBackend,
+			MaxOperationDuration: &lbcfapi.Duration{Duration: time.Minute},
+		},
+	}
+	fakeClient := fake.NewSimpleClientset(backend)
+	store := make(map[string]string)
+	ctrl := newBackendController(
+		fakeClient,
+		&fakeBackendLister{
+			get: backend,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakePodLister{},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{store: store},
+		&fakeRunningInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(backend)
+	resp := ctrl.syncBackendRecord(key)
+	if !resp.IsFailed() {
+		t.Fatalf("expect failed result, get %#v", resp)
+	}
+	if reason := store[backend.Name]; reason != "OperationTimeout" {
+		t.Fatalf("expect reason OperationTimeout, get %s", reason)
+	}
+	get, _ := fakeClient.LbcfV1beta1().BackendRecords(backend.Namespace).Get(backend.Name, v1.GetOptions{})
+	if get.Status.RunningOperation != nil {
+		t.Errorf("expect nil runningOperation, get %+v", get.Status.RunningOperation)
+	}
+	cond := util.GetBackendRecordCondition(&get.Status, lbcfapi.BackendDeregistered)
+	if cond == nil || cond.Status != lbcfapi.ConditionFalse || cond.Reason != lbcfapi.ReasonOperationTimeout.String() {
+		t.Errorf("expect condition Deregistered=False with reason Timeout, get %+v", cond)
+	}
+}
@@ -35,14 +35,20 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apimachinery/pkg/util/sets"
 	corev1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 )
 
+const (
+	outOfScopeCacheSize = 1024
+	outOfScopeCacheTTL  = 30 * time.Second
+)
+
 func newBackendGroupController(
 	client lbcfclient.Interface,
 	driverLister lbcflister.LoadBalancerDriverLister,
@@ -69,6 +75,7 @@ func newBackendGroupController(
 		relatedLoadBalancer: &sync.Map{},
 		relatedPod:          &sync.Map{},
 		dryRun:              dryRun,
+		outOfScopeCache:     utilcache.NewLRUExpireCache(outOfScopeCacheSize),
 	}
 }
 
@@ -88,6 +95,15 @@ type backendGroupController struct {
 	relatedLoadBalancer *sync.Map
 	relatedPod          *sync.Map
 	dryRun              bool
+
+	// podIndexer indexes BackendGroups by util.IndexBackendGroupByPod, all BackendGroups in namespace are checked if nil
+	podIndexer cache.Indexer
+	// memberPodListers are PodListers of member clusters that are ready, it is nil if no member cluster is configured
+	memberPodListers *util.MemberClusterSet
+	// scope is nil if all objects are handled
+	scope *util.WatchScope
+	// outOfScopeCache caches results of loadBalancerOutOfScope, so that the API server is not queried on every sync
+	outOfScopeCache *utilcache.LRUExpireCache
 }
 
 func (c *backendGroupController) syncBackendGroup(key string) *util.SyncResult {
@@ -109,6 +125,7 @@ func (c *backendGroupController) syncBackendGroup(key string) *util.SyncResult {
 
 	var errList util.ErrorList
 	var availableLBs []*lbcfapi.LoadBalancer
+	var doNotDelete []*lbcfapi.BackendRecord
 	for _, lbName := range group.Spec.GetLoadBalancers() {
 		// before LBCF 1.4, LoadBalancer with prefix lbcf- can be created outside kube-system.
 		// In order to be compatible, we first search the LoadBalancer in the namespace that BackendGroup resides,
@@ -124,7 +141,18 @@ func (c *backendGroupController) syncBackendGroup(key string) *util.SyncResult {
 			err = lastErr
 		}
 		lbDeleting := err == nil && lb.DeletionTimestamp != nil
-		if lbNotFound || lbDeleting {
+		if lbNotFound && c.loadBalancerOutOfScope(group, lbName) {
+			// the LoadBalancer is not deleted, its BackendRecords are kept until it matches the object selector again
+			event(c.eventRecorder, group, v1.EventTypeWarning, "LoadBalancerOutOfScope",
+				"LoadBalancer %s does not match object selector %q of lbcf-controller", lbName, c.scope.Selector().String())
+			existing, err := c.listBackendRecords(namespace, &lbName, group.Name)
+			if err != nil {
+				errList = append(errList, err)
+				continue
+			}
+			doNotDelete = append(doNotDelete, existing...)
+			continue
+		} else if lbNotFound || lbDeleting {
 			if lbNotFound {
 				event(c.eventRecorder, group, v1.EventTypeNormal, "GetLoadBalancerFailed", "%s", err)
 			}
@@ -142,15 +170,20 @@ func (c *backendGroupController) syncBackendGroup(key string) *util.SyncResult {
 		availableLBs = append(availableLBs, lb)
 	}
 
-	var expectedBackends, doNotDelete []*lbcfapi.BackendRecord
+	var expectedBackends, podsDoNotDelete []*lbcfapi.BackendRecord
 	if group.Spec.Pods != nil {
-		expectedBackends, doNotDelete, err = c.expectedPodBackends(group, availableLBs)
+		expectedBackends, podsDoNotDelete, err = c.expectedPodBackends(group, availableLBs)
 	} else if group.Spec.Service != nil {
 		expectedBackends, err = c.expectedServiceBackends(group, availableLBs)
 	} else {
 		expectedBackends, err = c.expectedStaticBackends(group, availableLBs)
 	}
-	if err := c.update(group, expectedBackends, doNotDelete); err != nil {
+	if err != nil {
+		// existing BackendRecords must not be deleted if the expected ones are unknown,
+		// otherwise all Pods would be deregistered. Binds behave the same in handleBackends
+		return util.ErrorResult(append(errList, err))
+	}
+	if err := c.update(group, expectedBackends, append(doNotDelete, podsDoNotDelete...)); err != nil {
 		errList = append(errList, err)
 	}
 	if len(errList) > 0 {
@@ -160,12 +193,72 @@ func (c *backendGroupController) syncBackendGroup(key string) *util.SyncResult {
 	return util.FinishedResult()
 }
 
+// loadBalancerOutOfScope returns true if the LoadBalancer lbName of group exists but is filtered out by the object selector.
+// Such LoadBalancers are not in the lister, so they are read from the API server, and the results are cached for outOfScopeCacheTTL
+func (c *backendGroupController) loadBalancerOutOfScope(group *lbcfapi.BackendGroup, lbName string) bool {
+	if c.scope == nil || c.scope.Selector().Empty() {
+		return false
+	}
+	cacheKey := util.NamespacedNameKeyFunc(group.Namespace, lbName)
+	if cached, ok := c.outOfScopeCache.Get(cacheKey); ok {
+		return cached.(bool)
+	}
+	outOfScope := c.getLoadBalancerOutOfScope(group, lbName)
+	c.outOfScopeCache.Add(cacheKey, outOfScope, outOfScopeCacheTTL)
+	return outOfScope
+}
+
+func (c *backendGroupController) getLoadBalancerOutOfScope(group *lbcfapi.BackendGroup, lbName string) bool {
+	namespaces := []string{group.Namespace}
+	if strings.HasPrefix(lbName, lbcfapi.SystemDriverPrefix) {
+		namespaces = append(namespaces, metav1.NamespaceSystem)
+	}
+	for _, namespace := range namespaces {
+		start := time.Now()
+		lb, err := c.client.LbcfV1beta1().LoadBalancers(namespace).Get(lbName, metav1.GetOptions{})
+		metrics.K8SOpLatencyObserve("LoadBalancer", metrics.OpGet, time.Since(start))
+		if err == nil {
+			return !c.scope.Contains(lb)
+		}
+	}
+	return false
+}
+
 func (c *backendGroupController) expectedPodBackends(
 	group *lbcfapi.BackendGroup,
 	lbList []*lbcfapi.LoadBalancer) ([]*lbcfapi.BackendRecord, []*lbcfapi.BackendRecord, error) {
+	var expectedRecords, doNotDelete []*lbcfapi.BackendRecord
+	for _, cluster := range util.PodClusters(group.Spec.Pods.Clusters) {
+		podLister, err := util.ClusterPodLister(c.podLister, c.memberPodListers, cluster)
+		if err != nil {
+			// Pods of an unavailable member cluster are unknown, its BackendRecords are kept while other clusters are synced
+			event(c.eventRecorder, group, v1.EventTypeWarning, "ListPodFailed", "%v", err)
+			existing, err := c.listBackendRecords(group.Namespace, nil, group.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			doNotDelete = append(doNotDelete, util.ClusterBackendRecords(existing, cluster)...)
+			continue
+		}
+		expected, notDelete, err := c.expectedPodBackendsInCluster(group, lbList, podLister, cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+		expectedRecords = append(expectedRecords, expected...)
+		doNotDelete = append(doNotDelete, notDelete...)
+	}
+	return expectedRecords, doNotDelete, nil
+}
+
+// expectedPodBackendsInCluster returns BackendRecords of Pods in cluster, empty cluster means the local cluster
+func (c *backendGroupController) expectedPodBackendsInCluster(
+	group *lbcfapi.BackendGroup,
+	lbList []*lbcfapi.LoadBalancer,
+	podLister corev1.PodLister,
+	cluster string) ([]*lbcfapi.BackendRecord, []*lbcfapi.BackendRecord, error) {
 	var pods []*v1.Pod
 	if group.Spec.Pods.ByLabel != nil {
-		podsInAllNameSpaces, err := c.podLister.List(labels.SelectorFromSet(labels.Set(group.Spec.Pods.ByLabel.Selector)))
+		podsInAllNameSpaces, err := podLister.List(labels.SelectorFromSet(labels.Set(group.Spec.Pods.ByLabel.Selector)))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -179,7 +272,7 @@ func (c *backendGroupController) expectedPodBackends(
 		pods = util.FilterPods(podsInAllNameSpaces, filter)
 	} else if len(group.Spec.Pods.ByName) > 0 {
 		for _, podName := range group.Spec.Pods.ByName {
-			pod, err := c.podLister.Pods(group.Namespace).Get(podName)
+			pod, err := podLister.Pods(group.Namespace).Get(podName)
 			if errors.IsNotFound(err) {
 				continue
 			} else if err != nil {
@@ -212,20 +305,20 @@ func (c *backendGroupController) expectedPodBackends(
 	for _, lb := range lbList {
 		// the requirement of registering a Pod is always pod.status.conditions[].Ready = True
 		for _, pod := range util.FilterPods(pods, util.PodAvailable) {
-			expectedRecords = append(expectedRecords, util.ConstructPodBackendRecord(lb, group, pod)...)
+			expectedRecords = append(expectedRecords, util.ConstructClusterPodBackendRecord(lb, group, pod, cluster)...)
 		}
 		for _, pod := range podsDoNotDereg {
-			doNotDelete = append(doNotDelete, util.ConstructPodBackendRecord(lb, group, pod)...)
+			doNotDelete = append(doNotDelete, util.ConstructClusterPodBackendRecord(lb, group, pod, cluster)...)
 		}
 	}
 	if klog.V(3) {
 		for _, r := range expectedRecords {
-			klog.V(3).Infof("expect record ==> LB: %s, BG: %s/%s, Pod: %s",
-				r.Spec.LBName, group.Namespace, group.Name, r.Spec.PodBackendInfo.Name)
+			klog.V(3).Infof("expect record ==> LB: %s, BG: %s/%s, Pod: %s, Cluster: %s",
+				r.Spec.LBName, group.Namespace, group.Name, r.Spec.PodBackendInfo.Name, cluster)
 		}
 		for _, r := range doNotDelete {
-			klog.V(3).Infof("not delete record ==> LB: %s, BG: %s/%s, Pod: %s",
-				r.Spec.LBName, group.Namespace, group.Name, r.Spec.PodBackendInfo.Name)
+			klog.V(3).Infof("not delete record ==> LB: %s, BG: %s/%s, Pod: %s, Cluster: %s",
+				r.Spec.LBName, group.Namespace, group.Name, r.Spec.PodBackendInfo.Name, cluster)
 		}
 	}
 	return expectedRecords, doNotDelete, nil
@@ -291,15 +384,21 @@ func (c *backendGroupController) update(
 			curRegistered++
 		}
 	}
-	if group.Status.Backends != int32(curTotal) || group.Status.RegisteredBackends != curRegistered {
-		group = group.DeepCopy()
-		group.Status.Backends = int32(curTotal)
-		group.Status.RegisteredBackends = curRegistered
-		if err := c.updateStatus(group, &group.Status); err != nil {
+	retryAt := group.Annotations[lbcfapi.AnnotationRetryAt]
+	if group.Status.Backends != int32(curTotal) || group.Status.RegisteredBackends != curRegistered || group.Status.LastRetryAt != retryAt {
+		cpy := group.DeepCopy()
+		cpy.Status.Backends = int32(curTotal)
+		cpy.Status.RegisteredBackends = curRegistered
+		cpy.Status.LastRetryAt = retryAt
+		if err := c.updateStatus(group, cpy); err != nil {
 			return err
 		}
+		group = cpy
 	}
 
+	for _, e := range expectedBackends {
+		util.SetRetryAt(e, retryAt)
+	}
 	needCreate, needUpdate, needDelete := util.CompareBackendRecords(expectedBackends, existingRecords, doNotDelete)
 
 	var errs util.ErrorList
@@ -322,6 +421,24 @@ func (c *backendGroupController) listRelatedBackendGroupsForPod(pod *v1.Pod) set
 	filter := func(group *lbcfapi.BackendGroup) bool {
 		return util.IsPodMatchBackendGroup(group, pod)
 	}
+	if c.podIndexer != nil {
+		set := sets.NewString()
+		for _, value := range util.PodIndexValues(pod) {
+			objs, err := c.podIndexer.ByIndex(util.IndexBackendGroupByPod, value)
+			if err != nil {
+				klog.Errorf("skip pod(%s/%s) add, list backendgroup by index failed: %v", pod.Namespace, pod.Name, err)
+				return nil
+			}
+			for _, obj := range objs {
+				group, ok := obj.(*lbcfapi.BackendGroup)
+				if !ok || !filter(group) {
+					continue
+				}
+				set.Insert(util.NamespacedNameKeyFunc(group.Namespace, group.Name))
+			}
+		}
+		return set
+	}
 	groups, err := c.listRelatedBackendGroups(pod.Namespace, filter)
 	if err != nil {
 		klog.Errorf("skip pod(%s/%s) add, list backendgroup failed: %v", pod.Namespace, pod.Name, err)
@@ -408,6 +525,7 @@ func (c *backendGroupController) updateBackendRecord(record *lbcfapi.BackendReco
 	start := time.Now()
 	_, err := c.client.LbcfV1beta1().BackendRecords(record.Namespace).Update(record)
 	metrics.K8SOpLatencyObserve("BackendRecord", metrics.OpUpdate, time.Since(start))
+	util.ObserveK8SWriteConflict("BackendRecord", metrics.OpUpdate, err)
 	if err != nil {
 		return fmt.Errorf("update BackendRecord %s/%s failed: %v", record.Namespace, record.Name, err)
 	}
@@ -472,19 +590,18 @@ func (c *backendGroupController) listBackendRecords(namespace string, lbName *st
 	return ret, nil
 }
 
-func (c *backendGroupController) updateStatus(group *lbcfapi.BackendGroup, status *lbcfapi.BackendGroupStatus) error {
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		group.Status = *status
-		_, updateErr := c.client.LbcfV1beta1().BackendGroups(group.Namespace).UpdateStatus(group)
-		if updateErr == nil {
-			return nil
-		}
-		if updated, err := c.client.LbcfV1beta1().BackendGroups(group.Namespace).Get(group.Name, metav1.GetOptions{}); err == nil {
-			group = updated
-		} else {
-			klog.Errorf("error getting updated BackendGroup %s/%s: %v", group.Namespace, group.Name, err)
+// updateStatus sends the changes from old.Status to cur.Status as a merge patch guarded by the resourceVersion of old,
+// a conflict is retried against the latest BackendGroup only if its status is not modified concurrently
+func (c *backendGroupController) updateStatus(old, cur *lbcfapi.BackendGroup) error {
+	return util.PatchStatus("BackendGroup", old.ResourceVersion, old.Status, cur.Status, func(pt types.PatchType, data []byte) error {
+		_, err := c.client.LbcfV1beta1().BackendGroups(cur.Namespace).Patch(cur.Name, pt, data, "status")
+		return err
+	}, func() (string, interface{}, error) {
+		latest, err := c.client.LbcfV1beta1().BackendGroups(cur.Namespace).Get(cur.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, err
 		}
-		return updateErr
+		return latest.ResourceVersion, latest.Status, nil
 	})
 }
 
@@ -17,16 +17,21 @@
 package lbcfcontroller
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned/fake"
+	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestBackendGroupCreateRecord(t *testing.T) {
@@ -91,6 +96,145 @@ func TestBackendGroupCreateRecord(t *testing.T) {
 	}
 }
 
+func TestBackendGroupCreateRecordInMemberClusters(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", map[string]string{"a1": "v1"}, nil)
+	fakeLBEnsured(lb)
+	localPod := newFakePod("", "pod-1", map[string]string{"k1": "v1"}, true, false)
+	// Pods in member clusters may have the same names as local Pods
+	memberPod := newFakePod("", "pod-1", map[string]string{"k1": "v1"}, true, false)
+	memberPod.UID = "memberUID"
+	group := newFakeBackendGroupOfPods(localPod.Namespace, "group", lb.Name, 80, "TCP", localPod.Labels, nil, nil)
+	group.Spec.Pods.Clusters = []string{lbcfapi.LocalClusterName, "member"}
+	fakeClient := fake.NewSimpleClientset(group)
+	ctrl := newBackendGroupController(
+		fakeClient,
+		&fakeDriverLister{},
+		&fakeLBLister{
+			get:  lb,
+			list: []*lbcfapi.LoadBalancer{lb},
+		},
+		&fakeBackendGroupLister{
+			get: group,
+		},
+		&fakeBackendLister{},
+		&fakePodLister{
+			list: []*v1.Pod{localPod},
+		},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		nil, nil,
+		false,
+	)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(group)
+
+	// Pods of the local cluster are registered even if another selected cluster is unknown
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+	localRecords, _ := fakeClient.LbcfV1beta1().BackendRecords(group.Namespace).List(metav1.ListOptions{})
+	if len(localRecords.Items) != 1 {
+		t.Fatalf("expect 1 BackendRecord, get %d", len(localRecords.Items))
+	}
+	ctrl.brLister = &fakeBackendLister{list: []*lbcfapi.BackendRecord{&localRecords.Items[0]}}
+
+	ctrl.memberPodListers = util.NewMemberClusterSet()
+	ctrl.memberPodListers.Set("member", nil, &fakePodLister{
+		list: []*v1.Pod{memberPod},
+	})
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+	records, _ := fakeClient.LbcfV1beta1().BackendRecords(group.Namespace).List(metav1.ListOptions{})
+	if len(records.Items) != 2 {
+		t.Fatalf("expect 2 BackendReocrds, get %v, %#v", len(records.Items), records.Items)
+	}
+	for _, r := range records.Items {
+		var expected []*lbcfapi.BackendRecord
+		switch r.Name {
+		case util.MakePodBackendName(lb.Name, group.Name, localPod.UID, lbcfapi.PortSelector{Port: 80, Protocol: "TCP"}):
+			expected = util.ConstructPodBackendRecord(lb, group, localPod)
+		case util.MakePodBackendName(lb.Name, group.Name, memberPod.UID, lbcfapi.PortSelector{Port: 80, Protocol: "TCP"}):
+			expected = util.ConstructClusterPodBackendRecord(lb, group, memberPod, "member")
+		default:
+			t.Fatalf("unknown BackendRecord %#v", r)
+		}
+		if !reflect.DeepEqual(*expected[0], r) {
+			t.Errorf("expect BackendRecord %#v \n get %#v", *expected[0], r)
+		}
+	}
+
+	// a member cluster whose kubeconfig Secret becomes invalid is removed,
+	// its BackendRecords are kept until its Pods are known again
+	var existing []*lbcfapi.BackendRecord
+	for i := range records.Items {
+		existing = append(existing, &records.Items[i])
+	}
+	ctrl.brLister = &fakeBackendLister{list: existing}
+	ctrl.memberPodListers.Remove("member")
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+	if records, _ := fakeClient.LbcfV1beta1().BackendRecords(group.Namespace).List(metav1.ListOptions{}); len(records.Items) != 2 {
+		t.Fatalf("expect 2 BackendRecords kept, get %d", len(records.Items))
+	}
+}
+
+func TestBackendGroupMemberClusterUnavailable(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", map[string]string{"a1": "v1"}, nil)
+	fakeLBEnsured(lb)
+	deletedPod := newFakePod("", "pod-1", map[string]string{"k1": "v1"}, true, false)
+	deletedPod.UID = "deletedUID"
+	newPod := newFakePod("", "pod-2", map[string]string{"k1": "v1"}, true, false)
+	newPod.UID = "newUID"
+	missingPod := newFakePod("", "pod-3", map[string]string{"k1": "v1"}, true, false)
+	missingPod.UID = "missingUID"
+	group := newFakeBackendGroupOfPods(newPod.Namespace, "group", lb.Name, 80, "TCP", newPod.Labels, nil, nil)
+	group.Spec.Pods.Clusters = []string{"ready", "missing"}
+	deletedRecord := util.ConstructClusterPodBackendRecord(lb, group, deletedPod, "ready")[0]
+	missingRecord := util.ConstructClusterPodBackendRecord(lb, group, missingPod, "missing")[0]
+	fakeClient := fake.NewSimpleClientset(group, deletedRecord, missingRecord)
+	ctrl := newBackendGroupController(
+		fakeClient,
+		&fakeDriverLister{},
+		&fakeLBLister{
+			get:  lb,
+			list: []*lbcfapi.LoadBalancer{lb},
+		},
+		&fakeBackendGroupLister{
+			get: group,
+		},
+		&fakeBackendLister{
+			list: []*lbcfapi.BackendRecord{deletedRecord, missingRecord},
+		},
+		&fakePodLister{},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		nil, nil,
+		false,
+	)
+	ctrl.memberPodListers = util.NewMemberClusterSet()
+	ctrl.memberPodListers.Set("ready", nil, &fakePodLister{
+		list: []*v1.Pod{newPod},
+	})
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(group)
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+
+	// Pods of the ready cluster are synced, BackendRecords of the missing cluster are kept
+	records, _ := fakeClient.LbcfV1beta1().BackendRecords(group.Namespace).List(metav1.ListOptions{})
+	names := sets.NewString()
+	for _, r := range records.Items {
+		names.Insert(r.Name)
+	}
+	expect := sets.NewString(
+		util.ConstructClusterPodBackendRecord(lb, group, newPod, "ready")[0].Name,
+		missingRecord.Name)
+	if !names.Equal(expect) {
+		t.Fatalf("expect BackendRecords %v, get %v", expect.List(), names.List())
+	}
+}
+
 func TestBackendGroupCreateRecordByPodName(t *testing.T) {
 	lb := newFakeLoadBalancer("", "lb", map[string]string{"a1": "v1"}, nil)
 	fakeLBEnsured(lb)
@@ -581,6 +725,66 @@ func TestBackendGroupDeleteRecordCausedByLBDeleted(t *testing.T) {
 	}
 }
 
+func TestBackendGroupLoadBalancerOutOfScope(t *testing.T) {
+	lb := newFakeLoadBalancer("", "lb", nil, nil)
+	group := newFakeBackendGroupOfStatic("", "group", lb.Name, "1.1.1.1:80")
+	existing := newFakeBackendRecord("", "existing")
+	existing.Labels = util.MakeBackendLabels(lb.Spec.LBDriver, lb.Name, group.Name, "", "")
+	fakeClient := fake.NewSimpleClientset(lb, group, existing)
+	recorder := record.NewFakeRecorder(10)
+	ctrl := newBackendGroupController(
+		fakeClient,
+		&fakeDriverLister{},
+		&fakeLBLister{},
+		&fakeBackendGroupLister{
+			get: group,
+		},
+		&fakeBackendLister{
+			list: []*lbcfapi.BackendRecord{existing},
+		},
+		&fakePodLister{},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		nil,
+		recorder,
+		false,
+	)
+	scope, err := util.NewWatchScope(nil, "app=lbcf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.scope = scope
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(group)
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, "LoadBalancerOutOfScope") {
+			t.Fatalf("expect LoadBalancerOutOfScope event, get %q", e)
+		}
+	default:
+		t.Fatalf("expect LoadBalancerOutOfScope event")
+	}
+
+	// BackendRecords of the LoadBalancer are kept, and the LoadBalancer is read from API server only once
+	if result := ctrl.syncBackendGroup(key); !result.IsFinished() {
+		t.Fatalf("expect succ result, get %#v", result.GetFailReason())
+	}
+	gets := 0
+	for _, action := range fakeClient.Actions() {
+		if action.Matches("delete", "backendrecords") {
+			t.Fatalf("expect BackendRecords of out-of-scope LoadBalancer kept, get %#v", action)
+		}
+		if action.Matches("get", "loadbalancers") {
+			gets++
+		}
+	}
+	if gets != 1 {
+		t.Fatalf("expect LoadBalancer read once, get %d", gets)
+	}
+}
+
 func fakeLBEnsured(lb *lbcfapi.LoadBalancer) {
 	ts := metav1.Now()
 	util.AddLBCondition(&lb.Status, lbcfapi.LoadBalancerCondition{
@@ -594,3 +798,63 @@ func fakeLBEnsured(lb *lbcfapi.LoadBalancer) {
 		LastTransitionTime: ts,
 	})
 }
+
+func TestBackendGroupListRelatedForPodByIndex(t *testing.T) {
+	pod := newFakePod("ns", "pod-1", map[string]string{"app": "web", "tier": "front"}, true, false)
+	groups := []*lbcfapi.BackendGroup{
+		newFakeBackendGroupOfPods("ns", "by-label", "lb", 80, "TCP", map[string]string{"app": "web"}, nil, nil),
+		newFakeBackendGroupOfPods("ns", "by-labels", "lb", 80, "TCP", map[string]string{"app": "web", "tier": "front"}, nil, nil),
+		newFakeBackendGroupOfPods("ns", "not-match", "lb", 80, "TCP", map[string]string{"app": "web", "tier": "back"}, nil, nil),
+		newFakeBackendGroupOfPods("ns", "except", "lb", 80, "TCP", map[string]string{"app": "web"}, []string{"pod-1"}, nil),
+		newFakeBackendGroupOfPods("ns", "by-name", "lb", 80, "TCP", nil, nil, []string{"pod-1"}),
+		newFakeBackendGroupOfPods("other", "other-ns", "lb", 80, "TCP", map[string]string{"app": "web"}, nil, nil),
+		newFakeBackendGroupOfService("ns", "svc", "lb", 80, "TCP", "svc"),
+	}
+	scanCtrl, indexCtrl := newPodIndexTestBackendGroupControllers(groups)
+	expect := scanCtrl.listRelatedBackendGroupsForPod(pod)
+	if !expect.Equal(sets.NewString("ns/by-label", "ns/by-labels", "ns/by-name")) {
+		t.Fatalf("unexpected groups listed without index: %v", expect.List())
+	}
+	if get := indexCtrl.listRelatedBackendGroupsForPod(pod); !get.Equal(expect) {
+		t.Errorf("expect %v, get %v", expect.List(), get.List())
+	}
+}
+
+func BenchmarkListRelatedBackendGroupsForPod(b *testing.B) {
+	// 5000 BackendGroups in one namespace, each selects pods of its own app
+	var groups []*lbcfapi.BackendGroup
+	for i := 0; i < 5000; i++ {
+		groups = append(groups, newFakeBackendGroupOfPods("ns", fmt.Sprintf("group-%d", i), "lb", 80, "TCP",
+			map[string]string{"app": fmt.Sprintf("app-%d", i)}, nil, nil))
+	}
+	pod := newFakePod("ns", "pod", map[string]string{"app": "app-100", "pod-template-hash": "abc"}, true, false)
+	scanCtrl, indexCtrl := newPodIndexTestBackendGroupControllers(groups)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanCtrl.listRelatedBackendGroupsForPod(pod)
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexCtrl.listRelatedBackendGroupsForPod(pod)
+		}
+	})
+}
+
+// newPodIndexTestBackendGroupControllers returns a controller listing all BackendGroups and a controller using podIndexer
+func newPodIndexTestBackendGroupControllers(groups []*lbcfapi.BackendGroup) (*backendGroupController, *backendGroupController) {
+	var objs []interface{}
+	for _, group := range groups {
+		objs = append(objs, group)
+	}
+	indexer := newPodIndexTestIndexer(util.IndexBackendGroupByPod, util.BackendGroupPodIndexFunc, objs...)
+	newCtrl := func() *backendGroupController {
+		return newBackendGroupController(fake.NewSimpleClientset(), &fakeDriverLister{}, &fakeLBLister{},
+			lbcflister.NewBackendGroupLister(indexer), &fakeBackendLister{}, &fakePodLister{},
+			&fakeSvcListerWithStore{}, &fakeNodeListerWithStore{}, nil, nil, false)
+	}
+	indexCtrl := newCtrl()
+	indexCtrl.podIndexer = indexer
+	return newCtrl(), indexCtrl
+}
@@ -22,11 +22,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	corev1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 )
 
@@ -36,6 +38,8 @@ func NewController(
 	bindLister v1.BindLister,
 	brLister lbcflister.BackendRecordLister,
 	podLister corev1.PodLister,
+	svcLister corev1.ServiceLister,
+	nodeLister corev1.NodeLister,
 	invoker util.WebhookInvoker,
 	recorder record.EventRecorder,
 	dryRun bool,
@@ -46,6 +50,8 @@ func NewController(
 		bindLister:     bindLister,
 		brLister:       brLister,
 		podLister:      podLister,
+		serviceLister:  svcLister,
+		nodeLister:     nodeLister,
 		webhookInvoker: invoker,
 		eventRecorder:  recorder,
 		dryRun:         dryRun,
@@ -55,14 +61,38 @@ func NewController(
 type Controller struct {
 	client lbcfclient.Interface
 
-	driverLister lbcflister.LoadBalancerDriverLister
-	bindLister   v1.BindLister
-	brLister     lbcflister.BackendRecordLister
-	podLister    corev1.PodLister
+	driverLister  lbcflister.LoadBalancerDriverLister
+	bindLister    v1.BindLister
+	brLister      lbcflister.BackendRecordLister
+	podLister     corev1.PodLister
+	serviceLister corev1.ServiceLister
+	nodeLister    corev1.NodeLister
 
 	webhookInvoker util.WebhookInvoker
 	eventRecorder  record.EventRecorder
 	dryRun         bool
+
+	// podIndexer indexes Binds by util.IndexBindByPod, all Binds in namespace are checked if nil
+	podIndexer cache.Indexer
+	// lbInfoIndexer indexes Binds by util.IndexBindByLBInfo, all Binds are checked if nil
+	lbInfoIndexer cache.Indexer
+	// memberPodListers are PodListers of member clusters that are ready, it is nil if no member cluster is configured
+	memberPodListers *util.MemberClusterSet
+}
+
+// SetPodIndexer makes ListRelatedBindForPod look up Binds in indexer by util.IndexBindByPod
+func (c *Controller) SetPodIndexer(indexer cache.Indexer) {
+	c.podIndexer = indexer
+}
+
+// SetLBInfoIndexer makes ListRelatedBindForLoadBalancer look up Binds in indexer by util.IndexBindByLBInfo
+func (c *Controller) SetLBInfoIndexer(indexer cache.Indexer) {
+	c.lbInfoIndexer = indexer
+}
+
+// SetMemberPodListers sets PodListers of member clusters, so that Binds can select Pods in member clusters
+func (c *Controller) SetMemberPodListers(listers *util.MemberClusterSet) {
+	c.memberPodListers = listers
 }
 
 func (c *Controller) Sync(key string) *util.SyncResult {
@@ -77,6 +107,10 @@ func (c *Controller) Sync(key string) *util.SyncResult {
 	} else if err != nil {
 		return util.ErrorResult(err)
 	}
+	if from, ok := bind.Annotations[lbcfv1.AnnotationMigratingFrom]; ok && bind.DeletionTimestamp == nil {
+		klog.Infof("skip Bind %s/%s: migrating from BackendGroup %s", namespace, name, from)
+		return util.FinishedResult()
+	}
 	if bind.DeletionTimestamp != nil {
 		if !util.HasFinalizer(bind.Finalizers, lbcfv1.FinalizerDeleteLB) {
 			return util.FinishedResult()
@@ -103,6 +137,11 @@ func (c *Controller) Sync(key string) *util.SyncResult {
 	}
 	needResync := c.handleLoadBalancer(bind)
 	needResync = needResync || c.handleBackends(bind)
+	if !c.dryRun && bind.Annotations[lbcfv1.AnnotationRetryAt] != bind.Status.LastRetryAt {
+		if err := c.recordRetryAt(bind); err != nil {
+			return util.ErrorResult(err)
+		}
+	}
 	if needResync {
 		return util.AsyncResult(10 * time.Second)
 	}
@@ -110,32 +149,119 @@ func (c *Controller) Sync(key string) *util.SyncResult {
 }
 
 func (c *Controller) ListRelatedBindForPod(pod *apicorev1.Pod) sets.String {
+	ret := sets.NewString()
+	if c.podIndexer != nil {
+		for _, value := range util.PodIndexValues(pod) {
+			objs, err := c.podIndexer.ByIndex(util.IndexBindByPod, value)
+			if err != nil {
+				klog.Errorf("list related Bind for pod %s/%s by index failed: %v", pod.Namespace, pod.Name, err)
+				return nil
+			}
+			for _, obj := range objs {
+				if bind, ok := obj.(*lbcfv1.Bind); ok && isPodMatchBind(bind, pod) {
+					ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
+				}
+			}
+		}
+		return ret
+	}
 	bindList, err := c.bindLister.Binds(pod.Namespace).List(labels.Everything())
 	if err != nil {
 		klog.Errorf("list related Bind for pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
 		return nil
 	}
+	for _, bind := range bindList {
+		if isPodMatchBind(bind, pod) {
+			ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
+		}
+	}
+	return ret
+}
+
+func isPodMatchBind(bind *lbcfv1.Bind, pod *apicorev1.Pod) bool {
+	if bind.Namespace != pod.Namespace || bind.Spec.Pods == nil {
+		return false
+	}
+	if bind.Spec.Pods.ByLabel != nil {
+		if sets.NewString(bind.Spec.Pods.ByLabel.Except...).Has(pod.Name) {
+			return false
+		}
+		selector := labels.SelectorFromSet(bind.Spec.Pods.ByLabel.Selector)
+		return selector.Matches(labels.Set(pod.Labels))
+	}
+	return sets.NewString(bind.Spec.Pods.ByName...).Has(pod.Name)
+}
+
+// ListRelatedBindForService returns keys of Binds selecting svc
+func (c *Controller) ListRelatedBindForService(svc *apicorev1.Service) sets.String {
+	bindList, err := c.bindLister.Binds(svc.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list related Bind for service %s/%s failed: %v", svc.Namespace, svc.Name, err)
+		return nil
+	}
 	ret := sets.NewString()
 	for _, bind := range bindList {
-		var key string
-		if len(bind.Namespace) > 0 {
-			key = bind.Namespace + "/" + bind.Name
-		} else {
-			key = bind.Name
+		if bind.Spec.Service != nil && bind.Spec.Service.Name == svc.Name {
+			ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
 		}
-		if bind.Spec.Pods.ByLabel != nil {
-			except := sets.NewString(bind.Spec.Pods.ByLabel.Except...)
-			if except.Has(pod.Name) {
-				continue
+	}
+	return ret
+}
+
+// ListRelatedBindForNode returns keys of Binds selecting Services on node
+func (c *Controller) ListRelatedBindForNode(node *apicorev1.Node) sets.String {
+	bindList, err := c.bindLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list related Bind for node %s failed: %v", node.Name, err)
+		return nil
+	}
+	ret := sets.NewString()
+	for _, bind := range bindList {
+		if bind.Spec.Service == nil {
+			continue
+		}
+		if labels.SelectorFromSet(bind.Spec.Service.NodeSelector).Matches(labels.Set(node.Labels)) {
+			ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
+		}
+	}
+	return ret
+}
+
+// GetBind returns the Bind in namespace named name
+func (c *Controller) GetBind(namespace, name string) (*lbcfv1.Bind, error) {
+	return c.bindLister.Binds(namespace).Get(name)
+}
+
+// ListRelatedBindForLoadBalancer returns keys of Binds whose load balancer is identified by driver and lbInfo
+func (c *Controller) ListRelatedBindForLoadBalancer(driverNamespace, driverName string, lbInfo map[string]string) sets.String {
+	if c.lbInfoIndexer != nil {
+		objs, err := c.lbInfoIndexer.ByIndex(util.IndexBindByLBInfo, util.LBInfoIndexValue(driverNamespace, driverName, lbInfo))
+		if err != nil {
+			klog.Errorf("list related Bind for load balancer %v by index failed: %v", lbInfo, err)
+			return nil
+		}
+		ret := sets.NewString()
+		for _, obj := range objs {
+			if bind, ok := obj.(*lbcfv1.Bind); ok {
+				ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
 			}
-			selector := labels.SelectorFromSet(bind.Spec.Pods.ByLabel.Selector)
-			if selector.Matches(labels.Set(pod.Labels)) {
-				ret.Insert(key)
+		}
+		return ret
+	}
+	bindList, err := c.bindLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list related Bind for load balancer %v failed: %v", lbInfo, err)
+		return nil
+	}
+	ret := sets.NewString()
+	for _, bind := range bindList {
+		for _, status := range bind.Status.LoadBalancerStatuses {
+			if status.Driver != driverName || util.NamespaceOfSharedObj(status.Driver, bind.Namespace) != driverNamespace {
+				continue
 			}
-		} else {
-			expect := sets.NewString(bind.Spec.Pods.ByName...)
-			if expect.Has(pod.Name) {
-				ret.Insert(key)
+			if util.EqualStringMap(status.LBInfo, lbInfo) {
+				ret.Insert(util.NamespacedNameKeyFunc(bind.Namespace, bind.Name))
+				break
 			}
 		}
 	}
@@ -147,24 +273,30 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 	for _, s := range bind.Status.LoadBalancerStatuses {
 		statusMap[s.Name] = s
 	}
+	// the failed attempts are reset when annotation lbcf.tkestack.io/retry-at is changed
+	resetRetry := bind.Annotations[lbcfv1.AnnotationRetryAt] != bind.Status.LastRetryAt
 	var lbNeedCreate, lbNeedEnsure []lbcfv1.TargetLoadBalancer
 	var lbNeedDelete []lbcfv1.TargetLoadBalancerStatus
 	if bind.DeletionTimestamp != nil {
 		for _, status := range bind.Status.LoadBalancerStatuses {
-			if len(status.LBInfo) > 0 {
+			if len(status.LBInfo) > 0 && !retryExhausted(bind, status, resetRetry) {
 				lbNeedDelete = append(lbNeedDelete, status)
 			}
 		}
 	} else {
 		for _, lb := range bind.Spec.LoadBalancers {
-			if curStatus, ok := statusMap[lb.Name]; !ok || !bindutil.IsLoadBalancerCreated(curStatus) {
+			curStatus, ok := statusMap[lb.Name]
+			if ok && retryExhausted(bind, curStatus, resetRetry) {
+				continue
+			}
+			if !ok || !bindutil.IsLoadBalancerCreated(curStatus) {
 				lbNeedCreate = append(lbNeedCreate, lb)
 			} else if !util.EqualStringMap(lb.Attributes, curStatus.LastSyncedAttributes) {
 				lbNeedEnsure = append(lbNeedEnsure, lb)
 			}
 		}
 		for _, status := range bind.Status.LoadBalancerStatuses {
-			if status.DeletionTimestamp != nil {
+			if status.DeletionTimestamp != nil && !retryExhausted(bind, status, resetRetry) {
 				lbNeedDelete = append(lbNeedDelete, status)
 			}
 		}
@@ -185,20 +317,23 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 			continue
 		}
 		wg.Add(1)
-		go func(lb lbcfv1.TargetLoadBalancer, driver *v1beta1.LoadBalancerDriver) {
+		go func(lb lbcfv1.TargetLoadBalancer, driver *v1beta1.LoadBalancerDriver, curStatus lbcfv1.TargetLoadBalancerStatus) {
 			defer wg.Done()
 			rsp, err := c.createLB(bind, lb, driver)
 			op := &lbOperation{
-				lbName:       lb.Name,
-				lbDriver:     lb.Driver,
-				lbSpec:       lb.Spec,
-				lbAttributes: lb.Attributes,
-				opType:       operationCreate,
-				err:          err,
-				createRsp:    rsp,
+				lbName:                lb.Name,
+				lbDriver:              lb.Driver,
+				lbSpec:                lb.Spec,
+				lbAttributes:          lb.Attributes,
+				statusBeforeOperation: curStatus,
+				maxOperationDuration:  util.GetMaxOperationDuration(driver, webhooks.CreateLoadBalancer),
+				maxAttempts:           maxAttempts(bind, driver),
+				opType:                operationCreate,
+				err:                   err,
+				createRsp:             rsp,
 			}
 			result.Store(lb.Name, op)
-		}(lb, driver)
+		}(lb, driver, statusMap[lb.Name])
 	}
 	for _, lb := range lbNeedEnsure {
 		driver := c.getDriver(bind, lb.Name, lb.Driver)
@@ -216,6 +351,8 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 				lbSpec:                lb.Spec,
 				lbAttributes:          lb.Attributes,
 				statusBeforeOperation: curStatus,
+				maxOperationDuration:  util.GetMaxOperationDuration(driver, webhooks.EnsureLoadBalancer),
+				maxAttempts:           maxAttempts(bind, driver),
 				opType:                operationEnsure,
 				err:                   err,
 				ensureRsp:             rsp,
@@ -239,6 +376,8 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 				lbSpec:                status.LBInfo,
 				lbAttributes:          status.LastSyncedAttributes,
 				statusBeforeOperation: status,
+				maxOperationDuration:  util.GetMaxOperationDuration(driver, webhooks.DeleteLoadBalancer),
+				maxAttempts:           maxAttempts(bind, driver),
 				opType:                operationDelete,
 				err:                   err,
 				deleteRsp:             rsp,
@@ -256,10 +395,24 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 	var newStatuses, needDeleteStatuses []lbcfv1.TargetLoadBalancerStatus
 	result.Range(func(key, value interface{}) bool {
 		op := value.(*lbOperation)
+		if op.timeout() {
+			metrics.OperationTimeoutsInc(op.lbDriver, op.webhookName())
+			c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "OperationTimeout", "%s", op.timeoutMessage())
+		}
+		// recordAttempt counts the failed attempts in sts, and stops retrying once the retry limit is exceeded
+		recordAttempt := func(sts *lbcfv1.TargetLoadBalancerStatus, reCheck bool) bool {
+			if op.recordAttempt(sts, bind.Generation, resetRetry) {
+				c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "RetryLimitExceeded",
+					"load balancer %s failed %d times, last error: %s", op.lbName, op.maxAttempts, op.failReason())
+				return false
+			}
+			return reCheck
+		}
 		switch op.opType {
 		case operationCreate:
 			sts, reCheck := op.parseCreateResult()
 			if sts != nil {
+				reCheck = recordAttempt(sts, reCheck)
 				newStatuses = append(newStatuses, *sts)
 			}
 			if reCheck {
@@ -268,6 +421,7 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 		case operationEnsure:
 			sts, reCheck := op.parseEnsureResult()
 			if sts != nil {
+				reCheck = recordAttempt(sts, reCheck)
 				newStatuses = append(newStatuses, *sts)
 			}
 			if reCheck {
@@ -276,6 +430,7 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 		case operationDelete:
 			newSts, needDeleteSts, reCheck := op.parseDeleteResult()
 			if newSts != nil {
+				reCheck = recordAttempt(newSts, reCheck)
 				newStatuses = append(newStatuses, *newSts)
 			} else if needDeleteSts != nil {
 				needDeleteStatuses = append(needDeleteStatuses, *needDeleteSts)
@@ -291,7 +446,7 @@ func (c *Controller) handleLoadBalancer(bind *lbcfv1.Bind) (needResync bool) {
 	mergedStatus := mergeStatus(bind.Status.LoadBalancerStatuses, newStatuses, needDeleteStatuses)
 	cpy := bind.DeepCopy()
 	cpy.Status.LoadBalancerStatuses = mergedStatus
-	if _, err := c.client.LbcfV1().Binds(bind.Namespace).UpdateStatus(cpy); err != nil {
+	if err := c.updateStatus(bind, cpy); err != nil {
 		klog.Errorf("update status of Bind %s/%s failed: %v", bind.Namespace, bind.Name, err)
 		return true
 	}
@@ -325,15 +480,15 @@ func (c *Controller) handleBackends(bind *lbcfv1.Bind) (needResync bool) {
 		return true
 	}
 
-	var expected []*v1beta1.BackendRecord
-	doNotDelete := sets.NewString()
-	var err error
-	expected, doNotDelete, err = c.expectedPodBackends(bind)
+	expected, doNotDelete, err := c.expectedBackends(bind)
 	if err != nil {
 		klog.Errorf("handle Bind %s/%s failed: %v", bind.Namespace, bind.Name, err)
 		c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "HandleBackendFailed", err.Error())
 		return true
 	}
+	for _, e := range expected {
+		util.SetRetryAt(e, bind.Annotations[lbcfv1.AnnotationRetryAt])
+	}
 	existBackendRecords, err := c.brLister.BackendRecords(bind.Namespace).List(labels.SelectorFromSet(map[string]string{
 		lbcfv1.LabelBindName: bind.Name,
 	}))
@@ -350,19 +505,13 @@ func (c *Controller) handleBackends(bind *lbcfv1.Bind) (needResync bool) {
 	if klog.V(3) {
 		var needCreateInfo, needUpdateInfo, needDeleteInfo []string
 		for _, e := range needCreate {
-			needCreateInfo = append(needCreateInfo, fmt.Sprintf("\t[pod]%s/%s:%s/%d --> [lb]%s\n",
-				e.Namespace, e.Spec.PodBackendInfo.Name, e.Spec.PodBackendInfo.Port.Protocol, e.Spec.PodBackendInfo.Port.Port,
-				e.Labels[lbcfv1.LabelLBName]))
+			needCreateInfo = append(needCreateInfo, describeBackendRecord(e))
 		}
 		for _, e := range needUpdate {
-			needUpdateInfo = append(needUpdateInfo, fmt.Sprintf("\t[pod]%s/%s:%s/%d --> [lb]%s\n",
-				e.Namespace, e.Spec.PodBackendInfo.Name, e.Spec.PodBackendInfo.Port.Protocol, e.Spec.PodBackendInfo.Port.Port,
-				e.Labels[lbcfv1.LabelLBName]))
+			needUpdateInfo = append(needUpdateInfo, describeBackendRecord(e))
 		}
 		for _, e := range needDelete {
-			needDeleteInfo = append(needDeleteInfo, fmt.Sprintf("\t[pod]%s/%s:%s/%d --> [lb]%s\n",
-				e.Namespace, e.Spec.PodBackendInfo.Name, e.Spec.PodBackendInfo.Port.Protocol, e.Spec.PodBackendInfo.Port.Port,
-				e.Labels[lbcfv1.LabelLBName]))
+			needDeleteInfo = append(needDeleteInfo, describeBackendRecord(e))
 		}
 		klog.V(3).Infof("Bind %s/%s need create following BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(needCreateInfo, ""))
 		klog.V(3).Infof("Bind %s/%s need update following BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(needUpdateInfo, ""))
@@ -390,7 +539,8 @@ func (c *Controller) handleBackends(bind *lbcfv1.Bind) (needResync bool) {
 	return len(errs) > 0
 }
 
-func (c *Controller) expectedPodBackends(bind *lbcfv1.Bind) (expected []*v1beta1.BackendRecord, doNotDelete sets.String, err error) {
+// expectedBackends returns the BackendRecords expected by bind, BackendRecords in doNotDelete are kept even if not expected
+func (c *Controller) expectedBackends(bind *lbcfv1.Bind) (expected []*v1beta1.BackendRecord, doNotDelete sets.String, err error) {
 	var createdLB []lbcfv1.TargetLoadBalancer
 	statusMap := make(map[string]lbcfv1.TargetLoadBalancerStatus)
 	for _, status := range bind.Status.LoadBalancerStatuses {
@@ -406,9 +556,150 @@ func (c *Controller) expectedPodBackends(bind *lbcfv1.Bind) (expected []*v1beta1
 		}
 	}
 
+	doNotDelete = sets.NewString()
+	if bind.Spec.Pods != nil {
+		expected, doNotDelete, err = c.expectedPodBackends(bind, createdLB, statusMap)
+	} else if bind.Spec.Service != nil {
+		expected, err = c.expectedServiceBackends(bind, createdLB, statusMap)
+	} else {
+		expected = expectedStaticBackends(bind, createdLB, statusMap)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if klog.V(3) {
+		var info []string
+		for _, e := range expected {
+			info = append(info, describeBackendRecord(e))
+		}
+		klog.Infof("Bind %s/%s expecting following BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(info, ""))
+		klog.Infof("Bind %s/%s expecting following doNotDelete BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(doNotDelete.List(), ""))
+	}
+	return expected, doNotDelete, nil
+}
+
+func (c *Controller) expectedPodBackends(
+	bind *lbcfv1.Bind,
+	createdLB []lbcfv1.TargetLoadBalancer,
+	statusMap map[string]lbcfv1.TargetLoadBalancerStatus) (expected []*v1beta1.BackendRecord, doNotDelete sets.String, err error) {
+	doNotDelete = sets.NewString()
+	for _, cluster := range util.PodClusters(bind.Spec.Pods.Clusters) {
+		podLister, err := util.ClusterPodLister(c.podLister, c.memberPodListers, cluster)
+		if err != nil {
+			// Pods of an unavailable member cluster are unknown, its BackendRecords are kept while other clusters are synced
+			c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "ListPodFailed", err.Error())
+			existing, err := c.brLister.BackendRecords(bind.Namespace).List(labels.SelectorFromSet(map[string]string{
+				lbcfv1.LabelBindName: bind.Name,
+			}))
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, r := range util.ClusterBackendRecords(existing, cluster) {
+				doNotDelete.Insert(r.Name)
+			}
+			continue
+		}
+		pods, err := c.selectPods(bind, podLister)
+		if err != nil {
+			return nil, nil, err
+		}
+		var readyPods, podsDoNotDelete []*apicorev1.Pod
+		for _, pod := range pods {
+			if util.PodAvailable(pod) {
+				readyPods = append(readyPods, pod)
+			} else if bindutil.DeregIfNotRunning(bind) {
+				if util.PodAvailableByRunning(pod) {
+					podsDoNotDelete = append(podsDoNotDelete, pod)
+				}
+			} else if bindutil.DeregByWebhook(bind) {
+				// todo
+			}
+		}
+
+		for _, lb := range createdLB {
+			lbStatus := statusMap[lb.Name]
+			for _, pod := range readyPods {
+				expected = append(expected, generateBackendRecord(bind, lb, lbStatus, pod, cluster)...)
+			}
+			for _, pod := range podsDoNotDelete {
+				for _, port := range bind.Spec.Pods.Ports {
+					doNotDelete.Insert(getPodBRName(bind, lb, pod, port, cluster))
+				}
+			}
+		}
+	}
+	return expected, doNotDelete, nil
+}
+
+// expectedServiceBackends returns BackendRecords of the NodePort of bind.spec.service on each selected Node
+func (c *Controller) expectedServiceBackends(
+	bind *lbcfv1.Bind,
+	createdLB []lbcfv1.TargetLoadBalancer,
+	statusMap map[string]lbcfv1.TargetLoadBalancerStatus) ([]*v1beta1.BackendRecord, error) {
+	nodes, err := c.nodeLister.List(labels.SelectorFromSet(bind.Spec.Service.NodeSelector))
+	if err != nil {
+		c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "ListNodeFailed", fmt.Sprintf("list nodes failed: %v", err))
+		return nil, err
+	}
+	svc, err := c.serviceLister.Services(bind.Namespace).Get(bind.Spec.Service.Name)
+	if errors.IsNotFound(err) {
+		klog.Infof("service %s/%s selected by Bind %s/%s doesn't exist, skipped",
+			bind.Namespace, bind.Spec.Service.Name, bind.Namespace, bind.Name)
+		return nil, nil
+	} else if err != nil {
+		c.eventRecorder.Eventf(bind, apicorev1.EventTypeWarning, "GetServiceFailed", fmt.Sprintf("get service failed: %v", err))
+		return nil, err
+	}
+	if svc.DeletionTimestamp != nil || svc.Spec.Type != apicorev1.ServiceTypeNodePort {
+		return nil, nil
+	}
+	svcPort := selectServicePort(svc, bind.Spec.Service.Port)
+	if svcPort == nil {
+		klog.Infof("servicePort not found in svc %s/%s. looking for: %d/%s",
+			svc.Namespace, svc.Name, bind.Spec.Service.Port.Port, bind.Spec.Service.Port.Protocol)
+		return nil, nil
+	}
+	var expected []*v1beta1.BackendRecord
+	for _, lb := range createdLB {
+		for _, node := range nodes {
+			expected = append(expected, generateServiceBackendRecord(bind, lb, statusMap[lb.Name], svc, svcPort.NodePort, node))
+		}
+	}
+	return expected, nil
+}
+
+// expectedStaticBackends returns BackendRecords of each address in bind.spec.static
+func expectedStaticBackends(
+	bind *lbcfv1.Bind,
+	createdLB []lbcfv1.TargetLoadBalancer,
+	statusMap map[string]lbcfv1.TargetLoadBalancerStatus) []*v1beta1.BackendRecord {
+	var expected []*v1beta1.BackendRecord
+	for _, lb := range createdLB {
+		for _, addr := range bind.Spec.Static {
+			expected = append(expected, generateStaticBackendRecord(bind, lb, statusMap[lb.Name], addr))
+		}
+	}
+	return expected
+}
+
+// selectServicePort returns the port of svc selected by port, nil is returned if not found or no NodePort is allocated
+func selectServicePort(svc *apicorev1.Service, port lbcfv1.PortSelector) *apicorev1.ServicePort {
+	for i, svcPort := range svc.Spec.Ports {
+		if svcPort.Port == port.Port && strings.EqualFold(string(svcPort.Protocol), port.Protocol) {
+			if svcPort.NodePort == 0 {
+				return nil
+			}
+			return &svc.Spec.Ports[i]
+		}
+	}
+	return nil
+}
+
+// selectPods returns Pods selected by bind in podLister
+func (c *Controller) selectPods(bind *lbcfv1.Bind, podLister corev1.PodLister) ([]*apicorev1.Pod, error) {
 	var pods []*apicorev1.Pod
 	if bind.Spec.Pods.ByLabel != nil {
-		podList, err := c.podLister.Pods(bind.Namespace).List(labels.SelectorFromSet(bind.Spec.Pods.ByLabel.Selector))
+		podList, err := podLister.Pods(bind.Namespace).List(labels.SelectorFromSet(bind.Spec.Pods.ByLabel.Selector))
 		if err != nil {
 			klog.Errorf("list pods for Bind %s/%s failed: %v", bind.Namespace, bind.Name, err)
 			c.eventRecorder.Eventf(
@@ -416,7 +707,7 @@ func (c *Controller) expectedPodBackends(bind *lbcfv1.Bind) (expected []*v1beta1
 				apicorev1.EventTypeWarning,
 				"ListPodFailed",
 				fmt.Sprintf("list pods failed: %v", err))
-			return nil, nil, err
+			return nil, err
 		}
 		except := sets.NewString(bind.Spec.Pods.ByLabel.Except...)
 		for _, pod := range podList {
@@ -426,7 +717,7 @@ func (c *Controller) expectedPodBackends(bind *lbcfv1.Bind) (expected []*v1beta1
 		}
 	} else {
 		for _, expect := range bind.Spec.Pods.ByName {
-			pod, err := c.podLister.Pods(bind.Namespace).Get(expect)
+			pod, err := podLister.Pods(bind.Namespace).Get(expect)
 			if err != nil {
 				if errors.IsNotFound(err) {
 					klog.Infof("pod %s/%s selected by Bind %s/%s doesn't exist, skipped",
@@ -445,50 +736,7 @@ func (c *Controller) expectedPodBackends(bind *lbcfv1.Bind) (expected []*v1beta1
 			pods = append(pods, pod)
 		}
 	}
-	var readyPods, podsDoNotDelete []*apicorev1.Pod
-	for _, pod := range pods {
-		if util.PodAvailable(pod) {
-			readyPods = append(readyPods, pod)
-		} else if bindutil.DeregIfNotRunning(bind) {
-			if util.PodAvailableByRunning(pod) {
-				podsDoNotDelete = append(podsDoNotDelete, pod)
-			}
-		} else if bindutil.DeregByWebhook(bind) {
-			// todo
-		}
-	}
-
-	doNotDelete = sets.NewString()
-	for _, lb := range createdLB {
-		lbStatus := statusMap[lb.Name]
-		for _, pod := range readyPods {
-			expected = append(expected, generateBackendRecord(bind, lb, lbStatus, pod)...)
-		}
-		for _, pod := range podsDoNotDelete {
-			for _, port := range bind.Spec.Pods.Ports {
-				brName := getBRName(
-					bind.Name,
-					lb.Name,
-					pod.Name,
-					pod.Status.PodIP,
-					port.Protocol,
-					fmt.Sprintf("%d", port.Port))
-				doNotDelete.Insert(brName)
-			}
-		}
-	}
-	if klog.V(3) {
-		var info []string
-		for _, e := range expected {
-			info = append(info, fmt.Sprintf("\t[pod]%s/%s:%s/%d --> [lb]%s\n",
-				e.Namespace, e.Spec.PodBackendInfo.Name, e.Spec.PodBackendInfo.Port.Protocol, e.Spec.PodBackendInfo.Port.Port,
-				e.Labels[lbcfv1.LabelLBName]))
-		}
-		klog.Infof("Bind %s/%s expecting following BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(info, ""))
-		klog.Infof("Bind %s/%s expecting following doNotDelete BackendRecord:\n%s", bind.Namespace, bind.Name, strings.Join(doNotDelete.List(), ""))
-	}
-	return expected, doNotDelete, nil
-
+	return pods, nil
 }
 
 func (c *Controller) createBackendRecord(record *v1beta1.BackendRecord) error {
@@ -527,6 +775,7 @@ func (c *Controller) updateBackendRecord(record *v1beta1.BackendRecord) error {
 	start := time.Now()
 	_, err := c.client.LbcfV1beta1().BackendRecords(record.Namespace).Update(record)
 	metrics.K8SOpLatencyObserve("BackendRecord", metrics.OpUpdate, time.Since(start))
+	util.ObserveK8SWriteConflict("BackendRecord", metrics.OpUpdate, err)
 	if err != nil {
 		return fmt.Errorf("update BackendRecord %s/%s failed: %v", record.Namespace, record.Name, err)
 	}
@@ -651,11 +900,39 @@ func (c *Controller) deleteLB(
 	return c.webhookInvoker.CallDeleteLoadBalancer(driver, req)
 }
 
+// recordRetryAt records the processed value of annotation lbcf.tkestack.io/retry-at in status
+func (c *Controller) recordRetryAt(bind *lbcfv1.Bind) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := c.client.LbcfV1().Binds(bind.Namespace).Get(bind.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		cpy := latest.DeepCopy()
+		cpy.Status.LastRetryAt = cpy.Annotations[lbcfv1.AnnotationRetryAt]
+		return c.updateStatus(latest, cpy)
+	})
+}
+
 func (c *Controller) removeFinalizer(bind *lbcfv1.Bind) error {
-	cpy := bind.DeepCopy()
-	cpy.Finalizers = util.RemoveFinalizer(cpy.Finalizers, lbcfv1.FinalizerDeleteLB)
-	_, err := c.client.LbcfV1().Binds(cpy.Namespace).Update(cpy)
-	return err
+	return util.PatchRemoveFinalizer("Bind", bind.Finalizers, lbcfv1.FinalizerDeleteLB, func(pt types.PatchType, data []byte) error {
+		_, err := c.client.LbcfV1().Binds(bind.Namespace).Patch(bind.Name, pt, data)
+		return err
+	})
+}
+
+// updateStatus sends the changes from old.Status to cur.Status as a merge patch guarded by the resourceVersion of old,
+// a conflict is retried against the latest Bind only if its status is not modified concurrently
+func (c *Controller) updateStatus(old, cur *lbcfv1.Bind) error {
+	return util.PatchStatus("Bind", old.ResourceVersion, old.Status, cur.Status, func(pt types.PatchType, data []byte) error {
+		_, err := c.client.LbcfV1().Binds(cur.Namespace).Patch(cur.Name, pt, data, "status")
+		return err
+	}, func() (string, interface{}, error) {
+		latest, err := c.client.LbcfV1().Binds(cur.Namespace).Get(cur.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		return latest.ResourceVersion, latest.Status, nil
+	})
 }
 
 type operationType int
@@ -672,6 +949,8 @@ type lbOperation struct {
 	lbSpec                map[string]string
 	lbAttributes          map[string]string
 	statusBeforeOperation lbcfv1.TargetLoadBalancerStatus
+	maxOperationDuration  time.Duration
+	maxAttempts           int32
 	opType                operationType
 	err                   error
 	createRsp             *webhooks.CreateLoadBalancerResponse
@@ -679,6 +958,121 @@ type lbOperation struct {
 	deleteRsp             *webhooks.DeleteLoadBalancerResponse
 }
 
+func (op lbOperation) webhookName() string {
+	switch op.opType {
+	case operationCreate:
+		return webhooks.CreateLoadBalancer
+	case operationEnsure:
+		return webhooks.EnsureLoadBalancer
+	case operationDelete:
+		return webhooks.DeleteLoadBalancer
+	}
+	return ""
+}
+
+func (op lbOperation) responseStatus() string {
+	switch op.opType {
+	case operationCreate:
+		if op.createRsp != nil {
+			return op.createRsp.Status
+		}
+	case operationEnsure:
+		if op.ensureRsp != nil {
+			return op.ensureRsp.Status
+		}
+	case operationDelete:
+		if op.deleteRsp != nil {
+			return op.deleteRsp.Status
+		}
+	}
+	return ""
+}
+
+// timeout returns true if the webhook is still running after maxOperationDuration
+func (op lbOperation) timeout() bool {
+	if op.err != nil || op.responseStatus() != webhooks.StatusRunning {
+		return false
+	}
+	running := op.statusBeforeOperation.RunningOperation
+	return running != nil &&
+		running.Webhook == op.webhookName() &&
+		util.IsOperationTimeout(running.StartTime.Time, op.maxOperationDuration)
+}
+
+// failed returns true if the webhook failed or timed out
+func (op lbOperation) failed() bool {
+	return op.err != nil || op.responseStatus() == webhooks.StatusFail || op.timeout()
+}
+
+// failReason returns the error of a failed operation
+func (op lbOperation) failReason() string {
+	if op.err != nil {
+		return op.err.Error()
+	} else if op.timeout() {
+		return op.timeoutMessage()
+	}
+	switch op.opType {
+	case operationCreate:
+		return op.createRsp.Msg
+	case operationEnsure:
+		return op.ensureRsp.Msg
+	case operationDelete:
+		return op.deleteRsp.Msg
+	}
+	return ""
+}
+
+// recordAttempt records the failed attempts of the operation in sts,
+// and marks the load balancer as Failed once the failed attempts reach maxAttempts
+func (op lbOperation) recordAttempt(sts *lbcfv1.TargetLoadBalancerStatus, generation int64, resetRetry bool) (exceeded bool) {
+	before := op.statusBeforeOperation
+	if !op.failed() {
+		if op.responseStatus() != webhooks.StatusSucc {
+			sts.FailedAttempts = before.FailedAttempts
+			sts.ObservedGeneration = before.ObservedGeneration
+			return false
+		}
+		sts.FailedAttempts = 0
+		sts.ObservedGeneration = generation
+	} else {
+		reset := resetRetry || before.ObservedGeneration != generation
+		sts.FailedAttempts, exceeded = util.NextFailedAttempts(before.FailedAttempts, reset, op.maxAttempts)
+		sts.ObservedGeneration = generation
+	}
+	cond := lbcfv1.TargetLoadBalancerCondition{
+		Type:               lbcfv1.LBFailed,
+		Status:             lbcfv1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+	}
+	if exceeded {
+		cond.Status = lbcfv1.ConditionTrue
+		cond.Reason = lbcfv1.ReasonRetryLimitExceeded
+		cond.Message = op.failReason()
+		sts.RetryAfter = metav1.Time{}
+	}
+	if exceeded || bindutil.IsLoadBalancerFailed(*sts) {
+		sts.Conditions = bindutil.AddOrUpdateLBCondition(sts.Conditions, cond)
+	}
+	return exceeded
+}
+
+func (op lbOperation) timeoutMessage() string {
+	return fmt.Sprintf("webhook %s for load balancer %s is still running after %s",
+		op.webhookName(), op.lbName, op.maxOperationDuration)
+}
+
+// runningOperation returns the RunningOperation that should be recorded in status when the webhook is running
+func (op lbOperation) runningOperation() *lbcfv1.RunningOperation {
+	running := op.statusBeforeOperation.RunningOperation
+	if running != nil && running.Webhook == op.webhookName() {
+		return running.DeepCopy()
+	}
+	return &lbcfv1.RunningOperation{
+		Webhook:   op.webhookName(),
+		StartTime: metav1.Now(),
+	}
+}
+
 func (op lbOperation) parseCreateResult() (*lbcfv1.TargetLoadBalancerStatus, bool) {
 	if op.opType != operationCreate {
 		return nil, false
@@ -743,10 +1137,27 @@ func (op lbOperation) parseCreateResult() (*lbcfv1.TargetLoadBalancerStatus, boo
 		}, true
 	case webhooks.StatusRunning:
 		interval := util.CalculateRetryInterval(op.createRsp.MinRetryDelayInSeconds)
+		if op.timeout() {
+			return &lbcfv1.TargetLoadBalancerStatus{
+				Name:       op.lbName,
+				Driver:     op.lbDriver,
+				RetryAfter: metav1.NewTime(time.Now().Add(interval)),
+				Conditions: []lbcfv1.TargetLoadBalancerCondition{
+					{
+						Type:               lbcfv1.LBCreated,
+						Status:             lbcfv1.ConditionFalse,
+						LastTransitionTime: metav1.Now(),
+						Reason:             lbcfv1.ReasonTimeout,
+						Message:            op.timeoutMessage(),
+					},
+				},
+			}, true
+		}
 		return &lbcfv1.TargetLoadBalancerStatus{
-			Name:       op.lbName,
-			Driver:     op.lbDriver,
-			RetryAfter: metav1.NewTime(time.Now().Add(interval)),
+			Name:             op.lbName,
+			Driver:           op.lbDriver,
+			RetryAfter:       metav1.NewTime(time.Now().Add(interval)),
+			RunningOperation: op.runningOperation(),
 			Conditions: []lbcfv1.TargetLoadBalancerCondition{
 				{
 					Type:               lbcfv1.LBCreated,
@@ -784,6 +1195,7 @@ func (op lbOperation) parseEnsureResult() (*lbcfv1.TargetLoadBalancerStatus, boo
 	case webhooks.StatusSucc:
 		newLBStatus.LastSyncedAttributes = op.lbAttributes
 		newLBStatus.RetryAfter = metav1.Time{}
+		newLBStatus.RunningOperation = nil
 		newLBStatus.Conditions = bindutil.AddOrUpdateLBCondition(newLBStatus.Conditions, lbcfv1.TargetLoadBalancerCondition{
 			Type:               lbcfv1.LBReady,
 			Status:             lbcfv1.ConditionTrue,
@@ -792,6 +1204,7 @@ func (op lbOperation) parseEnsureResult() (*lbcfv1.TargetLoadBalancerStatus, boo
 	case webhooks.StatusFail:
 		interval := util.CalculateRetryInterval(op.ensureRsp.MinRetryDelayInSeconds)
 		newLBStatus.RetryAfter = metav1.NewTime(time.Now().Add(interval))
+		newLBStatus.RunningOperation = nil
 		newLBStatus.Conditions = bindutil.AddOrUpdateLBCondition(newLBStatus.Conditions, lbcfv1.TargetLoadBalancerCondition{
 			Type:               lbcfv1.LBReady,
 			Status:             lbcfv1.ConditionFalse,
@@ -803,6 +1216,19 @@ func (op lbOperation) parseEnsureResult() (*lbcfv1.TargetLoadBalancerStatus, boo
 	case webhooks.StatusRunning:
 		interval := util.CalculateRetryInterval(op.ensureRsp.MinRetryDelayInSeconds)
 		newLBStatus.RetryAfter = metav1.NewTime(time.Now().Add(interval))
+		if op.timeout() {
+			newLBStatus.RunningOperation = nil
+			newLBStatus.Conditions = bindutil.AddOrUpdateLBCondition(newLBStatus.Conditions, lbcfv1.TargetLoadBalancerCondition{
+				Type:               lbcfv1.LBReady,
+				Status:             lbcfv1.ConditionFalse,
+				LastTransitionTime: metav1.Now(),
+				Reason:             lbcfv1.ReasonTimeout,
+				Message:            op.timeoutMessage(),
+			})
+			needRecheck = true
+			break
+		}
+		newLBStatus.RunningOperation = op.runningOperation()
 		if !bindutil.IsLoadBalancerReady(op.statusBeforeOperation) {
 			newLBStatus.Conditions = bindutil.AddOrUpdateLBCondition(newLBStatus.Conditions, lbcfv1.TargetLoadBalancerCondition{
 				Type:               lbcfv1.LBReady,
@@ -829,15 +1255,34 @@ func (op lbOperation) parseDeleteResult() (newStatus, needDelete *lbcfv1.TargetL
 	case webhooks.StatusSucc:
 		return nil, sts, false
 	case webhooks.StatusFail:
-		interval := util.CalculateRetryInterval(op.ensureRsp.MinRetryDelayInSeconds)
+		interval := util.CalculateRetryInterval(op.deleteRsp.MinRetryDelayInSeconds)
 		sts.RetryAfter = metav1.NewTime(time.Now().Add(interval))
+		sts.RunningOperation = nil
 		return sts, nil, true
 	case webhooks.StatusRunning:
-		return nil, nil, true
+		if op.timeout() {
+			interval := util.CalculateRetryInterval(op.deleteRsp.MinRetryDelayInSeconds)
+			sts.RetryAfter = metav1.NewTime(time.Now().Add(interval))
+			sts.RunningOperation = nil
+		} else {
+			sts.RunningOperation = op.runningOperation()
+		}
+		return sts, nil, true
 	}
 	return nil, nil, false
 }
 
+// maxAttempts returns the number of failed attempts allowed before a load balancer of bind is marked as Failed
+func maxAttempts(bind *lbcfv1.Bind, driver *v1beta1.LoadBalancerDriver) int32 {
+	return util.GetMaxAttempts(bindutil.ConvertEnsurePolicy(bind.Spec.EnsurePolicy), driver)
+}
+
+// retryExhausted returns true if the load balancer is Failed and is not retried until the Bind is modified
+// or annotation lbcf.tkestack.io/retry-at is changed
+func retryExhausted(bind *lbcfv1.Bind, status lbcfv1.TargetLoadBalancerStatus, resetRetry bool) bool {
+	return !resetRetry && bindutil.IsLoadBalancerFailed(status) && status.ObservedGeneration == bind.Generation
+}
+
 func mergeStatus(oldStatus, newStatus, deletedStatus []lbcfv1.TargetLoadBalancerStatus) []lbcfv1.TargetLoadBalancerStatus {
 	merged := make(map[string]lbcfv1.TargetLoadBalancerStatus)
 	for _, status := range oldStatus {
@@ -860,53 +1305,135 @@ func generateBackendRecord(
 	bind *lbcfv1.Bind,
 	lb lbcfv1.TargetLoadBalancer,
 	lbStatus lbcfv1.TargetLoadBalancerStatus,
-	pod *apicorev1.Pod) []*v1beta1.BackendRecord {
-	valueTrue := true
+	pod *apicorev1.Pod,
+	cluster string) []*v1beta1.BackendRecord {
 	var ret []*v1beta1.BackendRecord
 	for _, port := range bind.Spec.Pods.Ports {
-		ret = append(ret, &v1beta1.BackendRecord{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: getBRName(
-					bind.Name,
-					lb.Name,
-					pod.Name,
-					pod.Status.PodIP,
-					port.Protocol,
-					fmt.Sprintf("%d", port.Port)),
-				Namespace: bind.Namespace,
-				Labels:    makeBackendLabels(lb.Driver, bind.Name, lb.Name, pod.Name),
-				Finalizers: []string{
-					lbcfv1.FinalizerDeregisterBackend,
-				},
-				OwnerReferences: []metav1.OwnerReference{
-					{
-						APIVersion:         lbcfv1.APIVersion,
-						BlockOwnerDeletion: &valueTrue,
-						Controller:         &valueTrue,
-						Kind:               "Bind",
-						Name:               bind.Name,
-						UID:                bind.UID,
-					},
-				},
+		record := newBackendRecord(bind, lb, lbStatus,
+			getPodBRName(bind, lb, pod, port, cluster),
+			MakeBackendLabels(lb.Driver, bind.Name, lb.Name, "", pod.Name))
+		record.Spec.PodBackendInfo = &v1beta1.PodBackendRecord{
+			Name: pod.Name,
+			Port: v1beta1.PortSelector{
+				Port:     port.Port,
+				Protocol: port.Protocol,
 			},
-			Spec: v1beta1.BackendRecordSpec{
-				LBName:       lb.Name,
-				LBDriver:     lb.Driver,
-				LBInfo:       lbStatus.LBInfo,
-				LBAttributes: lbStatus.LastSyncedAttributes,
-				PodBackendInfo: &v1beta1.PodBackendRecord{
-					Name: pod.Name,
-					Port: v1beta1.PortSelector{
-						Port:     port.Port,
-						Protocol: port.Protocol,
-					},
+			Cluster: cluster,
+		}
+		record.Spec.Weight = util.GetBackendWeight(pod, bind.Spec.Pods.Weight)
+		ret = append(ret, record)
+	}
+	return ret
+}
+
+// generateServiceBackendRecord returns the BackendRecord of nodePort of svc on node
+func generateServiceBackendRecord(
+	bind *lbcfv1.Bind,
+	lb lbcfv1.TargetLoadBalancer,
+	lbStatus lbcfv1.TargetLoadBalancerStatus,
+	svc *apicorev1.Service,
+	nodePort int32,
+	node *apicorev1.Node) *v1beta1.BackendRecord {
+	port := bind.Spec.Service.Port
+	name := ServiceBackendRecordName(bind.Name, lb.Name, svc.Name, port, nodePort, node.Name)
+	record := newBackendRecord(bind, lb, lbStatus, name, MakeBackendLabels(lb.Driver, bind.Name, lb.Name, svc.Name, ""))
+	record.Spec.ServiceBackendInfo = &v1beta1.ServiceBackendRecord{
+		Name: svc.Name,
+		Port: v1beta1.PortSelector{
+			Port:     port.Port,
+			Protocol: port.Protocol,
+		},
+		NodePort: nodePort,
+		NodeName: node.Name,
+	}
+	record.Spec.Weight = bind.Spec.Service.Weight
+	return record
+}
+
+// generateStaticBackendRecord returns the BackendRecord of addr
+func generateStaticBackendRecord(
+	bind *lbcfv1.Bind,
+	lb lbcfv1.TargetLoadBalancer,
+	lbStatus lbcfv1.TargetLoadBalancerStatus,
+	addr string) *v1beta1.BackendRecord {
+	name := StaticBackendRecordName(bind.Name, lb.Name, addr)
+	record := newBackendRecord(bind, lb, lbStatus, name, MakeBackendLabels(lb.Driver, bind.Name, lb.Name, "", ""))
+	staticAddr := addr
+	record.Spec.StaticAddr = &staticAddr
+	return record
+}
+
+// newBackendRecord returns a BackendRecord of bind without backend info
+func newBackendRecord(
+	bind *lbcfv1.Bind,
+	lb lbcfv1.TargetLoadBalancer,
+	lbStatus lbcfv1.TargetLoadBalancerStatus,
+	name string,
+	labels map[string]string) *v1beta1.BackendRecord {
+	valueTrue := true
+	return &v1beta1.BackendRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: bind.Namespace,
+			Labels:    labels,
+			Finalizers: []string{
+				lbcfv1.FinalizerDeregisterBackend,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         lbcfv1.APIVersion,
+					BlockOwnerDeletion: &valueTrue,
+					Controller:         &valueTrue,
+					Kind:               "Bind",
+					Name:               bind.Name,
+					UID:                bind.UID,
 				},
-				Parameters:   bind.Spec.Parameters,
-				EnsurePolicy: bindutil.ConvertEnsurePolicy(bind.Spec.EnsurePolicy),
 			},
-		})
+		},
+		Spec: v1beta1.BackendRecordSpec{
+			LBName:       lb.Name,
+			LBDriver:     lb.Driver,
+			LBInfo:       lbStatus.LBInfo,
+			LBAttributes: lbStatus.LastSyncedAttributes,
+			Parameters:   bind.Spec.Parameters,
+			EnsurePolicy: bindutil.ConvertEnsurePolicy(bind.Spec.EnsurePolicy),
+			DrainPolicy:  bindutil.ConvertDrainPolicy(bind.Spec.DrainPolicy),
+		},
 	}
-	return ret
+}
+
+// getPodBRName returns the name of BackendRecord for pod in cluster, empty cluster means the local cluster
+func getPodBRName(bind *lbcfv1.Bind, lb lbcfv1.TargetLoadBalancer, pod *apicorev1.Pod, port lbcfv1.PortSelector, cluster string) string {
+	return PodBackendRecordName(bind.Name, lb.Name, pod.Name, pod.Status.PodIP, port, cluster)
+}
+
+// PodBackendRecordName returns the name of BackendRecord generated by Bind bindName for the Pod in cluster.
+// Pods in member clusters may have the same names and IPs as local Pods, so the cluster is part of the name.
+func PodBackendRecordName(bindName, lbName, podName, podIP string, port lbcfv1.PortSelector, cluster string) string {
+	segments := []string{
+		bindName,
+		lbName,
+		podName,
+		podIP,
+		port.Protocol,
+		fmt.Sprintf("%d", port.Port),
+	}
+	if cluster != "" {
+		segments = append(segments, cluster)
+	}
+	return getBRName(segments...)
+}
+
+// ServiceBackendRecordName returns the name of BackendRecord generated by Bind bindName for nodePort of Service on node.
+// The NodePort is part of the name, so that the old NodePort is deregistered if it is changed.
+func ServiceBackendRecordName(bindName, lbName, svcName string, port lbcfv1.PortSelector, nodePort int32, nodeName string) string {
+	return getBRName(bindName, lbName, "service", svcName, port.Protocol, fmt.Sprintf("%d", port.Port),
+		fmt.Sprintf("%d", nodePort), nodeName)
+}
+
+// StaticBackendRecordName returns the name of BackendRecord generated by Bind bindName for addr
+func StaticBackendRecordName(bindName, lbName, addr string) string {
+	return getBRName(bindName, lbName, "static", addr)
 }
 
 func getBRName(segments ...string) string {
@@ -915,16 +1442,36 @@ func getBRName(segments ...string) string {
 	return fmt.Sprintf("%x", h)
 }
 
-// makeBackendLabels generates labels for BackendRecord
-func makeBackendLabels(driverName, bindName, lbName, podName string) map[string]string {
+// MakeBackendLabels generates labels for BackendRecord of Bind
+func MakeBackendLabels(driverName, bindName, lbName, svcName, podName string) map[string]string {
 	ret := make(map[string]string)
 	ret[lbcfv1.LabelDriverName] = driverName
 	ret[lbcfv1.LabelBindName] = bindName
 	ret[lbcfv1.LabelLBName] = lbName
-	ret[lbcfv1.LabelPodName] = podName
+	if podName != "" {
+		ret[lbcfv1.LabelPodName] = podName
+	}
+	if svcName != "" {
+		ret[lbcfv1.LabelServiceName] = svcName
+	}
 	return ret
 }
 
+// describeBackendRecord returns a line describing the backend and load balancer of record for logging
+func describeBackendRecord(record *v1beta1.BackendRecord) string {
+	lbName := record.Labels[lbcfv1.LabelLBName]
+	if info := record.Spec.PodBackendInfo; info != nil {
+		return fmt.Sprintf("\t[pod]%s/%s:%s/%d --> [lb]%s\n",
+			record.Namespace, info.Name, info.Port.Protocol, info.Port.Port, lbName)
+	} else if info := record.Spec.ServiceBackendInfo; info != nil {
+		return fmt.Sprintf("\t[service]%s/%s:%s/%d [node]%s:%d --> [lb]%s\n",
+			record.Namespace, info.Name, info.Port.Protocol, info.Port.Port, info.NodeName, info.NodePort, lbName)
+	} else if record.Spec.StaticAddr != nil {
+		return fmt.Sprintf("\t[static]%s --> [lb]%s\n", *record.Spec.StaticAddr, lbName)
+	}
+	return fmt.Sprintf("\t[unknown]%s/%s --> [lb]%s\n", record.Namespace, record.Name, lbName)
+}
+
 // compareBackendRecords compares expect with have and returns actions should be taken to meet the expect.
 //
 // The actions are return in 3 BackendRecord slices:
@@ -934,6 +1481,8 @@ func makeBackendLabels(driverName, bindName, lbName, podName string) map[string]
 // needUpdate: BackendsReocrds in this slice already exist in K8S and should be update to k8s
 //
 // needDelete: BackendsRecords in this slice should be deleted from k8s
+//
+// BackendRecords adopted from BackendGroups are matched by annotation lbcf.tkestack.io/bind-record-name
 func compareBackendRecords(
 	expect []*v1beta1.BackendRecord,
 	have []*v1beta1.BackendRecord,
@@ -943,27 +1492,38 @@ func compareBackendRecords(
 		expectedRecords[e.Name] = e
 	}
 	haveRecords := make(map[string]*v1beta1.BackendRecord)
+	adoptedRecords := make(map[string]*v1beta1.BackendRecord)
 	for _, h := range have {
 		haveRecords[h.Name] = h
+		if name := h.Annotations[lbcfv1.AnnotationBindRecordName]; name != "" {
+			adoptedRecords[name] = h
+		}
 	}
+	matched := sets.NewString()
 	for k, expect := range expectedRecords {
 		have, ok := haveRecords[k]
+		if !ok {
+			have, ok = adoptedRecords[k]
+		}
 		if !ok {
 			needCreate = append(needCreate, expect)
 			continue
 		}
+		matched.Insert(have.Name)
 		//if !util.EqualStringMap(v.Spec.Parameters, cur.Spec.Parameters) {
 		if bindutil.NeedUpdateRecord(have, expect) {
 			update := have.DeepCopy()
 			update.Spec = expect.Spec
+			util.SetRetryAt(update, expect.Annotations[v1beta1.AnnotationRetryAt])
 			needUpdate = append(needUpdate, update)
 		}
 	}
 	for k, v := range haveRecords {
-		if _, ok := expectedRecords[k]; !ok {
-			if !doNotDelete.Has(v.Name) {
-				needDelete = append(needDelete, v)
-			}
+		if matched.Has(k) {
+			continue
+		}
+		if !doNotDelete.Has(v.Name) && !doNotDelete.Has(v.Annotations[lbcfv1.AnnotationBindRecordName]) {
+			needDelete = append(needDelete, v)
 		}
 	}
 	return
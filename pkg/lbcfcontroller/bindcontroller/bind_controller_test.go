@@ -0,0 +1,167 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package bindcontroller
+
+import (
+	"testing"
+
+	bindutil "tkestack.io/lb-controlling-framework/pkg/api/bind"
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
+
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLBOperationRecordAttempt(t *testing.T) {
+	failRsp := &webhooks.EnsureLoadBalancerResponse{
+		ResponseForFailRetryHooks: webhooks.ResponseForFailRetryHooks{
+			Status: webhooks.StatusFail,
+			Msg:    "fake fail",
+		},
+	}
+	succRsp := &webhooks.EnsureLoadBalancerResponse{
+		ResponseForFailRetryHooks: webhooks.ResponseForFailRetryHooks{
+			Status: webhooks.StatusSucc,
+		},
+	}
+	cases := []struct {
+		name           string
+		before         lbcfv1.TargetLoadBalancerStatus
+		rsp            *webhooks.EnsureLoadBalancerResponse
+		resetRetry     bool
+		expectAttempts int32
+		expectExceeded bool
+	}{
+		{
+			name:           "first-failure",
+			before:         lbcfv1.TargetLoadBalancerStatus{ObservedGeneration: 1},
+			rsp:            failRsp,
+			expectAttempts: 1,
+		},
+		{
+			name:           "exceeded",
+			before:         lbcfv1.TargetLoadBalancerStatus{FailedAttempts: 2, ObservedGeneration: 2},
+			rsp:            failRsp,
+			expectAttempts: 3,
+			expectExceeded: true,
+		},
+		{
+			name:           "reset-by-generation",
+			before:         lbcfv1.TargetLoadBalancerStatus{FailedAttempts: 2, ObservedGeneration: 1},
+			rsp:            failRsp,
+			expectAttempts: 1,
+		},
+		{
+			name:           "reset-by-retry-at",
+			before:         lbcfv1.TargetLoadBalancerStatus{FailedAttempts: 2, ObservedGeneration: 2},
+			rsp:            failRsp,
+			resetRetry:     true,
+			expectAttempts: 1,
+		},
+		{
+			name:           "succ",
+			before:         lbcfv1.TargetLoadBalancerStatus{FailedAttempts: 2, ObservedGeneration: 2},
+			rsp:            succRsp,
+			expectAttempts: 0,
+		},
+	}
+	for _, c := range cases {
+		op := lbOperation{
+			lbName:                "lb",
+			statusBeforeOperation: c.before,
+			maxAttempts:           3,
+			opType:                operationEnsure,
+			ensureRsp:             c.rsp,
+		}
+		sts, _ := op.parseEnsureResult()
+		exceeded := op.recordAttempt(sts, 2, c.resetRetry)
+		if exceeded != c.expectExceeded {
+			t.Errorf("case %s: expect exceeded %v, get %v", c.name, c.expectExceeded, exceeded)
+		}
+		if sts.FailedAttempts != c.expectAttempts {
+			t.Errorf("case %s: expect %d failed attempts, get %d", c.name, c.expectAttempts, sts.FailedAttempts)
+		}
+		if bindutil.IsLoadBalancerFailed(*sts) != c.expectExceeded {
+			t.Errorf("case %s: expect Failed condition %v, get %+v", c.name, c.expectExceeded, sts.Conditions)
+		}
+		bind := &lbcfv1.Bind{}
+		bind.Generation = 2
+		if retryExhausted(bind, *sts, false) != c.expectExceeded {
+			t.Errorf("case %s: expect retry exhausted %v", c.name, c.expectExceeded)
+		}
+	}
+}
+
+func TestExpectedPodBackendsMemberClusterUnavailable(t *testing.T) {
+	bind := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bind"},
+		Spec: lbcfv1.BindSpec{
+			LoadBalancers: []lbcfv1.TargetLoadBalancer{{Name: "lb", Driver: "driver"}},
+			Pods: &lbcfv1.PodBackend{
+				Ports:    []lbcfv1.PortSelector{{Port: 80, Protocol: "TCP"}},
+				ByLabel:  &lbcfv1.SelectPodByLabel{Selector: map[string]string{"app": "web"}},
+				Clusters: []string{"ready", "missing"},
+			},
+		},
+	}
+	newPod := func(name string) *apicorev1.Pod {
+		return &apicorev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{"app": "web"}},
+			Status: apicorev1.PodStatus{
+				PodIP:      "1.1.1.1",
+				Conditions: []apicorev1.PodCondition{{Type: apicorev1.PodReady, Status: apicorev1.ConditionTrue}},
+			},
+		}
+	}
+	lb := bind.Spec.LoadBalancers[0]
+	statusMap := map[string]lbcfv1.TargetLoadBalancerStatus{lb.Name: {Name: lb.Name}}
+	missingRecord := generateBackendRecord(bind, lb, statusMap[lb.Name], newPod("pod-missing"), "missing")[0]
+
+	brIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := brIndexer.Add(missingRecord); err != nil {
+		t.Fatal(err)
+	}
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	readyPod := newPod("pod-ready")
+	if err := podIndexer.Add(readyPod); err != nil {
+		t.Fatal(err)
+	}
+	c := NewController(nil, nil, nil, lbcflister.NewBackendRecordLister(brIndexer), nil, nil, nil, nil, record.NewFakeRecorder(10), false)
+	members := util.NewMemberClusterSet()
+	members.Set("ready", nil, corev1.NewPodLister(podIndexer))
+	c.SetMemberPodListers(members)
+
+	// Pods of the ready cluster are expected, BackendRecords of the missing cluster are kept
+	expected, doNotDelete, err := c.expectedPodBackends(bind, bind.Spec.LoadBalancers, statusMap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expected) != 1 || expected[0].Name != getPodBRName(bind, lb, readyPod, bind.Spec.Pods.Ports[0], "ready") {
+		t.Fatalf("expect BackendRecord of Pod in ready cluster, get %v", expected)
+	}
+	if !doNotDelete.Equal(sets.NewString(missingRecord.Name)) {
+		t.Fatalf("expect %s not deleted, get %v", missingRecord.Name, doNotDelete.List())
+	}
+}
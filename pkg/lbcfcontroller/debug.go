@@ -0,0 +1,116 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	"k8s.io/klog"
+)
+
+// DebugPath is the URL path that serves the state of queues for debugging
+const DebugPath = "/debug/lbcf"
+
+// debugState is the response of DebugPath
+type debugState struct {
+	Queues []util.QueueSnapshot `json:"queues"`
+	// InProgressDeleting is the BackendRecords being deregistered, keyed by "<lbInfo>|<backendAddr>"
+	InProgressDeleting map[string]string `json:"inProgressDeleting"`
+}
+
+// DebugHandler returns a http.Handler that shows the keys in queues, their requeues and last sync results,
+// and the BackendRecords being deregistered. Use ?queue=<name> to show only one queue.
+func (c *Controller) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.debugState(r.URL.Query().Get("queue"))); err != nil {
+			klog.Errorf("write debug state failed: %v", err)
+		}
+	})
+}
+
+// debugState returns the state of the queue named queueName, or all queues if queueName is empty
+func (c *Controller) debugState(queueName string) *debugState {
+	state := &debugState{
+		Queues:             []util.QueueSnapshot{},
+		InProgressDeleting: make(map[string]string),
+	}
+	for _, queue := range []util.ConditionalRateLimitingInterface{c.driverQueue, c.loadBalancerQueue, c.backendGroupQueue, c.backendQueue, c.bindQueue} {
+		if queueName != "" && queue.GetName() != queueName {
+			continue
+		}
+		snapshot := queue.Snapshot()
+		for _, items := range [][]util.QueueItem{snapshot.Ready, snapshot.Processing, snapshot.Delayed, snapshot.WaitingForFilter} {
+			for i := range items {
+				if c.syncResults != nil {
+					items[i].LastResult = c.syncResults.get(queue.GetName(), items[i].Key)
+				}
+			}
+		}
+		state.Queues = append(state.Queues, snapshot)
+	}
+	c.backendCtrl.inProgressDeleting.Range(func(key, value interface{}) bool {
+		state.InProgressDeleting[key.(string)] = value.(string)
+		return true
+	})
+	return state
+}
+
+// syncResultStore keeps the last SyncResult of keys in each queue.
+// A key is removed once it is successfully finished, or its object is deleted, so that keys of deleted objects are not kept forever.
+type syncResultStore struct {
+	mu      sync.RWMutex
+	records map[string]map[string]*util.SyncRecord
+}
+
+func newSyncResultStore() *syncResultStore {
+	return &syncResultStore{records: make(map[string]map[string]*util.SyncRecord)}
+}
+
+func (s *syncResultStore) set(queueName string, key string, result *util.SyncResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result.IsFinished() {
+		delete(s.records[queueName], key)
+		return
+	}
+	if s.records[queueName] == nil {
+		s.records[queueName] = make(map[string]*util.SyncRecord)
+	}
+	s.records[queueName][key] = util.NewSyncRecord(result)
+}
+
+func (s *syncResultStore) delete(queueName string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records[queueName], key)
+}
+
+func (s *syncResultStore) get(queueName string, key string) *util.SyncRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.records[queueName][key]
+}
@@ -0,0 +1,129 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDebugHandler(t *testing.T) {
+	newQueue := func(name string) util.ConditionalRateLimitingInterface {
+		return util.NewConditionalDelayingQueue(name, nil, time.Hour, time.Hour, time.Hour)
+	}
+	c := &Controller{
+		backendCtrl:       &backendController{inProgressDeleting: new(sync.Map)},
+		driverQueue:       newQueue("LoadBalancerDriver"),
+		loadBalancerQueue: newQueue("LoadBalancer"),
+		backendGroupQueue: newQueue("BackendGroup"),
+		backendQueue:      newQueue("BackendRecord"),
+		bindQueue:         newQueue("Bind"),
+		syncResults:       newSyncResultStore(),
+	}
+	c.backendCtrl.inProgressDeleting.Store("lb|addr", "ns/br")
+
+	c.enqueue("ns/failed", c.backendQueue)
+	c.processNextItem(c.backendQueue, func(key string) *util.SyncResult {
+		return util.FailResult(time.Hour, "fake fail")
+	})
+	// wait until the result is recorded and the key is added back with delay
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return len(c.backendQueue.Snapshot().Delayed) == 1, nil
+	}); err != nil {
+		t.Fatalf("expect ns/failed delayed: %v", err)
+	}
+
+	rsp := httptest.NewRecorder()
+	c.DebugHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, DebugPath+"?queue=BackendRecord", nil))
+	if rsp.Code != http.StatusOK {
+		t.Fatalf("expect 200, get %d", rsp.Code)
+	}
+	state := &debugState{}
+	if err := json.Unmarshal(rsp.Body.Bytes(), state); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(state.Queues) != 1 || state.Queues[0].Name != "BackendRecord" {
+		t.Fatalf("expect only queue BackendRecord, get %+v", state.Queues)
+	}
+	if state.InProgressDeleting["lb|addr"] != "ns/br" {
+		t.Errorf("expect inProgressDeleting lb|addr: ns/br, get %v", state.InProgressDeleting)
+	}
+	var item *util.QueueItem
+	for i := range state.Queues[0].Delayed {
+		if state.Queues[0].Delayed[i].Key == "ns/failed" {
+			item = &state.Queues[0].Delayed[i]
+		}
+	}
+	if item == nil {
+		t.Fatalf("expect ns/failed in queue, get %+v", state.Queues[0])
+	}
+	if item.NumRequeues != 1 {
+		t.Errorf("expect 1 requeue, get %d", item.NumRequeues)
+	}
+	if item.LastResult == nil || item.LastResult.Result != "WebhookFailed" || item.LastResult.FailReason != "fake fail" {
+		t.Errorf("unexpected last result: %+v", item.LastResult)
+	}
+
+	rsp = httptest.NewRecorder()
+	c.DebugHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, DebugPath, nil))
+	if rsp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect 405, get %d", rsp.Code)
+	}
+}
+
+func TestSyncResultStore(t *testing.T) {
+	s := newSyncResultStore()
+	s.set("queue", "key", util.ErrorResult(fmt.Errorf("fake error")))
+	if record := s.get("queue", "key"); record == nil || record.Result != "Failed" || record.FailReason != "fake error" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if record := s.get("another", "key"); record != nil {
+		t.Errorf("expect no record in another queue, get %+v", record)
+	}
+	s.set("queue", "key", util.FinishedResult())
+	if record := s.get("queue", "key"); record != nil {
+		t.Errorf("expect record removed after finished, get %+v", record)
+	}
+	s.set("queue", "key", util.ErrorResult(fmt.Errorf("fake error")))
+	s.delete("queue", "key")
+	if record := s.get("queue", "key"); record != nil {
+		t.Errorf("expect record removed after deleted, get %+v", record)
+	}
+}
+
+func TestSyncResultForgottenOnDelete(t *testing.T) {
+	c := &Controller{
+		bindQueue:   util.NewConditionalDelayingQueue("Bind", nil, time.Hour, time.Hour, time.Hour),
+		syncResults: newSyncResultStore(),
+	}
+	c.syncResults.set(c.bindQueue.GetName(), "ns/bind", util.ErrorResult(fmt.Errorf("fake error")))
+	c.deleteBind(cache.DeletedFinalStateUnknown{Key: "ns/bind"})
+	if record := c.syncResults.get(c.bindQueue.GetName(), "ns/bind"); record != nil {
+		t.Errorf("expect record removed after object deleted, get %+v", record)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ReadinessChecks returns the checks of controller served by /readyz
+func (c *Controller) ReadinessChecks() []util.HealthCheck {
+	return []util.HealthCheck{
+		{
+			Name:  "driver",
+			Check: c.checkDriver,
+		},
+	}
+}
+
+// LivenessChecks returns the checks of controller served by /livez.
+// A queue fails the check if it has pending items but no item is got or done within stallPeriod.
+func (c *Controller) LivenessChecks(stallPeriod time.Duration) []util.HealthCheck {
+	// in dry-run mode, items are never done
+	if c.dryRun || stallPeriod <= 0 {
+		return nil
+	}
+	var checks []util.HealthCheck
+	for _, queue := range []util.ConditionalRateLimitingInterface{
+		c.driverQueue,
+		c.loadBalancerQueue,
+		c.backendGroupQueue,
+		c.backendQueue,
+		c.bindQueue,
+	} {
+		q := queue
+		checks = append(checks, util.HealthCheck{
+			Name: "queue-" + q.GetName(),
+			Check: func() error {
+				// items are not got until workers are started
+				if atomic.LoadInt32(&c.workersStarted) == 0 {
+					return nil
+				}
+				return checkQueueProgress(q, stallPeriod, time.Now())
+			},
+		})
+	}
+	return checks
+}
+
+// checkDriver returns an error if there is no driver that is handled by this controller and able to accept new objects
+func (c *Controller) checkDriver() error {
+	drivers, err := c.driverCtrl.lister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, driver := range drivers {
+		if driver.DeletionTimestamp != nil || util.IsDriverDraining(driver) {
+			continue
+		}
+		if c.class != nil && !c.class.Owns(driver) {
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("no healthy LoadBalancerDriver found")
+}
+
+func checkQueueProgress(queue util.ConditionalRateLimitingInterface, stallPeriod time.Duration, now time.Time) error {
+	pending := queue.Len() + queue.LenProcessing()
+	if pending == 0 {
+		return nil
+	}
+	if since := now.Sub(queue.LastProgress()); since > stallPeriod {
+		return fmt.Errorf("%d items pending, no progress for %s", pending, since.Round(time.Second).String())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"testing"
+	"time"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckDriver(t *testing.T) {
+	draining := newFakeDriver("", "draining")
+	draining.Labels = map[string]string{lbcfapi.DriverDrainingLabel: "true"}
+	deleting := newFakeDriver("", "deleting")
+	ts := metav1.Now()
+	deleting.DeletionTimestamp = &ts
+
+	cases := []struct {
+		name      string
+		drivers   []*lbcfapi.LoadBalancerDriver
+		expectErr bool
+	}{
+		{
+			name:      "no-driver",
+			expectErr: true,
+		},
+		{
+			name:      "unhealthy-drivers",
+			drivers:   []*lbcfapi.LoadBalancerDriver{draining, deleting},
+			expectErr: true,
+		},
+		{
+			name:    "healthy-driver",
+			drivers: []*lbcfapi.LoadBalancerDriver{draining, newFakeDriver("", "healthy")},
+		},
+	}
+	for _, c := range cases {
+		driverCtrl := newDriverController(nil, &fakeDriverLister{list: c.drivers}, false)
+		ctrl := newFakeLBCFController(driverCtrl, nil, nil, nil)
+		if err := ctrl.checkDriver(); (err != nil) != c.expectErr {
+			t.Errorf("case %s, expect error: %v, get %v", c.name, c.expectErr, err)
+		}
+	}
+}
+
+func TestCheckQueueProgress(t *testing.T) {
+	queue := util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second)
+	if err := checkQueueProgress(queue, time.Minute, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("expect no error for empty queue, get %v", err)
+	}
+
+	queue.Add("a")
+	if err := checkQueueProgress(queue, time.Minute, time.Now()); err != nil {
+		t.Errorf("expect no error before stall period, get %v", err)
+	}
+	if err := checkQueueProgress(queue, time.Minute, time.Now().Add(time.Hour)); err == nil {
+		t.Errorf("expect error for pending item")
+	}
+
+	item, _ := queue.Get()
+	if queue.LenProcessing() != 1 {
+		t.Fatalf("expect 1 processing item, get %d", queue.LenProcessing())
+	}
+	if err := checkQueueProgress(queue, time.Minute, time.Now().Add(time.Hour)); err == nil {
+		t.Errorf("expect error for processing item")
+	}
+
+	queue.Done(item)
+	if err := checkQueueProgress(queue, time.Minute, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("expect no error after item is done, get %v", err)
+	}
+}
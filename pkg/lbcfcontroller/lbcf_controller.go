@@ -21,17 +21,23 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/bindcontroller"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
+	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/config"
 	"tkestack.io/lb-controlling-framework/cmd/lbcf-controller/app/context"
 	bindutil "tkestack.io/lb-controlling-framework/pkg/api/bind"
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
 	"tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
 	"tkestack.io/lb-controlling-framework/pkg/metrics"
@@ -39,14 +45,39 @@ import (
 
 // NewController creates a new LBCF-controller
 func NewController(ctx *context.Context) *Controller {
+	fairness := newFairness(ctx.Cfg.QueueTenantKey, ctx.Cfg.QueueTenantMaxProc, ctx.IsDryRun())
+	newQueue := func(name string, filter util.QueueFilter, backoff util.BackoffFunc, priority util.PriorityFunc, fairness *util.Fairness) util.ConditionalRateLimitingInterface {
+		return util.NewConditionalDelayingQueueWithPriority(name, filter, backoff, priority, fairness, ctx.Cfg.RetryJitterFactor,
+			ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay)
+	}
 	c := &Controller{
-		context:           ctx,
-		driverQueue:       util.NewConditionalDelayingQueue("LoadBalancerDriver", nil, ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay),
-		loadBalancerQueue: util.NewConditionalDelayingQueue("LoadBalancer", util.QueueFilterForLB(ctx.LBInformer.Lister()), ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay),
-		backendGroupQueue: util.NewConditionalDelayingQueue("BackendGroup", nil, ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay),
-		backendQueue:      util.NewConditionalDelayingQueue("BackendRecord", util.QueueFilterForBackend(ctx.BRInformer.Lister()), ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay),
-		bindQueue:         util.NewConditionalDelayingQueue("Bind", util.QueueFilterForBackend(ctx.BRInformer.Lister()), ctx.Cfg.MinRetryDelay, ctx.Cfg.RetryDelayStep, ctx.Cfg.MaxRetryDelay),
-		dryRun:            ctx.IsDryRun(),
+		context:     ctx,
+		auth:        util.NewDelegatedAuth(ctx.K8sClient),
+		syncResults: newSyncResultStore(),
+		// drivers are few and shared among namespaces, they are not divided into tenants
+		driverQueue: newQueue("LoadBalancerDriver", nil, nil, nil, nil),
+		loadBalancerQueue: newQueue("LoadBalancer", util.QueueFilterForLB(ctx.LBInformer.Lister()),
+			util.BackoffForLB(ctx.LBInformer.Lister(), ctx.LBDriverInformer.Lister()),
+			util.PriorityForLB(ctx.LBInformer.Lister()), fairness),
+		backendGroupQueue: newQueue("BackendGroup", nil, nil, nil, fairness),
+		backendQueue: newQueue("BackendRecord", util.QueueFilterForBackend(ctx.BRInformer.Lister()),
+			util.BackoffForBackend(ctx.BRInformer.Lister(), ctx.LBDriverInformer.Lister()),
+			util.PriorityForBackend(ctx.BRInformer.Lister()), fairness),
+		bindQueue: newQueue("Bind", util.QueueFilterForBackend(ctx.BRInformer.Lister()),
+			util.BackoffForBind(ctx.BindInformer.Lister(), ctx.LBDriverInformer.Lister()),
+			util.PriorityForBind(ctx.BindInformer.Lister()), fairness),
+		dryRun: ctx.IsDryRun(),
+	}
+	c.class = util.NewControllerClass(ctx.Cfg.ControllerName, ctx.LBDriverInformer.Lister(), ctx.LBInformer.Lister())
+	if ctx.Cfg.ShardBy != "" {
+		shard, err := newShardManager(ctx.K8sClient, ctx.Cfg.ShardBy, ctx.Cfg.ShardIdentity,
+			ctx.Cfg.ShardLeaseNamespace, ctx.Cfg.ShardLeaseDuration, c.resyncAll)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		c.shard = shard
+		c.stopCh = make(chan struct{})
+		c.shardReleased = make(chan struct{})
 	}
 
 	c.driverCtrl = newDriverController(c.context.LbcfClient, c.context.LBDriverInformer.Lister(), c.context.IsDryRun())
@@ -68,6 +99,29 @@ func NewController(ctx *context.Context) *Controller {
 		util.NewWebhookInvoker(),
 		ctx.IsDryRun(),
 	)
+	// the next retry time of failed objects is recorded in status, so that retry delays survive restarts
+	c.lbCtrl.retryDelay = func(key string, minDelay time.Duration) time.Duration {
+		return c.loadBalancerQueue.RetryDelay(key, minDelay)
+	}
+	c.backendCtrl.retryDelay = func(key string, minDelay time.Duration) time.Duration {
+		return c.backendQueue.RetryDelay(key, minDelay)
+	}
+	if ctx.Cfg.PodCacheMode == config.PodCacheModePruned {
+		c.backendCtrl.getFullPod = func(cluster, namespace, name string) (*v1.Pod, error) {
+			var client kubernetes.Interface = ctx.K8sClient
+			if cluster != "" {
+				member, ok := ctx.MemberClusters.Client(cluster)
+				if !ok {
+					return nil, fmt.Errorf("member cluster %q is unknown or not ready", cluster)
+				}
+				client = member
+			}
+			start := time.Now()
+			pod, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+			metrics.K8SOpLatencyObserve("Pod", metrics.OpGet, time.Since(start))
+			return pod, err
+		}
+	}
 	c.backendGroupCtrl = newBackendGroupController(
 		c.context.LbcfClient,
 		c.context.LBDriverInformer.Lister(),
@@ -81,16 +135,21 @@ func NewController(ctx *context.Context) *Controller {
 		ctx.EventRecorder,
 		ctx.IsDryRun(),
 	)
+	c.backendGroupCtrl.scope = ctx.Scope
 	c.bindController = bindcontroller.NewController(
 		c.context.LbcfClient,
 		c.context.LBDriverInformer.Lister(),
 		c.context.BindInformer.Lister(),
 		c.context.BRInformer.Lister(),
 		c.context.PodInformer.Lister(),
+		c.context.SvcInformer.Lister(),
+		c.context.NodeInformer.Lister(),
 		util.NewWebhookInvoker(),
 		ctx.EventRecorder,
 		ctx.IsDryRun(),
 	)
+	c.addPodIndexers()
+	c.addNotificationIndexers()
 
 	// enqueue backendgroup
 	c.context.PodInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
@@ -99,13 +158,35 @@ func NewController(ctx *context.Context) *Controller {
 		DeleteFunc: c.deletePod,
 	}, c.context.Cfg.InformerResyncPeriod)
 
-	// enqueue backendgroup
+	// Pods of member clusters are handled the same as local Pods, related objects are matched by namespace and name,
+	// objects not selecting the cluster are synced without changes
+	c.context.AddMemberPodEventHandler(func(cluster string) cache.ResourceEventHandler {
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc: c.addPod,
+			UpdateFunc: func(old, cur interface{}) {
+				c.updateClusterPod(cluster, old, cur)
+			},
+			DeleteFunc: c.deletePod,
+		}
+	})
+	c.backendGroupCtrl.memberPodListers = ctx.MemberClusters
+	c.backendCtrl.memberPodListers = ctx.MemberClusters
+	c.bindController.SetMemberPodListers(ctx.MemberClusters)
+
+	// enqueue backendgroup and bind
 	c.context.SvcInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.addService,
 		UpdateFunc: c.updateService,
 		DeleteFunc: c.deleteService,
 	}, c.context.Cfg.InformerResyncPeriod)
 
+	// enqueue bind
+	c.context.NodeInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.addNode,
+		UpdateFunc: c.updateNode,
+		DeleteFunc: c.deleteNode,
+	}, c.context.Cfg.InformerResyncPeriod)
+
 	// control loadBalancer lifecycle
 	c.context.LBInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.addLoadBalancer,
@@ -145,6 +226,8 @@ func NewController(ctx *context.Context) *Controller {
 // Controller implements LBCF-controller
 type Controller struct {
 	context *context.Context
+	// auth authenticates and authorizes drivers pushing notifications
+	auth *util.DelegatedAuth
 
 	driverCtrl       *driverController
 	lbCtrl           *loadBalancerController
@@ -158,6 +241,59 @@ type Controller struct {
 	backendQueue      util.ConditionalRateLimitingInterface
 	bindQueue         util.ConditionalRateLimitingInterface
 	dryRun            bool
+
+	// class is nil in tests, objects of all drivers are handled
+	class *util.ControllerClass
+	// shard is nil if sharding is disabled
+	shard *shardManager
+	// stopCh is closed by Stop
+	stopCh chan struct{}
+	// shardReleased is closed after the shard Lease of this replica is released
+	shardReleased chan struct{}
+	// workersStarted is set to 1 after workers are started
+	workersStarted int32
+	// lbIndexer indexes LoadBalancers by util.IndexLoadBalancerByLBInfo, brIndexer indexes BackendRecords by
+	// util.IndexBackendRecordByLBInfo and util.IndexBackendRecordByAddr. All objects are checked for notifications if nil
+	lbIndexer cache.Indexer
+	brIndexer cache.Indexer
+	// syncResults is the last results of keys that are not finished, it is shown by DebugHandler, nil means results are not kept
+	syncResults *syncResultStore
+}
+
+// addPodIndexers indexes BackendGroups, Binds and BackendRecords by pods, so that pod events are resolved without listing all objects in namespace
+func (c *Controller) addPodIndexers() {
+	if err := c.context.BGInformer.Informer().AddIndexers(cache.Indexers{util.IndexBackendGroupByPod: util.BackendGroupPodIndexFunc}); err != nil {
+		klog.Fatalf("add BackendGroup indexer failed: %v", err)
+	}
+	if err := c.context.BindInformer.Informer().AddIndexers(cache.Indexers{util.IndexBindByPod: util.BindPodIndexFunc}); err != nil {
+		klog.Fatalf("add Bind indexer failed: %v", err)
+	}
+	if err := c.context.BRInformer.Informer().AddIndexers(cache.Indexers{util.IndexBackendRecordByPod: util.BackendRecordPodIndexFunc}); err != nil {
+		klog.Fatalf("add BackendRecord indexer failed: %v", err)
+	}
+	c.backendGroupCtrl.podIndexer = c.context.BGInformer.Informer().GetIndexer()
+	c.backendCtrl.podIndexer = c.context.BRInformer.Informer().GetIndexer()
+	c.bindController.SetPodIndexer(c.context.BindInformer.Informer().GetIndexer())
+}
+
+// addNotificationIndexers indexes LoadBalancers, Binds and BackendRecords by lbInfo and backend address,
+// so that objects affected by driver notifications are resolved without listing all objects
+func (c *Controller) addNotificationIndexers() {
+	if err := c.context.LBInformer.Informer().AddIndexers(cache.Indexers{util.IndexLoadBalancerByLBInfo: util.LoadBalancerLBInfoIndexFunc}); err != nil {
+		klog.Fatalf("add LoadBalancer indexer failed: %v", err)
+	}
+	if err := c.context.BindInformer.Informer().AddIndexers(cache.Indexers{util.IndexBindByLBInfo: util.BindLBInfoIndexFunc}); err != nil {
+		klog.Fatalf("add Bind indexer failed: %v", err)
+	}
+	if err := c.context.BRInformer.Informer().AddIndexers(cache.Indexers{
+		util.IndexBackendRecordByLBInfo: util.BackendRecordLBInfoIndexFunc,
+		util.IndexBackendRecordByAddr:   util.BackendRecordAddrIndexFunc,
+	}); err != nil {
+		klog.Fatalf("add BackendRecord indexer failed: %v", err)
+	}
+	c.lbIndexer = c.context.LBInformer.Informer().GetIndexer()
+	c.brIndexer = c.context.BRInformer.Informer().GetIndexer()
+	c.bindController.SetLBInfoIndexer(c.context.BindInformer.Informer().GetIndexer())
 }
 
 // Start starts controller in a new goroutine
@@ -167,15 +303,50 @@ func (c *Controller) Start() {
 
 func (c *Controller) run() {
 	c.context.WaitForCacheSync()
+	if c.shard != nil {
+		go func() {
+			c.shard.run(c.stopCh)
+			close(c.shardReleased)
+		}()
+	}
 	go wait.Until(c.lbWorker, time.Second, wait.NeverStop)
 	go wait.Until(c.driverWorker, time.Second, wait.NeverStop)
 	go wait.Until(c.backendGroupWorker, time.Second, wait.NeverStop)
 	go wait.Until(c.backendWorker, time.Second, wait.NeverStop)
 	go wait.Until(c.updateQueuePendingMetric, 10*time.Second, wait.NeverStop)
 	go wait.Until(c.bindWorker, time.Second, wait.NeverStop)
+	atomic.StoreInt32(&c.workersStarted, 1)
+}
+
+// Stop releases the shard Lease of this replica, so that its shards are taken over by other replicas
+// without waiting for the Lease to expire. It does nothing if sharding is disabled or workers are not started.
+func (c *Controller) Stop() {
+	if c.shard == nil || atomic.LoadInt32(&c.workersStarted) == 0 {
+		return
+	}
+	close(c.stopCh)
+	<-c.shardReleased
+}
+
+// SetRetryDelay changes the retry delays of all queues at runtime
+func (c *Controller) SetRetryDelay(jitterFactor float64, minDelay time.Duration, step time.Duration, maxDelay time.Duration) {
+	for _, queue := range []util.ConditionalRateLimitingInterface{
+		c.driverQueue,
+		c.loadBalancerQueue,
+		c.backendGroupQueue,
+		c.backendQueue,
+		c.bindQueue,
+	} {
+		queue.SetRetryDelay(jitterFactor, minDelay, step, maxDelay)
+	}
+	klog.Infof("retry delay changed, minRetryDelay: %s, retryDelayStep: %s, maxRetryDelay: %s, retryJitterFactor: %v",
+		minDelay.String(), step.String(), maxDelay.String(), jitterFactor)
 }
 
 func (c *Controller) enqueue(obj interface{}, queue util.ConditionalRateLimitingInterface) {
+	if !c.owns(obj, queue) {
+		return
+	}
 	if _, ok := obj.(string); ok {
 		queue.Add(obj)
 		return
@@ -188,6 +359,152 @@ func (c *Controller) enqueue(obj interface{}, queue util.ConditionalRateLimiting
 	queue.Add(key)
 }
 
+// retryNow resets the rate limiter for obj and adds it to queue immediately
+func (c *Controller) retryNow(obj interface{}, queue util.ConditionalRateLimitingInterface) {
+	key, ok := obj.(string)
+	if !ok {
+		var err error
+		if key, err = cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err != nil {
+			klog.Errorf("enqueue failed: %v", err)
+			return
+		}
+	}
+	if !c.owns(key, queue) {
+		return
+	}
+	klog.Infof("retry %s %s immediately", queue.GetName(), key)
+	queue.Forget(key)
+	queue.Add(key)
+}
+
+// owns returns true if obj in queue is handled by this replica, obj is either a key or an object.
+//
+// Objects using drivers of other controllers are not handled, and if sharding is enabled,
+// only objects assigned to this replica are handled.
+func (c *Controller) owns(obj interface{}, queue util.ConditionalRateLimitingInterface) bool {
+	if c.class == nil && c.shard == nil {
+		return true
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	key, isKey := obj.(string)
+	if isKey {
+		// deleted objects are not found, they are handled with their keys
+		if found := c.getByKey(key, queue); found != nil {
+			obj = found
+			isKey = false
+		}
+	}
+	if c.class != nil && !isKey && !c.class.Owns(obj) {
+		return false
+	}
+	return c.shard == nil || c.shard.owns(c.shardKey(obj, queue))
+}
+
+// getByKey returns the object of key in queue, or nil if not found
+func (c *Controller) getByKey(key string, queue util.ConditionalRateLimitingInterface) interface{} {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	var obj interface{}
+	switch queue {
+	case c.driverQueue:
+		obj, err = c.driverCtrl.lister.LoadBalancerDrivers(namespace).Get(name)
+	case c.loadBalancerQueue:
+		obj, err = c.lbCtrl.lister.LoadBalancers(namespace).Get(name)
+	case c.backendGroupQueue:
+		obj, err = c.backendGroupCtrl.bgLister.BackendGroups(namespace).Get(name)
+	case c.backendQueue:
+		obj, err = c.backendCtrl.brLister.BackendRecords(namespace).Get(name)
+	case c.bindQueue:
+		obj, err = c.bindController.GetBind(namespace, name)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// shardKey returns the key used to assign obj to replicas.
+//
+// LoadBalancerDrivers and LoadBalancers are assigned by their own keys, so that objects shared from kube-system
+// are spread among replicas. BackendRecords are assigned by the load balancer they are registered to, so that
+// records of the same load balancer, which are ordered by inProgressDeleting, are always handled by the same replica.
+// If sharding by driver, LoadBalancers and BackendRecords are assigned by their driver instead.
+// Other objects are assigned by namespace.
+func (c *Controller) shardKey(obj interface{}, queue util.ConditionalRateLimitingInterface) string {
+	if key, ok := obj.(string); ok {
+		namespace, _, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return key
+		}
+		switch queue {
+		case c.driverQueue, c.loadBalancerQueue, c.backendQueue:
+			// deleted objects are found by nobody, any replica may finish them
+			return key
+		}
+		return namespace
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	switch o := obj.(type) {
+	case *v1beta1.LoadBalancerDriver:
+		return util.NamespacedNameKeyFunc(o.Namespace, o.Name)
+	case *v1beta1.LoadBalancer:
+		if c.shard.by == ShardByDriver {
+			return util.NamespacedNameKeyFunc(util.NamespaceOfSharedObj(o.Spec.LBDriver, o.Namespace), o.Spec.LBDriver)
+		}
+		return util.NamespacedNameKeyFunc(o.Namespace, o.Name)
+	case *v1beta1.BackendRecord:
+		driverNamespace := util.NamespaceOfSharedObj(o.Spec.LBDriver, o.Namespace)
+		if c.shard.by == ShardByDriver {
+			return util.NamespacedNameKeyFunc(driverNamespace, o.Spec.LBDriver)
+		}
+		return util.LBInfoIndexValue(driverNamespace, o.Spec.LBDriver, o.Spec.LBInfo)
+	}
+	return accessor.GetNamespace()
+}
+
+// resyncAll enqueues all objects owned by this replica, it is called after shards are reassigned.
+// Failed objects are added after the RetryAfter recorded by their previous owners
+func (c *Controller) resyncAll() {
+	if drivers, err := c.context.LBDriverInformer.Lister().List(labels.Everything()); err == nil {
+		for _, driver := range drivers {
+			c.enqueue(driver, c.driverQueue)
+		}
+	}
+	if lbs, err := c.context.LBInformer.Lister().List(labels.Everything()); err == nil {
+		for _, lb := range lbs {
+			if !c.seedRetry(lb, lb.Status.RetryStatus, c.loadBalancerQueue) {
+				c.enqueue(lb, c.loadBalancerQueue)
+			}
+		}
+	}
+	if groups, err := c.context.BGInformer.Lister().List(labels.Everything()); err == nil {
+		for _, group := range groups {
+			c.enqueue(group, c.backendGroupQueue)
+		}
+	}
+	if backends, err := c.context.BRInformer.Lister().List(labels.Everything()); err == nil {
+		for _, backend := range backends {
+			if !c.seedRetry(backend, backend.Status.RetryStatus, c.backendQueue) {
+				c.enqueue(backend, c.backendQueue)
+			}
+		}
+	}
+	if binds, err := c.context.BindInformer.Lister().List(labels.Everything()); err == nil {
+		for _, bind := range binds {
+			c.addBind(bind)
+		}
+	}
+}
+
 func (c *Controller) lbWorker() {
 	for c.processNextItem(c.loadBalancerQueue, c.lbCtrl.syncLB) {
 	}
@@ -226,9 +543,20 @@ func (c *Controller) processNextItem(queue util.ConditionalRateLimitingInterface
 			defer queue.Done(key)
 		}
 
+		// the shard may be reassigned to another replica after key is enqueued
+		if !c.owns(key, queue) {
+			klog.V(3).Infof("skip %s %s, not owned by this replica", queue.GetName(), key)
+			queue.Forget(key)
+			c.forgetSyncResult(key, queue)
+			metrics.WorkingKeysDec(queue.GetName())
+			return
+		}
 		klog.V(3).Infof("sync %s %s start", queue.GetName(), key)
 		startTime := time.Now()
 		result := syncFunc(key.(string))
+		if c.syncResults != nil {
+			c.syncResults.set(queue.GetName(), key.(string), result)
+		}
 
 		// reset rate limiter if not failed
 		if !result.IsFailed() {
@@ -271,6 +599,11 @@ func (c *Controller) addPod(obj interface{}) {
 }
 
 func (c *Controller) updatePod(old, cur interface{}) {
+	c.updateClusterPod("", old, cur)
+}
+
+// updateClusterPod handles update events of Pods in cluster, empty cluster means the local cluster
+func (c *Controller) updateClusterPod(cluster string, old, cur interface{}) {
 	oldPod := old.(*v1.Pod)
 	curPod := cur.(*v1.Pod)
 	klog.V(3).Infof("receive pod %s/%s update event", curPod.Namespace, curPod.Name)
@@ -280,28 +613,31 @@ func (c *Controller) updatePod(old, cur interface{}) {
 
 	labelChanged := !reflect.DeepEqual(oldPod.Labels, curPod.Labels)
 	statusChanged := util.PodAvailable(oldPod) != util.PodAvailable(curPod)
+	weightChanged := oldPod.Annotations[v1beta1.AnnotationBackendWeight] != curPod.Annotations[v1beta1.AnnotationBackendWeight]
 
 	klog.Infof("old pod %s/%s new pod %s/%s statusChanged %t", oldPod.Namespace, oldPod.Name, curPod.Namespace, curPod.Name, statusChanged)
 
 	// TODO:DeregisterWebhook
 	if statusChanged && (!util.PodAvailable(curPod) || !util.PodAvailableByRunning(curPod)) {
-		err := c.handlePodStatusChanged(curPod)
+		err := c.handlePodStatusChanged(cluster, curPod)
 		if err != nil {
 			klog.Errorf("failed to handle pod %s/%s status change: %v", curPod.Namespace, curPod.Name, err)
 		}
 	}
 
-	if labelChanged || statusChanged {
+	if labelChanged || statusChanged || weightChanged {
+		// BackendRecords of all related objects are updated if the weight is changed
+		union := statusChanged || weightChanged
 		oldGroups := c.backendGroupCtrl.listRelatedBackendGroupsForPod(oldPod)
 		groups := c.backendGroupCtrl.listRelatedBackendGroupsForPod(curPod)
-		groups = util.UnionOrDifferenceUnion(oldGroups, groups, statusChanged)
+		groups = util.UnionOrDifferenceUnion(oldGroups, groups, union)
 		for key := range groups {
 			c.enqueue(key, c.backendGroupQueue)
 		}
 
 		oldBinds := c.bindController.ListRelatedBindForPod(oldPod)
 		curBinds := c.bindController.ListRelatedBindForPod(curPod)
-		curBinds = util.UnionOrDifferenceUnion(oldBinds, curBinds, statusChanged)
+		curBinds = util.UnionOrDifferenceUnion(oldBinds, curBinds, union)
 		for key := range curBinds {
 			c.enqueue(key, c.bindQueue)
 		}
@@ -338,6 +674,9 @@ func (c *Controller) addService(obj interface{}) {
 	for key := range keys {
 		c.enqueue(key, c.backendGroupQueue)
 	}
+	for key := range c.bindController.ListRelatedBindForService(svc) {
+		c.enqueue(key, c.bindQueue)
+	}
 }
 
 func (c *Controller) updateService(old, cur interface{}) {
@@ -349,6 +688,9 @@ func (c *Controller) updateService(old, cur interface{}) {
 	for key := range c.backendGroupCtrl.listRelatedBackendGroupForSvc(curSvc) {
 		c.enqueue(key, c.backendGroupQueue)
 	}
+	for key := range c.bindController.ListRelatedBindForService(curSvc) {
+		c.enqueue(key, c.bindQueue)
+	}
 }
 
 func (c *Controller) deleteService(obj interface{}) {
@@ -369,15 +711,60 @@ func (c *Controller) deleteService(obj interface{}) {
 	c.addService(svc)
 }
 
+func (c *Controller) addNode(obj interface{}) {
+	node := obj.(*v1.Node)
+	for key := range c.bindController.ListRelatedBindForNode(node) {
+		c.enqueue(key, c.bindQueue)
+	}
+}
+
+func (c *Controller) updateNode(old, cur interface{}) {
+	oldNode := old.(*v1.Node)
+	curNode := cur.(*v1.Node)
+	if reflect.DeepEqual(oldNode.Labels, curNode.Labels) {
+		return
+	}
+	// Binds selecting the node before or after the update are both synced
+	keys := c.bindController.ListRelatedBindForNode(oldNode).Union(c.bindController.ListRelatedBindForNode(curNode))
+	for key := range keys {
+		c.enqueue(key, c.bindQueue)
+	}
+}
+
+func (c *Controller) deleteNode(obj interface{}) {
+	if _, ok := obj.(*v1.Node); ok {
+		c.addNode(obj)
+		return
+	}
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		klog.Errorf("Couldn't get object from tombstone %#v", obj)
+		return
+	}
+	node, ok := tombstone.Obj.(*v1.Node)
+	if !ok {
+		klog.Errorf("Tombstone contained object that is not a Node: %#v", obj)
+		return
+	}
+	c.addNode(node)
+}
+
 func (c *Controller) addBackendGroup(obj interface{}) {
 	c.enqueue(obj, c.backendGroupQueue)
 }
 
 func (c *Controller) updateBackendGroup(old, cur interface{}) {
+	oldGroup := old.(*v1beta1.BackendGroup)
+	curGroup := cur.(*v1beta1.BackendGroup)
+	if util.RetryTriggered(oldGroup, curGroup, curGroup.Status.LastRetryAt) {
+		c.retryNow(curGroup, c.backendGroupQueue)
+		return
+	}
 	c.enqueue(cur, c.backendGroupQueue)
 }
 
 func (c *Controller) deleteBackendGroup(obj interface{}) {
+	c.forgetSyncResult(obj, c.backendGroupQueue)
 	if _, ok := obj.(*v1beta1.BackendGroup); ok {
 		c.addBackendGroup(obj)
 		return
@@ -397,7 +784,9 @@ func (c *Controller) deleteBackendGroup(obj interface{}) {
 
 func (c *Controller) addLoadBalancer(obj interface{}) {
 	lb := obj.(*v1beta1.LoadBalancer)
-	c.enqueue(obj, c.loadBalancerQueue)
+	if !c.seedRetry(lb, lb.Status.RetryStatus, c.loadBalancerQueue) {
+		c.enqueue(obj, c.loadBalancerQueue)
+	}
 
 	for key := range c.backendGroupCtrl.listRelatedBackendGroupsForLB(lb) {
 		c.enqueue(key, c.backendGroupQueue)
@@ -410,7 +799,9 @@ func (c *Controller) updateLoadBalancer(old, cur interface{}) {
 	if oldLB.ResourceVersion == curLB.ResourceVersion {
 		return
 	}
-	if util.NeedEnqueueLB(oldLB, curLB) {
+	if util.RetryTriggered(oldLB, curLB, curLB.Status.LastRetryAt) {
+		c.retryNow(curLB, c.loadBalancerQueue)
+	} else if util.NeedEnqueueLB(oldLB, curLB) {
 		c.enqueue(curLB, c.loadBalancerQueue)
 	}
 	for key := range c.backendGroupCtrl.listRelatedBackendGroupsForLB(curLB) {
@@ -419,6 +810,7 @@ func (c *Controller) updateLoadBalancer(old, cur interface{}) {
 }
 
 func (c *Controller) deleteLoadBalancer(obj interface{}) {
+	c.forgetSyncResult(obj, c.loadBalancerQueue)
 	if _, ok := obj.(*v1beta1.LoadBalancer); ok {
 		c.addLoadBalancer(obj)
 		return
@@ -450,6 +842,7 @@ func (c *Controller) updateLoadBalancerDriver(old, cur interface{}) {
 }
 
 func (c *Controller) deleteLoadBalancerDriver(obj interface{}) {
+	c.forgetSyncResult(obj, c.driverQueue)
 	if _, ok := obj.(*v1beta1.LoadBalancerDriver); ok {
 		c.addLoadBalancerDriver(obj)
 		return
@@ -472,8 +865,34 @@ func (c *Controller) addBackendRecord(obj interface{}) {
 	klog.V(4).Infof("receive backendrecord %s/%s create event", backend.Namespace, backend.Name)
 	alwaysEnsure := backend.Spec.EnsurePolicy != nil && backend.Spec.EnsurePolicy.Policy == v1beta1.PolicyAlways
 	if !util.BackendRegistered(backend) || alwaysEnsure || backend.DeletionTimestamp != nil {
-		c.enqueue(obj, c.backendQueue)
+		if !c.seedRetry(backend, backend.Status.RetryStatus, c.backendQueue) {
+			c.enqueue(obj, c.backendQueue)
+		}
+	}
+}
+
+// seedRetry restores the retry delay of a failed LoadBalancer, BackendRecord or Bind from its status after lbcf-controller restarts
+// or shards are reassigned. It returns true if obj is added to queue after the recorded RetryAfter
+func (c *Controller) seedRetry(obj metav1.Object, retryStatus v1beta1.RetryStatus, queue util.ConditionalRateLimitingInterface) bool {
+	if retryStatus.RetryAfter == nil || retryStatus.FailedAttempts == 0 || obj.GetDeletionTimestamp() != nil {
+		return false
 	}
+	if util.NeedResetRetry(obj, retryStatus) || !time.Now().Before(retryStatus.RetryAfter.Time) {
+		return false
+	}
+	if !c.owns(obj, queue) {
+		return false
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return false
+	}
+	if !queue.SeedRetry(key, int(retryStatus.FailedAttempts), retryStatus.RetryAfter.Time) {
+		return false
+	}
+	klog.V(3).Infof("%s %s failed %d times before restart, retry after %s",
+		queue.GetName(), key, retryStatus.FailedAttempts, retryStatus.RetryAfter.String())
+	return true
 }
 
 func (c *Controller) updateBackendRecord(old, cur interface{}) {
@@ -483,7 +902,9 @@ func (c *Controller) updateBackendRecord(old, cur interface{}) {
 	if oldObj.ResourceVersion == curObj.ResourceVersion {
 		return
 	}
-	if util.NeedEnqueueBackend(oldObj, curObj) {
+	if util.RetryTriggered(oldObj, curObj, curObj.Status.LastRetryAt) {
+		c.retryNow(curObj, c.backendQueue)
+	} else if util.NeedEnqueueBackend(oldObj, curObj) {
 		c.enqueue(curObj, c.backendQueue)
 	}
 	if util.BackendRegistered(oldObj) != util.BackendRegistered(curObj) {
@@ -499,6 +920,7 @@ func (c *Controller) updateBackendRecord(old, cur interface{}) {
 }
 
 func (c *Controller) deleteBackendRecord(obj interface{}) {
+	c.forgetSyncResult(obj, c.backendQueue)
 	backend, ok := obj.(*v1beta1.BackendRecord)
 	if !ok {
 		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
@@ -524,15 +946,58 @@ func (c *Controller) deleteBackendRecord(obj interface{}) {
 }
 
 func (c *Controller) addBind(obj interface{}) {
-	c.enqueue(obj, c.bindQueue)
+	bind := obj.(*lbcfv1.Bind)
+	if !c.seedRetry(bind, bindRetryStatus(bind), c.bindQueue) {
+		c.enqueue(obj, c.bindQueue)
+	}
+}
+
+// bindRetryStatus returns the RetryStatus of the failed load balancer of bind that is retried first,
+// the returned RetryStatus is empty if no load balancer is waiting for retry
+func bindRetryStatus(bind *lbcfv1.Bind) v1beta1.RetryStatus {
+	var ret v1beta1.RetryStatus
+	for _, sts := range bind.Status.LoadBalancerStatuses {
+		if sts.FailedAttempts == 0 || sts.RetryAfter.IsZero() || bindutil.IsLoadBalancerFailed(sts) {
+			continue
+		}
+		if ret.RetryAfter == nil || sts.RetryAfter.Before(ret.RetryAfter) {
+			retryAfter := sts.RetryAfter
+			ret = v1beta1.RetryStatus{
+				FailedAttempts:     sts.FailedAttempts,
+				ObservedGeneration: sts.ObservedGeneration,
+				LastRetryAt:        bind.Status.LastRetryAt,
+				RetryAfter:         &retryAfter,
+			}
+		}
+	}
+	return ret
 }
 
 func (c *Controller) updateBind(old, cur interface{}) {
+	oldBind := old.(*lbcfv1.Bind)
+	curBind := cur.(*lbcfv1.Bind)
+	if util.RetryTriggered(oldBind, curBind, curBind.Status.LastRetryAt) {
+		c.retryNow(curBind, c.bindQueue)
+		return
+	}
 	c.enqueue(cur, c.bindQueue)
 }
 
 func (c *Controller) deleteBind(obj interface{}) {
-	c.addBind(obj)
+	c.forgetSyncResult(obj, c.bindQueue)
+	c.enqueue(obj, c.bindQueue)
+}
+
+// forgetSyncResult removes the last SyncResult of obj in queue, it is called when obj is deleted or no longer handled by this replica
+func (c *Controller) forgetSyncResult(obj interface{}, queue util.ConditionalRateLimitingInterface) {
+	if c.syncResults == nil {
+		return
+	}
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.syncResults.delete(queue.GetName(), key)
 }
 
 func (c *Controller) updateQueuePendingMetric() {
@@ -541,11 +1006,39 @@ func (c *Controller) updateQueuePendingMetric() {
 	metrics.PendingKeysSet(c.backendGroupQueue.GetName(), float64(c.backendGroupQueue.Len()))
 	metrics.PendingKeysSet(c.backendQueue.GetName(), float64(c.backendQueue.Len()))
 	metrics.PendingKeysSet(c.bindQueue.GetName(), float64(c.bindQueue.Len()))
+	for _, queue := range []util.ConditionalRateLimitingInterface{c.loadBalancerQueue, c.backendQueue, c.bindQueue} {
+		for _, p := range util.Priorities {
+			metrics.PendingKeysByPrioritySet(queue.GetName(), p.String(), float64(queue.LenOfPriority(p)))
+		}
+	}
+	for _, queue := range []util.ConditionalRateLimitingInterface{c.loadBalancerQueue, c.backendGroupQueue, c.backendQueue, c.bindQueue} {
+		metrics.PendingKeysByTenantSet(queue.GetName(), queue.LenOfTenants())
+	}
+}
+
+// newFairness returns how workers are shared among tenants, nil means fairness is disabled
+func newFairness(tenantKey string, maxProcessing int, dryRun bool) *util.Fairness {
+	switch tenantKey {
+	case config.QueueTenantNone:
+		return nil
+	case config.QueueTenantByNamespace:
+	default:
+		klog.Fatalf("unknown queue-tenant-key %q, supported values are %q and %q",
+			tenantKey, config.QueueTenantByNamespace, config.QueueTenantNone)
+	}
+	// in dry-run mode, items are never done
+	if dryRun {
+		maxProcessing = 0
+	}
+	return &util.Fairness{
+		Tenant:        util.TenantByNamespace,
+		MaxProcessing: maxProcessing,
+	}
 }
 
 // handlePodStatusChanged delete pod's BackendRecord directly when pod needs to be deregistered
 // please note that BackendRecord won't be removed instantly for all BackendRecords are created with finalizers
-func (c *Controller) handlePodStatusChanged(pod *v1.Pod) error {
+func (c *Controller) handlePodStatusChanged(cluster string, pod *v1.Pod) error {
 	startTime := time.Now()
 	klog.V(3).Infof("start handle pod %s/%s status changed", pod.Namespace, pod.Name)
 	defer func() {
@@ -572,6 +1065,10 @@ func (c *Controller) handlePodStatusChanged(pod *v1.Pod) error {
 				klog.Errorf("failed to get BackendRecord %s/%s: %v, skipping", namespace, name, err)
 				return
 			}
+			// Pods in different clusters may have the same name
+			if br.Spec.PodBackendInfo == nil || br.Spec.PodBackendInfo.Cluster != cluster {
+				return
+			}
 			controllerRef := metav1.GetControllerOf(br)
 			if controllerRef == nil {
 				klog.Warningf("BackendRecord %s/%s does not contain controllerRef, skipping", namespace, name)
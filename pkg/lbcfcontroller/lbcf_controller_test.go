@@ -132,7 +132,17 @@ func TestLBCFControllerUpdatePod_PodStatusChange(t *testing.T) {
 		nil, nil,
 		false,
 	)
-	c := newFakeLBCFController(nil, nil, nil, bgCtrl)
+	backendCtrl := newBackendController(
+		fake.NewSimpleClientset(),
+		&fakeBackendLister{},
+		&fakeDriverLister{},
+		&fakePodLister{},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		&fakeEventRecorder{},
+		&fakeSuccInvoker{},
+		false)
+	c := newFakeLBCFController(nil, nil, backendCtrl, bgCtrl)
 
 	c.updatePod(oldPod1, curPod1)
 	if c.backendGroupQueue.Len() != 1 {
@@ -289,6 +299,53 @@ func TestLBCFControllerUpdatePod_PodLabelChange(t *testing.T) {
 	}
 }
 
+func TestLBCFControllerUpdatePod_PodWeightChange(t *testing.T) {
+	podLabel := map[string]string{
+		"k1": "v1",
+	}
+	oldPod := newFakePod("", "pod-1", podLabel, true, false)
+	curPod := newFakePod("", "pod-1", podLabel, true, false)
+	curPod.Annotations = map[string]string{lbcfapi.AnnotationBackendWeight: "50"}
+	curPod.ResourceVersion = "another"
+
+	bg := newFakeBackendGroupOfPods("", "bg-1", "", 80, "tcp", podLabel, nil, nil)
+	bgCtrl := newBackendGroupController(
+		fake.NewSimpleClientset(),
+		&fakeDriverLister{},
+		&fakeLBLister{},
+		&fakeBackendGroupLister{
+			list: []*lbcfapi.BackendGroup{bg},
+		},
+		&fakeBackendLister{},
+		&fakePodLister{},
+		&fakeSvcListerWithStore{},
+		&fakeNodeListerWithStore{},
+		nil, nil,
+		false,
+	)
+	c := newFakeLBCFController(nil, nil, nil, bgCtrl)
+
+	c.updatePod(oldPod, curPod)
+	if c.backendGroupQueue.Len() != 1 {
+		t.Fatalf("queue length should be 1, get %d", c.backendGroupQueue.Len())
+	}
+	key, _ := c.backendGroupQueue.Get()
+	if expectedKey, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(bg); expectedKey != key {
+		t.Errorf("expected Backendgroup key %s found %v", expectedKey, key)
+	}
+	c.backendGroupQueue.Done(key)
+
+	// changes of other annotations are ignored
+	oldPod = curPod
+	curPod = oldPod.DeepCopy()
+	curPod.Annotations["other"] = "value"
+	curPod.ResourceVersion = "another-2"
+	c.updatePod(oldPod, curPod)
+	if c.backendGroupQueue.Len() != 0 {
+		t.Fatalf("queue length should be 0, get %d", c.backendGroupQueue.Len())
+	}
+}
+
 func TestLBCFControllerDeletePod(t *testing.T) {
 	podLabel1 := map[string]string{
 		"k1": "v1",
@@ -592,6 +649,62 @@ func TestLBCFControllerDeleteService(t *testing.T) {
 	c.backendGroupQueue.Done(groupKey)
 }
 
+func TestLBCFControllerServiceAndNodeEnqueueBind(t *testing.T) {
+	svc := newFakeService("", "test-svc", apiv1.ServiceTypeNodePort)
+	svcBind := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-bind"},
+		Spec: lbcfv1.BindSpec{
+			Service: &lbcfv1.ServiceBackend{
+				Name:         svc.Name,
+				Port:         lbcfv1.PortSelector{Port: 80, Protocol: "TCP"},
+				NodeSelector: map[string]string{"role": "lb"},
+			},
+		},
+	}
+	podBind := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-bind"},
+		Spec: lbcfv1.BindSpec{
+			Pods: &lbcfv1.PodBackend{ByName: []string{"pod-0"}},
+		},
+	}
+	c := newFakeLBCFController(nil, nil, nil, nil)
+	c.backendGroupCtrl = newBackendGroupController(nil, nil, nil, &fakeBackendGroupLister{}, nil, nil, nil, nil, nil, nil, false)
+	c.bindController = bindcontroller.NewController(nil, nil, &fakeBindLister{data: []*lbcfv1.Bind{svcBind, podBind}}, nil, nil, nil, nil, nil, nil, false)
+	c.bindQueue = util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second)
+	expectBindEnqueued := func(action string) {
+		if c.bindQueue.Len() != 1 {
+			t.Fatalf("%s: queue length should be 1, get %d", action, c.bindQueue.Len())
+		}
+		key, _ := c.bindQueue.Get()
+		if key != svcBind.Name {
+			t.Errorf("%s: expect Bind %s enqueued, get %v", action, svcBind.Name, key)
+		}
+		c.bindQueue.Done(key)
+		c.bindQueue.Forget(key)
+	}
+
+	c.addService(svc)
+	expectBindEnqueued("add service")
+
+	node := &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0", Labels: map[string]string{"role": "lb"}}}
+	c.addNode(node)
+	expectBindEnqueued("add node")
+
+	// Binds selecting the node before the label change are synced to deregister it
+	unselected := node.DeepCopy()
+	unselected.Labels = map[string]string{"role": "other"}
+	c.updateNode(node, unselected)
+	expectBindEnqueued("update node labels")
+
+	c.updateNode(unselected, unselected.DeepCopy())
+	if c.bindQueue.Len() != 0 {
+		t.Fatalf("expect no Bind enqueued if node labels are not changed, get %d", c.bindQueue.Len())
+	}
+
+	c.deleteNode(cache.DeletedFinalStateUnknown{Key: node.Name, Obj: node})
+	expectBindEnqueued("delete node")
+}
+
 func TestLBCFControllerAddLoadBalancer(t *testing.T) {
 	lb := newFakeLoadBalancer("", "lb", nil, nil)
 	bg := newFakeBackendGroupOfPods(lb.Namespace, "bg", lb.Name, 80, "tcp", nil, nil, nil)
@@ -815,6 +928,37 @@ func TestLBCFControllerUpdateLoadBalancer(t *testing.T) {
 			expectLBQueue: 1,
 			expectBGQueue: 1,
 		},
+		{
+			name: "retry-triggered",
+			old:  newFakeLoadBalancer("", "lb", nil, nil),
+			cur: func() *lbcfapi.LoadBalancer {
+				lb := newFakeLoadBalancer("", "lb", nil, nil)
+				lb.Annotations = map[string]string{
+					lbcfapi.AnnotationRetryAt: "2019-01-01T00:00:00Z",
+				}
+				lb.ResourceVersion = "another"
+				return lb
+			}(),
+			ctrl:          newFakeLBCFController(nil, lbCtrl, nil, bgCtrl),
+			expectLBQueue: 1,
+			expectBGQueue: 1,
+		},
+		{
+			name: "retry-already-processed",
+			old:  newFakeLoadBalancer("", "lb", nil, nil),
+			cur: func() *lbcfapi.LoadBalancer {
+				lb := newFakeLoadBalancer("", "lb", nil, nil)
+				lb.Annotations = map[string]string{
+					lbcfapi.AnnotationRetryAt: "2019-01-01T00:00:00Z",
+				}
+				lb.Status.LastRetryAt = "2019-01-01T00:00:00Z"
+				lb.ResourceVersion = "another"
+				return lb
+			}(),
+			ctrl:          newFakeLBCFController(nil, lbCtrl, nil, bgCtrl),
+			expectLBQueue: 0,
+			expectBGQueue: 1,
+		},
 	}
 
 	for _, c := range cases {
@@ -1487,6 +1631,100 @@ func TestLBCFControllerProcessNextItemPeriodic(t *testing.T) {
 	}
 }
 
+func TestBindListRelatedForPodByIndex(t *testing.T) {
+	pod := newFakePod("ns", "pod-1", map[string]string{"app": "web", "tier": "front"}, true, false)
+	binds := []*lbcfv1.Bind{
+		newFakeBindOfPods("ns", "by-label", map[string]string{"app": "web"}, nil, nil),
+		newFakeBindOfPods("ns", "by-labels", map[string]string{"app": "web", "tier": "front"}, nil, nil),
+		newFakeBindOfPods("ns", "not-match", map[string]string{"app": "web", "tier": "back"}, nil, nil),
+		newFakeBindOfPods("ns", "except", map[string]string{"app": "web"}, []string{"pod-1"}, nil),
+		newFakeBindOfPods("ns", "by-name", nil, nil, []string{"pod-1"}),
+		newFakeBindOfPods("other", "other-ns", map[string]string{"app": "web"}, nil, nil),
+	}
+	scanCtrl, indexCtrl := newPodIndexTestBindControllers(binds)
+	expect := scanCtrl.ListRelatedBindForPod(pod)
+	if !expect.Equal(sets.NewString("ns/by-label", "ns/by-labels", "ns/by-name")) {
+		t.Fatalf("unexpected Binds listed without index: %v", expect.List())
+	}
+	if get := indexCtrl.ListRelatedBindForPod(pod); !get.Equal(expect) {
+		t.Errorf("expect %v, get %v", expect.List(), get.List())
+	}
+}
+
+func BenchmarkListRelatedBindForPod(b *testing.B) {
+	// 5000 Binds in one namespace, each selects pods of its own app
+	var binds []*lbcfv1.Bind
+	for i := 0; i < 5000; i++ {
+		binds = append(binds, newFakeBindOfPods("ns", fmt.Sprintf("bind-%d", i),
+			map[string]string{"app": fmt.Sprintf("app-%d", i)}, nil, nil))
+	}
+	pod := newFakePod("ns", "pod", map[string]string{"app": "app-100", "pod-template-hash": "abc"}, true, false)
+	scanCtrl, indexCtrl := newPodIndexTestBindControllers(binds)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanCtrl.ListRelatedBindForPod(pod)
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexCtrl.ListRelatedBindForPod(pod)
+		}
+	})
+}
+
+// newPodIndexTestBindControllers returns a controller listing all Binds and a controller using podIndexer
+func newPodIndexTestBindControllers(binds []*lbcfv1.Bind) (*bindcontroller.Controller, *bindcontroller.Controller) {
+	var objs []interface{}
+	for _, bind := range binds {
+		objs = append(objs, bind)
+	}
+	indexer := newPodIndexTestIndexer(util.IndexBindByPod, util.BindPodIndexFunc, objs...)
+	newCtrl := func() *bindcontroller.Controller {
+		return bindcontroller.NewController(fake.NewSimpleClientset(), &fakeDriverLister{}, lbcflisterv1.NewBindLister(indexer),
+			&fakeBackendLister{}, &fakePodLister{}, &fakeSvcListerWithStore{}, &fakeNodeListerWithStore{}, nil, nil, false)
+	}
+	indexCtrl := newCtrl()
+	indexCtrl.SetPodIndexer(indexer)
+	return newCtrl(), indexCtrl
+}
+
+// newPodIndexTestIndexer returns an indexer containing objs, indexed by namespace and by pods with indexFunc.
+// It is shared by controllers listing objects related to a pod, with and without the pod index
+func newPodIndexTestIndexer(indexName string, indexFunc cache.IndexFunc, objs ...interface{}) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		indexName:            indexFunc,
+	})
+	for _, obj := range objs {
+		indexer.Add(obj)
+	}
+	return indexer
+}
+
+func newFakeBindOfPods(namespace, name string, labelSelector map[string]string, labelExcept []string, byName []string) *lbcfv1.Bind {
+	bind := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: lbcfv1.BindSpec{
+			Pods: &lbcfv1.PodBackend{
+				Ports: []lbcfv1.PortSelector{{Port: 80, Protocol: "TCP"}},
+			},
+		},
+	}
+	if labelSelector != nil {
+		bind.Spec.Pods.ByLabel = &lbcfv1.SelectPodByLabel{
+			Selector: labelSelector,
+			Except:   labelExcept,
+		}
+	} else {
+		bind.Spec.Pods.ByName = byName
+	}
+	return bind
+}
+
 func newFakeBackendRecord(namespace, name string) *lbcfapi.BackendRecord {
 	return &lbcfapi.BackendRecord{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1670,12 +1908,13 @@ func newFakeLBCFController(driverCtrl *driverController, lbCtrl *loadBalancerCon
 		lbCtrl:           lbCtrl,
 		backendCtrl:      backendCtrl,
 		backendGroupCtrl: bgCtrl,
-		bindController:   bindcontroller.NewController(nil, nil, &fakeBindLister{}, nil, nil, nil, nil, false),
+		bindController:   bindcontroller.NewController(nil, nil, &fakeBindLister{}, nil, nil, nil, nil, nil, nil, false),
 
 		driverQueue:       util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second),
 		loadBalancerQueue: util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second),
 		backendGroupQueue: util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second),
 		backendQueue:      util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second),
+		bindQueue:         util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second),
 	}
 }
 
@@ -1954,6 +2193,12 @@ func (l *fakeSvcListerWithStore) GetPodServices(pod *apiv1.Pod) ([]*apiv1.Servic
 
 type fakeSuccInvoker struct{}
 
+func (c *fakeSuccInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: true,
+	}, nil
+}
+
 func (c *fakeSuccInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2035,8 +2280,18 @@ func (c *fakeSuccInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDri
 		}}, nil
 }
 
+func (c *fakeSuccInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 type fakeFailInvoker struct{}
 
+func (c *fakeFailInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: false,
+	}, nil
+}
+
 func (c *fakeFailInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2117,8 +2372,18 @@ func (c *fakeFailInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDri
 		}}, nil
 }
 
+func (c *fakeFailInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 type fakeRunningInvoker struct{}
 
+func (c *fakeRunningInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: true,
+	}, nil
+}
+
 func (c *fakeRunningInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2203,8 +2468,18 @@ func (c *fakeRunningInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancer
 			Msg: "this webhook can NOT return running"}}, nil
 }
 
+func (c *fakeRunningInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 type fakeInvalidInvoker struct{}
 
+func (c *fakeInvalidInvoker) CallHealthz(driver *lbcfapi.LoadBalancerDriver, req *webhooks.HealthzRequest) (*webhooks.HealthzResponse, error) {
+	return &webhooks.HealthzResponse{
+		Healthy: false,
+	}, nil
+}
+
 func (c *fakeInvalidInvoker) CallValidateLoadBalancer(driver *lbcfapi.LoadBalancerDriver, req *webhooks.ValidateLoadBalancerRequest) (*webhooks.ValidateLoadBalancerResponse, error) {
 	return &webhooks.ValidateLoadBalancerResponse{
 		ResponseForNoRetryHooks: webhooks.ResponseForNoRetryHooks{
@@ -2287,6 +2562,10 @@ func (c *fakeInvalidInvoker) CallJudgePodDeregister(driver *lbcfapi.LoadBalancer
 	return &webhooks.JudgePodDeregisterResponse{}, nil
 }
 
+func (c *fakeInvalidInvoker) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	return c.CallDeregisterBackend(driver, req)
+}
+
 type fakeEventRecorder struct {
 	store map[string]string
 }
@@ -2310,3 +2589,66 @@ func (r *fakeEventRecorder) PastEventf(object runtime.Object, timestamp metav1.T
 
 func (r *fakeEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
 }
+
+func TestLBCFControllerAddBackendRecordSeedRetry(t *testing.T) {
+	c := newFakeLBCFController(nil, nil, nil, nil)
+	retryAfter := metav1.NewTime(time.Now().Add(time.Hour))
+
+	failed := newFakeBackendRecord("", "failed")
+	failed.Status.FailedAttempts = 3
+	failed.Status.RetryAfter = &retryAfter
+	c.addBackendRecord(failed)
+	if c.backendQueue.Len() != 0 {
+		t.Fatalf("expect failed BackendRecord delayed, get %d", c.backendQueue.Len())
+	}
+	// the max delay of fake queues is 2s
+	if get := c.backendQueue.RetryDelay("failed", 0); get != 2*time.Second {
+		t.Fatalf("expect failures restored, get delay %s", get.String())
+	}
+
+	// outdated retry status is ignored
+	modified := newFakeBackendRecord("", "modified")
+	modified.Generation = 2
+	modified.Status.FailedAttempts = 3
+	modified.Status.RetryAfter = &retryAfter
+	c.addBackendRecord(modified)
+	if c.backendQueue.Len() != 1 {
+		t.Fatalf("expect modified BackendRecord enqueued, get %d", c.backendQueue.Len())
+	}
+}
+
+func TestLBCFControllerAddBindSeedRetry(t *testing.T) {
+	c := newFakeLBCFController(nil, nil, nil, nil)
+	retryAfter := metav1.NewTime(time.Now().Add(time.Hour))
+
+	failed := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{Name: "failed"},
+		Status: lbcfv1.BindStatus{
+			LoadBalancerStatuses: []lbcfv1.TargetLoadBalancerStatus{
+				{Name: "succ"},
+				{Name: "later", FailedAttempts: 1, RetryAfter: metav1.NewTime(retryAfter.Add(time.Hour))},
+				{Name: "failed", FailedAttempts: 3, RetryAfter: retryAfter},
+			},
+		},
+	}
+	if get := bindRetryStatus(failed); get.FailedAttempts != 3 || get.RetryAfter == nil || !get.RetryAfter.Equal(&retryAfter) {
+		t.Fatalf("expect retry status of the load balancer retried first, get %+v", get)
+	}
+	c.addBind(failed)
+	if c.bindQueue.Len() != 0 {
+		t.Fatalf("expect failed Bind delayed, get %d", c.bindQueue.Len())
+	}
+	// the max delay of fake queues is 2s
+	if get := c.bindQueue.RetryDelay("failed", 0); get != 2*time.Second {
+		t.Fatalf("expect failures restored, get delay %s", get.String())
+	}
+
+	// outdated retry status is ignored
+	modified := failed.DeepCopy()
+	modified.Name = "modified"
+	modified.Generation = 2
+	c.addBind(modified)
+	if c.bindQueue.Len() != 1 {
+		t.Fatalf("expect modified Bind enqueued, get %d", c.bindQueue.Len())
+	}
+}
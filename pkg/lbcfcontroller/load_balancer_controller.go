@@ -19,6 +19,7 @@ package lbcfcontroller
 
 import (
 	"fmt"
+	"time"
 
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	lbcfclient "tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned"
@@ -29,6 +30,7 @@ import (
 	apicore "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
@@ -61,6 +63,9 @@ type loadBalancerController struct {
 	eventRecorder  record.EventRecorder
 	webhookInvoker util.WebhookInvoker
 	dryRun         bool
+
+	// retryDelay returns the delay before a failed LoadBalancer is retried, RetryAfter is not recorded if it is nil
+	retryDelay func(key string, minDelay time.Duration) time.Duration
 }
 
 func (c *loadBalancerController) syncLB(key string) *util.SyncResult {
@@ -75,17 +80,35 @@ func (c *loadBalancerController) syncLB(key string) *util.SyncResult {
 		return util.ErrorResult(err)
 	}
 
-	if lb.DeletionTimestamp != nil {
-		if !util.HasFinalizer(lb.Finalizers, lbcfapi.FinalizerDeleteLB) {
-			return util.FinishedResult()
-		}
-		return c.deleteLoadBalancer(lb)
+	if lb.DeletionTimestamp != nil && !util.HasFinalizer(lb.Finalizers, lbcfapi.FinalizerDeleteLB) {
+		return util.FinishedResult()
+	}
+	if util.LBRetryExhausted(lb) {
+		klog.Infof("LoadBalancer %s is not retried because retry limit is exceeded", key)
+		return util.FinishedResult()
 	}
 
-	if !util.LBCreated(lb) {
-		return c.createLoadBalancer(lb)
+	var result *util.SyncResult
+	if lb.DeletionTimestamp != nil {
+		result = c.deleteLoadBalancer(lb)
+	} else if !util.LBCreated(lb) {
+		result = c.createLoadBalancer(lb)
+	} else {
+		result = c.ensureLoadBalancer(lb)
+	}
+	if c.dryRun {
+		return result
+	}
+	if result.IsWebhookFailed() {
+		return c.recordFailure(lb, result)
+	}
+	retryTriggered := lb.Annotations[lbcfapi.AnnotationRetryAt] != lb.Status.LastRetryAt
+	if !result.IsFailed() && (retryTriggered || (!result.IsRunning() && lb.Status.FailedAttempts > 0)) {
+		if err := c.resetFailure(lb); err != nil {
+			return util.ErrorResult(err)
+		}
 	}
-	return c.ensureLoadBalancer(lb)
+	return result
 }
 
 func (c *loadBalancerController) createLoadBalancer(lb *lbcfapi.LoadBalancer) *util.SyncResult {
@@ -120,7 +143,9 @@ func (c *loadBalancerController) createLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 
 	switch rsp.Status {
 	case webhooks.StatusSucc:
+		old := lb
 		lb = lb.DeepCopy()
+		lb.Status.RunningOperation = nil
 		if len(rsp.LBInfo) > 0 {
 			lb.Status.LBInfo = rsp.LBInfo
 		} else {
@@ -138,8 +163,7 @@ func (c *loadBalancerController) createLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 			LastTransitionTime: v1.Now(),
 			Message:            rsp.Msg,
 		})
-		_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).UpdateStatus(lb)
-		if err != nil {
+		if err := c.updateStatus(old, lb); err != nil {
 			c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedCreateLoadBalancer", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
@@ -149,12 +173,13 @@ func (c *loadBalancerController) createLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 		}
 		return util.FinishedResult()
 	case webhooks.StatusFail:
+		if err := util.ClearRunningOperation(c.runningOperationObject(lb, "")); err != nil {
+			return util.ErrorResult(err)
+		}
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedCreateLoadBalancer", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(lb, apicore.EventTypeNormal, "RunningCreateLoadBalancer", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(lb, lbcfapi.LBCreated), driver, webhooks.CreateLoadBalancer, "RunningCreateLoadBalancer", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "InvalidCreateLoadBalancer", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
@@ -198,15 +223,16 @@ func (c *loadBalancerController) ensureLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 
 	switch rsp.Status {
 	case webhooks.StatusSucc:
+		old := lb
 		lb = lb.DeepCopy()
+		lb.Status.RunningOperation = nil
 		util.AddLBCondition(&lb.Status, lbcfapi.LoadBalancerCondition{
 			Type:               lbcfapi.LBAttributesSynced,
 			Status:             lbcfapi.ConditionTrue,
 			LastTransitionTime: v1.Now(),
 			Message:            rsp.Msg,
 		})
-		_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).UpdateStatus(lb)
-		if err != nil {
+		if err := c.updateStatus(old, lb); err != nil {
 			c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedEnsureLoadBalancer", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
@@ -216,7 +242,9 @@ func (c *loadBalancerController) ensureLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 		}
 		return util.FinishedResult()
 	case webhooks.StatusFail:
+		old := lb
 		lb = lb.DeepCopy()
+		lb.Status.RunningOperation = nil
 		util.AddLBCondition(&lb.Status, lbcfapi.LoadBalancerCondition{
 			Type:               lbcfapi.LBAttributesSynced,
 			Status:             lbcfapi.ConditionFalse,
@@ -224,17 +252,14 @@ func (c *loadBalancerController) ensureLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 			Reason:             lbcfapi.ReasonOperationFailed.String(),
 			Message:            rsp.Msg,
 		})
-		_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).UpdateStatus(lb)
-		if err != nil {
+		if err := c.updateStatus(old, lb); err != nil {
 			c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedEnsureLoadBalancer", "update status failed: %v", err)
 			return util.ErrorResult(err)
 		}
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedEnsureLoadBalancer", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(lb, apicore.EventTypeNormal, "RunningEnsureLoadBalancer", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(lb, lbcfapi.LBAttributesSynced), driver, webhooks.EnsureLoadBalancer, "RunningEnsureLoadBalancer", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "InvalidEnsureLoadBalancer", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
@@ -275,12 +300,13 @@ func (c *loadBalancerController) deleteLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 	case webhooks.StatusSucc:
 		return c.removeFinalizer(lb)
 	case webhooks.StatusFail:
+		if err := util.ClearRunningOperation(c.runningOperationObject(lb, "")); err != nil {
+			return util.ErrorResult(err)
+		}
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "FailedDeleteLoadBalancer", "msg: %s", rsp.Msg)
 		return util.FailResult(util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds), rsp.Msg)
 	case webhooks.StatusRunning:
-		c.eventRecorder.Eventf(lb, apicore.EventTypeNormal, "RunningDeleteLoadBalancer", "msg: %s", rsp.Msg)
-		delay := util.CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
-		return util.AsyncResult(delay)
+		return util.HandleRunningOperation(c.runningOperationObject(lb, lbcfapi.LBDeleted), driver, webhooks.DeleteLoadBalancer, "RunningDeleteLoadBalancer", rsp.ResponseForFailRetryHooks, c.eventRecorder)
 	default:
 		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "InvalidDeleteLoadBalancer", "unsupported status: %s, msg: %s", rsp.Status, rsp.Msg)
 		return util.ErrorResult(fmt.Errorf("unknown status %q", rsp.Status))
@@ -288,11 +314,116 @@ func (c *loadBalancerController) deleteLoadBalancer(lb *lbcfapi.LoadBalancer) *u
 }
 
 func (c *loadBalancerController) removeFinalizer(lb *lbcfapi.LoadBalancer) *util.SyncResult {
-	lb = lb.DeepCopy()
-	lb.Finalizers = util.RemoveFinalizer(lb.Finalizers, lbcfapi.FinalizerDeleteLB)
-	_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Update(lb)
+	err := util.PatchRemoveFinalizer("LoadBalancer", lb.Finalizers, lbcfapi.FinalizerDeleteLB, func(pt types.PatchType, data []byte) error {
+		_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Patch(lb.Name, pt, data)
+		return err
+	})
 	if err != nil {
 		return util.ErrorResult(err)
 	}
 	return util.FinishedResult()
 }
+
+// runningOperationObject returns lb for util.HandleRunningOperation and util.ClearRunningOperation,
+// condType is set to False with reason Timeout if the operation times out
+func (c *loadBalancerController) runningOperationObject(lb *lbcfapi.LoadBalancer, condType lbcfapi.LoadBalancerConditionType) *util.RunningOperationObject {
+	cpy := lb.DeepCopy()
+	return &util.RunningOperationObject{
+		Object:           lb,
+		RunningOperation: lb.Status.RunningOperation,
+		SetRunningOperation: func(op *lbcfapi.RunningOperation) {
+			cpy.Status.RunningOperation = op
+		},
+		SetTimeout: func(msg string) {
+			util.AddLBCondition(&cpy.Status, lbcfapi.LoadBalancerCondition{
+				Type:               condType,
+				Status:             lbcfapi.ConditionFalse,
+				LastTransitionTime: v1.Now(),
+				Reason:             lbcfapi.ReasonOperationTimeout.String(),
+				Message:            msg,
+			})
+		},
+		Update: func() error {
+			return c.updateStatus(lb, cpy)
+		},
+	}
+}
+
+// recordFailure increases the failed attempts of lb. The LoadBalancer is marked as Failed and will not be retried
+// once the failed attempts reach the maxAttempts configured in retryPolicy
+func (c *loadBalancerController) recordFailure(lb *lbcfapi.LoadBalancer, result *util.SyncResult) *util.SyncResult {
+	var driver *lbcfapi.LoadBalancerDriver
+	if d, err := c.driverLister.LoadBalancerDrivers(util.NamespaceOfSharedObj(lb.Spec.LBDriver, lb.Namespace)).Get(lb.Spec.LBDriver); err == nil {
+		driver = d
+	}
+	maxAttempts := util.GetMaxAttempts(lb.Spec.EnsurePolicy, driver)
+	var retryDelay time.Duration
+	if c.retryDelay != nil {
+		key, _ := cache.MetaNamespaceKeyFunc(lb)
+		retryDelay = c.retryDelay(key, result.GetNextRun())
+	}
+	exceeded, err := util.RecordFailure(c.retryStatusObject(lb), maxAttempts, retryDelay, result.GetFailReason())
+	if err != nil {
+		return util.ErrorResult(err)
+	}
+	if exceeded {
+		c.eventRecorder.Eventf(lb, apicore.EventTypeWarning, "RetryLimitExceeded", "failed %d times, last error: %s", maxAttempts, result.GetFailReason())
+		return util.FinishedResult()
+	}
+	return result
+}
+
+// resetFailure clears the failed attempts recorded in status of lb and records the processed retry-at annotation
+func (c *loadBalancerController) resetFailure(lb *lbcfapi.LoadBalancer) error {
+	return util.ResetFailure(c.retryStatusObject(lb))
+}
+
+// retryStatusObject returns a function that reads the latest lb for counting failed attempts in its status
+func (c *loadBalancerController) retryStatusObject(lb *lbcfapi.LoadBalancer) func() (*util.RetryStatusObject, error) {
+	return func() (*util.RetryStatusObject, error) {
+		fetched, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		latest := fetched.DeepCopy()
+		return &util.RetryStatusObject{
+			Object:      latest,
+			RetryStatus: &latest.Status.RetryStatus,
+			IsFailed: func() bool {
+				cond := util.GetLBCondition(&latest.Status, lbcfapi.LBFailed)
+				return cond != nil && cond.Status == lbcfapi.ConditionTrue
+			},
+			SetFailed: func(failed bool, msg string) {
+				cond := lbcfapi.LoadBalancerCondition{
+					Type:               lbcfapi.LBFailed,
+					Status:             lbcfapi.ConditionFalse,
+					LastTransitionTime: v1.Now(),
+				}
+				if failed {
+					cond.Status = lbcfapi.ConditionTrue
+					cond.Reason = lbcfapi.ReasonRetryLimitExceeded.String()
+					cond.Message = msg
+				}
+				util.AddLBCondition(&latest.Status, cond)
+			},
+			Update: func() error {
+				return c.updateStatus(fetched, latest)
+			},
+		}, nil
+	}
+}
+
+// updateStatus sends the changes from old.Status to cur.Status as a merge patch guarded by the resourceVersion of old,
+// a conflict is retried against the latest LoadBalancer only if its status is not modified concurrently
+func (c *loadBalancerController) updateStatus(old, cur *lbcfapi.LoadBalancer) error {
+	return util.PatchStatus("LoadBalancer", old.ResourceVersion, old.Status, cur.Status, func(pt types.PatchType, data []byte) error {
+		_, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(cur.Namespace).Patch(cur.Name, pt, data, "status")
+		return err
+	}, func() (string, interface{}, error) {
+		latest, err := c.lbcfClient.LbcfV1beta1().LoadBalancers(cur.Namespace).Get(cur.Name, v1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		return latest.ResourceVersion, latest.Status, nil
+	})
+}
@@ -17,14 +17,23 @@
 package lbcfcontroller
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned/fake"
 	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
+	"tkestack.io/lb-controlling-framework/pkg/metrics"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -101,6 +110,111 @@ func TestLoadBalancerCreateFail(t *testing.T) {
 	}
 }
 
+func TestLoadBalancerCreateFailRetryLimitExceeded(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+	driver.Spec.RetryPolicy = &lbcfapi.RetryPolicyConfig{MaxAttempts: 1}
+	fakeClient := fake.NewSimpleClientset(lb)
+	store := make(map[string]string)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: store},
+		&fakeFailInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if result.IsFailed() || result.IsRunning() || result.IsPeriodic() {
+		t.Fatalf("expect finished, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if get.Status.FailedAttempts != 1 {
+		t.Errorf("expect 1 failed attempt, get %d", get.Status.FailedAttempts)
+	}
+	if !util.LBRetryExhausted(get) {
+		t.Errorf("expect retry exhausted, get status: %#v", get.Status)
+	}
+	if reason := store[lb.Name]; reason != "RetryLimitExceeded" {
+		t.Fatalf("expect reason RetryLimitExceeded, get %s", reason)
+	}
+
+	// an exhausted LoadBalancer is not retried
+	ctrl.lister = &fakeLBLister{get: get}
+	ctrl.webhookInvoker = &fakeSuccInvoker{}
+	if result := ctrl.syncLB(key); result.IsFailed() || result.IsRunning() {
+		t.Fatalf("expect finished, get %+v", result)
+	}
+	if get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{}); util.LBCreated(get) {
+		t.Fatalf("expect LoadBalancer not retried")
+	}
+
+	// changing the retry annotation triggers a new attempt
+	get = get.DeepCopy()
+	get.Annotations = map[string]string{lbcfapi.AnnotationRetryAt: "2019-01-01T00:00:00Z"}
+	if _, err := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Update(get); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	ctrl.lister = &fakeLBLister{get: get}
+	if result := ctrl.syncLB(key); result.IsFailed() || result.IsRunning() {
+		t.Fatalf("expect finished, get %+v", result)
+	}
+	get, _ = fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if !util.LBCreated(get) {
+		t.Errorf("expect LoadBalancer created, get status: %#v", get.Status)
+	}
+	if get.Status.FailedAttempts != 0 || util.LBRetryExhausted(get) {
+		t.Errorf("expect failed attempts reset, get status: %#v", get.Status)
+	}
+}
+
+func TestLoadBalancerCreateFailRecordRetryAfter(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	fakeClient := fake.NewSimpleClientset(lb)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: newFakeDriver(lb.Namespace, lb.Spec.LBDriver),
+		},
+		&fakeEventRecorder{store: make(map[string]string)},
+		&fakeFailInvoker{},
+		false)
+	ctrl.retryDelay = func(key string, minDelay time.Duration) time.Duration {
+		return time.Hour
+	}
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	if result := ctrl.syncLB(key); !result.IsFailed() {
+		t.Fatalf("expect failed, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if get.Status.FailedAttempts != 1 {
+		t.Errorf("expect 1 failed attempt, get %d", get.Status.FailedAttempts)
+	}
+	if get.Status.RetryAfter == nil || time.Until(get.Status.RetryAfter.Time) < 59*time.Minute {
+		t.Errorf("expect retry after 1 hour, get %v", get.Status.RetryAfter)
+	}
+
+	// RetryAfter is cleared once succeeded
+	ctrl.lister = &fakeLBLister{get: get}
+	ctrl.webhookInvoker = &fakeSuccInvoker{}
+	if result := ctrl.syncLB(key); result.IsFailed() {
+		t.Fatalf("expect succ, get %+v", result)
+	}
+	get, _ = fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if get.Status.FailedAttempts != 0 || get.Status.RetryAfter != nil {
+		t.Errorf("expect retry status reset, get status: %#v", get.Status)
+	}
+}
+
 func TestLoadBalancerCreateRunning(t *testing.T) {
 	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
 	lb.Spec.LBDriver = "test-driver"
@@ -119,14 +233,21 @@ func TestLoadBalancerCreateRunning(t *testing.T) {
 		&fakeRunningInvoker{},
 		false)
 	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	observed := k8sOpLatencyCount(t, "LoadBalancer", metrics.OpPatchStatus)
 	result := ctrl.syncLB(key)
 	if !result.IsRunning() {
 		t.Fatalf("expect running, get %+v", result)
 	}
+	if get := k8sOpLatencyCount(t, "LoadBalancer", metrics.OpPatchStatus); get != observed+1 {
+		t.Errorf("expect 1 latency observed for runningOperation, get %d", get-observed)
+	}
 	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
 	if util.LBCreated(get) {
 		t.Errorf("expect LoadBalancer created=false, get status: %#v", get.Status)
 	}
+	if get.Status.RunningOperation == nil || get.Status.RunningOperation.Webhook != webhooks.CreateLoadBalancer {
+		t.Errorf("expect runningOperation %s, get %+v", webhooks.CreateLoadBalancer, get.Status.RunningOperation)
+	}
 	if len(store) != 1 {
 		t.Fatalf("expect 1 event, get %d", len(store))
 	} else if reason, ok := store[lb.Name]; !ok {
@@ -136,6 +257,128 @@ func TestLoadBalancerCreateRunning(t *testing.T) {
 	}
 }
 
+func TestLoadBalancerCreateRunningTimeout(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	lb.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.CreateLoadBalancer,
+		StartTime: v1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+	driver.Spec.MaxOperationDuration = &lbcfapi.Duration{Duration: 10 * time.Minute}
+	fakeClient := fake.NewSimpleClientset(lb)
+	store := make(map[string]string)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: store},
+		&fakeRunningInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsFailed() {
+		t.Fatalf("expect failed, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if get.Status.RunningOperation != nil {
+		t.Errorf("expect nil runningOperation, get %+v", get.Status.RunningOperation)
+	}
+	cond := util.GetLBCondition(&get.Status, lbcfapi.LBCreated)
+	if cond == nil || cond.Status != lbcfapi.ConditionFalse || cond.Reason != lbcfapi.ReasonOperationTimeout.String() {
+		t.Errorf("expect condition Created=False with reason Timeout, get %+v", cond)
+	}
+	if reason := store[lb.Name]; reason != "OperationTimeout" {
+		t.Fatalf("expect reason OperationTimeout, get %s", reason)
+	}
+}
+
+func TestLoadBalancerDeleteRunningTimeout(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	timestamp := v1.Now()
+	lb.DeletionTimestamp = &timestamp
+	lb.Finalizers = []string{lbcfapi.FinalizerDeleteLB}
+	lb.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.DeleteLoadBalancer,
+		StartTime: v1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+	driver.Spec.MaxOperationDuration = &lbcfapi.Duration{Duration: 10 * time.Minute}
+	fakeClient := fake.NewSimpleClientset(lb)
+	store := make(map[string]string)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: store},
+		&fakeRunningInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsFailed() {
+		t.Fatalf("expect failed, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if get.Status.RunningOperation != nil {
+		t.Errorf("expect nil runningOperation, get %+v", get.Status.RunningOperation)
+	}
+	cond := util.GetLBCondition(&get.Status, lbcfapi.LBDeleted)
+	if cond == nil || cond.Status != lbcfapi.ConditionFalse || cond.Reason != lbcfapi.ReasonOperationTimeout.String() {
+		t.Errorf("expect condition Deleted=False with reason Timeout, get %+v", cond)
+	}
+	if reason := store[lb.Name]; reason != "OperationTimeout" {
+		t.Fatalf("expect reason OperationTimeout, get %s", reason)
+	}
+}
+
+func TestLoadBalancerCreateRunningNotTimeout(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	startTime := v1.NewTime(time.Now().Add(-time.Hour))
+	lb.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.CreateLoadBalancer,
+		StartTime: startTime,
+	}
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+	driver.Spec.MaxOperationDuration = &lbcfapi.Duration{Duration: 10 * time.Minute}
+	driver.Spec.Webhooks = []lbcfapi.WebhookConfig{
+		{
+			Name:                 webhooks.CreateLoadBalancer,
+			MaxOperationDuration: &lbcfapi.Duration{Duration: 2 * time.Hour},
+		},
+	}
+	fakeClient := fake.NewSimpleClientset(lb)
+	store := make(map[string]string)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: store},
+		&fakeRunningInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsRunning() {
+		t.Fatalf("expect running, get %+v", result)
+	}
+	if reason := store[lb.Name]; reason != "RunningCreateLoadBalancer" {
+		t.Fatalf("expect reason RunningCreateLoadBalancer, get %s", reason)
+	}
+}
+
 func TestLoadBalancerCreateInvalid(t *testing.T) {
 	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
 	lb.Spec.LBDriver = "test-driver"
@@ -442,6 +685,153 @@ func TestLoadBalancerDelete(t *testing.T) {
 	}
 }
 
+func TestLoadBalancerDeleteKeepsOtherFinalizers(t *testing.T) {
+	timestamp := v1.Now()
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.DeletionTimestamp = &timestamp
+	lb.ObjectMeta.Finalizers = []string{lbcfapi.FinalizerDeleteLB}
+	lb.Spec.LBDriver = "test-driver"
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+
+	// a finalizer is added by others after the lb is cached
+	latest := lb.DeepCopy()
+	latest.Finalizers = append(latest.Finalizers, "other")
+	fakeClient := fake.NewSimpleClientset(latest)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: make(map[string]string)},
+		&fakeSuccInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsFinished() {
+		t.Fatalf("expect succ, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if !reflect.DeepEqual(get.Finalizers, []string{"other"}) {
+		t.Fatalf("expect finalizers [other], get %v", get.Finalizers)
+	}
+
+	// finalizers are reordered by others, the cached index is out of date
+	latest = lb.DeepCopy()
+	latest.Finalizers = []string{"other", lbcfapi.FinalizerDeleteLB}
+	fakeClient = fake.NewSimpleClientset(latest)
+	ctrl.lbcfClient = fakeClient
+	result = ctrl.syncLB(key)
+	if !result.IsFailed() {
+		t.Fatalf("expect error, get %+v", result)
+	}
+	get, _ = fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if len(get.Finalizers) != 2 {
+		t.Fatalf("expect finalizers not changed, get %v", get.Finalizers)
+	}
+}
+
+// withResourceVersionCheck makes status patches of LoadBalancers fail with conflict if the resourceVersion precondition
+// is not the latest, as api-server does
+func withResourceVersionCheck(client *fake.Clientset) {
+	client.PrependReactor("patch", "loadbalancers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(k8stesting.PatchAction)
+		var patch struct {
+			Metadata struct {
+				ResourceVersion string `json:"resourceVersion"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(patchAction.GetPatch(), &patch); err != nil || patch.Metadata.ResourceVersion == "" {
+			return false, nil, nil
+		}
+		stored, err := client.Tracker().Get(action.GetResource(), patchAction.GetNamespace(), patchAction.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		if stored.(*lbcfapi.LoadBalancer).ResourceVersion != patch.Metadata.ResourceVersion {
+			return true, nil, errors.NewConflict(action.GetResource().GroupResource(), patchAction.GetName(), fmt.Errorf("stale resourceVersion"))
+		}
+		return false, nil, nil
+	})
+}
+
+func TestLoadBalancerStatusUpdateKeepsSpec(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	lb.ResourceVersion = "1"
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+
+	// the spec and labels of lb are modified by others after it is cached
+	latest := lb.DeepCopy()
+	latest.ResourceVersion = "2"
+	latest.Spec.Attributes = map[string]string{"a": "b"}
+	latest.Labels = map[string]string{"k": "v"}
+	fakeClient := fake.NewSimpleClientset(latest)
+	withResourceVersionCheck(fakeClient)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: make(map[string]string)},
+		&fakeSuccInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsFinished() {
+		t.Fatalf("expect succ, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if !util.LBCreated(get) {
+		t.Fatalf("expect lb created, get %+v", get.Status)
+	}
+	if get.Spec.Attributes["a"] != "b" || get.Labels["k"] != "v" {
+		t.Fatalf("expect concurrent changes kept, get %+v", get)
+	}
+}
+
+func TestLoadBalancerStatusUpdateStale(t *testing.T) {
+	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
+	lb.Spec.LBDriver = "test-driver"
+	lb.ResourceVersion = "1"
+	driver := newFakeDriver(lb.Namespace, lb.Spec.LBDriver)
+
+	// the status of lb is modified by another worker after it is cached
+	latest := lb.DeepCopy()
+	latest.ResourceVersion = "2"
+	latest.Status.RunningOperation = &lbcfapi.RunningOperation{
+		Webhook:   webhooks.CreateLoadBalancer,
+		StartTime: v1.Now(),
+	}
+	fakeClient := fake.NewSimpleClientset(latest)
+	withResourceVersionCheck(fakeClient)
+	ctrl := newLoadBalancerController(
+		fakeClient,
+		&fakeLBLister{
+			get: lb,
+		},
+		&fakeDriverLister{
+			get: driver,
+		},
+		&fakeEventRecorder{store: make(map[string]string)},
+		&fakeSuccInvoker{},
+		false)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(lb)
+	result := ctrl.syncLB(key)
+	if !result.IsFailed() {
+		t.Fatalf("expect error, get %+v", result)
+	}
+	get, _ := fakeClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Get(lb.Name, v1.GetOptions{})
+	if !reflect.DeepEqual(get.Status, latest.Status) {
+		t.Fatalf("expect status not overwritten, get %+v", get.Status)
+	}
+}
+
 func TestLoadBalancerDeleteFailed(t *testing.T) {
 	timestamp := v1.Now()
 	lb := newFakeLoadBalancer("", "test-lb", nil, nil)
@@ -555,3 +945,26 @@ func TestLoadBalancerDeleteInvalid(t *testing.T) {
 		t.Fatalf("expect reason InvalidDeleteLoadBalancer, get %s", reason)
 	}
 }
+
+// k8sOpLatencyCount returns the number of latencies observed for op on obj
+func k8sOpLatencyCount(t *testing.T, obj, op string) uint64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "k8s_operation_latency" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["k8s_op_obj"] == obj && labels["k8s_op_type"] == op {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
+	"tkestack.io/lb-controlling-framework/pkg/metrics"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+)
+
+const (
+	// NotificationPath is the URL path on which drivers push notifications
+	NotificationPath = "/driver-notifications"
+
+	// notificationSubresource is the subresource of LoadBalancerDriver that drivers must be allowed to create
+	// to push notifications, e.g. verb "create" on resource "loadbalancerdrivers/notifications"
+	notificationSubresource = "notifications"
+
+	maxNotificationBodySize = 1 << 20
+)
+
+// NotificationHandler returns a http.Handler that receives notifications pushed by drivers.
+//
+// Drivers are authenticated by the bearer token in request with TokenReview, and must be allowed to
+// create loadbalancerdrivers/notifications of the driver named in the notification.
+// Both decisions are cached for a short time, see util.DelegatedAuth.
+func (c *Controller) NotificationHandler() http.Handler {
+	return http.HandlerFunc(c.serveNotification)
+}
+
+func (c *Controller) serveNotification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeNotificationResponse(w, http.StatusMethodNotAllowed, 0, "only POST is allowed")
+		return
+	}
+	// the caller is authenticated before its request body is decoded
+	user, code, err := c.auth.Authenticate(r)
+	if err != nil {
+		klog.Warningf("reject notification from %s: %v", r.RemoteAddr, err)
+		writeNotificationResponse(w, code, 0, err.Error())
+		return
+	}
+	notification := &webhooks.DriverNotification{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxNotificationBodySize)).Decode(notification); err != nil {
+		writeNotificationResponse(w, http.StatusBadRequest, 0, fmt.Sprintf("decode notification failed: %v", err))
+		return
+	}
+	if err := validateNotification(notification); err != nil {
+		writeNotificationResponse(w, http.StatusBadRequest, 0, err.Error())
+		return
+	}
+	if code, err := c.authorizeNotification(user, notification); err != nil {
+		klog.Warningf("reject notification of driver %s/%s: %v", notification.DriverNamespace, notification.DriverName, err)
+		writeNotificationResponse(w, code, 0, err.Error())
+		return
+	}
+	metrics.DriverNotificationsInc(notification.DriverName, notification.Type)
+	enqueued := c.handleNotification(notification)
+	klog.Infof("notification %s from driver %s/%s, lbInfo: %v, backendAddr: %s, msg: %s, %d objects enqueued",
+		notification.Type, notification.DriverNamespace, notification.DriverName,
+		notification.LBInfo, notification.BackendAddr, notification.Msg, enqueued)
+	writeNotificationResponse(w, http.StatusOK, enqueued, "")
+}
+
+func validateNotification(notification *webhooks.DriverNotification) error {
+	if notification.DriverName == "" || notification.DriverNamespace == "" {
+		return fmt.Errorf("driverName and driverNamespace must be set")
+	}
+	if !webhooks.KnownNotifications.Has(notification.Type) {
+		return fmt.Errorf("unknown notification type %q, supported types: %s",
+			notification.Type, strings.Join(webhooks.KnownNotifications.List(), ","))
+	}
+	if len(notification.LBInfo) == 0 && notification.BackendAddr == "" {
+		return fmt.Errorf("at least one of lbInfo and backendAddr must be set")
+	}
+	if notification.Type == webhooks.NotificationLoadBalancerDeleted && len(notification.LBInfo) == 0 {
+		return fmt.Errorf("lbInfo must be set for notification %s", notification.Type)
+	}
+	if notification.Type == webhooks.NotificationBackendUnhealthy && notification.BackendAddr == "" {
+		return fmt.Errorf("backendAddr must be set for notification %s", notification.Type)
+	}
+	return nil
+}
+
+// authorizeNotification returns the http status code and an error if user is not allowed to push notification
+func (c *Controller) authorizeNotification(user authenticationv1.UserInfo, notification *webhooks.DriverNotification) (int, error) {
+	code, err := c.auth.Authorize(user, authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace:   notification.DriverNamespace,
+			Verb:        "create",
+			Group:       lbcfapi.SchemeGroupVersion.Group,
+			Resource:    "loadbalancerdrivers",
+			Subresource: notificationSubresource,
+			Name:        notification.DriverName,
+		},
+	})
+	if err != nil {
+		return code, fmt.Errorf("user %q is not allowed to push notifications for driver %s/%s: %v",
+			user.Username, notification.DriverNamespace, notification.DriverName, err)
+	}
+	return http.StatusOK, nil
+}
+
+// handleNotification enqueues objects affected by notification immediately, and returns the number of enqueued objects
+func (c *Controller) handleNotification(notification *webhooks.DriverNotification) int {
+	lbKeys := sets.NewString()
+	backendKeys := sets.NewString()
+	bindKeys := sets.NewString()
+
+	switch notification.Type {
+	case webhooks.NotificationLoadBalancerDeleted:
+		// all BackendRecords of the deleted load balancer are affected, whatever backendAddr is
+		lbKeys = c.listLoadBalancersForNotification(notification)
+		bindKeys = c.bindController.ListRelatedBindForLoadBalancer(notification.DriverNamespace, notification.DriverName, notification.LBInfo)
+		backendKeys = c.listBackendsForNotification(notification, false)
+	case webhooks.NotificationBackendUnhealthy:
+		backendKeys = c.listBackendsForNotification(notification, true)
+	case webhooks.NotificationOperationCompleted:
+		if len(notification.LBInfo) > 0 {
+			lbKeys = c.listLoadBalancersForNotification(notification)
+			bindKeys = c.bindController.ListRelatedBindForLoadBalancer(notification.DriverNamespace, notification.DriverName, notification.LBInfo)
+		}
+		if notification.BackendAddr != "" {
+			backendKeys = c.listBackendsForNotification(notification, true)
+		}
+	}
+
+	for key := range lbKeys {
+		c.retryNow(key, c.loadBalancerQueue)
+	}
+	for key := range backendKeys {
+		c.retryNow(key, c.backendQueue)
+	}
+	for key := range bindKeys {
+		c.retryNow(key, c.bindQueue)
+	}
+	return lbKeys.Len() + backendKeys.Len() + bindKeys.Len()
+}
+
+func (c *Controller) listLoadBalancersForNotification(notification *webhooks.DriverNotification) sets.String {
+	ret := sets.NewString()
+	if c.lbIndexer != nil {
+		objs, err := c.lbIndexer.ByIndex(util.IndexLoadBalancerByLBInfo,
+			util.LBInfoIndexValue(notification.DriverNamespace, notification.DriverName, notification.LBInfo))
+		if err != nil {
+			klog.Errorf("list LoadBalancers for notification by index failed: %v", err)
+			return ret
+		}
+		for _, obj := range objs {
+			if lb, ok := obj.(*lbcfapi.LoadBalancer); ok {
+				ret.Insert(util.NamespacedNameKeyFunc(lb.Namespace, lb.Name))
+			}
+		}
+		return ret
+	}
+	lbList, err := c.lbCtrl.lister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list LoadBalancers for notification failed: %v", err)
+		return ret
+	}
+	for _, lb := range lbList {
+		if lb.Spec.LBDriver != notification.DriverName ||
+			util.NamespaceOfSharedObj(lb.Spec.LBDriver, lb.Namespace) != notification.DriverNamespace {
+			continue
+		}
+		if util.EqualStringMap(lb.Status.LBInfo, notification.LBInfo) {
+			ret.Insert(util.NamespacedNameKeyFunc(lb.Namespace, lb.Name))
+		}
+	}
+	return ret
+}
+
+func (c *Controller) listBackendsForNotification(notification *webhooks.DriverNotification, matchAddr bool) sets.String {
+	ret := sets.NewString()
+	if c.brIndexer != nil {
+		index, value := util.IndexBackendRecordByLBInfo,
+			util.LBInfoIndexValue(notification.DriverNamespace, notification.DriverName, notification.LBInfo)
+		if matchAddr {
+			index, value = util.IndexBackendRecordByAddr,
+				util.BackendAddrIndexValue(notification.DriverNamespace, notification.DriverName, notification.BackendAddr)
+		}
+		objs, err := c.brIndexer.ByIndex(index, value)
+		if err != nil {
+			klog.Errorf("list BackendRecords for notification by index failed: %v", err)
+			return ret
+		}
+		for _, obj := range objs {
+			backend, ok := obj.(*lbcfapi.BackendRecord)
+			if !ok || len(notification.LBInfo) > 0 && !util.EqualStringMap(backend.Spec.LBInfo, notification.LBInfo) {
+				continue
+			}
+			ret.Insert(util.NamespacedNameKeyFunc(backend.Namespace, backend.Name))
+		}
+		return ret
+	}
+	backendList, err := c.backendCtrl.brLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("list BackendRecords for notification failed: %v", err)
+		return ret
+	}
+	for _, backend := range backendList {
+		if backend.Spec.LBDriver != notification.DriverName ||
+			util.NamespaceOfSharedObj(backend.Spec.LBDriver, backend.Namespace) != notification.DriverNamespace {
+			continue
+		}
+		if matchAddr && backend.Status.BackendAddr != notification.BackendAddr {
+			continue
+		}
+		if len(notification.LBInfo) > 0 && !util.EqualStringMap(backend.Spec.LBInfo, notification.LBInfo) {
+			continue
+		}
+		ret.Insert(util.NamespacedNameKeyFunc(backend.Namespace, backend.Name))
+	}
+	return ret
+}
+
+func writeNotificationResponse(w http.ResponseWriter, code int, enqueued int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(&webhooks.DriverNotificationResponse{
+		Enqueued: enqueued,
+		Msg:      msg,
+	}); err != nil {
+		klog.Errorf("write notification response failed: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned/fake"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestServeNotification(t *testing.T) {
+	lb := newFakeLoadBalancer("default", "lb", nil, nil)
+	lb.Spec.LBDriver = "driver"
+	lb.Status.LBInfo = map[string]string{"id": "lb-1"}
+	otherLB := newFakeLoadBalancer("default", "other-lb", nil, nil)
+	otherLB.Spec.LBDriver = "driver"
+	otherLB.Status.LBInfo = map[string]string{"id": "lb-2"}
+
+	backend := newFakeBackendRecord("default", "backend")
+	backend.Spec.LBDriver = "driver"
+	backend.Spec.LBInfo = map[string]string{"id": "lb-1"}
+	backend.Status.BackendAddr = "1.1.1.1:80"
+	otherBackend := newFakeBackendRecord("default", "other-backend")
+	otherBackend.Spec.LBDriver = "driver"
+	otherBackend.Spec.LBInfo = map[string]string{"id": "lb-2"}
+	otherBackend.Status.BackendAddr = "2.2.2.2:80"
+
+	type testCase struct {
+		name           string
+		token          string
+		body           string
+		notification   webhooks.DriverNotification
+		expectCode     int
+		expectEnqueued int
+		expectLBQueue  int
+		expectBRQueue  int
+	}
+	cases := []testCase{
+		{
+			name:  "lb-deleted",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationLoadBalancerDeleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode:     http.StatusOK,
+			expectEnqueued: 2,
+			expectLBQueue:  1,
+			expectBRQueue:  1,
+		},
+		{
+			name:  "lb-deleted-with-backend-addr",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationLoadBalancerDeleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+				BackendAddr:     "2.2.2.2:80",
+			},
+			expectCode:     http.StatusOK,
+			expectEnqueued: 2,
+			expectLBQueue:  1,
+			expectBRQueue:  1,
+		},
+		{
+			name:  "operation-completed-with-lb-info-and-backend-addr",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationOperationCompleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+				BackendAddr:     "1.1.1.1:80",
+			},
+			expectCode:     http.StatusOK,
+			expectEnqueued: 2,
+			expectLBQueue:  1,
+			expectBRQueue:  1,
+		},
+		{
+			name:  "backend-unhealthy",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationBackendUnhealthy,
+				BackendAddr:     "2.2.2.2:80",
+			},
+			expectCode:     http.StatusOK,
+			expectEnqueued: 1,
+			expectBRQueue:  1,
+		},
+		{
+			name:  "operation-completed-other-driver",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "another-driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationOperationCompleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusOK,
+		},
+		{
+			name: "no-token",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationLoadBalancerDeleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid-body-no-token",
+			body:       "{",
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:  "invalid-token",
+			token: "invalid",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationLoadBalancerDeleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:  "forbidden",
+			token: "forbidden",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationLoadBalancerDeleted,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusForbidden,
+		},
+		{
+			name:  "unknown-type",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            "Unknown",
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:  "missing-backend-addr",
+			token: "allowed",
+			notification: webhooks.DriverNotification{
+				DriverName:      "driver",
+				DriverNamespace: "default",
+				Type:            webhooks.NotificationBackendUnhealthy,
+				LBInfo:          map[string]string{"id": "lb-1"},
+			},
+			expectCode: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		lbCtrl := newLoadBalancerController(fake.NewSimpleClientset(), &fakeLBLister{
+			list: []*lbcfapi.LoadBalancer{lb, otherLB},
+		}, &fakeDriverLister{}, &fakeEventRecorder{}, &fakeSuccInvoker{}, false)
+		backendCtrl := newBackendController(fake.NewSimpleClientset(), &fakeBackendLister{
+			list: []*lbcfapi.BackendRecord{backend, otherBackend},
+		}, &fakeDriverLister{}, &fakePodLister{}, &fakeSvcListerWithStore{}, &fakeNodeListerWithStore{}, &fakeEventRecorder{}, &fakeSuccInvoker{}, false)
+		ctrl := newFakeLBCFController(nil, lbCtrl, backendCtrl, nil)
+		ctrl.bindQueue = util.NewConditionalDelayingQueue("test", nil, time.Second, time.Second, 2*time.Second)
+		ctrl.auth = util.NewDelegatedAuth(newFakeAuthClient())
+
+		body, _ := json.Marshal(c.notification)
+		if c.body != "" {
+			body = []byte(c.body)
+		}
+		req := httptest.NewRequest(http.MethodPost, NotificationPath, bytes.NewReader(body))
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rec := httptest.NewRecorder()
+		ctrl.NotificationHandler().ServeHTTP(rec, req)
+		if rec.Code != c.expectCode {
+			t.Errorf("case %s, expect code %d, get %d, body: %s", c.name, c.expectCode, rec.Code, rec.Body.String())
+			continue
+		}
+		rsp := &webhooks.DriverNotificationResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), rsp); err != nil {
+			t.Errorf("case %s, decode response failed: %v", c.name, err)
+		} else if rsp.Enqueued != c.expectEnqueued {
+			t.Errorf("case %s, expect %d enqueued, get %d", c.name, c.expectEnqueued, rsp.Enqueued)
+		}
+		if ctrl.loadBalancerQueue.Len() != c.expectLBQueue {
+			t.Errorf("case %s, expect LoadBalancer queue %d, get %d", c.name, c.expectLBQueue, ctrl.loadBalancerQueue.Len())
+		}
+		if ctrl.backendQueue.Len() != c.expectBRQueue {
+			t.Errorf("case %s, expect BackendRecord queue %d, get %d", c.name, c.expectBRQueue, ctrl.backendQueue.Len())
+		}
+	}
+}
+
+func TestNotificationLookupByIndex(t *testing.T) {
+	lb := newFakeLoadBalancer("default", "lb", nil, nil)
+	lb.Spec.LBDriver = "driver"
+	lb.Status.LBInfo = map[string]string{"id": "lb-1", "region": "r1"}
+	otherLB := newFakeLoadBalancer("default", "other-lb", nil, nil)
+	otherLB.Spec.LBDriver = "another-driver"
+	otherLB.Status.LBInfo = map[string]string{"id": "lb-1", "region": "r1"}
+	backend := newFakeBackendRecord("default", "backend")
+	backend.Spec.LBDriver = "driver"
+	backend.Spec.LBInfo = map[string]string{"id": "lb-1", "region": "r1"}
+	backend.Status.BackendAddr = "1.1.1.1:80"
+	otherBackend := newFakeBackendRecord("default", "other-backend")
+	otherBackend.Spec.LBDriver = "driver"
+	otherBackend.Spec.LBInfo = map[string]string{"id": "lb-2"}
+	otherBackend.Status.BackendAddr = "1.1.1.1:80"
+
+	lbCtrl := newLoadBalancerController(fake.NewSimpleClientset(), &fakeLBLister{
+		list: []*lbcfapi.LoadBalancer{lb, otherLB},
+	}, &fakeDriverLister{}, &fakeEventRecorder{}, &fakeSuccInvoker{}, false)
+	backendCtrl := newBackendController(fake.NewSimpleClientset(), &fakeBackendLister{
+		list: []*lbcfapi.BackendRecord{backend, otherBackend},
+	}, &fakeDriverLister{}, &fakePodLister{}, &fakeSvcListerWithStore{}, &fakeNodeListerWithStore{}, &fakeEventRecorder{}, &fakeSuccInvoker{}, false)
+	listCtrl := newFakeLBCFController(nil, lbCtrl, backendCtrl, nil)
+	indexCtrl := newFakeLBCFController(nil, lbCtrl, backendCtrl, nil)
+	indexCtrl.lbIndexer = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		util.IndexLoadBalancerByLBInfo: util.LoadBalancerLBInfoIndexFunc,
+	})
+	indexCtrl.brIndexer = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		util.IndexBackendRecordByLBInfo: util.BackendRecordLBInfoIndexFunc,
+		util.IndexBackendRecordByAddr:   util.BackendRecordAddrIndexFunc,
+	})
+	indexCtrl.lbIndexer.Add(lb)
+	indexCtrl.lbIndexer.Add(otherLB)
+	indexCtrl.brIndexer.Add(backend)
+	indexCtrl.brIndexer.Add(otherBackend)
+
+	notifications := []*webhooks.DriverNotification{
+		{
+			DriverName:      "driver",
+			DriverNamespace: "default",
+			LBInfo:          map[string]string{"region": "r1", "id": "lb-1"},
+		},
+		{
+			DriverName:      "driver",
+			DriverNamespace: "default",
+			BackendAddr:     "1.1.1.1:80",
+		},
+		{
+			DriverName:      "driver",
+			DriverNamespace: "default",
+			LBInfo:          map[string]string{"id": "lb-2"},
+			BackendAddr:     "1.1.1.1:80",
+		},
+	}
+	for i, n := range notifications {
+		// only notifications without backendAddr here have the lbInfo of a LoadBalancer
+		if n.BackendAddr == "" {
+			expect, get := listCtrl.listLoadBalancersForNotification(n), indexCtrl.listLoadBalancersForNotification(n)
+			if expect.Len() == 0 || !expect.Equal(get) {
+				t.Errorf("case %d, expect LoadBalancers %v, get %v", i, expect.List(), get.List())
+			}
+		}
+		matchAddr := n.BackendAddr != ""
+		expect, get := listCtrl.listBackendsForNotification(n, matchAddr), indexCtrl.listBackendsForNotification(n, matchAddr)
+		if expect.Len() == 0 || !expect.Equal(get) {
+			t.Errorf("case %d, expect BackendRecords %v, get %v", i, expect.List(), get.List())
+		}
+	}
+}
+
+// newFakeAuthClient returns a fake client that authenticates token "allowed" and "forbidden",
+// and only user "allowed" is authorized
+func newFakeAuthClient() *k8sfake.Clientset {
+	client := k8sfake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		if review.Spec.Token == "allowed" || review.Spec.Token == "forbidden" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		attr := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "allowed" &&
+			attr.Resource == "loadbalancerdrivers" &&
+			attr.Subresource == notificationSubresource &&
+			attr.Verb == "create"
+		return true, sar, nil
+	})
+	return client
+}
@@ -0,0 +1,263 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// ShardByNamespace assigns BackendGroups and Binds to replicas by their namespace,
+	// LoadBalancers and LoadBalancerDrivers by their keys, and BackendRecords by the load balancer they are registered to
+	ShardByNamespace = "namespace"
+	// ShardByDriver assigns LoadBalancers, BackendRecords and LoadBalancerDrivers to replicas by driver,
+	// other objects are assigned by namespace
+	ShardByDriver = "driver"
+
+	// labelShardMember is added to the Leases of all replicas taking part in sharding
+	labelShardMember = "lbcf.tkestack.io/shard-member"
+
+	shardLeasePrefix = "lbcf-controller-shard-"
+)
+
+// shardManager maintains the Lease of the current replica, and assigns shards to live replicas.
+//
+// A replica is alive as long as its Lease is renewed within the lease duration. Shards are assigned with
+// rendezvous hashing, so when a replica joins or dies, only shards of that replica are reassigned.
+//
+// Shards gained from a member change are not owned until the lease duration has passed, by then the previous owner
+// has either noticed the change or lost its own Lease, so that no shard is processed by two replicas at the same time.
+type shardManager struct {
+	client        kubernetes.Interface
+	by            string
+	identity      string
+	namespace     string
+	leaseDuration time.Duration
+	// onChange is called after members changed
+	onChange func()
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	members []string
+	// previous is the members before the last change, shards not owned in previous are owned after handoffAt
+	previous  []string
+	handoffAt time.Time
+	// handoffPending is true if onChange should be called again after handoffAt
+	handoffPending bool
+	// renewedAt is the last time the Lease of current replica is renewed, nothing is owned after the Lease expires
+	renewedAt time.Time
+	// stopped is set after the Lease of current replica is released
+	stopped bool
+}
+
+func newShardManager(client kubernetes.Interface, by, identity, namespace string, leaseDuration time.Duration, onChange func()) (*shardManager, error) {
+	if by != ShardByNamespace && by != ShardByDriver {
+		return nil, fmt.Errorf("unsupported shard-by %q, must be %q or %q", by, ShardByNamespace, ShardByDriver)
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("shard identity must be set")
+	}
+	if leaseDuration < time.Second {
+		return nil, fmt.Errorf("shard lease duration must be at least 1s, get %s", leaseDuration.String())
+	}
+	return &shardManager{
+		client:        client,
+		by:            by,
+		identity:      identity,
+		namespace:     namespace,
+		leaseDuration: leaseDuration,
+		onChange:      onChange,
+		now:           time.Now,
+	}, nil
+}
+
+// run renews the Lease of current replica until stopCh is closed, and then releases the Lease
+func (m *shardManager) run(stopCh <-chan struct{}) {
+	wait.Until(m.sync, m.leaseDuration/3, stopCh)
+	m.release()
+}
+
+// sync renews the Lease of current replica and refreshes live members
+func (m *shardManager) sync() {
+	now := m.now()
+	renewErr := m.renew()
+	if renewErr != nil {
+		klog.Errorf("renew shard lease %s/%s failed: %v", m.namespace, shardLeasePrefix+m.identity, renewErr)
+	}
+	members, err := m.liveMembers()
+	if err != nil {
+		klog.Errorf("list shard leases failed: %v", err)
+	}
+
+	m.mu.Lock()
+	var notify bool
+	if renewErr == nil {
+		if m.expired(now) {
+			// shards of current replica may have been taken over while its Lease was expired
+			m.previous = nil
+			m.handoffAt = now.Add(m.leaseDuration)
+			m.handoffPending = true
+		}
+		m.renewedAt = now
+	}
+	if err == nil && !reflect.DeepEqual(m.members, members) {
+		klog.Infof("shard members changed to %v, shards gained are owned after %s", members, now.Add(m.leaseDuration).String())
+		m.previous = m.members
+		m.members = members
+		m.handoffAt = now.Add(m.leaseDuration)
+		m.handoffPending = true
+		notify = true
+	} else if m.handoffPending && now.After(m.handoffAt) {
+		m.handoffPending = false
+		notify = true
+	}
+	m.mu.Unlock()
+	if notify && m.onChange != nil {
+		m.onChange()
+	}
+}
+
+// expired returns true if the Lease of current replica is not renewed within the lease duration before now
+func (m *shardManager) expired(now time.Time) bool {
+	return !now.Before(m.renewedAt.Add(m.leaseDuration))
+}
+
+// release stops owning shards and deletes the Lease of current replica,
+// so that other replicas take over its shards without waiting for the Lease to expire
+func (m *shardManager) release() {
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
+	leaseName := shardLeasePrefix + m.identity
+	err := m.client.CoordinationV1().Leases(m.namespace).Delete(leaseName, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("release shard lease %s/%s failed: %v", m.namespace, leaseName, err)
+		return
+	}
+	klog.Infof("shard lease %s/%s released", m.namespace, leaseName)
+}
+
+func (m *shardManager) renew() error {
+	leaseName := shardLeasePrefix + m.identity
+	durationSeconds := int32(m.leaseDuration / time.Second)
+	renewTime := metav1.NewMicroTime(m.now())
+	lease, err := m.client.CoordinationV1().Leases(m.namespace).Get(leaseName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = m.client.CoordinationV1().Leases(m.namespace).Create(&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      leaseName,
+				Namespace: m.namespace,
+				Labels: map[string]string{
+					labelShardMember: "true",
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+	cpy := lease.DeepCopy()
+	cpy.Spec.HolderIdentity = &m.identity
+	cpy.Spec.LeaseDurationSeconds = &durationSeconds
+	cpy.Spec.RenewTime = &renewTime
+	_, err = m.client.CoordinationV1().Leases(m.namespace).Update(cpy)
+	return err
+}
+
+// liveMembers returns the sorted identities of replicas whose Lease is not expired
+func (m *shardManager) liveMembers() ([]string, error) {
+	leaseList, err := m.client.CoordinationV1().Leases(m.namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{labelShardMember: "true"}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	var members []string
+	for _, lease := range leaseList.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expireAt := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if now.Before(expireAt) {
+			members = append(members, *spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// owns returns true if shardKey is assigned to current replica.
+// Nothing is owned before current replica joins the members, after its Lease expires or after the Lease is released.
+func (m *shardManager) owns(shardKey string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	if m.stopped || m.expired(now) || shardOwner(shardKey, m.members) != m.identity {
+		return false
+	}
+	// wait until the previous owner stops processing shardKey
+	return now.After(m.handoffAt) || shardOwner(shardKey, m.previous) == m.identity
+}
+
+// shardOwner returns the member with the highest hash score of shardKey, or an empty string if there is no member
+func shardOwner(shardKey string, members []string) string {
+	var owner string
+	var maxScore uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member))
+		h.Write([]byte{0})
+		h.Write([]byte(shardKey))
+		if score := mix64(h.Sum64()); owner == "" || score > maxScore {
+			owner = member
+			maxScore = score
+		}
+	}
+	return owner
+}
+
+// mix64 is the finalizer of MurmurHash3, FNV alone distributes poorly for inputs that differ only in a few bytes
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
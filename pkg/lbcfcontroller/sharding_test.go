@@ -0,0 +1,256 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lbcfcontroller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestShardOwner(t *testing.T) {
+	if owner := shardOwner("default", nil); owner != "" {
+		t.Fatalf("expect no owner, get %s", owner)
+	}
+	members := []string{"replica-0", "replica-1", "replica-2"}
+	owners := make(map[string]string)
+	count := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+		owners[key] = shardOwner(key, members)
+		count[owners[key]]++
+	}
+	for _, member := range members {
+		if count[member] == 0 {
+			t.Errorf("expect shards assigned to %s", member)
+		}
+	}
+
+	// only shards of the dead replica are reassigned
+	alive := []string{"replica-0", "replica-2"}
+	for key, oldOwner := range owners {
+		newOwner := shardOwner(key, alive)
+		if oldOwner != "replica-1" && newOwner != oldOwner {
+			t.Errorf("shard %s is reassigned from %s to %s", key, oldOwner, newOwner)
+		} else if newOwner == "replica-1" {
+			t.Errorf("shard %s is assigned to dead replica", key)
+		}
+	}
+}
+
+func TestShardManagerSync(t *testing.T) {
+	now := time.Now()
+	expiredAt := metav1.NewMicroTime(now.Add(-time.Minute))
+	duration := int32(15)
+	deadIdentity := "replica-dead"
+	client := k8sfake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shardLeasePrefix + deadIdentity,
+			Namespace: "kube-system",
+			Labels:    map[string]string{labelShardMember: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &deadIdentity,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &expiredAt,
+		},
+	})
+	changed := 0
+	m, err := newShardManager(client, ShardByNamespace, "replica-0", "kube-system", 15*time.Second, func() {
+		changed++
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.now = func() time.Time { return now }
+	if m.owns("default") {
+		t.Fatalf("expect nothing owned before joining members")
+	}
+
+	m.sync()
+	if changed != 1 {
+		t.Fatalf("expect onChange called once, get %d", changed)
+	}
+	if len(m.members) != 1 || m.members[0] != "replica-0" {
+		t.Fatalf("expect members [replica-0], get %v", m.members)
+	}
+	if m.owns("default") {
+		t.Fatalf("expect shards gained not owned before the previous owner's lease expires")
+	}
+	lease, err := client.CoordinationV1().Leases("kube-system").Get(shardLeasePrefix+"replica-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expect lease created, get err: %v", err)
+	}
+	if *lease.Spec.HolderIdentity != "replica-0" || *lease.Spec.LeaseDurationSeconds != 15 {
+		t.Fatalf("unexpected lease spec: %+v", lease.Spec)
+	}
+
+	// renew without member change
+	m.now = func() time.Time { return now.Add(5 * time.Second) }
+	m.sync()
+	if changed != 1 {
+		t.Fatalf("expect onChange not called, get %d", changed)
+	}
+	lease, _ = client.CoordinationV1().Leases("kube-system").Get(shardLeasePrefix+"replica-0", metav1.GetOptions{})
+	if !lease.Spec.RenewTime.Time.Equal(now.Add(5 * time.Second)) {
+		t.Fatalf("expect lease renewed, get %v", lease.Spec.RenewTime)
+	}
+
+	// shards gained are owned and resynced after the lease duration
+	m.now = func() time.Time { return now.Add(16 * time.Second) }
+	m.sync()
+	if changed != 2 {
+		t.Fatalf("expect onChange called after handoff, get %d", changed)
+	}
+	if !m.owns("default") || !m.owns("kube-system") {
+		t.Fatalf("expect all shards owned by the only member")
+	}
+	m.now = func() time.Time { return now.Add(21 * time.Second) }
+	m.sync()
+	if changed != 2 {
+		t.Fatalf("expect onChange not called, get %d", changed)
+	}
+
+	// nothing is owned after the lease of current replica expires
+	m.now = func() time.Time { return now.Add(40 * time.Second) }
+	if m.owns("default") {
+		t.Fatalf("expect nothing owned after lease expired")
+	}
+
+	// nothing is owned after the lease is released
+	m.now = func() time.Time { return now.Add(22 * time.Second) }
+	m.release()
+	if m.owns("default") {
+		t.Fatalf("expect nothing owned after lease released")
+	}
+	if _, err := client.CoordinationV1().Leases("kube-system").Get(shardLeasePrefix+"replica-0", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("expect lease deleted, get err: %v", err)
+	}
+}
+
+func TestShardManagerHandoff(t *testing.T) {
+	now := time.Now()
+	m := &shardManager{
+		identity:      "replica-0",
+		leaseDuration: 15 * time.Second,
+		now:           func() time.Time { return now },
+		renewedAt:     now,
+		members:       []string{"replica-0"},
+		previous:      []string{"replica-0", "replica-1"},
+		handoffAt:     now.Add(15 * time.Second),
+	}
+	// shards of the dead replica-1 are gained, others are kept
+	var gained, kept string
+	for i := 0; gained == "" || kept == ""; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+		if shardOwner(key, m.previous) == "replica-0" {
+			kept = key
+		} else {
+			gained = key
+		}
+	}
+	if m.owns(gained) {
+		t.Fatalf("expect %s not owned before the previous owner's lease expires", gained)
+	}
+	if !m.owns(kept) {
+		t.Fatalf("expect %s owned", kept)
+	}
+	m.now = func() time.Time { return now.Add(16 * time.Second) }
+	m.renewedAt = now.Add(10 * time.Second)
+	if !m.owns(gained) {
+		t.Fatalf("expect %s owned after handoff", gained)
+	}
+}
+
+func TestNewShardManagerInvalid(t *testing.T) {
+	if _, err := newShardManager(k8sfake.NewSimpleClientset(), "pod", "replica-0", "kube-system", 15*time.Second, nil); err == nil {
+		t.Errorf("expect error for invalid shard-by")
+	}
+	if _, err := newShardManager(k8sfake.NewSimpleClientset(), ShardByDriver, "", "kube-system", 15*time.Second, nil); err == nil {
+		t.Errorf("expect error for empty identity")
+	}
+}
+
+func TestEnqueueFilteredByShard(t *testing.T) {
+	lbCtrl := newLoadBalancerController(nil, &fakeLBLister{}, &fakeDriverLister{}, &fakeEventRecorder{}, &fakeSuccInvoker{}, false)
+	c := newFakeLBCFController(nil, lbCtrl, nil, nil)
+	c.shard = &shardManager{by: ShardByNamespace, identity: "replica-0", leaseDuration: 15 * time.Second, now: time.Now}
+
+	c.enqueue(newFakeLoadBalancer("default", "lb", nil, nil), c.loadBalancerQueue)
+	if c.loadBalancerQueue.Len() != 0 {
+		t.Fatalf("expect nothing enqueued before joining members, get %d", c.loadBalancerQueue.Len())
+	}
+
+	c.shard.members = []string{"replica-0", "replica-1"}
+	c.shard.renewedAt = time.Now()
+	var owned, notOwned string
+	for i := 0; owned == "" || notOwned == ""; i++ {
+		ns := fmt.Sprintf("namespace-%d", i)
+		if shardOwner(ns+"/lb", c.shard.members) == "replica-0" {
+			owned = ns
+		} else {
+			notOwned = ns
+		}
+	}
+	c.enqueue(newFakeLoadBalancer(owned, "lb", nil, nil), c.loadBalancerQueue)
+	c.enqueue(newFakeLoadBalancer(notOwned, "lb", nil, nil), c.loadBalancerQueue)
+	c.enqueue(notOwned+"/lb", c.loadBalancerQueue)
+	if c.loadBalancerQueue.Len() != 1 {
+		t.Fatalf("expect 1 enqueued, get %d", c.loadBalancerQueue.Len())
+	}
+	key, _ := c.loadBalancerQueue.Get()
+	if key.(string) != owned+"/lb" {
+		t.Fatalf("expect %s/lb, get %v", owned, key)
+	}
+}
+
+func TestShardKeyOfBackendRecord(t *testing.T) {
+	c := newFakeLBCFController(nil, nil, nil, nil)
+	c.shard = &shardManager{by: ShardByNamespace}
+	lbInfo := map[string]string{"lbID": "lb-1"}
+	backend := newFakeBackendRecord("namespace-a", "backend-a")
+	backend.Spec.LBDriver = "lbcf-driver"
+	backend.Spec.LBInfo = lbInfo
+	other := newFakeBackendRecord("namespace-b", "backend-b")
+	other.Spec.LBDriver = "lbcf-driver"
+	other.Spec.LBInfo = lbInfo
+
+	// records of the same load balancer in different namespaces are handled by the same replica
+	if a, b := c.shardKey(backend, c.backendQueue), c.shardKey(other, c.backendQueue); a != b {
+		t.Fatalf("expect the same shard key, get %s and %s", a, b)
+	}
+	other.Spec.LBInfo = map[string]string{"lbID": "lb-2"}
+	if a, b := c.shardKey(backend, c.backendQueue), c.shardKey(other, c.backendQueue); a == b {
+		t.Fatalf("expect different shard keys, get %s", a)
+	}
+
+	// shared objects in kube-system are assigned by their own keys
+	if a, b := c.shardKey(newFakeLoadBalancer("kube-system", "lbcf-lb-1", nil, nil), c.loadBalancerQueue),
+		c.shardKey(newFakeLoadBalancer("kube-system", "lbcf-lb-2", nil, nil), c.loadBalancerQueue); a == b {
+		t.Fatalf("expect different shard keys of LoadBalancers, get %s", a)
+	}
+	if a, b := c.shardKey(newFakeDriver("kube-system", "lbcf-driver-1"), c.driverQueue),
+		c.shardKey(newFakeDriver("kube-system", "lbcf-driver-2"), c.driverQueue); a == b {
+		t.Fatalf("expect different shard keys of drivers, get %s", a)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// the TTLs are the defaults of the delegating authenticator and authorizer of kube-apiserver
+	authnCacheTTL      = 10 * time.Second
+	authzAllowCacheTTL = 10 * time.Second
+	authzDenyCacheTTL  = 10 * time.Second
+	authCacheSize      = 1024
+)
+
+// AuthenticateRequest authenticates the bearer token in r with TokenReview.
+// It returns the user and http.StatusOK if authenticated, otherwise the http status code and an error.
+func AuthenticateRequest(client kubernetes.Interface, r *http.Request) (authenticationv1.UserInfo, int, error) {
+	if client == nil {
+		return authenticationv1.UserInfo{}, http.StatusServiceUnavailable, fmt.Errorf("authenticator is not available")
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || len(strings.TrimSpace(auth[len("Bearer "):])) == 0 {
+		return authenticationv1.UserInfo{}, http.StatusUnauthorized, fmt.Errorf("bearer token is required")
+	}
+	review, err := client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: strings.TrimSpace(auth[len("Bearer "):]),
+		},
+	})
+	if err != nil {
+		return authenticationv1.UserInfo{}, http.StatusInternalServerError, fmt.Errorf("token review failed: %v", err)
+	}
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, http.StatusUnauthorized, fmt.Errorf("unauthenticated: %s", review.Status.Error)
+	}
+	return review.Status.User, http.StatusOK, nil
+}
+
+// AuthorizeUser checks if user is allowed to access the resource or non-resource URL in spec with SubjectAccessReview.
+// It returns http.StatusOK if allowed, otherwise the http status code and an error.
+func AuthorizeUser(client kubernetes.Interface, user authenticationv1.UserInfo, spec authorizationv1.SubjectAccessReviewSpec) (int, error) {
+	extra := make(map[string]authorizationv1.ExtraValue)
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	spec.User = user.Username
+	spec.UID = user.UID
+	spec.Groups = user.Groups
+	spec.Extra = extra
+	sar, err := client.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: spec,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("subject access review failed: %v", err)
+	}
+	if !sar.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("user %q is not allowed: %s", user.Username, sar.Status.Reason)
+	}
+	return http.StatusOK, nil
+}
+
+// DelegatedAuth authenticates and authorizes requests with TokenReview and SubjectAccessReview.
+// Decisions are cached for a short time like the delegating authenticator and authorizer of kube-apiserver,
+// so that frequent requests of the same user do not create reviews every time.
+// Failed reviews are not cached.
+type DelegatedAuth struct {
+	client     kubernetes.Interface
+	authnCache *cache.LRUExpireCache
+	authzCache *cache.LRUExpireCache
+}
+
+type authResult struct {
+	user authenticationv1.UserInfo
+	code int
+	err  error
+}
+
+// NewDelegatedAuth creates a DelegatedAuth that sends reviews with client
+func NewDelegatedAuth(client kubernetes.Interface) *DelegatedAuth {
+	return &DelegatedAuth{
+		client:     client,
+		authnCache: cache.NewLRUExpireCache(authCacheSize),
+		authzCache: cache.NewLRUExpireCache(authCacheSize),
+	}
+}
+
+// Authenticate is AuthenticateRequest with cached results, tokens are cached by their hash
+func (a *DelegatedAuth) Authenticate(r *http.Request) (authenticationv1.UserInfo, int, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return AuthenticateRequest(a.client, r)
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(auth[len("Bearer "):])))
+	key := hex.EncodeToString(sum[:])
+	if cached, ok := a.authnCache.Get(key); ok {
+		result := cached.(authResult)
+		return result.user, result.code, result.err
+	}
+	user, code, err := AuthenticateRequest(a.client, r)
+	if code == http.StatusOK || code == http.StatusUnauthorized {
+		a.authnCache.Add(key, authResult{user: user, code: code, err: err}, authnCacheTTL)
+	}
+	return user, code, err
+}
+
+// Authorize is AuthorizeUser with cached results, allowed and denied decisions are cached separately
+func (a *DelegatedAuth) Authorize(user authenticationv1.UserInfo, spec authorizationv1.SubjectAccessReviewSpec) (int, error) {
+	b, err := json.Marshal(struct {
+		User authenticationv1.UserInfo
+		Spec authorizationv1.SubjectAccessReviewSpec
+	}{user, spec})
+	if err != nil {
+		return AuthorizeUser(a.client, user, spec)
+	}
+	key := string(b)
+	if cached, ok := a.authzCache.Get(key); ok {
+		result := cached.(authResult)
+		return result.code, result.err
+	}
+	code, err := AuthorizeUser(a.client, user, spec)
+	switch code {
+	case http.StatusOK:
+		a.authzCache.Add(key, authResult{code: code}, authzAllowCacheTTL)
+	case http.StatusForbidden:
+		a.authzCache.Add(key, authResult{code: code, err: err}, authzDenyCacheTTL)
+	}
+	return code, err
+}
+
+// WithDelegatedAuth wraps handler so that only requests authorized by kube-apiserver are served.
+// Users must be allowed to access the non-resource URL of the request with the verb of the http method in lower case.
+func WithDelegatedAuth(client kubernetes.Interface, handler http.Handler) http.Handler {
+	auth := NewDelegatedAuth(client)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, code, err := auth.Authenticate(r)
+		if err == nil {
+			code, err = auth.Authorize(user, authorizationv1.SubjectAccessReviewSpec{
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: r.URL.Path,
+					Verb: strings.ToLower(r.Method),
+				},
+			})
+		}
+		if err != nil {
+			klog.V(3).Infof("reject %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, err.Error(), code)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWithDelegatedAuth(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		if review.Spec.Token == "allowed" || review.Spec.Token == "forbidden" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		attr := sar.Spec.NonResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "allowed" && attr != nil && attr.Path == "/metrics" && attr.Verb == "get"
+		return true, sar, nil
+	})
+	handler := WithDelegatedAuth(client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	cases := []struct {
+		name       string
+		token      string
+		expectCode int
+	}{
+		{
+			name:       "no-token",
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "unauthenticated",
+			token:      "unknown",
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "forbidden",
+			token:      "forbidden",
+			expectCode: http.StatusForbidden,
+		},
+		{
+			name:       "allowed",
+			token:      "allowed",
+			expectCode: http.StatusOK,
+		},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		if rsp.Code != c.expectCode {
+			t.Errorf("case %s, expect code %d, get %d", c.name, c.expectCode, rsp.Code)
+		}
+	}
+}
+
+func TestDelegatedAuthCache(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	var tokenReviews, accessReviews int
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tokenReviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		review.Status.Authenticated = review.Spec.Token == "allowed"
+		review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		accessReviews++
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		sar.Status.Allowed = sar.Spec.NonResourceAttributes.Path == "/metrics"
+		return true, sar, nil
+	})
+	auth := NewDelegatedAuth(client)
+	for i := 0; i < 3; i++ {
+		for _, token := range []string{"allowed", "unknown"} {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if _, code, _ := auth.Authenticate(req); (code == http.StatusOK) != (token == "allowed") {
+				t.Fatalf("token %s, unexpected code %d", token, code)
+			}
+		}
+		for _, path := range []string{"/metrics", "/debug"} {
+			code, _ := auth.Authorize(authenticationv1.UserInfo{Username: "allowed"}, authorizationv1.SubjectAccessReviewSpec{
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: path, Verb: "get"},
+			})
+			if (code == http.StatusOK) != (path == "/metrics") {
+				t.Fatalf("path %s, unexpected code %d", path, code)
+			}
+		}
+	}
+	if tokenReviews != 2 {
+		t.Errorf("expect 2 TokenReviews, get %d", tokenReviews)
+	}
+	if accessReviews != 2 {
+		t.Errorf("expect 2 SubjectAccessReviews, get %d", accessReviews)
+	}
+}
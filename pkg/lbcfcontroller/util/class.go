@@ -0,0 +1,104 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	lbcflister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// ControllerNameOfDriver returns the name of the controller that handles objects using driver
+func ControllerNameOfDriver(driver *lbcfapi.LoadBalancerDriver) string {
+	if driver.Spec.ControllerName == "" {
+		return lbcfapi.DefaultControllerName
+	}
+	return driver.Spec.ControllerName
+}
+
+// ControllerClass decides whether an object is handled by the controller named name.
+//
+// An object is handled by the controller of its driver. Objects whose driver can not be found,
+// e.g. a BackendGroup referencing LoadBalancers that don't exist, are handled by the default controller.
+type ControllerClass struct {
+	name         string
+	driverLister lbcflister.LoadBalancerDriverLister
+	lbLister     lbcflister.LoadBalancerLister
+}
+
+// NewControllerClass creates a new ControllerClass, it never returns nil, an empty name means the default controller
+func NewControllerClass(name string, driverLister lbcflister.LoadBalancerDriverLister, lbLister lbcflister.LoadBalancerLister) *ControllerClass {
+	if name == "" {
+		name = lbcfapi.DefaultControllerName
+	}
+	return &ControllerClass{
+		name:         name,
+		driverLister: driverLister,
+		lbLister:     lbLister,
+	}
+}
+
+// Name returns the controller name
+func (c *ControllerClass) Name() string {
+	return c.name
+}
+
+// Owns returns true if obj is handled by this controller.
+// obj must be one of LoadBalancerDriver, LoadBalancer, BackendGroup, BackendRecord and Bind,
+// other objects are always owned.
+func (c *ControllerClass) Owns(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	switch o := obj.(type) {
+	case *lbcfapi.LoadBalancerDriver:
+		return ControllerNameOfDriver(o) == c.name
+	case *lbcfapi.LoadBalancer:
+		return c.ownsDriver(o.Spec.LBDriver, o.Namespace)
+	case *lbcfapi.BackendRecord:
+		return c.ownsDriver(o.Spec.LBDriver, o.Namespace)
+	case *lbcfapi.BackendGroup:
+		for _, lbName := range o.Spec.GetLoadBalancers() {
+			lb, err := c.lbLister.LoadBalancers(NamespaceOfSharedObj(lbName, o.Namespace)).Get(lbName)
+			if err == nil {
+				return c.ownsDriver(lb.Spec.LBDriver, lb.Namespace)
+			}
+		}
+		return c.isDefault()
+	case *lbcfv1.Bind:
+		if len(o.Spec.LoadBalancers) > 0 {
+			return c.ownsDriver(o.Spec.LoadBalancers[0].Driver, o.Namespace)
+		}
+		return c.isDefault()
+	}
+	return true
+}
+
+func (c *ControllerClass) ownsDriver(driverName string, namespace string) bool {
+	driver, err := c.driverLister.LoadBalancerDrivers(NamespaceOfSharedObj(driverName, namespace)).Get(driverName)
+	if err != nil {
+		return c.isDefault()
+	}
+	return ControllerNameOfDriver(driver) == c.name
+}
+
+func (c *ControllerClass) isDefault() bool {
+	return c.name == lbcfapi.DefaultControllerName
+}
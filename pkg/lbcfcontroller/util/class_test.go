@@ -0,0 +1,107 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"testing"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestControllerClass(t *testing.T) {
+	driverLister := &fakeDriverListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancerDriver{
+			"default-driver": {
+				ObjectMeta: v1.ObjectMeta{Name: "default-driver"},
+			},
+			"canary-driver": {
+				ObjectMeta: v1.ObjectMeta{Name: "canary-driver"},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					ControllerName: "canary",
+				},
+			},
+		},
+	}
+	lbLister := &fakeLBListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancer{
+			"canary-lb": {
+				ObjectMeta: v1.ObjectMeta{Name: "canary-lb"},
+				Spec:       lbcfapi.LoadBalancerSpec{LBDriver: "canary-driver"},
+			},
+		},
+	}
+	defaultClass := NewControllerClass("", driverLister, lbLister)
+	canaryClass := NewControllerClass("canary", driverLister, lbLister)
+
+	type testCase struct {
+		name         string
+		obj          interface{}
+		ownByDefault bool
+	}
+	cases := []testCase{
+		{
+			name:         "default-driver",
+			obj:          driverLister.store["default-driver"],
+			ownByDefault: true,
+		},
+		{
+			name: "canary-driver",
+			obj:  driverLister.store["canary-driver"],
+		},
+		{
+			name:         "lb-of-default-driver",
+			obj:          &lbcfapi.LoadBalancer{Spec: lbcfapi.LoadBalancerSpec{LBDriver: "default-driver"}},
+			ownByDefault: true,
+		},
+		{
+			name:         "lb-of-driver-not-found",
+			obj:          &lbcfapi.LoadBalancer{Spec: lbcfapi.LoadBalancerSpec{LBDriver: "not-exist"}},
+			ownByDefault: true,
+		},
+		{
+			name: "backend-of-canary-driver",
+			obj:  &lbcfapi.BackendRecord{Spec: lbcfapi.BackendRecordSpec{LBDriver: "canary-driver"}},
+		},
+		{
+			name: "backendgroup-of-canary-lb",
+			obj:  &lbcfapi.BackendGroup{Spec: lbcfapi.BackendGroupSpec{LoadBalancers: []string{"canary-lb"}}},
+		},
+		{
+			name:         "backendgroup-of-lb-not-found",
+			obj:          &lbcfapi.BackendGroup{Spec: lbcfapi.BackendGroupSpec{LoadBalancers: []string{"not-exist"}}},
+			ownByDefault: true,
+		},
+		{
+			name: "bind-of-canary-driver",
+			obj: &lbcfv1.Bind{Spec: lbcfv1.BindSpec{LoadBalancers: []lbcfv1.TargetLoadBalancer{
+				{Name: "lb", Driver: "canary-driver"},
+			}}},
+		},
+	}
+	for _, c := range cases {
+		if get := defaultClass.Owns(c.obj); get != c.ownByDefault {
+			t.Errorf("case %s, expect owned by default controller %v, get %v", c.name, c.ownByDefault, get)
+		}
+		if get := canaryClass.Owns(c.obj); get == c.ownByDefault {
+			t.Errorf("case %s, expect owned by canary controller %v, get %v", c.name, !c.ownByDefault, get)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/listers/core/v1"
+)
+
+// MemberClusterKubeConfigKey is the key of kubeconfig in Secrets of member clusters
+const MemberClusterKubeConfigKey = "kubeconfig"
+
+// MemberCluster is a cluster whose Pods can be selected by BackendGroups and Binds
+type MemberCluster struct {
+	Name string
+	// SecretNamespace and SecretName refer to the Secret in local cluster that stores kubeconfig of the member cluster
+	SecretNamespace string
+	SecretName      string
+}
+
+// ParseMemberCluster parses a member cluster in format <name>=<namespace>/<secret>
+func ParseMemberCluster(raw string) (MemberCluster, error) {
+	kv := strings.SplitN(raw, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return MemberCluster{}, fmt.Errorf("invalid member cluster %q, expect <name>=<namespace>/<secret>", raw)
+	}
+	if kv[0] == lbcfapi.LocalClusterName {
+		return MemberCluster{}, fmt.Errorf("invalid member cluster %q, name %q is reserved for the local cluster", raw, lbcfapi.LocalClusterName)
+	}
+	secret := strings.SplitN(kv[1], "/", 2)
+	if len(secret) != 2 || secret[0] == "" || secret[1] == "" {
+		return MemberCluster{}, fmt.Errorf("invalid member cluster %q, expect <name>=<namespace>/<secret>", raw)
+	}
+	return MemberCluster{
+		Name:            kv[0],
+		SecretNamespace: secret[0],
+		SecretName:      secret[1],
+	}, nil
+}
+
+// PodClusters returns the clusters where Pods are selected, the local cluster is returned as an empty string
+func PodClusters(clusters []string) []string {
+	if len(clusters) == 0 {
+		return []string{""}
+	}
+	var ret []string
+	for _, c := range clusters {
+		if c == lbcfapi.LocalClusterName {
+			c = ""
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// MemberClusterSet is the set of member clusters that are ready, whose Pods are cached and can be listed.
+// Member clusters are added and removed when their kubeconfig Secrets change, all methods are safe for concurrent use.
+// A nil MemberClusterSet is empty.
+type MemberClusterSet struct {
+	mu         sync.RWMutex
+	clients    map[string]kubernetes.Interface
+	podListers map[string]corev1.PodLister
+}
+
+// NewMemberClusterSet creates an empty MemberClusterSet
+func NewMemberClusterSet() *MemberClusterSet {
+	return &MemberClusterSet{
+		clients:    make(map[string]kubernetes.Interface),
+		podListers: make(map[string]corev1.PodLister),
+	}
+}
+
+// Set adds member cluster name, or replaces its client and PodLister
+func (s *MemberClusterSet) Set(name string, client kubernetes.Interface, podLister corev1.PodLister) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[name] = client
+	s.podListers[name] = podLister
+}
+
+// Remove removes member cluster name
+func (s *MemberClusterSet) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, name)
+	delete(s.podListers, name)
+}
+
+// Client returns the client of member cluster name
+func (s *MemberClusterSet) Client(name string) (kubernetes.Interface, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.clients[name]
+	return client, ok
+}
+
+// PodLister returns the PodLister of member cluster name
+func (s *MemberClusterSet) PodLister(name string) (corev1.PodLister, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lister, ok := s.podListers[name]
+	return lister, ok
+}
+
+// ClusterPodLister returns the PodLister of cluster, empty cluster means the local cluster.
+// An error is returned if the member cluster is unknown or not ready
+func ClusterPodLister(local corev1.PodLister, members *MemberClusterSet, cluster string) (corev1.PodLister, error) {
+	if cluster == "" || cluster == lbcfapi.LocalClusterName {
+		return local, nil
+	}
+	if lister, ok := members.PodLister(cluster); ok {
+		return lister, nil
+	}
+	return nil, fmt.Errorf("member cluster %q is unknown or not ready", cluster)
+}
+
+// ClusterBackendRecords returns the BackendRecords of Pods in cluster, empty cluster means the local cluster
+func ClusterBackendRecords(records []*lbcfapi.BackendRecord, cluster string) []*lbcfapi.BackendRecord {
+	var ret []*lbcfapi.BackendRecord
+	for _, r := range records {
+		if r.Spec.PodBackendInfo != nil && r.Spec.PodBackendInfo.Cluster == cluster {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
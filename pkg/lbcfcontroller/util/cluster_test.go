@@ -0,0 +1,116 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1 "k8s.io/client-go/listers/core/v1"
+)
+
+func TestParseMemberCluster(t *testing.T) {
+	cluster, err := ParseMemberCluster("member=kube-system/member-kubeconfig")
+	if err != nil {
+		t.Fatalf("expect no error, get %v", err)
+	}
+	expect := MemberCluster{Name: "member", SecretNamespace: "kube-system", SecretName: "member-kubeconfig"}
+	if cluster != expect {
+		t.Fatalf("expect %+v, get %+v", expect, cluster)
+	}
+
+	invalid := []string{
+		"",
+		"member",
+		"=kube-system/secret",
+		"member=secret",
+		"member=/secret",
+		"member=kube-system/",
+		lbcfapi.LocalClusterName + "=kube-system/secret",
+	}
+	for _, raw := range invalid {
+		if _, err := ParseMemberCluster(raw); err == nil {
+			t.Errorf("expect error for %q", raw)
+		}
+	}
+}
+
+func TestPodClusters(t *testing.T) {
+	if get := PodClusters(nil); !reflect.DeepEqual(get, []string{""}) {
+		t.Errorf("expect only the local cluster, get %v", get)
+	}
+	get := PodClusters([]string{lbcfapi.LocalClusterName, "member"})
+	if !reflect.DeepEqual(get, []string{"", "member"}) {
+		t.Errorf("expect local cluster and member, get %v", get)
+	}
+}
+
+type namedPodLister struct {
+	name string
+}
+
+func (l *namedPodLister) List(selector labels.Selector) ([]*v1.Pod, error) {
+	return nil, nil
+}
+
+func (l *namedPodLister) Pods(namespace string) corev1.PodNamespaceLister {
+	return nil
+}
+
+func TestClusterPodLister(t *testing.T) {
+	local := &namedPodLister{name: "local"}
+	member := &namedPodLister{name: "member"}
+	members := NewMemberClusterSet()
+	members.Set("member", nil, member)
+
+	for _, cluster := range []string{"", lbcfapi.LocalClusterName} {
+		if lister, err := ClusterPodLister(local, members, cluster); err != nil || lister != local {
+			t.Errorf("cluster %q, expect local lister, get %v, %v", cluster, lister, err)
+		}
+	}
+	if lister, err := ClusterPodLister(local, members, "member"); err != nil || lister != member {
+		t.Errorf("expect member lister, get %v, %v", lister, err)
+	}
+	if _, err := ClusterPodLister(local, members, "unknown"); err == nil {
+		t.Errorf("expect error for unknown cluster")
+	}
+	members.Remove("member")
+	if _, err := ClusterPodLister(local, members, "member"); err == nil {
+		t.Errorf("expect error for removed cluster")
+	}
+	if _, err := ClusterPodLister(local, nil, "member"); err == nil {
+		t.Errorf("expect error if no member cluster is configured")
+	}
+}
+
+func TestClusterBackendRecords(t *testing.T) {
+	local := &lbcfapi.BackendRecord{Spec: lbcfapi.BackendRecordSpec{PodBackendInfo: &lbcfapi.PodBackendRecord{Name: "local"}}}
+	member := &lbcfapi.BackendRecord{Spec: lbcfapi.BackendRecordSpec{PodBackendInfo: &lbcfapi.PodBackendRecord{Name: "member", Cluster: "member"}}}
+	static := &lbcfapi.BackendRecord{Spec: lbcfapi.BackendRecordSpec{}}
+	records := []*lbcfapi.BackendRecord{local, member, static}
+	if get := ClusterBackendRecords(records, ""); !reflect.DeepEqual(get, []*lbcfapi.BackendRecord{local}) {
+		t.Errorf("expect local BackendRecord, get %v", get)
+	}
+	if get := ClusterBackendRecords(records, "member"); !reflect.DeepEqual(get, []*lbcfapi.BackendRecord{member}) {
+		t.Errorf("expect member BackendRecord, get %v", get)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventPolicy controls how events are emitted
+type EventPolicy struct {
+	// SuppressReasons are reasons of Normal events that are not emitted, e.g. routine success events
+	SuppressReasons []string
+	// AggregateWindow is the window that similar events of an object, i.e. events with the same reason, are aggregated in
+	AggregateWindow time.Duration
+	// AggregateMaxEvents is the number of similar events of an object in AggregateWindow before they are aggregated
+	AggregateMaxEvents int
+	// QPS and Burst limit the events of each object
+	QPS   float32
+	Burst int
+}
+
+// CorrelatorOptions returns the options for record.NewBroadcasterWithCorrelatorOptions, zero values mean defaults of client-go
+func (p *EventPolicy) CorrelatorOptions() record.CorrelatorOptions {
+	return record.CorrelatorOptions{
+		MaxEvents:            p.AggregateMaxEvents,
+		MaxIntervalInSeconds: int(p.AggregateWindow / time.Second),
+		QPS:                  p.QPS,
+		BurstSize:            p.Burst,
+	}
+}
+
+// NewSuppressingEventRecorder returns a record.EventRecorder that drops Normal events with reasons in suppressReasons
+func NewSuppressingEventRecorder(recorder record.EventRecorder, suppressReasons []string) record.EventRecorder {
+	if len(suppressReasons) == 0 {
+		return recorder
+	}
+	return &suppressingEventRecorder{
+		recorder: recorder,
+		suppress: sets.NewString(suppressReasons...),
+	}
+}
+
+type suppressingEventRecorder struct {
+	recorder record.EventRecorder
+	suppress sets.String
+}
+
+func (r *suppressingEventRecorder) suppressed(eventtype, reason string) bool {
+	return eventtype == v1.EventTypeNormal && r.suppress.Has(reason)
+}
+
+func (r *suppressingEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if !r.suppressed(eventtype, reason) {
+		r.recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r *suppressingEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if !r.suppressed(eventtype, reason) {
+		r.recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *suppressingEventRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	if !r.suppressed(eventtype, reason) {
+		r.recorder.PastEventf(object, timestamp, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *suppressingEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if !r.suppressed(eventtype, reason) {
+		r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
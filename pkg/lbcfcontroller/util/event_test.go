@@ -0,0 +1,122 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSuppressingEventRecorder(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	recorder := NewSuppressingEventRecorder(fake, []string{"SuccSynced"})
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}}
+
+	recorder.Eventf(pod, v1.EventTypeNormal, "SuccSynced", "msg")
+	recorder.Event(pod, v1.EventTypeNormal, "SuccSynced", "msg")
+	recorder.Eventf(pod, v1.EventTypeWarning, "SuccSynced", "msg")
+	recorder.Eventf(pod, v1.EventTypeNormal, "SuccCreated", "msg")
+	close(fake.Events)
+	var got []string
+	for e := range fake.Events {
+		got = append(got, e)
+	}
+	expect := []string{"Warning SuccSynced msg", "Normal SuccCreated msg"}
+	if len(got) != len(expect) || got[0] != expect[0] || got[1] != expect[1] {
+		t.Errorf("expect %v, get %v", expect, got)
+	}
+
+	if r := NewSuppressingEventRecorder(fake, nil); r != fake {
+		t.Errorf("expect recorder not wrapped if no reason is suppressed")
+	}
+}
+
+func TestEventPolicyRateLimit(t *testing.T) {
+	// limits of client-go are used by default, the policy here is set explicitly to be different from them
+	policy := &EventPolicy{
+		AggregateWindow:    time.Minute,
+		AggregateMaxEvents: 10,
+		QPS:                1.0 / 3600,
+		Burst:              2,
+	}
+	opts := policy.CorrelatorOptions()
+	if opts.MaxIntervalInSeconds != 60 || opts.MaxEvents != 10 || opts.BurstSize != 2 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	sink := &fakeEventSink{}
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(opts)
+	defer broadcaster.Shutdown()
+	broadcaster.StartRecordingToSink(sink)
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "test"})
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", UID: types.UID("uid")}}
+	another := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "another", Namespace: "ns", UID: types.UID("another")}}
+	for i := 0; i < 5; i++ {
+		recorder.Eventf(pod, v1.EventTypeWarning, "Failed", "failure %d", i)
+	}
+	recorder.Eventf(another, v1.EventTypeWarning, "Failed", "failure")
+
+	// 2 events of pod are allowed by burst, and events of another pod are not limited
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return sink.count() >= 3, nil
+	}); err != nil {
+		t.Fatalf("expect 3 events, get %d", sink.count())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if sink.count() != 3 {
+		t.Errorf("expect 3 events, get %d", sink.count())
+	}
+}
+
+type fakeEventSink struct {
+	mu     sync.Mutex
+	events []*v1.Event
+}
+
+func (s *fakeEventSink) record(event *v1.Event) (*v1.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return event, nil
+}
+
+func (s *fakeEventSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func (s *fakeEventSink) Create(event *v1.Event) (*v1.Event, error) {
+	return s.record(event)
+}
+
+func (s *fakeEventSink) Update(event *v1.Event) (*v1.Event, error) {
+	return s.record(event)
+}
+
+func (s *fakeEventSink) Patch(event *v1.Event, data []byte) (*v1.Event, error) {
+	return s.record(event)
+}
@@ -0,0 +1,105 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"time"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// RetryStatusObject is the latest version of a LoadBalancer or BackendRecord read from api-server,
+// it is modified by RecordFailure and ResetFailure
+type RetryStatusObject struct {
+	metav1.Object
+	RetryStatus *lbcfapi.RetryStatus
+	// IsFailed returns true if the Failed condition of the object is True
+	IsFailed func() bool
+	// SetFailed sets the Failed condition of the object to True with the last error msg, or to False
+	SetFailed func(failed bool, msg string)
+	// Update writes the modified status with the resourceVersion of the object
+	Update func() error
+}
+
+// NextFailedAttempts returns the failed attempts after a new failure, the attempts are counted from 0 if reset is true.
+// exceeded is true once the attempts reach maxAttempts, 0 means no limit
+func NextFailedAttempts(attempts int32, reset bool, maxAttempts int32) (next int32, exceeded bool) {
+	if reset {
+		attempts = 0
+	}
+	attempts++
+	return attempts, maxAttempts > 0 && attempts >= maxAttempts
+}
+
+// RecordFailure increases the failed attempts of the object returned by get, and marks it as Failed once maxAttempts is reached.
+// RetryAfter is set to retryDelay later unless the object is Failed or retryDelay is 0.
+// The object is read again by get and modified again if it is modified concurrently
+func RecordFailure(get func() (*RetryStatusObject, error), maxAttempts int32, retryDelay time.Duration, msg string) (exceeded bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := get()
+		if err != nil {
+			return err
+		}
+		status := obj.RetryStatus
+		status.FailedAttempts, exceeded = NextFailedAttempts(status.FailedAttempts, NeedResetRetry(obj, *status), maxAttempts)
+		status.ObservedGeneration = obj.GetGeneration()
+		status.LastRetryAt = obj.GetAnnotations()[lbcfapi.AnnotationRetryAt]
+		status.RetryAfter = nil
+		if !exceeded && retryDelay > 0 {
+			retryAfter := metav1.NewTime(time.Now().Add(retryDelay))
+			status.RetryAfter = &retryAfter
+		}
+		if exceeded {
+			obj.SetFailed(true, msg)
+		} else if obj.IsFailed() {
+			obj.SetFailed(false, "")
+		}
+		return obj.Update()
+	})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return exceeded, err
+}
+
+// ResetFailure clears the failed attempts of the object returned by get, and records the processed retry-at annotation.
+// The object is read again by get and modified again if it is modified concurrently
+func ResetFailure(get func() (*RetryStatusObject, error)) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := get()
+		if err != nil {
+			return err
+		}
+		status := obj.RetryStatus
+		status.FailedAttempts = 0
+		status.RetryAfter = nil
+		status.LastRetryAt = obj.GetAnnotations()[lbcfapi.AnnotationRetryAt]
+		if obj.IsFailed() {
+			obj.SetFailed(false, "")
+		}
+		return obj.Update()
+	})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tkestack.io/lb-controlling-framework/pkg/metrics"
+)
+
+// Feature is the name of a behavior that can be turned on or off by --feature-gates
+type Feature string
+
+// FeatureStage is the maturity of a feature
+type FeatureStage string
+
+const (
+	// Alpha features are disabled by default, they may be changed or removed in any release
+	Alpha FeatureStage = "ALPHA"
+	// Beta features are enabled by default, they are well tested and can still be disabled if problems are found
+	Beta FeatureStage = "BETA"
+	// GA features are always enabled, their gates are kept for compatibility and can not be disabled
+	GA FeatureStage = "GA"
+)
+
+// FeatureSpec is the default value and the stage of a feature
+type FeatureSpec struct {
+	Default bool
+	Stage   FeatureStage
+}
+
+const (
+	// MultiClusterBackends allows BackendGroups and Binds to select Pods in member clusters
+	MultiClusterBackends Feature = "MultiClusterBackends"
+)
+
+// DefaultFeatures are all the features known by lbcf-controller
+var DefaultFeatures = map[Feature]FeatureSpec{
+	MultiClusterBackends: {Default: false, Stage: Alpha},
+}
+
+// FeatureGate records whether features are enabled. It implements pflag.Value so it can be set by --feature-gates.
+// A nil FeatureGate returns the default value of DefaultFeatures.
+type FeatureGate struct {
+	mu      sync.RWMutex
+	known   map[Feature]FeatureSpec
+	enabled map[Feature]bool
+}
+
+// NewFeatureGate returns a FeatureGate with all features in known set to their default values
+func NewFeatureGate(known map[Feature]FeatureSpec) *FeatureGate {
+	return &FeatureGate{
+		known:   known,
+		enabled: make(map[Feature]bool),
+	}
+}
+
+// Set parses a comma separated list of key=value pairs, e.g. "FeatureA=true,FeatureB=false"
+func (f *FeatureGate) Set(value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	enabled := make(map[Feature]bool)
+	for k, v := range f.enabled {
+		enabled[k] = v
+	}
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("missing bool value for feature %s", kv[0])
+		}
+		name := Feature(strings.TrimSpace(kv[0]))
+		spec, ok := f.known[name]
+		if !ok {
+			return fmt.Errorf("unknown feature %s", name)
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("invalid value of feature %s: %v", name, err)
+		}
+		if spec.Stage == GA && !b {
+			return fmt.Errorf("feature %s is GA and can not be disabled", name)
+		}
+		enabled[name] = b
+	}
+	f.enabled = enabled
+	return nil
+}
+
+// String returns the features set explicitly, sorted by name
+func (f *FeatureGate) String() string {
+	if f == nil {
+		return ""
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var pairs []string
+	for name, b := range f.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, b))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Type implements pflag.Value
+func (f *FeatureGate) Type() string {
+	return "mapStringBool"
+}
+
+// Enabled returns whether feature is enabled, unknown features are always disabled
+func (f *FeatureGate) Enabled(feature Feature) bool {
+	if f == nil {
+		return DefaultFeatures[feature].Default
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if b, ok := f.enabled[feature]; ok {
+		return b
+	}
+	return f.known[feature].Default
+}
+
+// EnabledFeatures returns the enabled features sorted by name
+func (f *FeatureGate) EnabledFeatures() []Feature {
+	var ret []Feature
+	for _, name := range f.sortedKnown() {
+		if f.Enabled(name) {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// KnownFeatures returns the description of known features used in help message
+func (f *FeatureGate) KnownFeatures() []string {
+	var ret []string
+	for _, name := range f.sortedKnown() {
+		spec := f.knownFeatures()[name]
+		ret = append(ret, fmt.Sprintf("%s=true|false (%s - default=%t)", name, spec.Stage, spec.Default))
+	}
+	return ret
+}
+
+// RecordMetrics exposes whether each known feature is enabled in metrics
+func (f *FeatureGate) RecordMetrics() {
+	for _, name := range f.sortedKnown() {
+		metrics.FeatureEnabledSet(string(name), string(f.knownFeatures()[name].Stage), f.Enabled(name))
+	}
+}
+
+func (f *FeatureGate) knownFeatures() map[Feature]FeatureSpec {
+	if f == nil {
+		return DefaultFeatures
+	}
+	return f.known
+}
+
+func (f *FeatureGate) sortedKnown() []Feature {
+	var ret []Feature
+	for name := range f.knownFeatures() {
+		ret = append(ret, name)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret
+}
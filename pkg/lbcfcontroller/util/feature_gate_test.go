@@ -0,0 +1,110 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+var testFeatures = map[Feature]FeatureSpec{
+	"AlphaFeature": {Default: false, Stage: Alpha},
+	"BetaFeature":  {Default: true, Stage: Beta},
+	"GAFeature":    {Default: true, Stage: GA},
+}
+
+func TestFeatureGateDefault(t *testing.T) {
+	gate := NewFeatureGate(testFeatures)
+	if gate.Enabled("AlphaFeature") {
+		t.Errorf("expect alpha feature disabled by default")
+	}
+	if !gate.Enabled("BetaFeature") || !gate.Enabled("GAFeature") {
+		t.Errorf("expect beta and GA features enabled by default")
+	}
+	if gate.Enabled("UnknownFeature") {
+		t.Errorf("expect unknown feature disabled")
+	}
+	expect := []Feature{"BetaFeature", "GAFeature"}
+	if get := gate.EnabledFeatures(); !reflect.DeepEqual(get, expect) {
+		t.Errorf("expect %v, get %v", expect, get)
+	}
+	if gate.String() != "" {
+		t.Errorf("expect empty string, get %q", gate.String())
+	}
+
+	var nilGate *FeatureGate
+	if nilGate.Enabled("AlphaFeature") || nilGate.String() != "" {
+		t.Errorf("expect nil gate uses DefaultFeatures")
+	}
+}
+
+func TestFeatureGateSet(t *testing.T) {
+	gate := NewFeatureGate(testFeatures)
+	if err := gate.Set("AlphaFeature=true, BetaFeature=false"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !gate.Enabled("AlphaFeature") || gate.Enabled("BetaFeature") {
+		t.Errorf("expect alpha enabled and beta disabled")
+	}
+	if gate.String() != "AlphaFeature=true,BetaFeature=false" {
+		t.Errorf("unexpected string %q", gate.String())
+	}
+	expect := []Feature{"AlphaFeature", "GAFeature"}
+	if get := gate.EnabledFeatures(); !reflect.DeepEqual(get, expect) {
+		t.Errorf("expect %v, get %v", expect, get)
+	}
+
+	invalid := []string{
+		"UnknownFeature=true",
+		"AlphaFeature",
+		"AlphaFeature=yes",
+		"GAFeature=false",
+	}
+	for _, value := range invalid {
+		if err := gate.Set(value); err == nil {
+			t.Errorf("expect error for %q", value)
+		}
+	}
+	// failed Set keeps the previous value
+	if !gate.Enabled("AlphaFeature") || gate.Enabled("BetaFeature") {
+		t.Errorf("expect features not changed by invalid value")
+	}
+}
+
+func TestFeatureGateFlag(t *testing.T) {
+	gate := NewFeatureGate(testFeatures)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Var(gate, "feature-gates", "")
+	if err := fs.Parse([]string{"--feature-gates=AlphaFeature=true", "--feature-gates=GAFeature=true"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !gate.Enabled("AlphaFeature") || !gate.Enabled("BetaFeature") {
+		t.Errorf("expect values of repeated flags are merged")
+	}
+	expect := []string{
+		"AlphaFeature=true|false (ALPHA - default=false)",
+		"BetaFeature=true|false (BETA - default=true)",
+		"GAFeature=true|false (GA - default=true)",
+	}
+	if get := gate.KnownFeatures(); !reflect.DeepEqual(get, expect) {
+		t.Errorf("expect %v, get %v", expect, get)
+	}
+	gate.RecordMetrics()
+}
@@ -0,0 +1,72 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// HealthCheck is a named check served by /readyz or /livez
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
+
+// HealthHandler returns an http.Handler that runs all checks.
+// Checks listed in query parameter "exclude" are skipped,
+// and the result of each check is written to response if the query parameter "verbose" is set or any check fails.
+func HealthHandler(name string, checks []HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		excluded := make(map[string]bool)
+		for _, e := range r.URL.Query()["exclude"] {
+			excluded[e] = true
+		}
+		_, verbose := r.URL.Query()["verbose"]
+
+		var out bytes.Buffer
+		failed := false
+		for _, c := range checks {
+			if excluded[c.Name] {
+				fmt.Fprintf(&out, "[+]%s excluded: ok\n", c.Name)
+				continue
+			}
+			if err := c.Check(); err != nil {
+				failed = true
+				fmt.Fprintf(&out, "[-]%s failed: %v\n", c.Name, err)
+				continue
+			}
+			fmt.Fprintf(&out, "[+]%s ok\n", c.Name)
+		}
+
+		if failed {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(&out, "%s check failed\n", name)
+			w.Write(out.Bytes())
+			return
+		}
+		if !verbose {
+			w.Write([]byte("ok"))
+			return
+		}
+		fmt.Fprintf(&out, "%s check passed\n", name)
+		w.Write(out.Bytes())
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	checks := []HealthCheck{
+		{
+			Name:  "succ",
+			Check: func() error { return nil },
+		},
+		{
+			Name:  "fail",
+			Check: func() error { return fmt.Errorf("fake error") },
+		},
+	}
+	cases := []struct {
+		name          string
+		url           string
+		expectCode    int
+		expectContain []string
+	}{
+		{
+			name:          "failed",
+			url:           "/readyz",
+			expectCode:    http.StatusInternalServerError,
+			expectContain: []string{"[+]succ ok", "[-]fail failed: fake error", "readyz check failed"},
+		},
+		{
+			name:          "exclude-failed",
+			url:           "/readyz?exclude=fail",
+			expectCode:    http.StatusOK,
+			expectContain: []string{"ok"},
+		},
+		{
+			name:          "verbose",
+			url:           "/readyz?exclude=fail&verbose",
+			expectCode:    http.StatusOK,
+			expectContain: []string{"[+]succ ok", "[+]fail excluded: ok", "readyz check passed"},
+		},
+	}
+	handler := HealthHandler("readyz", checks)
+	for _, c := range cases {
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, c.url, nil))
+		if rsp.Code != c.expectCode {
+			t.Errorf("case %s, expect code %d, get %d", c.name, c.expectCode, rsp.Code)
+		}
+		for _, s := range c.expectContain {
+			if !strings.Contains(rsp.Body.String(), s) {
+				t.Errorf("case %s, expect %q in body, get %q", c.name, s, rsp.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"sort"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// IndexBackendGroupByPod indexes BackendGroups by the pods they may select
+	IndexBackendGroupByPod = "lbcf-backendgroup-by-pod"
+	// IndexBindByPod indexes Binds by the pods they may select
+	IndexBindByPod = "lbcf-bind-by-pod"
+	// IndexBackendRecordByPod indexes BackendRecords by their pods
+	IndexBackendRecordByPod = "lbcf-backendrecord-by-pod"
+
+	// IndexLoadBalancerByLBInfo indexes LoadBalancers by driver and status.lbInfo
+	IndexLoadBalancerByLBInfo = "lbcf-loadbalancer-by-lbinfo"
+	// IndexBindByLBInfo indexes Binds by driver and lbInfo of their load balancers
+	IndexBindByLBInfo = "lbcf-bind-by-lbinfo"
+	// IndexBackendRecordByLBInfo indexes BackendRecords by driver and spec.lbInfo
+	IndexBackendRecordByLBInfo = "lbcf-backendrecord-by-lbinfo"
+	// IndexBackendRecordByAddr indexes BackendRecords by driver and status.backendAddr
+	IndexBackendRecordByAddr = "lbcf-backendrecord-by-addr"
+)
+
+// BackendGroupPodIndexFunc is the cache.IndexFunc of IndexBackendGroupByPod
+func BackendGroupPodIndexFunc(obj interface{}) ([]string, error) {
+	group, ok := obj.(*lbcfapi.BackendGroup)
+	if !ok || group.Spec.Pods == nil {
+		return nil, nil
+	}
+	var selector map[string]string
+	if group.Spec.Pods.ByLabel != nil {
+		selector = group.Spec.Pods.ByLabel.Selector
+	}
+	return podSelectorIndexValues(group.Namespace, group.Spec.Pods.ByLabel != nil, selector, group.Spec.Pods.ByName), nil
+}
+
+// BindPodIndexFunc is the cache.IndexFunc of IndexBindByPod
+func BindPodIndexFunc(obj interface{}) ([]string, error) {
+	bind, ok := obj.(*lbcfv1.Bind)
+	if !ok || bind.Spec.Pods == nil {
+		return nil, nil
+	}
+	var selector map[string]string
+	if bind.Spec.Pods.ByLabel != nil {
+		selector = bind.Spec.Pods.ByLabel.Selector
+	}
+	return podSelectorIndexValues(bind.Namespace, bind.Spec.Pods.ByLabel != nil, selector, bind.Spec.Pods.ByName), nil
+}
+
+// BackendRecordPodIndexFunc is the cache.IndexFunc of IndexBackendRecordByPod
+func BackendRecordPodIndexFunc(obj interface{}) ([]string, error) {
+	br, ok := obj.(*lbcfapi.BackendRecord)
+	if !ok || br.Spec.PodBackendInfo == nil {
+		return nil, nil
+	}
+	return []string{podNameIndexValue(br.Namespace, br.Spec.PodBackendInfo.Name)}, nil
+}
+
+// LoadBalancerLBInfoIndexFunc is the cache.IndexFunc of IndexLoadBalancerByLBInfo
+func LoadBalancerLBInfoIndexFunc(obj interface{}) ([]string, error) {
+	lb, ok := obj.(*lbcfapi.LoadBalancer)
+	if !ok || len(lb.Status.LBInfo) == 0 {
+		return nil, nil
+	}
+	return []string{LBInfoIndexValue(NamespaceOfSharedObj(lb.Spec.LBDriver, lb.Namespace), lb.Spec.LBDriver, lb.Status.LBInfo)}, nil
+}
+
+// BindLBInfoIndexFunc is the cache.IndexFunc of IndexBindByLBInfo
+func BindLBInfoIndexFunc(obj interface{}) ([]string, error) {
+	bind, ok := obj.(*lbcfv1.Bind)
+	if !ok {
+		return nil, nil
+	}
+	var ret []string
+	for _, status := range bind.Status.LoadBalancerStatuses {
+		if len(status.LBInfo) > 0 {
+			ret = append(ret, LBInfoIndexValue(NamespaceOfSharedObj(status.Driver, bind.Namespace), status.Driver, status.LBInfo))
+		}
+	}
+	return ret, nil
+}
+
+// BackendRecordLBInfoIndexFunc is the cache.IndexFunc of IndexBackendRecordByLBInfo
+func BackendRecordLBInfoIndexFunc(obj interface{}) ([]string, error) {
+	br, ok := obj.(*lbcfapi.BackendRecord)
+	if !ok || len(br.Spec.LBInfo) == 0 {
+		return nil, nil
+	}
+	return []string{LBInfoIndexValue(NamespaceOfSharedObj(br.Spec.LBDriver, br.Namespace), br.Spec.LBDriver, br.Spec.LBInfo)}, nil
+}
+
+// BackendRecordAddrIndexFunc is the cache.IndexFunc of IndexBackendRecordByAddr
+func BackendRecordAddrIndexFunc(obj interface{}) ([]string, error) {
+	br, ok := obj.(*lbcfapi.BackendRecord)
+	if !ok || br.Status.BackendAddr == "" {
+		return nil, nil
+	}
+	return []string{BackendAddrIndexValue(NamespaceOfSharedObj(br.Spec.LBDriver, br.Namespace), br.Spec.LBDriver, br.Status.BackendAddr)}, nil
+}
+
+// LBInfoIndexValue returns the value to look up objects of a load balancer created by driver in
+// IndexLoadBalancerByLBInfo, IndexBindByLBInfo and IndexBackendRecordByLBInfo
+func LBInfoIndexValue(driverNamespace, driverName string, lbInfo map[string]string) string {
+	// keys of map are sorted by json.Marshal
+	b, _ := json.Marshal(lbInfo)
+	return driverNamespace + "/" + driverName + "/" + string(b)
+}
+
+// BackendAddrIndexValue returns the value to look up BackendRecords of addr registered by driver in IndexBackendRecordByAddr
+func BackendAddrIndexValue(driverNamespace, driverName, addr string) string {
+	return driverNamespace + "/" + driverName + "/" + addr
+}
+
+// PodIndexValues returns the values to look up objects that may select pod in IndexBackendGroupByPod and IndexBindByPod.
+// Objects found by the values must be matched against pod again, because only one label of the selector is indexed
+func PodIndexValues(pod *v1.Pod) []string {
+	ret := []string{
+		podNameIndexValue(pod.Namespace, pod.Name),
+		pod.Namespace + "/all",
+	}
+	for k, v := range pod.Labels {
+		ret = append(ret, podLabelIndexValue(pod.Namespace, k, v))
+	}
+	return ret
+}
+
+// PodNameIndexValue returns the value to look up BackendRecords of pod in IndexBackendRecordByPod
+func PodNameIndexValue(pod *v1.Pod) string {
+	return podNameIndexValue(pod.Namespace, pod.Name)
+}
+
+// podSelectorIndexValues indexes an object that selects pods by name, or by label.
+// Only the first label in selector is indexed, since a matched pod must have all labels in selector
+func podSelectorIndexValues(namespace string, byLabel bool, selector map[string]string, byName []string) []string {
+	if !byLabel {
+		var ret []string
+		for _, name := range byName {
+			ret = append(ret, podNameIndexValue(namespace, name))
+		}
+		return ret
+	}
+	if len(selector) == 0 {
+		return []string{namespace + "/all"}
+	}
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return []string{podLabelIndexValue(namespace, keys[0], selector[keys[0]])}
+}
+
+func podNameIndexValue(namespace, name string) string {
+	return namespace + "/name:" + name
+}
+
+func podLabelIndexValue(namespace, key, value string) string {
+	return namespace + "/label:" + key + "=" + value
+}
@@ -0,0 +1,156 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"testing"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestBackendGroupPodIndex(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{IndexBackendGroupByPod: BackendGroupPodIndexFunc})
+	groups := []*lbcfapi.BackendGroup{
+		newIndexTestBackendGroup("ns", "by-label", map[string]string{"app": "web", "tier": "front"}, nil),
+		newIndexTestBackendGroup("ns", "by-other-label", map[string]string{"app": "db"}, nil),
+		newIndexTestBackendGroup("ns", "all", map[string]string{}, nil),
+		newIndexTestBackendGroup("ns", "by-name", nil, []string{"pod-0"}),
+		newIndexTestBackendGroup("other", "other-ns", map[string]string{"app": "web"}, nil),
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "static"},
+			Spec:       lbcfapi.BackendGroupSpec{Static: []string{"1.1.1.1:80"}},
+		},
+	}
+	for _, group := range groups {
+		if err := indexer.Add(group); err != nil {
+			t.Fatalf("add group failed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		pod    *v1.Pod
+		expect sets.String
+	}{
+		{
+			pod:    newIndexTestPod("ns", "pod-0", map[string]string{"app": "web", "tier": "front"}),
+			expect: sets.NewString("ns/by-label", "ns/all", "ns/by-name"),
+		},
+		{
+			pod:    newIndexTestPod("ns", "pod-1", map[string]string{"app": "db"}),
+			expect: sets.NewString("ns/by-other-label", "ns/all"),
+		},
+		{
+			pod:    newIndexTestPod("ns", "pod-2", nil),
+			expect: sets.NewString("ns/all"),
+		},
+		{
+			pod:    newIndexTestPod("other", "pod-0", map[string]string{"app": "web"}),
+			expect: sets.NewString("other/other-ns"),
+		},
+	}
+	for _, c := range cases {
+		got := sets.NewString()
+		for _, value := range PodIndexValues(c.pod) {
+			objs, err := indexer.ByIndex(IndexBackendGroupByPod, value)
+			if err != nil {
+				t.Fatalf("ByIndex failed: %v", err)
+			}
+			for _, obj := range objs {
+				group := obj.(*lbcfapi.BackendGroup)
+				// index values are candidates, they must be matched again
+				if IsPodMatchBackendGroup(group, c.pod) {
+					got.Insert(NamespacedNameKeyFunc(group.Namespace, group.Name))
+				}
+			}
+		}
+		if !got.Equal(c.expect) {
+			t.Errorf("pod %s/%s, expect %v, get %v", c.pod.Namespace, c.pod.Name, c.expect.List(), got.List())
+		}
+	}
+}
+
+func TestBindPodIndexFunc(t *testing.T) {
+	bind := &lbcfv1.Bind{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "bind"},
+		Spec: lbcfv1.BindSpec{
+			Pods: &lbcfv1.PodBackend{
+				ByLabel: &lbcfv1.SelectPodByLabel{
+					Selector: map[string]string{"b": "2", "a": "1"},
+				},
+			},
+		},
+	}
+	values, _ := BindPodIndexFunc(bind)
+	if len(values) != 1 || values[0] != "ns/label:a=1" {
+		t.Errorf("expect [ns/label:a=1], get %v", values)
+	}
+	bind.Spec.Pods.ByLabel = nil
+	bind.Spec.Pods.ByName = []string{"pod-0", "pod-1"}
+	values, _ = BindPodIndexFunc(bind)
+	if !sets.NewString(values...).Equal(sets.NewString("ns/name:pod-0", "ns/name:pod-1")) {
+		t.Errorf("expect [ns/name:pod-0 ns/name:pod-1], get %v", values)
+	}
+}
+
+func TestBackendRecordPodIndexFunc(t *testing.T) {
+	br := &lbcfapi.BackendRecord{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "br"},
+		Spec: lbcfapi.BackendRecordSpec{
+			PodBackendInfo: &lbcfapi.PodBackendRecord{Name: "pod-0"},
+		},
+	}
+	values, _ := BackendRecordPodIndexFunc(br)
+	if len(values) != 1 || values[0] != PodNameIndexValue(newIndexTestPod("ns", "pod-0", nil)) {
+		t.Errorf("expect [ns/name:pod-0], get %v", values)
+	}
+	br.Spec.PodBackendInfo = nil
+	if values, _ := BackendRecordPodIndexFunc(br); len(values) != 0 {
+		t.Errorf("expect no value, get %v", values)
+	}
+}
+
+func newIndexTestBackendGroup(namespace, name string, selector map[string]string, byName []string) *lbcfapi.BackendGroup {
+	group := &lbcfapi.BackendGroup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: lbcfapi.BackendGroupSpec{
+			Pods: &lbcfapi.PodBackend{},
+		},
+	}
+	if selector != nil {
+		group.Spec.Pods.ByLabel = &lbcfapi.SelectPodByLabel{Selector: selector}
+	} else {
+		group.Spec.Pods.ByName = byName
+	}
+	return group
+}
+
+func newIndexTestPod(namespace, name string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+	}
+}
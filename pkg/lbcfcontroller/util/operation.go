@@ -0,0 +1,92 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
+	"tkestack.io/lb-controlling-framework/pkg/metrics"
+
+	apicore "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// RunningOperationObject is a LoadBalancer or BackendRecord whose webhooks may respond with status Running,
+// it is modified by HandleRunningOperation and ClearRunningOperation
+type RunningOperationObject struct {
+	// Object is the object events are recorded on
+	Object runtime.Object
+	// RunningOperation is the operation recorded in status of the object
+	RunningOperation *lbcfapi.RunningOperation
+	// SetRunningOperation sets the operation recorded in status
+	SetRunningOperation func(op *lbcfapi.RunningOperation)
+	// SetTimeout sets the condition of the operation to False with reason Timeout and msg
+	SetTimeout func(msg string)
+	// Update writes the modified status with the resourceVersion of the object
+	Update func() error
+}
+
+// HandleRunningOperation records the start time of webhookName in status when it responds with status Running for the first time.
+// If the webhook keeps running longer than the maxOperationDuration configured in driver, the operation is considered failed
+// and its condition is set to False with reason Timeout.
+func HandleRunningOperation(
+	obj *RunningOperationObject,
+	driver *lbcfapi.LoadBalancerDriver,
+	webhookName string,
+	eventReason string,
+	rsp webhooks.ResponseForFailRetryHooks,
+	recorder record.EventRecorder) *SyncResult {
+	delay := CalculateRetryInterval(rsp.MinRetryDelayInSeconds)
+	if op := obj.RunningOperation; op != nil && op.Webhook == webhookName {
+		maxDuration := GetMaxOperationDuration(driver, webhookName)
+		if IsOperationTimeout(op.StartTime.Time, maxDuration) {
+			msg := fmt.Sprintf("webhook %s is still running after %s, msg: %s", webhookName, maxDuration, rsp.Msg)
+			obj.SetRunningOperation(nil)
+			obj.SetTimeout(msg)
+			if err := obj.Update(); err != nil {
+				return ErrorResult(err)
+			}
+			metrics.OperationTimeoutsInc(driver.Name, webhookName)
+			recorder.Eventf(obj.Object, apicore.EventTypeWarning, "OperationTimeout", "%s", msg)
+			return FailResult(delay, msg)
+		}
+	} else {
+		obj.SetRunningOperation(&lbcfapi.RunningOperation{
+			Webhook:   webhookName,
+			StartTime: metav1.Now(),
+		})
+		if err := obj.Update(); err != nil {
+			return ErrorResult(err)
+		}
+	}
+	recorder.Eventf(obj.Object, apicore.EventTypeNormal, eventReason, "msg: %s", rsp.Msg)
+	return AsyncResult(delay)
+}
+
+// ClearRunningOperation removes the operation recorded in status
+func ClearRunningOperation(obj *RunningOperationObject) error {
+	if obj.RunningOperation == nil {
+		return nil
+	}
+	obj.SetRunningOperation(nil)
+	return obj.Update()
+}
@@ -0,0 +1,157 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tkestack.io/lb-controlling-framework/pkg/metrics"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// PatchFunc sends a patch of the given type to api-server
+type PatchFunc func(pt types.PatchType, data []byte) error
+
+// StatusMergePatch returns a merge patch that changes status from oldStatus to curStatus.
+// If resourceVersion is not empty, it is sent as a precondition, and the patch is rejected with a conflict
+// if the object is modified after it is read. A nil patch is returned if the status is not changed.
+func StatusMergePatch(resourceVersion string, oldStatus, curStatus interface{}) ([]byte, error) {
+	oldJSON, err := json.Marshal(map[string]interface{}{"status": oldStatus})
+	if err != nil {
+		return nil, err
+	}
+	curJSON, err := json.Marshal(map[string]interface{}{"status": curStatus})
+	if err != nil {
+		return nil, err
+	}
+	data, err := jsonpatch.CreateMergePatch(oldJSON, curJSON)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "{}" {
+		return nil, nil
+	}
+	if resourceVersion == "" {
+		return data, nil
+	}
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	patch["metadata"] = map[string]interface{}{"resourceVersion": resourceVersion}
+	return json.Marshal(patch)
+}
+
+// PatchStatus sends a merge patch that changes status from oldStatus to curStatus, with resourceVersion of the object
+// oldStatus is read from as a precondition, so that a status computed from a stale object never overwrites a newer one.
+// On conflict, refresh reads the resourceVersion and status of the latest object. The patch is sent again with the latest
+// resourceVersion only if the latest status still equals oldStatus, that is, only fields other than status are modified.
+// Otherwise the conflict is returned, and the object should be synced again with the latest status.
+// Nothing is sent if the status is not changed.
+func PatchStatus(kind string, resourceVersion string, oldStatus, curStatus interface{},
+	patch PatchFunc, refresh func() (string, interface{}, error)) error {
+	if apiequality.Semantic.DeepEqual(oldStatus, curStatus) {
+		return nil
+	}
+	statusModified := false
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) && !statusModified
+	}, func() error {
+		data, err := StatusMergePatch(resourceVersion, oldStatus, curStatus)
+		if err != nil {
+			return fmt.Errorf("create status patch for %s failed: %v", kind, err)
+		}
+		if data == nil {
+			return nil
+		}
+		err = doPatch(kind, metrics.OpPatchStatus, types.MergePatchType, data, patch)
+		if !errors.IsConflict(err) {
+			return err
+		}
+		latestVersion, latestStatus, getErr := refresh()
+		if getErr != nil {
+			return getErr
+		}
+		resourceVersion = latestVersion
+		statusModified = !apiequality.Semantic.DeepEqual(oldStatus, latestStatus)
+		return err
+	})
+}
+
+// RemoveFinalizerPatch returns a JSON patch that removes finalizer from finalizers.
+// Each removal is guarded by a test operation, the patch fails if finalizers are modified concurrently.
+// A nil patch is returned if finalizer is not found.
+func RemoveFinalizerPatch(finalizers []string, finalizer string) ([]byte, error) {
+	type operation struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value string `json:"value,omitempty"`
+	}
+	var ops []operation
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		if finalizers[i] != finalizer {
+			continue
+		}
+		path := fmt.Sprintf("/metadata/finalizers/%d", i)
+		ops = append(ops,
+			operation{Op: "test", Path: path, Value: finalizer},
+			operation{Op: "remove", Path: path})
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(ops)
+}
+
+// PatchRemoveFinalizer sends a JSON patch that removes finalizer from finalizers.
+// Nothing is sent if finalizer is not found.
+func PatchRemoveFinalizer(kind string, finalizers []string, finalizer string, patch PatchFunc) error {
+	data, err := RemoveFinalizerPatch(finalizers, finalizer)
+	if err != nil {
+		return fmt.Errorf("create finalizer patch for %s failed: %v", kind, err)
+	}
+	if data == nil {
+		return nil
+	}
+	return doPatch(kind, metrics.OpPatch, types.JSONPatchType, data, patch)
+}
+
+// ObserveK8SWriteConflict counts err in metrics if it is a conflict error
+func ObserveK8SWriteConflict(kind, op string, err error) {
+	if errors.IsConflict(err) {
+		metrics.K8SOpConflictsInc(kind, op)
+	}
+}
+
+func doPatch(kind, op string, pt types.PatchType, data []byte, patch PatchFunc) error {
+	start := time.Now()
+	err := patch(pt, data)
+	metrics.K8SOpLatencyObserve(kind, op, time.Since(start))
+	// a failed test operation of JSON patch is responded with 422
+	if errors.IsConflict(err) || (pt == types.JSONPatchType && errors.IsInvalid(err)) {
+		metrics.K8SOpConflictsInc(kind, op)
+	}
+	return err
+}
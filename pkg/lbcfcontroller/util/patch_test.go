@@ -0,0 +1,220 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStatusMergePatch(t *testing.T) {
+	old := lbcfapi.BackendGroupStatus{Backends: 1, RegisteredBackends: 1}
+	data, err := StatusMergePatch("1", old, old)
+	if err != nil || data != nil {
+		t.Fatalf("expect nil patch for unchanged status, get %s, err: %v", string(data), err)
+	}
+	data, err = StatusMergePatch("1", old, lbcfapi.BackendGroupStatus{Backends: 2, RegisteredBackends: 1})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expect := `{"metadata":{"resourceVersion":"1"},"status":{"backends":2}}`
+	if !jsonpatch.Equal(data, []byte(expect)) {
+		t.Fatalf("expect %s, get %s", expect, string(data))
+	}
+	data, err = StatusMergePatch("", old, lbcfapi.BackendGroupStatus{Backends: 2, RegisteredBackends: 1})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if expect := `{"status":{"backends":2}}`; !jsonpatch.Equal(data, []byte(expect)) {
+		t.Fatalf("expect %s, get %s", expect, string(data))
+	}
+}
+
+func TestPatchStatus(t *testing.T) {
+	status := lbcfapi.BackendGroupStatus{Backends: 1}
+	var sent []string
+	patch := func(pt types.PatchType, data []byte) error {
+		if pt != types.MergePatchType {
+			t.Fatalf("expect merge patch, get %s", pt)
+		}
+		sent = append(sent, string(data))
+		return nil
+	}
+	refresh := func() (string, interface{}, error) {
+		t.Fatalf("unexpected refresh")
+		return "", nil, nil
+	}
+	if err := PatchStatus("BackendGroup", "1", status, status, patch, refresh); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	} else if len(sent) != 0 {
+		t.Fatalf("expect no patch for unchanged status")
+	}
+	if err := PatchStatus("BackendGroup", "1", status, lbcfapi.BackendGroupStatus{Backends: 2}, patch, refresh); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	} else if len(sent) != 1 {
+		t.Fatalf("expect patch sent")
+	}
+}
+
+func TestPatchStatusConflict(t *testing.T) {
+	conflict := errors.NewConflict(schema.GroupResource{Resource: "backendgroups"}, "group", fmt.Errorf("fake"))
+	status := lbcfapi.BackendGroupStatus{Backends: 1}
+	cases := []struct {
+		name         string
+		latestStatus lbcfapi.BackendGroupStatus
+		expectCalls  int
+		expectErr    bool
+	}{
+		{
+			// only metadata or spec is modified, the patch is sent again with the latest resourceVersion
+			name:         "status-not-modified",
+			latestStatus: status,
+			expectCalls:  2,
+		},
+		{
+			// the status is modified, the patch computed from a stale status is not sent again
+			name:         "status-modified",
+			latestStatus: lbcfapi.BackendGroupStatus{Backends: 3},
+			expectCalls:  1,
+			expectErr:    true,
+		},
+	}
+	for _, c := range cases {
+		var versions []string
+		err := PatchStatus("BackendGroup", "1", status, lbcfapi.BackendGroupStatus{Backends: 2}, func(pt types.PatchType, data []byte) error {
+			var patch struct {
+				Metadata struct {
+					ResourceVersion string `json:"resourceVersion"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal(data, &patch); err != nil {
+				t.Fatalf("case %s: invalid patch %s", c.name, string(data))
+			}
+			versions = append(versions, patch.Metadata.ResourceVersion)
+			if len(versions) == 1 {
+				return conflict
+			}
+			return nil
+		}, func() (string, interface{}, error) {
+			return "2", c.latestStatus, nil
+		})
+		if len(versions) != c.expectCalls {
+			t.Errorf("case %s: expect %d calls, get %d", c.name, c.expectCalls, len(versions))
+		}
+		if len(versions) == 2 && versions[1] != "2" {
+			t.Errorf("case %s: expect patch resent with the latest resourceVersion, get %s", c.name, versions[1])
+		}
+		if c.expectErr && !errors.IsConflict(err) {
+			t.Errorf("case %s: expect conflict, get %v", c.name, err)
+		} else if !c.expectErr && err != nil {
+			t.Errorf("case %s: unexpected err: %v", c.name, err)
+		}
+	}
+}
+
+func TestRemoveFinalizerPatch(t *testing.T) {
+	cases := []struct {
+		name       string
+		cached     []string
+		latest     string
+		expect     string
+		expectFail bool
+	}{
+		{
+			name:   "remove",
+			cached: []string{"a", "b", "c"},
+			latest: `{"metadata":{"finalizers":["a","b","c"]}}`,
+			expect: `{"metadata":{"finalizers":["a","c"]}}`,
+		},
+		{
+			name:   "remove-duplicated",
+			cached: []string{"b", "a", "b"},
+			latest: `{"metadata":{"finalizers":["b","a","b"]}}`,
+			expect: `{"metadata":{"finalizers":["a"]}}`,
+		},
+		{
+			name:   "appended-by-others",
+			cached: []string{"a", "b"},
+			latest: `{"metadata":{"finalizers":["a","b","c"]}}`,
+			expect: `{"metadata":{"finalizers":["a","c"]}}`,
+		},
+		{
+			name:       "reordered-by-others",
+			cached:     []string{"a", "b"},
+			latest:     `{"metadata":{"finalizers":["b","a"]}}`,
+			expectFail: true,
+		},
+	}
+	for _, c := range cases {
+		data, err := RemoveFinalizerPatch(c.cached, "b")
+		if err != nil {
+			t.Fatalf("case %s: unexpected err: %v", c.name, err)
+		}
+		patch, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			t.Fatalf("case %s: invalid patch %s: %v", c.name, string(data), err)
+		}
+		get, err := patch.Apply([]byte(c.latest))
+		if c.expectFail {
+			if err == nil {
+				t.Errorf("case %s: expect fail, get %s", c.name, string(get))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case %s: unexpected err: %v", c.name, err)
+		}
+		if !jsonpatch.Equal(get, []byte(c.expect)) {
+			t.Errorf("case %s: expect %s, get %s", c.name, c.expect, string(get))
+		}
+	}
+
+	data, err := RemoveFinalizerPatch([]string{"a"}, "b")
+	if err != nil || data != nil {
+		t.Fatalf("expect nil patch, get %s, err: %v", string(data), err)
+	}
+}
+
+func TestPatchRemoveFinalizer(t *testing.T) {
+	called := false
+	patch := func(pt types.PatchType, data []byte) error {
+		called = true
+		if pt != types.JSONPatchType {
+			t.Fatalf("expect json patch, get %s", pt)
+		}
+		return nil
+	}
+	if err := PatchRemoveFinalizer("Bind", []string{"a"}, "b", patch); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	} else if called {
+		t.Fatalf("expect no patch if finalizer not found")
+	}
+	if err := PatchRemoveFinalizer("Bind", []string{"a", "b"}, "b", patch); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	} else if !called {
+		t.Fatalf("expect patch sent")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// lbcfAnnotationPrefix is the prefix of annotations kept in pruned Pods
+const lbcfAnnotationPrefix = "lbcf.tkestack.io/"
+
+// PrunePod returns a copy of pod that contains only the fields used by lbcf-controller,
+// i.e. labels, annotations of LBCF, IPs, phase, conditions and container ports
+func PrunePod(pod *v1.Pod) *v1.Pod {
+	pruned := &v1.Pod{
+		TypeMeta: pod.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              pod.Name,
+			Namespace:         pod.Namespace,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			Generation:        pod.Generation,
+			CreationTimestamp: pod.CreationTimestamp,
+			DeletionTimestamp: pod.DeletionTimestamp,
+			Labels:            pod.Labels,
+			OwnerReferences:   pod.OwnerReferences,
+		},
+		Spec: v1.PodSpec{
+			NodeName: pod.Spec.NodeName,
+		},
+		Status: v1.PodStatus{
+			Phase:      pod.Status.Phase,
+			Conditions: pod.Status.Conditions,
+			HostIP:     pod.Status.HostIP,
+			PodIP:      pod.Status.PodIP,
+			PodIPs:     pod.Status.PodIPs,
+		},
+	}
+	for k, v := range pod.Annotations {
+		if !strings.HasPrefix(k, lbcfAnnotationPrefix) {
+			continue
+		}
+		if pruned.Annotations == nil {
+			pruned.Annotations = make(map[string]string)
+		}
+		pruned.Annotations[k] = v
+	}
+	for _, c := range pod.Spec.Containers {
+		pruned.Spec.Containers = append(pruned.Spec.Containers, v1.Container{
+			Name:  c.Name,
+			Ports: c.Ports,
+		})
+	}
+	return pruned
+}
+
+// NewPrunedPodListWatch wraps lw so that Pods are pruned by PrunePod before they are cached by informers
+func NewPrunedPodListWatch(lw cache.ListerWatcher) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			list, ok := obj.(*v1.PodList)
+			if !ok {
+				return obj, nil
+			}
+			pruned := &v1.PodList{
+				TypeMeta: list.TypeMeta,
+				ListMeta: list.ListMeta,
+				Items:    make([]v1.Pod, 0, len(list.Items)),
+			}
+			for i := range list.Items {
+				pruned.Items = append(pruned.Items, *PrunePod(&list.Items[i]))
+			}
+			return pruned, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				if pod, ok := in.Object.(*v1.Pod); ok {
+					in.Object = PrunePod(pod)
+				}
+				return in, true
+			}), nil
+		},
+	}
+}
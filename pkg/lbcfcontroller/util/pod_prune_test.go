@@ -0,0 +1,132 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPrunePod(t *testing.T) {
+	pod := newPruneTestPod()
+	pruned := PrunePod(pod)
+	expect := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "ns",
+			UID:             "uid",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": "web"},
+			Annotations:     map[string]string{"lbcf.tkestack.io/foo": "bar"},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node",
+			Containers: []v1.Container{
+				{
+					Name:  "c",
+					Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 80}},
+				},
+			},
+		},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			HostIP:     "10.0.0.1",
+			PodIP:      "172.16.0.1",
+		},
+	}
+	if !reflect.DeepEqual(pruned, expect) {
+		t.Errorf("expect %+v, get %+v", expect, pruned)
+	}
+	if !PodAvailable(pruned) {
+		t.Errorf("expect pruned pod available")
+	}
+}
+
+func TestPrunedPodListWatch(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	lw := NewPrunedPodListWatch(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &v1.PodList{Items: []v1.Pod{*newPruneTestPod()}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatch, nil
+		},
+	})
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	list := obj.(*v1.PodList)
+	if len(list.Items) != 1 || !reflect.DeepEqual(&list.Items[0], PrunePod(newPruneTestPod())) {
+		t.Errorf("expect pruned pod in list, get %+v", list.Items)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	defer w.Stop()
+	go fakeWatch.Add(newPruneTestPod())
+	event := <-w.ResultChan()
+	if event.Type != watch.Added || !reflect.DeepEqual(event.Object, PrunePod(newPruneTestPod())) {
+		t.Errorf("expect pruned pod in watch event, get %+v", event)
+	}
+}
+
+func newPruneTestPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "ns",
+			UID:             "uid",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": "web"},
+			Annotations: map[string]string{
+				"lbcf.tkestack.io/foo":                             "bar",
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node",
+			Containers: []v1.Container{
+				{
+					Name:  "c",
+					Image: "image",
+					Env:   []v1.EnvVar{{Name: "k", Value: "v"}},
+					Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 80}},
+				},
+			},
+			Volumes: []v1.Volume{{Name: "v"}},
+		},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			Conditions:        []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			HostIP:            "10.0.0.1",
+			PodIP:             "172.16.0.1",
+			ContainerStatuses: []v1.ContainerStatus{{Name: "c"}},
+		},
+	}
+}
@@ -0,0 +1,504 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	lbcfv1lister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1"
+	"tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Priority is the priority class of items in ConditionalRateLimitingInterface, items with higher priority are got first
+type Priority int
+
+const (
+	// PriorityPeriodic is the priority of items that are already synced and ensured periodically
+	PriorityPeriodic Priority = iota
+	// PriorityRegister is the priority of items that are not synced yet
+	PriorityRegister
+	// PriorityDeregister is the priority of items being deleted
+	PriorityDeregister
+)
+
+// Priorities are all priorities ordered from the highest to the lowest
+var Priorities = []Priority{PriorityDeregister, PriorityRegister, PriorityPeriodic}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityDeregister:
+		return "deregister"
+	case PriorityRegister:
+		return "register"
+	case PriorityPeriodic:
+		return "periodic"
+	}
+	return "unknown"
+}
+
+// PriorityFunc returns the priority of item, it is called when item becomes ready to be got
+type PriorityFunc func(item interface{}) Priority
+
+// PriorityForLB returns a PriorityFunc for LoadBalancer
+func PriorityForLB(lbLister v1beta1.LoadBalancerLister) PriorityFunc {
+	return func(item interface{}) Priority {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return PriorityRegister
+		}
+		lb, err := lbLister.LoadBalancers(namespace).Get(name)
+		if errors.IsNotFound(err) {
+			return PriorityDeregister
+		} else if err != nil {
+			return PriorityRegister
+		}
+		if lb.DeletionTimestamp != nil {
+			return PriorityDeregister
+		}
+		if cond := GetLBCondition(&lb.Status, lbcfapi.LBCreated); cond == nil || cond.Status != lbcfapi.ConditionTrue {
+			return PriorityRegister
+		}
+		return PriorityPeriodic
+	}
+}
+
+// PriorityForBackend returns a PriorityFunc for BackendRecord
+func PriorityForBackend(backendLister v1beta1.BackendRecordLister) PriorityFunc {
+	return func(item interface{}) Priority {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return PriorityRegister
+		}
+		backend, err := backendLister.BackendRecords(namespace).Get(name)
+		if errors.IsNotFound(err) {
+			return PriorityDeregister
+		} else if err != nil {
+			return PriorityRegister
+		}
+		if backend.DeletionTimestamp != nil {
+			return PriorityDeregister
+		}
+		if cond := GetBackendRecordCondition(&backend.Status, lbcfapi.BackendRegistered); cond == nil || cond.Status != lbcfapi.ConditionTrue {
+			return PriorityRegister
+		}
+		return PriorityPeriodic
+	}
+}
+
+// PriorityForBind returns a PriorityFunc for Bind
+func PriorityForBind(bindLister lbcfv1lister.BindLister) PriorityFunc {
+	return func(item interface{}) Priority {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return PriorityRegister
+		}
+		bind, err := bindLister.Binds(namespace).Get(name)
+		if errors.IsNotFound(err) {
+			return PriorityDeregister
+		} else if err != nil {
+			return PriorityRegister
+		}
+		if bind.DeletionTimestamp != nil {
+			return PriorityDeregister
+		}
+		ready := len(bind.Status.LoadBalancerStatuses) >= len(bind.Spec.LoadBalancers)
+		for _, lbStatus := range bind.Status.LoadBalancerStatuses {
+			if lbStatus.DeletionTimestamp != nil {
+				return PriorityDeregister
+			}
+			if !bindLBReady(lbStatus) {
+				ready = false
+			}
+		}
+		if !ready {
+			return PriorityRegister
+		}
+		return PriorityPeriodic
+	}
+}
+
+func bindLBReady(lbStatus lbcfv1.TargetLoadBalancerStatus) bool {
+	for _, cond := range lbStatus.Conditions {
+		if cond.Type == lbcfv1.LBReady {
+			return cond.Status == lbcfv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// TenantFunc returns the tenant of item, waiting items of different tenants are got in turn
+type TenantFunc func(item interface{}) string
+
+// TenantByNamespace returns the namespace of item as its tenant
+func TenantByNamespace(item interface{}) string {
+	key, ok := item.(string)
+	if !ok {
+		return ""
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return ""
+	}
+	return namespace
+}
+
+// Fairness shares workers among tenants
+type Fairness struct {
+	Tenant TenantFunc
+	// MaxProcessing is the maximum number of items of a tenant being processed at the same time.
+	// 0 means no limit, and tenants are only got in turn.
+	MaxProcessing int
+}
+
+// priorityDelayingQueue is a workqueue.DelayingInterface that items with higher priority are got first
+type priorityDelayingQueue struct {
+	*priorityQueue
+	waiting workqueue.DelayingInterface
+	delayed delayTracker
+}
+
+func newPriorityDelayingQueue(priority PriorityFunc, fairness *Fairness) *priorityDelayingQueue {
+	q := &priorityDelayingQueue{
+		priorityQueue: newPriorityQueue(priority, fairness),
+		waiting:       workqueue.NewDelayingQueue(),
+	}
+	go q.run()
+	return q
+}
+
+// AddAfter adds item after the indicated duration has passed, the priority of item is calculated when it is added
+func (q *priorityDelayingQueue) AddAfter(item interface{}, duration time.Duration) {
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	q.delayed.add(item, duration)
+	q.waiting.AddAfter(item, duration)
+}
+
+// ShutDown shuts down the queue and the items waiting to be added are dropped
+func (q *priorityDelayingQueue) ShutDown() {
+	q.waiting.ShutDown()
+	q.priorityQueue.ShutDown()
+}
+
+func (q *priorityDelayingQueue) run() {
+	for {
+		item, quit := q.waiting.Get()
+		if quit {
+			return
+		}
+		q.waiting.Done(item)
+		q.delayed.remove(item)
+		q.Add(item)
+	}
+}
+
+// lane is the FIFOs of one Priority, tenants are got in turn
+type lane struct {
+	fifos map[string][]interface{}
+	// tenants that have entries in fifos, in round-robin order
+	tenants []string
+	next    int
+}
+
+func (l *lane) push(tenant string, item interface{}) {
+	if len(l.fifos[tenant]) == 0 {
+		l.tenants = append(l.tenants, tenant)
+	}
+	l.fifos[tenant] = append(l.fifos[tenant], item)
+}
+
+// priorityQueue is a workqueue.Interface with one lane for each Priority.
+// Like workqueue.Type, an item is never processed concurrently and is deduplicated while waiting.
+// If an item is added again with a higher priority, it is moved to the higher lane,
+// and the stale entry in the lower lane is skipped when got.
+type priorityQueue struct {
+	priority PriorityFunc
+	fairness Fairness
+
+	cond  *sync.Cond
+	lanes map[Priority]*lane
+	// counts is the number of waiting items of each priority and tenant
+	counts map[Priority]map[string]int
+	// queued is the priority of waiting items
+	queued map[interface{}]Priority
+	// tenantOf is the tenant of waiting and processing items
+	tenantOf           map[interface{}]string
+	dirty              map[interface{}]bool
+	processing         map[interface{}]bool
+	processingOfTenant map[string]int
+	shuttingDown       bool
+}
+
+func newPriorityQueue(priority PriorityFunc, fairness *Fairness) *priorityQueue {
+	q := &priorityQueue{
+		priority:           priority,
+		cond:               sync.NewCond(&sync.Mutex{}),
+		lanes:              make(map[Priority]*lane),
+		counts:             make(map[Priority]map[string]int),
+		queued:             make(map[interface{}]Priority),
+		tenantOf:           make(map[interface{}]string),
+		dirty:              make(map[interface{}]bool),
+		processing:         make(map[interface{}]bool),
+		processingOfTenant: make(map[string]int),
+	}
+	if fairness != nil {
+		q.fairness = *fairness
+	}
+	for _, p := range Priorities {
+		q.lanes[p] = &lane{fifos: make(map[string][]interface{})}
+		q.counts[p] = make(map[string]int)
+	}
+	return q
+}
+
+// Add marks item as needing processing
+func (q *priorityQueue) Add(item interface{}) {
+	p := PriorityRegister
+	if q.priority != nil {
+		p = q.priority(item)
+	}
+	tenant := ""
+	if q.fairness.Tenant != nil {
+		tenant = q.fairness.Tenant(item)
+	}
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if q.dirty[item] {
+		if cur, ok := q.queued[item]; ok && p > cur {
+			q.queued[item] = p
+			if !q.processing[item] {
+				q.decCount(cur, q.tenantOf[item])
+				q.push(item, p)
+			}
+		}
+		return
+	}
+	q.dirty[item] = true
+	q.queued[item] = p
+	if q.processing[item] {
+		return
+	}
+	q.tenantOf[item] = tenant
+	q.push(item, p)
+}
+
+func (q *priorityQueue) push(item interface{}, p Priority) {
+	tenant := q.tenantOf[item]
+	q.lanes[p].push(tenant, item)
+	q.counts[p][tenant]++
+	q.cond.Signal()
+}
+
+func (q *priorityQueue) decCount(p Priority, tenant string) {
+	q.counts[p][tenant]--
+	if q.counts[p][tenant] <= 0 {
+		delete(q.counts[p], tenant)
+	}
+}
+
+// Len returns the number of items waiting to be got
+func (q *priorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	total := 0
+	for _, p := range Priorities {
+		for _, n := range q.counts[p] {
+			total += n
+		}
+	}
+	return total
+}
+
+// LenOfPriority returns the number of items with priority p waiting to be got
+func (q *priorityQueue) LenOfPriority(p Priority) int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	total := 0
+	for _, n := range q.counts[p] {
+		total += n
+	}
+	return total
+}
+
+// LenOfTenants returns the number of waiting items of each tenant
+func (q *priorityQueue) LenOfTenants() map[string]int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	ret := make(map[string]int)
+	for _, p := range Priorities {
+		for tenant, n := range q.counts[p] {
+			ret[tenant] += n
+		}
+	}
+	return ret
+}
+
+// snapshot returns the items waiting to be got and being processed
+func (q *priorityQueue) snapshot() (ready []QueueItem, processing []QueueItem) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	ready = make([]QueueItem, 0, len(q.queued))
+	for item, p := range q.queued {
+		if q.processing[item] {
+			continue
+		}
+		ready = append(ready, QueueItem{Key: fmt.Sprint(item), Priority: p.String(), Tenant: q.tenantOf[item]})
+	}
+	processing = make([]QueueItem, 0, len(q.processing))
+	for item := range q.processing {
+		processing = append(processing, QueueItem{Key: fmt.Sprint(item), Tenant: q.tenantOf[item]})
+	}
+	return sortQueueItems(ready), sortQueueItems(processing)
+}
+
+// Get blocks until it can return an item to be processed.
+// Items with higher priority are got first, and tenants with the same priority are got in turn.
+// Items of tenants that have MaxProcessing items being processed are not got until one of them is done.
+func (q *priorityQueue) Get() (interface{}, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for {
+		if item, ok := q.pop(); ok {
+			return item, false
+		}
+		if q.shuttingDown {
+			return nil, true
+		}
+		q.cond.Wait()
+	}
+}
+
+// pop returns the next waiting item, tenants that reach MaxProcessing are skipped
+func (q *priorityQueue) pop() (interface{}, bool) {
+	for _, p := range Priorities {
+		l := q.lanes[p]
+		for i := 0; i < len(l.tenants); {
+			idx := (l.next + i) % len(l.tenants)
+			tenant := l.tenants[idx]
+			item, ok := q.peek(p, tenant)
+			if !ok {
+				// all entries of tenant are stale
+				delete(l.fifos, tenant)
+				l.tenants = append(l.tenants[:idx], l.tenants[idx+1:]...)
+				if idx < l.next {
+					l.next--
+				}
+				if len(l.tenants) > 0 {
+					l.next %= len(l.tenants)
+				} else {
+					l.next = 0
+				}
+				continue
+			}
+			if q.fairness.MaxProcessing > 0 && q.processingOfTenant[tenant] >= q.fairness.MaxProcessing {
+				i++
+				continue
+			}
+
+			l.fifos[tenant] = l.fifos[tenant][1:]
+			if len(l.fifos[tenant]) == 0 {
+				delete(l.fifos, tenant)
+				l.tenants = append(l.tenants[:idx], l.tenants[idx+1:]...)
+				l.next = idx
+			} else {
+				l.next = idx + 1
+			}
+			if len(l.tenants) > 0 {
+				l.next %= len(l.tenants)
+			} else {
+				l.next = 0
+			}
+
+			q.decCount(p, tenant)
+			delete(q.queued, item)
+			delete(q.dirty, item)
+			q.processing[item] = true
+			q.processingOfTenant[tenant]++
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// peek drops stale entries at the head of the FIFO of tenant, and returns the first valid item.
+// An entry is stale if its item is moved to a higher lane, already got, or being processed.
+func (q *priorityQueue) peek(p Priority, tenant string) (interface{}, bool) {
+	fifo := q.lanes[p].fifos[tenant]
+	for len(fifo) > 0 {
+		item := fifo[0]
+		if cur, ok := q.queued[item]; ok && cur == p && !q.processing[item] && q.tenantOf[item] == tenant {
+			q.lanes[p].fifos[tenant] = fifo
+			return item, true
+		}
+		fifo[0] = nil
+		fifo = fifo[1:]
+	}
+	q.lanes[p].fifos[tenant] = fifo
+	return nil, false
+}
+
+// Done marks item as done processing, the item is queued again if it is added while being processed
+func (q *priorityQueue) Done(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if !q.processing[item] {
+		return
+	}
+	delete(q.processing, item)
+	tenant := q.tenantOf[item]
+	q.processingOfTenant[tenant]--
+	if q.processingOfTenant[tenant] <= 0 {
+		delete(q.processingOfTenant, tenant)
+	}
+	if q.dirty[item] {
+		q.push(item, q.queued[item])
+		return
+	}
+	delete(q.tenantOf, item)
+	// items of tenants that reached MaxProcessing may be got now
+	q.cond.Broadcast()
+}
+
+// ShutDown makes Get return once all items are got
+func (q *priorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+// ShuttingDown returns true if ShutDown is called
+func (q *priorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
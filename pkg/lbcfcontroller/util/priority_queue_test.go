@@ -0,0 +1,178 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"testing"
+	"time"
+
+	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPriorityQueueOrder(t *testing.T) {
+	priorities := map[string]Priority{
+		"periodic-1": PriorityPeriodic,
+		"periodic-2": PriorityPeriodic,
+		"register":   PriorityRegister,
+		"deregister": PriorityDeregister,
+	}
+	q := newPriorityQueue(func(item interface{}) Priority {
+		return priorities[item.(string)]
+	}, nil)
+	for _, item := range []string{"periodic-1", "periodic-2", "register", "deregister"} {
+		q.Add(item)
+	}
+	if q.Len() != 4 || q.LenOfPriority(PriorityPeriodic) != 2 {
+		t.Fatalf("expect 4 items and 2 periodic items, get %d and %d", q.Len(), q.LenOfPriority(PriorityPeriodic))
+	}
+
+	// periodic-2 is upgraded to deregister, and got after the existing deregister item
+	priorities["periodic-2"] = PriorityDeregister
+	q.Add("periodic-2")
+	expect := []string{"deregister", "periodic-2", "register", "periodic-1"}
+	for _, e := range expect {
+		item, _ := q.Get()
+		if item.(string) != e {
+			t.Fatalf("expect %s, get %v", e, item)
+		}
+		q.Done(item)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("expect empty queue, get %d", q.Len())
+	}
+}
+
+func TestPriorityQueueProcessing(t *testing.T) {
+	q := newPriorityQueue(nil, nil)
+	q.Add("a")
+	item, _ := q.Get()
+
+	// an item being processed is not got again until done
+	q.Add("a")
+	q.Add("a")
+	if q.Len() != 0 {
+		t.Fatalf("expect 0 items waiting, get %d", q.Len())
+	}
+	q.Done(item)
+	if q.Len() != 1 || q.LenOfPriority(PriorityRegister) != 1 {
+		t.Fatalf("expect 1 item waiting after done, get %d", q.Len())
+	}
+	q.Get()
+
+	q.ShutDown()
+	if _, shutdown := q.Get(); !shutdown {
+		t.Fatalf("expect shutdown")
+	}
+}
+
+func TestPriorityDelayingQueueAddAfter(t *testing.T) {
+	q := newPriorityDelayingQueue(nil, nil)
+	defer q.ShutDown()
+	q.AddAfter("a", 10*time.Millisecond)
+	if q.Len() != 0 {
+		t.Fatalf("expect 0 items before delay, get %d", q.Len())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if q.Len() != 1 {
+		t.Fatalf("expect 1 item after delay, get %d", q.Len())
+	}
+}
+
+func TestPriorityForBackend(t *testing.T) {
+	ts := metav1.Now()
+	lister := &fakeBackendListerWithStore{
+		store: map[string]*lbcfapi.BackendRecord{
+			"deleting": {
+				ObjectMeta: metav1.ObjectMeta{Name: "deleting", DeletionTimestamp: &ts},
+			},
+			"new": {
+				ObjectMeta: metav1.ObjectMeta{Name: "new"},
+			},
+			"registered": {
+				ObjectMeta: metav1.ObjectMeta{Name: "registered"},
+				Status: lbcfapi.BackendRecordStatus{
+					Conditions: []lbcfapi.BackendRecordCondition{
+						{
+							Type:   lbcfapi.BackendRegistered,
+							Status: lbcfapi.ConditionTrue,
+						},
+					},
+				},
+			},
+		},
+	}
+	cases := map[string]Priority{
+		"default/deleting":   PriorityDeregister,
+		"default/not-exist":  PriorityDeregister,
+		"default/new":        PriorityRegister,
+		"default/registered": PriorityPeriodic,
+	}
+	priority := PriorityForBackend(lister)
+	for key, expect := range cases {
+		if get := priority(key); get != expect {
+			t.Errorf("key %s, expect %s, get %s", key, expect.String(), get.String())
+		}
+	}
+}
+
+func TestPriorityQueueFairness(t *testing.T) {
+	q := newPriorityQueue(nil, &Fairness{
+		Tenant:        TenantByNamespace,
+		MaxProcessing: 2,
+	})
+	for _, key := range []string{"big/1", "big/2", "big/3", "big/4", "small/1", "other/1"} {
+		q.Add(key)
+	}
+	if tenants := q.LenOfTenants(); tenants["big"] != 4 || tenants["small"] != 1 {
+		t.Fatalf("expect 4 items of big and 1 item of small, get %v", tenants)
+	}
+
+	// tenants are got in turn, and big reaches MaxProcessing after 2 items
+	expect := []string{"big/1", "small/1", "other/1", "big/2"}
+	var got []interface{}
+	for _, e := range expect {
+		item, _ := q.Get()
+		if item.(string) != e {
+			t.Fatalf("expect %s, get %v", e, item)
+		}
+		got = append(got, item)
+	}
+
+	result := make(chan interface{})
+	go func() {
+		item, _ := q.Get()
+		result <- item
+	}()
+	select {
+	case item := <-result:
+		t.Fatalf("expect blocked by MaxProcessing, get %v", item)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.Done(got[0])
+	select {
+	case item := <-result:
+		if item.(string) != "big/3" {
+			t.Fatalf("expect big/3, get %v", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect big/3 after an item of big is done")
+	}
+}
@@ -18,11 +18,15 @@
 package util
 
 import (
+	"math"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
+	lbcfv1lister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 
 	"golang.org/x/time/rate"
@@ -37,23 +41,74 @@ type ConditionalRateLimitingInterface interface {
 	AddAfterFiltered(item interface{}, duration time.Duration)
 	LenWaitingForFilter() int
 	GetName() string
+	// SetRetryDelay changes the retry delays at runtime, failures of items are kept
+	SetRetryDelay(jitterFactor float64, minDelay time.Duration, step time.Duration, maxDelay time.Duration)
+	// LenProcessing returns the number of items that are got but not done
+	LenProcessing() int
+	// LastProgress returns the last time an item is got or done
+	LastProgress() time.Time
+	// LenOfPriority returns the number of items with priority p waiting to be got
+	LenOfPriority(p Priority) int
+	// LenOfTenants returns the number of waiting items of each tenant
+	LenOfTenants() map[string]int
+	// RetryDelay returns the delay, with jitter, that AddAfterMinimumDelay uses for the next failure of item.
+	// The delay is kept until item is added by AddAfterMinimumDelay or forgotten, so that it can be recorded before item is added
+	RetryDelay(item interface{}, minDelay time.Duration) time.Duration
+	// SeedRetry restores the failures of item recorded before restart and adds item after retryAfter,
+	// it returns false and does nothing if item already has failures in memory
+	SeedRetry(item interface{}, failures int, retryAfter time.Time) bool
+	// Snapshot returns the items in the queue, it is used for debugging
+	Snapshot() QueueSnapshot
 }
 
 // NewConditionalDelayingQueue returns a new instance of ConditionalRateLimitingInterface. If minDelay is less than step, the real minimum delay is step.
 func NewConditionalDelayingQueue(name string, filter QueueFilter, minDelay time.Duration, step time.Duration, maxDelay time.Duration) ConditionalRateLimitingInterface {
-	rateLimiter := workqueue.NewMaxOfRateLimiter(
-		workqueue.NewItemExponentialFailureRateLimiter(step, maxDelay),
-		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
-		&fixedRateDelayLimiter{duration: minDelay},
-	)
+	return NewConditionalDelayingQueueWithBackoff(name, filter, nil, 0, minDelay, step, maxDelay)
+}
+
+// NewConditionalDelayingQueueWithBackoff returns a new instance of ConditionalRateLimitingInterface.
+// The retry delay of an item is calculated with the Backoff returned by backoff if it is not nil,
+// and a random duration up to jitterFactor*delay is added to the retry delay.
+func NewConditionalDelayingQueueWithBackoff(
+	name string,
+	filter QueueFilter,
+	backoff BackoffFunc,
+	jitterFactor float64,
+	minDelay time.Duration,
+	step time.Duration,
+	maxDelay time.Duration) ConditionalRateLimitingInterface {
+	return NewConditionalDelayingQueueWithPriority(name, filter, backoff, nil, nil, jitterFactor, minDelay, step, maxDelay)
+}
+
+// NewConditionalDelayingQueueWithPriority returns a new instance of ConditionalRateLimitingInterface.
+// Items are got in the order of the Priority returned by priority, all items have PriorityRegister if priority is nil.
+// Items with the same priority are shared among tenants by fairness, all items belong to one tenant if fairness is nil.
+func NewConditionalDelayingQueueWithPriority(
+	name string,
+	filter QueueFilter,
+	backoff BackoffFunc,
+	priority PriorityFunc,
+	fairness *Fairness,
+	jitterFactor float64,
+	minDelay time.Duration,
+	step time.Duration,
+	maxDelay time.Duration) ConditionalRateLimitingInterface {
 	q := &conditionalRateLimitingQueue{
-		DelayingInterface:      workqueue.NewDelayingQueue(),
-		rateLimiter:            rateLimiter,
+		DelayingInterface:      newPriorityDelayingQueue(priority, fairness),
 		waitingWithFilterQueue: workqueue.NewDelayingQueue(),
 		filter:                 filter,
+		backoff:                backoff,
+		jitterFactor:           jitterFactor,
 		minDelay:               minDelay,
+		step:                   step,
+		maxDelay:               maxDelay,
 		name:                   name,
+		lastProgress:           time.Now(),
 	}
+	q.failures = newItemFailureLimiter(func(failures int) time.Duration {
+		return q.backoffDelay(&Backoff{}, failures)
+	})
+	q.bucket = &workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)}
 
 	go q.run()
 
@@ -62,35 +117,219 @@ func NewConditionalDelayingQueue(name string, filter QueueFilter, minDelay time.
 
 type conditionalRateLimitingQueue struct {
 	workqueue.DelayingInterface
-	rateLimiter            workqueue.RateLimiter
+	// bucket limits the overall rate of retries of all items
+	bucket                 workqueue.RateLimiter
+	failures               *itemFailureLimiter
 	waitingWithFilterQueue workqueue.DelayingInterface
+	waitingForFilter       delayTracker
 	filter                 QueueFilter
-	minDelay               time.Duration
+	backoff                BackoffFunc
 	name                   string
+
+	// mu protects the retry delays below
+	mu           sync.RWMutex
+	jitterFactor float64
+	minDelay     time.Duration
+	step         time.Duration
+	maxDelay     time.Duration
+	// reservedDelays are delays returned by RetryDelay and not yet used by AddAfterMinimumDelay
+	reservedDelays map[interface{}]time.Duration
+
+	// progressMu protects the progress of workers below
+	progressMu   sync.Mutex
+	processing   int
+	lastProgress time.Time
+}
+
+// Get blocks until it can return an item to be processed, the progress of workers is recorded
+func (q *conditionalRateLimitingQueue) Get() (interface{}, bool) {
+	item, shutdown := q.DelayingInterface.Get()
+	if !shutdown {
+		q.progressMu.Lock()
+		q.processing++
+		q.lastProgress = time.Now()
+		q.progressMu.Unlock()
+	}
+	return item, shutdown
 }
 
-// AddAfterMinimumDelay adds item after at least the indicated minDelay has passed
+// Done marks item as done processing, the progress of workers is recorded
+func (q *conditionalRateLimitingQueue) Done(item interface{}) {
+	q.DelayingInterface.Done(item)
+	q.progressMu.Lock()
+	if q.processing > 0 {
+		q.processing--
+	}
+	q.lastProgress = time.Now()
+	q.progressMu.Unlock()
+}
+
+// LenOfPriority returns the number of items with priority p waiting to be got
+func (q *conditionalRateLimitingQueue) LenOfPriority(p Priority) int {
+	pq, ok := q.DelayingInterface.(*priorityDelayingQueue)
+	if !ok {
+		return 0
+	}
+	return pq.LenOfPriority(p)
+}
+
+// LenOfTenants returns the number of waiting items of each tenant
+func (q *conditionalRateLimitingQueue) LenOfTenants() map[string]int {
+	pq, ok := q.DelayingInterface.(*priorityDelayingQueue)
+	if !ok {
+		return nil
+	}
+	return pq.LenOfTenants()
+}
+
+// LenProcessing returns the number of items that are got but not done
+func (q *conditionalRateLimitingQueue) LenProcessing() int {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+	return q.processing
+}
+
+// LastProgress returns the last time an item is got or done
+func (q *conditionalRateLimitingQueue) LastProgress() time.Time {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+	return q.lastProgress
+}
+
+// AddAfterMinimumDelay adds item after at least the indicated minDelay has passed.
+// The retry delay of item never bypasses the overall rate limit shared by all items, even if its driver overrides the backoff
 func (q *conditionalRateLimitingQueue) AddAfterMinimumDelay(item interface{}, minDelay time.Duration) {
-	delay := q.rateLimiter.When(item)
+	bucketDelay := q.bucket.When(item)
+	delay := q.failures.When(item)
+	if reserved, ok := q.takeReservedDelay(item); ok {
+		delay = reserved
+	} else {
+		if q.backoff != nil {
+			if b := q.backoff(item); b != nil {
+				delay = q.backoffDelay(b, q.failures.NumRequeues(item))
+			}
+		}
+		if jitterFactor := q.getJitterFactor(); jitterFactor > 0 {
+			delay = wait.Jitter(delay, jitterFactor)
+		}
+	}
+	if bucketDelay > delay {
+		delay = bucketDelay
+	}
 	if minDelay.Nanoseconds() > delay.Nanoseconds() {
 		delay = minDelay
 	}
 	q.DelayingInterface.AddAfter(item, delay)
 }
 
+// RetryDelay returns the delay, with jitter, that AddAfterMinimumDelay uses for the next failure of item.
+// The delay is kept until item is added by AddAfterMinimumDelay or forgotten, so that it can be recorded before item is added
+func (q *conditionalRateLimitingQueue) RetryDelay(item interface{}, minDelay time.Duration) time.Duration {
+	b := &Backoff{}
+	if q.backoff != nil {
+		if itemBackoff := q.backoff(item); itemBackoff != nil {
+			b = itemBackoff
+		}
+	}
+	delay := q.backoffDelay(b, q.failures.NumRequeues(item)+1)
+	if jitterFactor := q.getJitterFactor(); jitterFactor > 0 {
+		delay = wait.Jitter(delay, jitterFactor)
+	}
+	if minDelay > delay {
+		delay = minDelay
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.reservedDelays == nil {
+		q.reservedDelays = make(map[interface{}]time.Duration)
+	}
+	q.reservedDelays[item] = delay
+	return delay
+}
+
+// takeReservedDelay returns and removes the delay returned by RetryDelay for item
+func (q *conditionalRateLimitingQueue) takeReservedDelay(item interface{}) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delay, ok := q.reservedDelays[item]
+	delete(q.reservedDelays, item)
+	return delay, ok
+}
+
+// SeedRetry restores the failures of item recorded before restart and adds item after retryAfter,
+// it returns false and does nothing if item already has failures in memory
+func (q *conditionalRateLimitingQueue) SeedRetry(item interface{}, failures int, retryAfter time.Time) bool {
+	if !q.failures.Seed(item, failures) {
+		return false
+	}
+	q.DelayingInterface.AddAfter(item, time.Until(retryAfter))
+	return true
+}
+
 // Forget indicates that an item is finished being retried
 func (q *conditionalRateLimitingQueue) Forget(item interface{}) {
-	q.rateLimiter.Forget(item)
+	q.failures.Forget(item)
+	q.takeReservedDelay(item)
 }
 
 // AddAfterFiltered adds item after the indicated duration has passed, a filter will run on the item before Get
 func (q *conditionalRateLimitingQueue) AddAfterFiltered(item interface{}, duration time.Duration) {
-	if duration.Nanoseconds() < q.minDelay.Nanoseconds() {
-		duration = q.minDelay
+	if minDelay := q.getMinDelay(); duration.Nanoseconds() < minDelay.Nanoseconds() {
+		duration = minDelay
 	}
+	q.waitingForFilter.add(item, duration)
 	q.waitingWithFilterQueue.AddAfter(item, duration)
 }
 
+// backoffDelay calculates the exponential retry delay with b, fields not set in b fall back to the queue's parameters
+func (q *conditionalRateLimitingQueue) backoffDelay(b *Backoff, failures int) time.Duration {
+	q.mu.RLock()
+	minDelay, step, maxDelay := q.minDelay, q.step, q.maxDelay
+	q.mu.RUnlock()
+	if b.MinDelay > 0 {
+		minDelay = b.MinDelay
+	}
+	if b.Step > 0 {
+		step = b.Step
+	}
+	if b.MaxDelay > 0 {
+		maxDelay = b.MaxDelay
+	}
+	delay := maxDelay
+	if failures > 0 {
+		exp := float64(step.Nanoseconds()) * math.Pow(2, float64(failures-1))
+		if exp < float64(maxDelay.Nanoseconds()) {
+			delay = time.Duration(exp)
+		}
+	}
+	if delay < minDelay {
+		delay = minDelay
+	}
+	return delay
+}
+
+// SetRetryDelay changes the retry delays at runtime, failures of items are kept
+func (q *conditionalRateLimitingQueue) SetRetryDelay(jitterFactor float64, minDelay time.Duration, step time.Duration, maxDelay time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jitterFactor = jitterFactor
+	q.minDelay = minDelay
+	q.step = step
+	q.maxDelay = maxDelay
+}
+
+func (q *conditionalRateLimitingQueue) getMinDelay() time.Duration {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.minDelay
+}
+
+func (q *conditionalRateLimitingQueue) getJitterFactor() float64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.jitterFactor
+}
+
 func (q *conditionalRateLimitingQueue) GetName() string {
 	return q.name
 }
@@ -100,6 +339,24 @@ func (q *conditionalRateLimitingQueue) LenWaitingForFilter() int {
 	return q.waitingWithFilterQueue.Len()
 }
 
+// Snapshot returns the items in the queue, it is used for debugging
+func (q *conditionalRateLimitingQueue) Snapshot() QueueSnapshot {
+	s := QueueSnapshot{
+		Name:             q.name,
+		WaitingForFilter: q.waitingForFilter.items(),
+	}
+	if pq, ok := q.DelayingInterface.(*priorityDelayingQueue); ok {
+		s.Ready, s.Processing = pq.snapshot()
+		s.Delayed = pq.delayed.items()
+	}
+	for _, items := range [][]QueueItem{s.Ready, s.Processing, s.Delayed, s.WaitingForFilter} {
+		for i := range items {
+			items[i].NumRequeues = q.failures.NumRequeues(items[i].Key)
+		}
+	}
+	return s
+}
+
 func (q *conditionalRateLimitingQueue) run() {
 	for q.filterQueue() {
 	}
@@ -112,6 +369,7 @@ func (q *conditionalRateLimitingQueue) filterQueue() bool {
 		return false
 	}
 	q.waitingWithFilterQueue.Done(item)
+	q.waitingForFilter.remove(item)
 
 	if q.filter == nil {
 		q.Add(item)
@@ -121,7 +379,7 @@ func (q *conditionalRateLimitingQueue) filterQueue() bool {
 	match, err := q.filter(item)
 	if err != nil {
 		klog.Errorf("conditionalRateLimitingQueue filter %v failed: %v", item, err)
-		q.AddAfterFiltered(item, q.minDelay)
+		q.AddAfterFiltered(item, q.getMinDelay())
 		return true
 	}
 	if match {
@@ -130,19 +388,51 @@ func (q *conditionalRateLimitingQueue) filterQueue() bool {
 	return true
 }
 
-type fixedRateDelayLimiter struct {
-	duration time.Duration
+// itemFailureLimiter counts failures of items, and calculates the delay with the current failures
+type itemFailureLimiter struct {
+	mu       sync.Mutex
+	failures map[interface{}]int
+	delay    func(failures int) time.Duration
 }
 
-func (l *fixedRateDelayLimiter) When(item interface{}) time.Duration {
-	return l.duration
+func newItemFailureLimiter(delay func(failures int) time.Duration) *itemFailureLimiter {
+	return &itemFailureLimiter{
+		failures: make(map[interface{}]int),
+		delay:    delay,
+	}
 }
 
-func (l *fixedRateDelayLimiter) Forget(item interface{}) {
+func (l *itemFailureLimiter) When(item interface{}) time.Duration {
+	l.mu.Lock()
+	l.failures[item]++
+	failures := l.failures[item]
+	l.mu.Unlock()
+	return l.delay(failures)
 }
 
-func (l *fixedRateDelayLimiter) NumRequeues(item interface{}) int {
-	return 0
+func (l *itemFailureLimiter) Forget(item interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, item)
+}
+
+// Seed sets the failures of item if it has no failures, it returns false if item already has failures
+func (l *itemFailureLimiter) Seed(item interface{}, failures int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failures[item] > 0 {
+		return false
+	}
+	if failures > 0 {
+		l.failures[item] = failures
+	}
+	return true
+}
+
+func (l *itemFailureLimiter) NumRequeues(item interface{}) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.failures[item]
 }
 
 // QueueFilter is a function that filter queue elements
@@ -191,3 +481,94 @@ func QueueFilterForBackend(backendLister v1beta1.BackendRecordLister) QueueFilte
 		return false, nil
 	}
 }
+
+// Backoff contains the parameters used to calculate retry delay, zero values mean the queue's parameters are used
+type Backoff struct {
+	MinDelay time.Duration
+	Step     time.Duration
+	MaxDelay time.Duration
+}
+
+// BackoffFunc returns the Backoff for item, nil means the queue's parameters are used
+type BackoffFunc func(item interface{}) *Backoff
+
+// BackoffForLB returns a BackoffFunc that uses the retryDelay configured in the driver of LoadBalancer
+func BackoffForLB(lbLister v1beta1.LoadBalancerLister, driverLister v1beta1.LoadBalancerDriverLister) BackoffFunc {
+	return func(item interface{}) *Backoff {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return nil
+		}
+		lb, err := lbLister.LoadBalancers(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		return driverBackoff(driverLister, lb.Spec.LBDriver, lb.Namespace)
+	}
+}
+
+// BackoffForBackend returns a BackoffFunc that uses the retryDelay configured in the driver of BackendRecord
+func BackoffForBackend(backendLister v1beta1.BackendRecordLister, driverLister v1beta1.LoadBalancerDriverLister) BackoffFunc {
+	return func(item interface{}) *Backoff {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return nil
+		}
+		backend, err := backendLister.BackendRecords(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		return driverBackoff(driverLister, backend.Spec.LBDriver, backend.Namespace)
+	}
+}
+
+// BackoffForBind returns a BackoffFunc that uses the retryDelay configured in the drivers of Bind.
+// If the load balancers of Bind use different drivers, the smallest value of each field is used,
+// so that no driver is retried later than it configures.
+func BackoffForBind(bindLister lbcfv1lister.BindLister, driverLister v1beta1.LoadBalancerDriverLister) BackoffFunc {
+	return func(item interface{}) *Backoff {
+		namespace, name, err := cache.SplitMetaNamespaceKey(item.(string))
+		if err != nil {
+			return nil
+		}
+		bind, err := bindLister.Binds(namespace).Get(name)
+		if err != nil {
+			return nil
+		}
+		var merged *Backoff
+		for _, lb := range bind.Spec.LoadBalancers {
+			b := driverBackoff(driverLister, lb.Driver, bind.Namespace)
+			if b == nil {
+				continue
+			}
+			if merged == nil {
+				merged = b
+				continue
+			}
+			merged.MinDelay = minNonZeroDuration(merged.MinDelay, b.MinDelay)
+			merged.Step = minNonZeroDuration(merged.Step, b.Step)
+			merged.MaxDelay = minNonZeroDuration(merged.MaxDelay, b.MaxDelay)
+		}
+		return merged
+	}
+}
+
+func minNonZeroDuration(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func driverBackoff(driverLister v1beta1.LoadBalancerDriverLister, driverName string, namespace string) *Backoff {
+	driver, err := driverLister.LoadBalancerDrivers(NamespaceOfSharedObj(driverName, namespace)).Get(driverName)
+	if err != nil || driver.Spec.RetryDelay == nil {
+		return nil
+	}
+	cfg := driver.Spec.RetryDelay
+	return &Backoff{
+		MinDelay: GetDuration(cfg.MinDelay, 0),
+		Step:     GetDuration(cfg.Step, 0),
+		MaxDelay: GetDuration(cfg.MaxDelay, 0),
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueueSnapshot is the items in a ConditionalRateLimitingInterface at some time, it is used for debugging
+type QueueSnapshot struct {
+	Name string `json:"name"`
+	// Ready are items waiting to be got
+	Ready []QueueItem `json:"ready"`
+	// Processing are items that are got but not done
+	Processing []QueueItem `json:"processing"`
+	// Delayed are items added with a delay, they are ready after ReadyAt
+	Delayed []QueueItem `json:"delayed"`
+	// WaitingForFilter are items added by AddAfterFiltered, they are filtered after ReadyAt
+	WaitingForFilter []QueueItem `json:"waitingForFilter"`
+}
+
+// QueueItem is an item in QueueSnapshot
+type QueueItem struct {
+	Key         string      `json:"key"`
+	Priority    string      `json:"priority,omitempty"`
+	Tenant      string      `json:"tenant,omitempty"`
+	ReadyAt     *time.Time  `json:"readyAt,omitempty"`
+	NumRequeues int         `json:"numRequeues"`
+	LastResult  *SyncRecord `json:"lastResult,omitempty"`
+}
+
+// SyncRecord is a SyncResult returned at Time
+type SyncRecord struct {
+	Result     string    `json:"result"`
+	FailReason string    `json:"failReason,omitempty"`
+	NextRun    string    `json:"nextRun,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// NewSyncRecord records result returned at now
+func NewSyncRecord(result *SyncResult) *SyncRecord {
+	record := &SyncRecord{
+		Result:     result.String(),
+		FailReason: result.GetFailReason(),
+		Time:       time.Now(),
+	}
+	if !result.IsFinished() {
+		record.NextRun = result.GetNextRun().String()
+	}
+	return record
+}
+
+func sortQueueItems(items []QueueItem) []QueueItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items
+}
+
+// delayTracker records when items added with delays are ready, since workqueue.DelayingInterface can not be listed.
+// The zero value is ready to use.
+type delayTracker struct {
+	mu      sync.Mutex
+	readyAt map[interface{}]time.Time
+}
+
+// add records item is ready after delay. Like workqueue.DelayingInterface, the earlier time is kept if item is already waiting
+func (t *delayTracker) add(item interface{}, delay time.Duration) {
+	at := time.Now().Add(delay)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// a past time means item is ready and being moved out, so it is added again
+	if cur, ok := t.readyAt[item]; ok && cur.After(time.Now()) && !at.Before(cur) {
+		return
+	}
+	if t.readyAt == nil {
+		t.readyAt = make(map[interface{}]time.Time)
+	}
+	t.readyAt[item] = at
+}
+
+// remove is called when item is ready, it keeps item if item is added again with a later time
+func (t *delayTracker) remove(item interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cur, ok := t.readyAt[item]; ok && !cur.After(time.Now()) {
+		delete(t.readyAt, item)
+	}
+}
+
+func (t *delayTracker) items() []QueueItem {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make([]QueueItem, 0, len(t.readyAt))
+	for item, at := range t.readyAt {
+		readyAt := at
+		ret = append(ret, QueueItem{Key: fmt.Sprint(item), ReadyAt: &readyAt})
+	}
+	return sortQueueItems(ret)
+}
@@ -26,8 +26,12 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	lbcfv1lister "tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1"
 	"tkestack.io/lb-controlling-framework/pkg/client-go/listers/lbcf.tkestack.io/v1beta1"
 )
 
@@ -100,14 +104,10 @@ func TestConditionalRateLimitingQueueAddAfterFiltered(t *testing.T) {
 	}
 	for _, c := range cases {
 		//q := NewConditionalDelayingQueue(c.filter, time.Millisecond, time.Millisecond, time.Millisecond)
-		rateLimiter := workqueue.NewMaxOfRateLimiter(
-			workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond),
-			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
-			&fixedRateDelayLimiter{duration: time.Millisecond},
-		)
 		q := &conditionalRateLimitingQueue{
 			DelayingInterface:      workqueue.NewDelayingQueue(),
-			rateLimiter:            rateLimiter,
+			bucket:                 &workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+			failures:               newItemFailureLimiter(func(int) time.Duration { return time.Millisecond }),
 			waitingWithFilterQueue: workqueue.NewDelayingQueue(),
 			filter:                 c.filter,
 			minDelay:               time.Millisecond,
@@ -240,6 +240,268 @@ func TestQueueFilterForBackend(t *testing.T) {
 	}
 }
 
+func TestConditionalRateLimitingQueueBackoffDelay(t *testing.T) {
+	q := NewConditionalDelayingQueue("test", nil, time.Second, 2*time.Second, time.Minute).(*conditionalRateLimitingQueue)
+	type testCase struct {
+		name     string
+		backoff  *Backoff
+		failures int
+		expect   time.Duration
+	}
+	cases := []testCase{
+		{
+			name:     "queue-default",
+			backoff:  &Backoff{},
+			failures: 3,
+			expect:   8 * time.Second,
+		},
+		{
+			name:     "override-step",
+			backoff:  &Backoff{Step: 100 * time.Millisecond},
+			failures: 2,
+			expect:   time.Second,
+		},
+		{
+			name:     "override-step-and-min",
+			backoff:  &Backoff{MinDelay: 10 * time.Millisecond, Step: 100 * time.Millisecond},
+			failures: 2,
+			expect:   200 * time.Millisecond,
+		},
+		{
+			name:     "override-max",
+			backoff:  &Backoff{MaxDelay: 5 * time.Second},
+			failures: 10,
+			expect:   5 * time.Second,
+		},
+	}
+	for _, c := range cases {
+		if get := q.backoffDelay(c.backoff, c.failures); get != c.expect {
+			t.Errorf("case %s, expect %v, get %v", c.name, c.expect, get)
+		}
+	}
+}
+
+func TestConditionalRateLimitingQueueAddAfterMinimumDelayWithBackoff(t *testing.T) {
+	backoff := func(item interface{}) *Backoff {
+		return &Backoff{MinDelay: time.Millisecond, Step: time.Millisecond, MaxDelay: time.Millisecond}
+	}
+	q := NewConditionalDelayingQueueWithBackoff("test", nil, backoff, 0.1, time.Minute, time.Minute, time.Minute)
+	startTime := time.Now()
+	q.AddAfterMinimumDelay(struct{}{}, 0)
+	_, quit := q.Get()
+	if quit {
+		t.Fatalf("should not quit")
+	}
+	if elapsed := time.Since(startTime); elapsed > 10*time.Second {
+		t.Fatalf("too long, %s", elapsed.String())
+	}
+}
+
+func TestConditionalRateLimitingQueueAddAfterMinimumDelayWithBackoffKeepBucketLimit(t *testing.T) {
+	backoff := func(item interface{}) *Backoff {
+		return &Backoff{MinDelay: time.Millisecond, Step: time.Millisecond, MaxDelay: time.Millisecond}
+	}
+	q := NewConditionalDelayingQueueWithBackoff("test", nil, backoff, 0, time.Minute, time.Minute, time.Minute).(*conditionalRateLimitingQueue)
+	q.bucket = &workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(1), 1)}
+	q.AddAfterMinimumDelay("item-1", 0)
+	if _, quit := q.Get(); quit {
+		t.Fatalf("should not quit")
+	}
+	// the only token of the bucket is taken by item-1, item-2 must wait for the bucket although its backoff is 1ms
+	q.AddAfterMinimumDelay("item-2", 0)
+	time.Sleep(100 * time.Millisecond)
+	if q.Len() != 0 {
+		t.Fatalf("expect item-2 delayed by the bucket, get %d item(s) ready", q.Len())
+	}
+}
+
+func TestConditionalRateLimitingQueueSetRetryDelay(t *testing.T) {
+	q := NewConditionalDelayingQueue("test", nil, time.Minute, time.Minute, time.Minute)
+	q.AddAfterMinimumDelay("item", 0)
+	if q.Len() != 0 {
+		t.Fatalf("expect item delayed")
+	}
+	q.SetRetryDelay(0, time.Millisecond, time.Millisecond, time.Millisecond)
+	startTime := time.Now()
+	q.AddAfterMinimumDelay("item", 0)
+	if _, quit := q.Get(); quit {
+		t.Fatalf("should not quit")
+	}
+	if elapsed := time.Since(startTime); elapsed > 10*time.Second {
+		t.Fatalf("too long, %s", elapsed.String())
+	}
+	if get := q.(*conditionalRateLimitingQueue).failures.NumRequeues("item"); get != 2 {
+		t.Fatalf("expect failures kept, get %d", get)
+	}
+}
+
+func TestConditionalRateLimitingQueueSeedRetry(t *testing.T) {
+	q := NewConditionalDelayingQueue("test", nil, time.Second, time.Second, time.Hour)
+	if get := q.RetryDelay("item", 0); get != time.Second {
+		t.Fatalf("expect delay of the 1st failure 1s, get %s", get.String())
+	}
+	if !q.SeedRetry("item", 3, time.Now().Add(50*time.Millisecond)) {
+		t.Fatalf("expect seeded")
+	}
+	if q.Len() != 0 {
+		t.Fatalf("expect item delayed")
+	}
+	if get := q.RetryDelay("item", 0); get != 8*time.Second {
+		t.Fatalf("expect delay of the 4th failure 8s, get %s", get.String())
+	}
+	if get := q.RetryDelay("item", time.Minute); get != time.Minute {
+		t.Fatalf("expect minDelay 1m, get %s", get.String())
+	}
+	if q.SeedRetry("item", 1, time.Now()) {
+		t.Fatalf("expect not seeded if item has failures")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if q.Len() != 1 {
+		t.Fatalf("expect item added after retryAfter, get %d", q.Len())
+	}
+}
+
+func TestConditionalRateLimitingQueueRetryDelayWithJitter(t *testing.T) {
+	q := NewConditionalDelayingQueueWithBackoff("test", nil, nil, 1, time.Hour, time.Hour, 10*time.Hour)
+	delay := q.RetryDelay("item", 0)
+	if delay < time.Hour || delay > 2*time.Hour {
+		t.Fatalf("expect delay with jitter in [1h, 2h], get %s", delay.String())
+	}
+	start := time.Now()
+	q.AddAfterMinimumDelay("item", 0)
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return len(q.Snapshot().Delayed) == 1, nil
+	}); err != nil {
+		t.Fatalf("expect item delayed: %v", err)
+	}
+	readyAt := q.Snapshot().Delayed[0].ReadyAt
+	if diff := readyAt.Sub(start.Add(delay)); diff < 0 || diff > time.Second {
+		t.Errorf("expect item added after the delay returned by RetryDelay %s, get ready at %s", delay.String(), readyAt.String())
+	}
+
+	// the delay is not reserved once item is forgotten
+	q.RetryDelay("forgotten", 0)
+	q.Forget("forgotten")
+	if _, ok := q.(*conditionalRateLimitingQueue).takeReservedDelay("forgotten"); ok {
+		t.Errorf("expect reserved delay removed after forget")
+	}
+}
+
+func TestBackoffForLB(t *testing.T) {
+	lbLister := &fakeLBListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancer{
+			"with-retry-delay": {
+				ObjectMeta: v1.ObjectMeta{
+					Name: "with-retry-delay",
+				},
+				Spec: lbcfapi.LoadBalancerSpec{
+					LBDriver: "driver-with-retry-delay",
+				},
+			},
+			"without-retry-delay": {
+				ObjectMeta: v1.ObjectMeta{
+					Name: "without-retry-delay",
+				},
+				Spec: lbcfapi.LoadBalancerSpec{
+					LBDriver: "driver",
+				},
+			},
+		},
+	}
+	driverLister := &fakeDriverListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancerDriver{
+			"driver-with-retry-delay": {
+				ObjectMeta: v1.ObjectMeta{
+					Name: "driver-with-retry-delay",
+				},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					RetryDelay: &lbcfapi.RetryDelayConfig{
+						Step: &lbcfapi.Duration{Duration: time.Second},
+					},
+				},
+			},
+			"driver": {
+				ObjectMeta: v1.ObjectMeta{
+					Name: "driver",
+				},
+			},
+		},
+	}
+	backoff := BackoffForLB(lbLister, driverLister)
+	if get := backoff(NamespacedNameKeyFunc("", "with-retry-delay")); get == nil || get.Step != time.Second || get.MinDelay != 0 {
+		t.Errorf("expect step 1s, get %+v", get)
+	}
+	if get := backoff(NamespacedNameKeyFunc("", "without-retry-delay")); get != nil {
+		t.Errorf("expect nil, get %+v", get)
+	}
+	if get := backoff(NamespacedNameKeyFunc("", "not-exist")); get != nil {
+		t.Errorf("expect nil, get %+v", get)
+	}
+}
+
+func TestBackoffForBind(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	binds := []*lbcfv1.Bind{
+		{
+			ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "multiple-drivers"},
+			Spec: lbcfv1.BindSpec{
+				LoadBalancers: []lbcfv1.TargetLoadBalancer{
+					{Name: "lb-1", Driver: "driver-slow"},
+					{Name: "lb-2", Driver: "driver-fast"},
+					{Name: "lb-3", Driver: "driver"},
+				},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: "without-retry-delay"},
+			Spec: lbcfv1.BindSpec{
+				LoadBalancers: []lbcfv1.TargetLoadBalancer{
+					{Name: "lb-1", Driver: "driver"},
+				},
+			},
+		},
+	}
+	for _, bind := range binds {
+		if err := indexer.Add(bind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	driverLister := &fakeDriverListerWithStore{
+		store: map[string]*lbcfapi.LoadBalancerDriver{
+			"driver-slow": {
+				ObjectMeta: v1.ObjectMeta{Name: "driver-slow"},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					RetryDelay: &lbcfapi.RetryDelayConfig{
+						Step:     &lbcfapi.Duration{Duration: 10 * time.Second},
+						MaxDelay: &lbcfapi.Duration{Duration: time.Minute},
+					},
+				},
+			},
+			"driver-fast": {
+				ObjectMeta: v1.ObjectMeta{Name: "driver-fast"},
+				Spec: lbcfapi.LoadBalancerDriverSpec{
+					RetryDelay: &lbcfapi.RetryDelayConfig{
+						Step: &lbcfapi.Duration{Duration: time.Second},
+					},
+				},
+			},
+			"driver": {
+				ObjectMeta: v1.ObjectMeta{Name: "driver"},
+			},
+		},
+	}
+	backoff := BackoffForBind(lbcfv1lister.NewBindLister(indexer), driverLister)
+	if get := backoff(NamespacedNameKeyFunc("default", "multiple-drivers")); get == nil || get.Step != time.Second || get.MaxDelay != time.Minute || get.MinDelay != 0 {
+		t.Errorf("expect step 1s and maxDelay 1m, get %+v", get)
+	}
+	if get := backoff(NamespacedNameKeyFunc("default", "without-retry-delay")); get != nil {
+		t.Errorf("expect nil, get %+v", get)
+	}
+	if get := backoff(NamespacedNameKeyFunc("default", "not-exist")); get != nil {
+		t.Errorf("expect nil, get %+v", get)
+	}
+}
+
 type fakeLBListerWithStore struct {
 	store map[string]*lbcfapi.LoadBalancer
 }
@@ -295,3 +557,75 @@ func (l *fakeBackendListerWithStore) List(selector labels.Selector) (ret []*lbcf
 func (l *fakeBackendListerWithStore) BackendRecords(namespace string) v1beta1.BackendRecordNamespaceLister {
 	return l
 }
+
+type fakeDriverListerWithStore struct {
+	store map[string]*lbcfapi.LoadBalancerDriver
+}
+
+func (l *fakeDriverListerWithStore) Get(name string) (*lbcfapi.LoadBalancerDriver, error) {
+	driver, ok := l.store[name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{
+			Group:    "lbcf.tkestack.io/v1beta1",
+			Resource: "LoadBalancerDriver",
+		}, name)
+	}
+	return driver, nil
+}
+
+func (l *fakeDriverListerWithStore) List(selector labels.Selector) (ret []*lbcfapi.LoadBalancerDriver, err error) {
+	for _, driver := range l.store {
+		ret = append(ret, driver)
+	}
+	return
+}
+
+func (l *fakeDriverListerWithStore) LoadBalancerDrivers(namespace string) v1beta1.LoadBalancerDriverNamespaceLister {
+	return l
+}
+
+func TestConditionalRateLimitingQueueSnapshot(t *testing.T) {
+	q := NewConditionalDelayingQueue("test", nil, time.Second, time.Second, time.Hour)
+	q.Add("processing")
+	if item, _ := q.Get(); item != "processing" {
+		t.Fatalf("expect processing, get %v", item)
+	}
+	q.Add("ready")
+	q.AddAfterMinimumDelay("delayed", time.Hour)
+	q.AddAfterFiltered("filtered", time.Hour)
+
+	s := q.Snapshot()
+	if s.Name != "test" {
+		t.Errorf("expect name test, get %s", s.Name)
+	}
+	if len(s.Ready) != 1 || s.Ready[0].Key != "ready" || s.Ready[0].Priority != PriorityRegister.String() {
+		t.Errorf("unexpected ready items: %+v", s.Ready)
+	}
+	if len(s.Processing) != 1 || s.Processing[0].Key != "processing" {
+		t.Errorf("unexpected processing items: %+v", s.Processing)
+	}
+	if len(s.Delayed) != 1 || s.Delayed[0].Key != "delayed" || s.Delayed[0].NumRequeues != 1 ||
+		s.Delayed[0].ReadyAt == nil || time.Until(*s.Delayed[0].ReadyAt) < 59*time.Minute {
+		t.Errorf("unexpected delayed items: %+v", s.Delayed)
+	}
+	if len(s.WaitingForFilter) != 1 || s.WaitingForFilter[0].Key != "filtered" || s.WaitingForFilter[0].ReadyAt == nil {
+		t.Errorf("unexpected items waiting for filter: %+v", s.WaitingForFilter)
+	}
+}
+
+func TestConditionalRateLimitingQueueSnapshotDelayedReady(t *testing.T) {
+	q := NewConditionalDelayingQueue("test", nil, time.Millisecond, time.Millisecond, time.Millisecond)
+	q.AddAfter("item", 50*time.Millisecond)
+	q.AddAfterFiltered("item", 50*time.Millisecond)
+	if s := q.Snapshot(); len(s.Delayed) != 1 || len(s.WaitingForFilter) != 1 {
+		t.Fatalf("expect item delayed and waiting for filter, get %+v", s)
+	}
+	time.Sleep(200 * time.Millisecond)
+	s := q.Snapshot()
+	if len(s.Delayed) != 0 || len(s.WaitingForFilter) != 0 {
+		t.Errorf("expect no delayed item, get %+v", s)
+	}
+	if len(s.Ready) != 1 || s.Ready[0].Key != "item" {
+		t.Errorf("expect item ready, get %+v", s.Ready)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabel "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// WatchScope describes the namespaces and labels of objects handled by lbcf-controller.
+//
+// kube-system is always in scope, because shared LoadBalancers and LoadBalancerDrivers (those named with prefix "lbcf-")
+// are created in kube-system.
+type WatchScope struct {
+	namespaces sets.String
+	selector   k8slabel.Selector
+}
+
+// NewWatchScope creates a WatchScope, empty namespaces means all namespaces, empty selector means all objects
+func NewWatchScope(namespaces []string, selector string) (*WatchScope, error) {
+	s := &WatchScope{
+		namespaces: sets.NewString(),
+		selector:   k8slabel.Everything(),
+	}
+	for _, ns := range namespaces {
+		if ns != "" {
+			s.namespaces.Insert(ns)
+		}
+	}
+	if s.namespaces.Len() > 0 {
+		s.namespaces.Insert(metav1.NamespaceSystem)
+	}
+	if selector != "" {
+		parsed, err := k8slabel.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object selector %q: %v", selector, err)
+		}
+		s.selector = parsed
+	}
+	return s, nil
+}
+
+// Namespaces returns the sorted namespaces to watch, []string{""} means all namespaces
+func (s *WatchScope) Namespaces() []string {
+	if s.namespaces.Len() == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return s.namespaces.List()
+}
+
+// Selector returns the label selector of LoadBalancers, BackendGroups and Binds
+func (s *WatchScope) Selector() k8slabel.Selector {
+	return s.selector
+}
+
+// ContainsNamespace returns true if namespace is watched
+func (s *WatchScope) ContainsNamespace(namespace string) bool {
+	return s.namespaces.Len() == 0 || s.namespaces.Has(namespace)
+}
+
+// Contains returns true if obj is watched.
+// The object selector is only applied to LoadBalancers, BackendGroups and Binds,
+// for other objects use ContainsNamespace instead.
+func (s *WatchScope) Contains(obj metav1.Object) bool {
+	return s.ContainsNamespace(obj.GetNamespace()) && s.selector.Matches(k8slabel.Set(obj.GetLabels()))
+}
+
+// String implements fmt.Stringer
+func (s *WatchScope) String() string {
+	return fmt.Sprintf("namespaces: %v, selector: %q", s.Namespaces(), s.selector.String())
+}
@@ -0,0 +1,62 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatchScope(t *testing.T) {
+	all, err := NewWatchScope(nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(all.Namespaces(), []string{""}) {
+		t.Errorf("expect all namespaces, get %v", all.Namespaces())
+	}
+	if !all.Contains(&metav1.ObjectMeta{Namespace: "any"}) {
+		t.Errorf("expect all objects in scope")
+	}
+
+	scoped, err := NewWatchScope([]string{"tenant-b", "tenant-a"}, "team in (x,y)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := []string{"kube-system", "tenant-a", "tenant-b"}; !reflect.DeepEqual(scoped.Namespaces(), expect) {
+		t.Errorf("expect namespaces %v, get %v", expect, scoped.Namespaces())
+	}
+	if !scoped.Contains(&metav1.ObjectMeta{Namespace: "tenant-a", Labels: map[string]string{"team": "x"}}) {
+		t.Errorf("expect object in scope")
+	}
+	if scoped.Contains(&metav1.ObjectMeta{Namespace: "tenant-a", Labels: map[string]string{"team": "z"}}) {
+		t.Errorf("expect object not matching selector out of scope")
+	}
+	if scoped.ContainsNamespace("tenant-c") {
+		t.Errorf("expect namespace tenant-c out of scope")
+	}
+	if !scoped.ContainsNamespace("kube-system") {
+		t.Errorf("expect kube-system always in scope")
+	}
+
+	if _, err := NewWatchScope(nil, "team in (x"); err == nil {
+		t.Errorf("expect error for invalid selector")
+	}
+}
@@ -81,6 +81,11 @@ func (s *SyncResult) IsFailed() bool {
 	return s.faild != nil
 }
 
+// IsWebhookFailed indicates the operation failed because the webhook responded with status Fail, or timed out
+func (s *SyncResult) IsWebhookFailed() bool {
+	return s.faild != nil && s.faild.isWebhookFail
+}
+
 // IsRunning indicates the operation is still in progress
 func (s *SyncResult) IsRunning() bool {
 	return s.async != nil
@@ -91,6 +96,21 @@ func (s *SyncResult) IsPeriodic() bool {
 	return s.periodic != nil
 }
 
+// String returns the type of SyncResult
+func (s *SyncResult) String() string {
+	switch {
+	case s.IsWebhookFailed():
+		return "WebhookFailed"
+	case s.IsFailed():
+		return "Failed"
+	case s.IsRunning():
+		return "Running"
+	case s.IsPeriodic():
+		return "Periodic"
+	}
+	return "Finished"
+}
+
 // GetFailReason returns the error stored in SyncResult
 func (s *SyncResult) GetFailReason() string {
 	if s.faild == nil {
@@ -21,6 +21,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -112,6 +113,45 @@ func LBEnsured(lb *lbcfapi.LoadBalancer) bool {
 	return condition.Status == lbcfapi.ConditionTrue
 }
 
+// LBRetryExhausted indicates the given LoadBalancer has run out of its retry budget and should not be retried
+func LBRetryExhausted(lb *lbcfapi.LoadBalancer) bool {
+	condition := GetLBCondition(&lb.Status, lbcfapi.LBFailed)
+	if condition == nil || condition.Status != lbcfapi.ConditionTrue {
+		return false
+	}
+	return !NeedResetRetry(lb, lb.Status.RetryStatus)
+}
+
+// BackendRetryExhausted indicates the given BackendRecord has run out of its retry budget and should not be retried
+func BackendRetryExhausted(backend *lbcfapi.BackendRecord) bool {
+	condition := GetBackendRecordCondition(&backend.Status, lbcfapi.BackendFailed)
+	if condition == nil || condition.Status != lbcfapi.ConditionTrue {
+		return false
+	}
+	return !NeedResetRetry(backend, backend.Status.RetryStatus)
+}
+
+// NeedResetRetry returns true if the failed attempts recorded in retryStatus are outdated, which happens when
+// the object is modified (including being deleted) or the annotation lbcf.tkestack.io/retry-at is changed
+func NeedResetRetry(obj metav1.Object, retryStatus lbcfapi.RetryStatus) bool {
+	if obj.GetGeneration() != retryStatus.ObservedGeneration {
+		return true
+	}
+	return obj.GetAnnotations()[lbcfapi.AnnotationRetryAt] != retryStatus.LastRetryAt
+}
+
+// GetMaxAttempts returns the number of failed attempts allowed before an object is marked as Failed.
+// The retryPolicy in ensurePolicy takes precedence over the one in driver, 0 means no limit
+func GetMaxAttempts(ensurePolicy *lbcfapi.EnsurePolicyConfig, driver *lbcfapi.LoadBalancerDriver) int32 {
+	if ensurePolicy != nil && ensurePolicy.RetryPolicy != nil {
+		return ensurePolicy.RetryPolicy.MaxAttempts
+	}
+	if driver != nil && driver.Spec.RetryPolicy != nil {
+		return driver.Spec.RetryPolicy.MaxAttempts
+	}
+	return 0
+}
+
 // GetLBCondition is an helper function to get specific LoadBalancer condition
 func GetLBCondition(status *lbcfapi.LoadBalancerStatus, conditionType lbcfapi.LoadBalancerConditionType) *lbcfapi.LoadBalancerCondition {
 	for i := range status.Conditions {
@@ -274,6 +314,26 @@ func GetDuration(cfg *lbcfapi.Duration, defaultValue time.Duration) time.Duratio
 	return cfg.Duration
 }
 
+// GetMaxOperationDuration returns the longest time the webhook is allowed to stay in Running status.
+// The value configured in WebhookConfig takes precedence over the one in driver spec, 0 means no limit
+func GetMaxOperationDuration(driver *lbcfapi.LoadBalancerDriver, webhookName string) time.Duration {
+	for _, wh := range driver.Spec.Webhooks {
+		if wh.Name == webhookName && wh.MaxOperationDuration != nil {
+			return wh.MaxOperationDuration.Duration
+		}
+	}
+	return GetDuration(driver.Spec.MaxOperationDuration, 0)
+}
+
+// IsOperationTimeout returns true if an operation started at start has been running longer than maxDuration.
+// It always returns false if maxDuration is not positive
+func IsOperationTimeout(start time.Time, maxDuration time.Duration) bool {
+	if maxDuration <= 0 || start.IsZero() {
+		return false
+	}
+	return time.Since(start) > maxDuration
+}
+
 // ErrorList is an helper that collects errors
 type ErrorList []error
 
@@ -339,6 +399,11 @@ func MakeBackendLabels(driverName, lbName, groupName, svcName, podName string) m
 
 // ConstructPodBackendRecord constructs a new BackendRecord
 func ConstructPodBackendRecord(lb *lbcfapi.LoadBalancer, group *lbcfapi.BackendGroup, pod *v1.Pod) []*lbcfapi.BackendRecord {
+	return ConstructClusterPodBackendRecord(lb, group, pod, "")
+}
+
+// ConstructClusterPodBackendRecord constructs new BackendRecords of type pod for a Pod in cluster, empty cluster means the local cluster
+func ConstructClusterPodBackendRecord(lb *lbcfapi.LoadBalancer, group *lbcfapi.BackendGroup, pod *v1.Pod, cluster string) []*lbcfapi.BackendRecord {
 	valueTrue := true
 	var ret []*lbcfapi.BackendRecord
 	for _, port := range group.Spec.Pods.GetPortSelectors() {
@@ -367,17 +432,36 @@ func ConstructPodBackendRecord(lb *lbcfapi.LoadBalancer, group *lbcfapi.BackendG
 				LBInfo:       lb.Status.LBInfo,
 				LBAttributes: lb.Spec.Attributes,
 				PodBackendInfo: &lbcfapi.PodBackendRecord{
-					Name: pod.Name,
-					Port: port,
+					Name:    pod.Name,
+					Port:    port,
+					Cluster: cluster,
 				},
 				Parameters:   group.Spec.Parameters,
 				EnsurePolicy: group.Spec.EnsurePolicy,
+				DrainPolicy:  group.Spec.DrainPolicy,
+				Weight:       GetBackendWeight(pod, group.Spec.Pods.Weight),
 			},
 		})
 	}
 	return ret
 }
 
+// GetBackendWeight returns the weight of pod, which is the value of annotation lbcf.tkestack.io/backend-weight if it is valid,
+// otherwise weight is returned
+func GetBackendWeight(pod *v1.Pod, weight *int32) *int32 {
+	value, ok := pod.Annotations[lbcfapi.AnnotationBackendWeight]
+	if !ok {
+		return weight
+	}
+	w, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil || w < 0 {
+		klog.Warningf("ignore invalid annotation %s=%q of pod %s/%s", lbcfapi.AnnotationBackendWeight, value, pod.Namespace, pod.Name)
+		return weight
+	}
+	ret := int32(w)
+	return &ret
+}
+
 // ConstructServiceBackendRecord constructs a new BackendRecord of type service
 func ConstructServiceBackendRecord(lb *lbcfapi.LoadBalancer, group *lbcfapi.BackendGroup, svc *v1.Service, node *v1.Node) *lbcfapi.BackendRecord {
 	var selectedSvcPort *v1.ServicePort
@@ -425,6 +509,8 @@ func ConstructServiceBackendRecord(lb *lbcfapi.LoadBalancer, group *lbcfapi.Back
 			},
 			Parameters:   group.Spec.Parameters,
 			EnsurePolicy: group.Spec.EnsurePolicy,
+			DrainPolicy:  group.Spec.DrainPolicy,
+			Weight:       group.Spec.Service.Weight,
 		},
 	}
 }
@@ -458,6 +544,7 @@ func ConstructStaticBackend(lb *lbcfapi.LoadBalancer, group *lbcfapi.BackendGrou
 			LBAttributes: lb.Spec.Attributes,
 			Parameters:   group.Spec.Parameters,
 			EnsurePolicy: group.Spec.EnsurePolicy,
+			DrainPolicy:  group.Spec.DrainPolicy,
 			StaticAddr:   &staticAddr,
 		},
 	}
@@ -473,9 +560,31 @@ func needUpdateRecord(curObj *lbcfapi.BackendRecord, expectObj *lbcfapi.BackendR
 	if !reflect.DeepEqual(curObj.Spec.EnsurePolicy, expectObj.Spec.EnsurePolicy) {
 		return true
 	}
+	if !reflect.DeepEqual(curObj.Spec.Weight, expectObj.Spec.Weight) {
+		return true
+	}
+	if !reflect.DeepEqual(curObj.Spec.DrainPolicy, expectObj.Spec.DrainPolicy) {
+		return true
+	}
+	if retryAt, ok := expectObj.Annotations[lbcfapi.AnnotationRetryAt]; ok && curObj.Annotations[lbcfapi.AnnotationRetryAt] != retryAt {
+		return true
+	}
 	return false
 }
 
+// SetRetryAt sets annotation lbcf.tkestack.io/retry-at of record to retryAt, which is the value on its owner.
+// Changing the annotation on a BackendRecord retries it immediately and resets its failed attempts,
+// so retrying a BackendGroup or Bind retries all of its BackendRecords as well
+func SetRetryAt(record *lbcfapi.BackendRecord, retryAt string) {
+	if retryAt == "" {
+		return
+	}
+	if record.Annotations == nil {
+		record.Annotations = make(map[string]string)
+	}
+	record.Annotations[lbcfapi.AnnotationRetryAt] = retryAt
+}
+
 // IterateBackends runs handler on every BackendRecord in all and returns error if any error occurs
 func IterateBackends(all []*lbcfapi.BackendRecord, handler func(*lbcfapi.BackendRecord) error) error {
 	var errList []error
@@ -593,6 +702,7 @@ func CompareBackendRecords(
 		if needUpdateRecord(cur, v) {
 			update := cur.DeepCopy()
 			update.Spec = v.Spec
+			SetRetryAt(update, v.Annotations[lbcfapi.AnnotationRetryAt])
 			needUpdate = append(needUpdate, update)
 		}
 	}
@@ -657,6 +767,15 @@ func NeedEnqueueBackend(old *lbcfapi.BackendRecord, cur *lbcfapi.BackendRecord)
 	return false
 }
 
+// RetryTriggered returns true if annotation lbcf.tkestack.io/retry-at is changed to a value that is not processed yet
+func RetryTriggered(old metav1.Object, cur metav1.Object, lastRetryAt string) bool {
+	retryAt := cur.GetAnnotations()[lbcfapi.AnnotationRetryAt]
+	if retryAt == "" || retryAt == lastRetryAt {
+		return false
+	}
+	return retryAt != old.GetAnnotations()[lbcfapi.AnnotationRetryAt]
+}
+
 // NeedPeriodicEnsure tests if ensurePolicy is on
 func NeedPeriodicEnsure(cfg *lbcfapi.EnsurePolicyConfig, deleting bool) bool {
 	if deleting {
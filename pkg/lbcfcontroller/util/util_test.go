@@ -26,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 
 	lbcfapi "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/webhooks"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -1260,6 +1261,11 @@ func TestCompareBackendRecords(t *testing.T) {
 	expectUpdate3 := expectUpdate1.DeepCopy()
 	expectUpdate3.Name = "update3"
 
+	weight := int32(10)
+	expectUpdate4 := expectUpdate1.DeepCopy()
+	expectUpdate4.Name = "update4"
+	expectUpdate4.Spec.Weight = &weight
+
 	update1 := expectUpdate1.DeepCopy()
 	update1.Spec.LBAttributes["update-attr"] = "value"
 
@@ -1274,6 +1280,9 @@ func TestCompareBackendRecords(t *testing.T) {
 		},
 	}
 
+	update4 := expectUpdate4.DeepCopy()
+	update4.Spec.Weight = nil
+
 	expectSame := &lbcfapi.BackendRecord{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "expect-same",
@@ -1320,8 +1329,8 @@ func TestCompareBackendRecords(t *testing.T) {
 		},
 	}
 
-	expect := []*lbcfapi.BackendRecord{expectAdd, expectSame, expectUpdate1, expectUpdate2, expectUpdate3}
-	have := []*lbcfapi.BackendRecord{expectDelete, expectSame, update1, update2, update3, expectDoNotDelete}
+	expect := []*lbcfapi.BackendRecord{expectAdd, expectSame, expectUpdate1, expectUpdate2, expectUpdate3, expectUpdate4}
+	have := []*lbcfapi.BackendRecord{expectDelete, expectSame, update1, update2, update3, update4, expectDoNotDelete}
 
 	getAdd, getUpdate, getDelete := CompareBackendRecords(expect, have, []*lbcfapi.BackendRecord{expectDoNotDelete})
 	if len(getAdd) != 1 {
@@ -1330,11 +1339,11 @@ func TestCompareBackendRecords(t *testing.T) {
 		t.Fatalf("expectAdd %+v, getAdd %+v", expectAdd, getAdd)
 	}
 
-	if len(getUpdate) != 3 {
+	if len(getUpdate) != 4 {
 		for _, g := range getUpdate {
 			t.Log(g.Name)
 		}
-		t.Fatalf("expect update 3, get %d", len(getUpdate))
+		t.Fatalf("expect update 4, get %d", len(getUpdate))
 	}
 
 	if len(getDelete) != 1 {
@@ -1344,6 +1353,30 @@ func TestCompareBackendRecords(t *testing.T) {
 	}
 }
 
+func TestGetBackendWeight(t *testing.T) {
+	weight := int32(10)
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expect      int32
+	}{
+		{name: "no-annotation", expect: 10},
+		{name: "annotation", annotations: map[string]string{lbcfapi.AnnotationBackendWeight: "20"}, expect: 20},
+		{name: "annotation-zero", annotations: map[string]string{lbcfapi.AnnotationBackendWeight: "0"}, expect: 0},
+		{name: "annotation-negative", annotations: map[string]string{lbcfapi.AnnotationBackendWeight: "-1"}, expect: 10},
+		{name: "annotation-invalid", annotations: map[string]string{lbcfapi.AnnotationBackendWeight: "abc"}, expect: 10},
+	}
+	for _, c := range cases {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-0", Annotations: c.annotations}}
+		if get := GetBackendWeight(pod, &weight); get == nil || *get != c.expect {
+			t.Errorf("case %s: expect %d, get %v", c.name, c.expect, get)
+		}
+	}
+	if get := GetBackendWeight(&v1.Pod{}, nil); get != nil {
+		t.Errorf("expect nil, get %d", *get)
+	}
+}
+
 func TestSyncResult(t *testing.T) {
 	succ := FinishedResult()
 	empty := SyncResult{}
@@ -1492,3 +1525,135 @@ func TestIsLoadBalancerAllowedForBackendGroup(t *testing.T) {
 		}
 	}
 }
+
+func TestGetMaxOperationDuration(t *testing.T) {
+	driver := &lbcfapi.LoadBalancerDriver{
+		Spec: lbcfapi.LoadBalancerDriverSpec{
+			MaxOperationDuration: &lbcfapi.Duration{Duration: time.Hour},
+			Webhooks: []lbcfapi.WebhookConfig{
+				{
+					Name:                 webhooks.EnsureBackend,
+					MaxOperationDuration: &lbcfapi.Duration{Duration: time.Minute},
+				},
+				{
+					Name: webhooks.DeregBackend,
+				},
+			},
+		},
+	}
+	if get := GetMaxOperationDuration(driver, webhooks.EnsureBackend); get != time.Minute {
+		t.Errorf("expect %v, get %v", time.Minute, get)
+	}
+	if get := GetMaxOperationDuration(driver, webhooks.DeregBackend); get != time.Hour {
+		t.Errorf("expect %v, get %v", time.Hour, get)
+	}
+	if get := GetMaxOperationDuration(&lbcfapi.LoadBalancerDriver{}, webhooks.DeregBackend); get != 0 {
+		t.Errorf("expect 0, get %v", get)
+	}
+}
+
+func TestIsOperationTimeout(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	if !IsOperationTimeout(start, time.Minute) {
+		t.Errorf("expect timeout")
+	}
+	if IsOperationTimeout(start, 2*time.Hour) {
+		t.Errorf("expect not timeout")
+	}
+	if IsOperationTimeout(start, 0) {
+		t.Errorf("expect not timeout if max duration is not set")
+	}
+	if IsOperationTimeout(time.Time{}, time.Minute) {
+		t.Errorf("expect not timeout if start time is not set")
+	}
+}
+
+func TestGetMaxAttempts(t *testing.T) {
+	driver := &lbcfapi.LoadBalancerDriver{
+		Spec: lbcfapi.LoadBalancerDriverSpec{
+			RetryPolicy: &lbcfapi.RetryPolicyConfig{MaxAttempts: 10},
+		},
+	}
+	ensurePolicy := &lbcfapi.EnsurePolicyConfig{
+		Policy:      lbcfapi.PolicyIfNotSucc,
+		RetryPolicy: &lbcfapi.RetryPolicyConfig{MaxAttempts: 3},
+	}
+	if get := GetMaxAttempts(ensurePolicy, driver); get != 3 {
+		t.Errorf("expect 3, get %d", get)
+	}
+	if get := GetMaxAttempts(&lbcfapi.EnsurePolicyConfig{Policy: lbcfapi.PolicyIfNotSucc}, driver); get != 10 {
+		t.Errorf("expect 10, get %d", get)
+	}
+	if get := GetMaxAttempts(nil, nil); get != 0 {
+		t.Errorf("expect 0, get %d", get)
+	}
+}
+
+func TestNeedResetRetry(t *testing.T) {
+	lb := &lbcfapi.LoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{
+			Generation: 2,
+			Annotations: map[string]string{
+				lbcfapi.AnnotationRetryAt: "1",
+			},
+		},
+	}
+	if NeedResetRetry(lb, lbcfapi.RetryStatus{ObservedGeneration: 2, LastRetryAt: "1"}) {
+		t.Errorf("expect not reset")
+	}
+	if !NeedResetRetry(lb, lbcfapi.RetryStatus{ObservedGeneration: 1, LastRetryAt: "1"}) {
+		t.Errorf("expect reset when generation changed")
+	}
+	if !NeedResetRetry(lb, lbcfapi.RetryStatus{ObservedGeneration: 2, LastRetryAt: ""}) {
+		t.Errorf("expect reset when retry annotation changed")
+	}
+}
+
+func TestRetryTriggered(t *testing.T) {
+	old := &lbcfapi.LoadBalancer{}
+	cur := &lbcfapi.LoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				lbcfapi.AnnotationRetryAt: "2019-01-01T00:00:00Z",
+			},
+		},
+	}
+	if !RetryTriggered(old, cur, "") {
+		t.Errorf("expect triggered")
+	}
+	if RetryTriggered(old, cur, "2019-01-01T00:00:00Z") {
+		t.Errorf("expect not triggered if already processed")
+	}
+	if RetryTriggered(cur, cur, "") {
+		t.Errorf("expect not triggered if annotation not changed")
+	}
+	if RetryTriggered(cur, old, "") {
+		t.Errorf("expect not triggered if annotation removed")
+	}
+}
+
+func TestCompareBackendRecordsPropagateRetryAt(t *testing.T) {
+	have := &lbcfapi.BackendRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "record",
+		},
+	}
+	expect := have.DeepCopy()
+	SetRetryAt(expect, "")
+	_, needUpdate, _ := CompareBackendRecords([]*lbcfapi.BackendRecord{expect}, []*lbcfapi.BackendRecord{have}, nil)
+	if len(needUpdate) != 0 {
+		t.Fatalf("expect no update if owner is not retried, get %d", len(needUpdate))
+	}
+
+	SetRetryAt(expect, "2019-01-01T00:00:00Z")
+	_, needUpdate, _ = CompareBackendRecords([]*lbcfapi.BackendRecord{expect}, []*lbcfapi.BackendRecord{have}, nil)
+	if len(needUpdate) != 1 {
+		t.Fatalf("expect 1 update, get %d", len(needUpdate))
+	}
+	if get := needUpdate[0].Annotations[lbcfapi.AnnotationRetryAt]; get != "2019-01-01T00:00:00Z" {
+		t.Errorf("expect retry-at propagated, get %q", get)
+	}
+	if _, ok := have.Annotations[lbcfapi.AnnotationRetryAt]; ok {
+		t.Errorf("expect existing BackendRecord not modified")
+	}
+}
@@ -54,6 +54,8 @@ type WebhookInvoker interface {
 	CallDeregisterBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error)
 
 	CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDriver, req *webhooks.JudgePodDeregisterRequest) (*webhooks.JudgePodDeregisterResponse, error)
+
+	CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error)
 }
 
 // NewWebhookInvoker creates a new instance of WebhookInvoker
@@ -233,6 +235,24 @@ func (w *WebhookInvokerImpl) CallDeregisterBackend(driver *lbcfapi.LoadBalancerD
 	return rsp, nil
 }
 
+// CallDrainBackend calls webhook drainBackend on driver
+func (w *WebhookInvokerImpl) CallDrainBackend(driver *lbcfapi.LoadBalancerDriver, req *webhooks.BackendOperationRequest) (*webhooks.BackendOperationResponse, error) {
+	metrics.WebhookCallsInc(NamespacedNameKeyFunc(driver.Namespace, driver.Name), "drainBackend")
+	rsp := &webhooks.BackendOperationResponse{}
+	start := time.Now()
+	if err := callWebhook(driver, webhooks.DrainBackend, req, rsp); err != nil {
+		metrics.WebhookErrorsInc(NamespacedNameKeyFunc(driver.Namespace, driver.Name), "drainBackend")
+		return nil, err
+	}
+	elapsed := time.Since(start)
+	metrics.WebhookLatencyObserve(NamespacedNameKeyFunc(driver.Namespace, driver.Name), "drainBackend", elapsed)
+	if rsp.Status == webhooks.StatusFail {
+		metrics.WebhookFailsInc(NamespacedNameKeyFunc(driver.Namespace, driver.Name), "drainBackend")
+	}
+	klog.V(3).Infof("call drainBackend on driver %s, req: %v, rsp: %v, took %s", driver.Name, req, rsp, elapsed.String())
+	return rsp, nil
+}
+
 func (w *WebhookInvokerImpl) CallJudgePodDeregister(driver *lbcfapi.LoadBalancerDriver, req *webhooks.JudgePodDeregisterRequest) (*webhooks.JudgePodDeregisterResponse, error) {
 	metrics.WebhookCallsInc(NamespacedNameKeyFunc(driver.Namespace, driver.Name), "judgePodDeregister")
 	rsp := &webhooks.JudgePodDeregisterResponse{}
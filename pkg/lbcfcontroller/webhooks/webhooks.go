@@ -54,9 +54,12 @@ const (
 
 	// JudgePodDeregister is the name and URL path of webhook judgePodDeregister
 	JudgePodDeregister = "judgePodDeregister"
+
+	// DrainBackend is the name and URL path of webhook drainBackend, it is only called for drainPolicy with method Webhook
+	DrainBackend = "drainBackend"
 )
 
-// KnownWebhooks is a set contains all supported webhooks
+// KnownWebhooks is a set contains all webhooks that must be implemented by drivers
 var KnownWebhooks = sets.NewString(
 	Healthz,
 	ValidateLoadBalancer,
@@ -69,6 +72,12 @@ var KnownWebhooks = sets.NewString(
 	DeregBackend,
 )
 
+// OptionalWebhooks is a set contains supported webhooks that drivers may not implement,
+// they are only called if required by objects, e.g. drainBackend is called for drainPolicy with method Webhook
+var OptionalWebhooks = sets.NewString(
+	DrainBackend,
+)
+
 // HealthzRequest is the request for webhook healthz
 type HealthzRequest struct {
 }
@@ -209,6 +218,8 @@ type GenerateBackendAddrRequest struct {
 type PodBackendInGenerateAddrRequest struct {
 	Pod  v1.Pod               `json:"pod"`
 	Port v1beta1.PortSelector `json:"port"`
+	// Cluster is the member cluster where the Pod runs, empty means the cluster where lbcf-controller runs
+	Cluster string `json:"cluster,omitempty"`
 }
 
 // ServiceBackendInGenerateAddrRequest is part of GenerateBackendAddrRequest
@@ -225,7 +236,7 @@ type GenerateBackendAddrResponse struct {
 	BackendAddr string `json:"backendAddr"`
 }
 
-// BackendOperationRequest is the request for webhook ensureBackend and deregisterBackend
+// BackendOperationRequest is the request for webhook ensureBackend, deregisterBackend and drainBackend
 type BackendOperationRequest struct {
 	RequestForRetryHooks
 	DryRun       bool              `json:"dryRun"`
@@ -233,9 +244,11 @@ type BackendOperationRequest struct {
 	BackendAddr  string            `json:"backendAddr"`
 	Parameters   map[string]string `json:"parameters"`
 	InjectedInfo map[string]string `json:"injectedInfo"`
+	// Weight is only set for ensureBackend, the driver decides the weight if it is not set
+	Weight *int32 `json:"weight,omitempty"`
 }
 
-// BackendOperationResponse is the response for webhook ensureBackend and deregisterBackend
+// BackendOperationResponse is the response for webhook ensureBackend, deregisterBackend and drainBackend
 type BackendOperationResponse struct {
 	ResponseForFailRetryHooks
 	InjectedInfo map[string]string `json:"injectedInfo"`
@@ -252,3 +265,37 @@ type JudgePodDeregisterResponse struct {
 	ResponseForNoRetryHooks
 	DoNotDeregister []*v1.Pod `json:"doNotDeregister"`
 }
+
+const (
+	// NotificationLoadBalancerDeleted indicates the load balancer is deleted outside of lbcf
+	NotificationLoadBalancerDeleted = "LoadBalancerDeleted"
+
+	// NotificationBackendUnhealthy indicates the backend becomes unhealthy
+	NotificationBackendUnhealthy = "BackendUnhealthy"
+
+	// NotificationOperationCompleted indicates an operation that responded with status Running is completed
+	NotificationOperationCompleted = "OperationCompleted"
+)
+
+// KnownNotifications is a set contains all supported notification types
+var KnownNotifications = sets.NewString(
+	NotificationLoadBalancerDeleted,
+	NotificationBackendUnhealthy,
+	NotificationOperationCompleted,
+)
+
+// DriverNotification is pushed by drivers to lbcf-controller, objects related to LBInfo or BackendAddr are synchronized immediately
+type DriverNotification struct {
+	DriverName      string            `json:"driverName"`
+	DriverNamespace string            `json:"driverNamespace"`
+	Type            string            `json:"type"`
+	LBInfo          map[string]string `json:"lbInfo,omitempty"`
+	BackendAddr     string            `json:"backendAddr,omitempty"`
+	Msg             string            `json:"msg,omitempty"`
+}
+
+// DriverNotificationResponse is the response for DriverNotification
+type DriverNotificationResponse struct {
+	Enqueued int    `json:"enqueued"`
+	Msg      string `json:"msg,omitempty"`
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -11,25 +12,45 @@ var (
 	webhookCalls      *prometheus.CounterVec
 	webhookErrors     *prometheus.CounterVec
 	webhookFails      *prometheus.CounterVec
+	operationTimeouts *prometheus.CounterVec
+	notifications     *prometheus.CounterVec
 	webhookLatency    *prometheus.HistogramVec
 	k8sOpLatency      *prometheus.HistogramVec
+	k8sOpConflicts    *prometheus.CounterVec
 	keyProcessLatency *prometheus.HistogramVec
 	pendingKeys       *prometheus.GaugeVec
+	pendingByPriority *prometheus.GaugeVec
+	pendingByTenant   *prometheus.GaugeVec
 	workingKeys       *prometheus.GaugeVec
+	featureEnabled    *prometheus.GaugeVec
+)
+
+var (
+	// tenantsMu protects lastTenants, the tenants set for each key kind
+	tenantsMu   sync.Mutex
+	lastTenants = make(map[string]map[string]bool)
 )
 
 const (
 	labelKeyKind     = "key_kind"
 	labelDriverName  = "driver_name"
 	labelWebhookName = "webhook_name"
+	labelNotifyType  = "notification_type"
 	labelK8sOpObj    = "k8s_op_obj"
 	labelK8sOpType   = "k8s_op_type"
 	labelCRD         = "crd"
+	labelPriority    = "priority"
+	labelTenant      = "tenant"
+	labelFeatureName = "name"
+	labelStage       = "stage"
 
 	OpCreate       = "Create"
 	OpUpdate       = "Update"
 	OpUpdateStatus = "UpdateStatus"
 	OpDelete       = "Delete"
+	OpGet          = "Get"
+	OpPatch        = "Patch"
+	OpPatchStatus  = "PatchStatus"
 )
 
 func init() {
@@ -54,6 +75,20 @@ func init() {
 		},
 		[]string{labelDriverName, labelWebhookName})
 
+	operationTimeouts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "operation_timeouts",
+			Help: "The total number of operations that stayed in Running status longer than maxOperationDuration",
+		},
+		[]string{labelDriverName, labelWebhookName})
+
+	notifications = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "driver_notifications",
+			Help: "The total number of notifications pushed by drivers",
+		},
+		[]string{labelDriverName, labelNotifyType})
+
 	webhookLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "webhook_latency",
@@ -72,6 +107,13 @@ func init() {
 		},
 		[]string{labelK8sOpObj, labelK8sOpType})
 
+	k8sOpConflicts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_operation_conflicts",
+			Help: "The total number of k8s write operations rejected because the object was modified concurrently",
+		},
+		[]string{labelK8sOpObj, labelK8sOpType})
+
 	keyProcessLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "key_process_latency",
@@ -88,12 +130,33 @@ func init() {
 		},
 		[]string{labelKeyKind})
 
+	pendingByPriority = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pending_key_by_priority",
+			Help: "The number of keys waiting to be processed in each priority",
+		},
+		[]string{labelKeyKind, labelPriority})
+
+	pendingByTenant = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pending_key_by_tenant",
+			Help: "The number of keys waiting to be processed of each tenant",
+		},
+		[]string{labelKeyKind, labelTenant})
+
 	workingKeys = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "working_key",
 			Help: "The number of keys being processed",
 		},
 		[]string{labelKeyKind})
+
+	featureEnabled = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "feature_enabled",
+			Help: "whether a feature gate is enabled, 1 means enabled and 0 means disabled",
+		},
+		[]string{labelFeatureName, labelStage})
 }
 
 func WebhookCallsInc(driverName, webhookName string) {
@@ -120,6 +183,22 @@ func WebhookFailsInc(driverName, webhookName string) {
 	webhookFails.With(l).Inc()
 }
 
+func OperationTimeoutsInc(driverName, webhookName string) {
+	l := prometheus.Labels{
+		labelDriverName:  driverName,
+		labelWebhookName: webhookName,
+	}
+	operationTimeouts.With(l).Inc()
+}
+
+func DriverNotificationsInc(driverName, notificationType string) {
+	l := prometheus.Labels{
+		labelDriverName: driverName,
+		labelNotifyType: notificationType,
+	}
+	notifications.With(l).Inc()
+}
+
 func WebhookLatencyObserve(driverName, webhookName string, elapsed time.Duration) {
 	l := prometheus.Labels{
 		labelDriverName:  driverName,
@@ -137,6 +216,14 @@ func K8SOpLatencyObserve(obj, op string, elapsed time.Duration) {
 	k8sOpLatency.With(l).Observe(elapsed.Seconds())
 }
 
+func K8SOpConflictsInc(obj, op string) {
+	l := prometheus.Labels{
+		labelK8sOpObj:  obj,
+		labelK8sOpType: op,
+	}
+	k8sOpConflicts.With(l).Inc()
+}
+
 func KeyProcessLatencyObserve(crd string, elapsed time.Duration) {
 	l := prometheus.Labels{
 		labelCRD: crd,
@@ -151,6 +238,31 @@ func PendingKeysSet(kind string, value float64) {
 	pendingKeys.With(l).Set(value)
 }
 
+func PendingKeysByPrioritySet(kind string, priority string, value float64) {
+	l := prometheus.Labels{
+		labelKeyKind:  kind,
+		labelPriority: priority,
+	}
+	pendingByPriority.With(l).Set(value)
+}
+
+// PendingKeysByTenantSet sets the pending keys of each tenant, tenants not in pending are removed
+func PendingKeysByTenantSet(kind string, pending map[string]int) {
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+	for tenant := range lastTenants[kind] {
+		if _, ok := pending[tenant]; !ok {
+			pendingByTenant.Delete(prometheus.Labels{labelKeyKind: kind, labelTenant: tenant})
+		}
+	}
+	cur := make(map[string]bool)
+	for tenant, n := range pending {
+		pendingByTenant.With(prometheus.Labels{labelKeyKind: kind, labelTenant: tenant}).Set(float64(n))
+		cur[tenant] = true
+	}
+	lastTenants[kind] = cur
+}
+
 func WorkingKeysInc(kind string) {
 	l := prometheus.Labels{
 		labelKeyKind: kind,
@@ -164,3 +276,15 @@ func WorkingKeysDec(kind string) {
 	}
 	workingKeys.With(l).Dec()
 }
+
+func FeatureEnabledSet(name, stage string, enabled bool) {
+	l := prometheus.Labels{
+		labelFeatureName: name,
+		labelStage:       stage,
+	}
+	var value float64
+	if enabled {
+		value = 1
+	}
+	featureEnabled.With(l).Set(value)
+}
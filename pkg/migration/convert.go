@@ -0,0 +1,214 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package migration
+
+import (
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	"tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConvertToBind converts group and its LoadBalancers to a Bind of the same name.
+// The returned Bind is annotated with lbcf.tkestack.io/migrating-from, so that it is not synced until the migration is finished,
+// and its status records the LoadBalancers as created, so that createLoadBalancer is not called again.
+func ConvertToBind(group *v1beta1.BackendGroup, lbs []*v1beta1.LoadBalancer) *lbcfv1.Bind {
+	bind := &lbcfv1.Bind{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: lbcfv1.APIVersion,
+			Kind:       "Bind",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      group.Name,
+			Namespace: group.Namespace,
+			Labels:    group.Labels,
+			Annotations: map[string]string{
+				lbcfv1.AnnotationMigratingFrom: group.Name,
+			},
+			Finalizers: []string{
+				lbcfv1.FinalizerDeleteLB,
+			},
+		},
+		Spec: lbcfv1.BindSpec{
+			Parameters:        group.Spec.Parameters,
+			DeregisterWebhook: convertDeregisterWebhook(group.Spec.DeregisterWebhook),
+			EnsurePolicy:      convertEnsurePolicy(group.Spec.EnsurePolicy),
+			DrainPolicy:       convertDrainPolicy(group.Spec.DrainPolicy),
+		},
+	}
+	if group.Spec.DeregisterPolicy != nil {
+		policy := lbcfv1.DeregPolicy(*group.Spec.DeregisterPolicy)
+		bind.Spec.DeregisterPolicy = &policy
+	}
+	if group.Spec.Pods != nil {
+		bind.Spec.Pods = convertPodBackend(group.Spec.Pods)
+	} else if group.Spec.Service != nil {
+		bind.Spec.Service = &lbcfv1.ServiceBackend{
+			Name:         group.Spec.Service.Name,
+			Port:         convertPortSelector(group.Spec.Service.Port),
+			NodeSelector: group.Spec.Service.NodeSelector,
+			Weight:       group.Spec.Service.Weight,
+		}
+	} else {
+		bind.Spec.Static = group.Spec.Static
+	}
+
+	for _, lb := range lbs {
+		bind.Spec.LoadBalancers = append(bind.Spec.LoadBalancers, lbcfv1.TargetLoadBalancer{
+			Name:       lb.Name,
+			Driver:     lb.Spec.LBDriver,
+			Spec:       lb.Spec.LBSpec,
+			Attributes: lb.Spec.Attributes,
+		})
+		bind.Status.LoadBalancerStatuses = append(bind.Status.LoadBalancerStatuses, convertLoadBalancerStatus(lb))
+	}
+	return bind
+}
+
+// convertLoadBalancerStatus returns the status of a created LoadBalancer in Bind.
+// Attributes are regarded as synced unless the AttributesSynced condition is not True, in which case they are ensured by the Bind.
+func convertLoadBalancerStatus(lb *v1beta1.LoadBalancer) lbcfv1.TargetLoadBalancerStatus {
+	now := metav1.Now()
+	status := lbcfv1.TargetLoadBalancerStatus{
+		Name:   lb.Name,
+		Driver: lb.Spec.LBDriver,
+		LBInfo: lb.Status.LBInfo,
+		Conditions: []lbcfv1.TargetLoadBalancerCondition{
+			{
+				Type:               lbcfv1.LBCreated,
+				Status:             lbcfv1.ConditionTrue,
+				LastTransitionTime: now,
+			},
+		},
+	}
+	if len(lb.Spec.Attributes) == 0 || attributesSynced(lb) {
+		status.LastSyncedAttributes = lb.Spec.Attributes
+		status.Conditions = append(status.Conditions, lbcfv1.TargetLoadBalancerCondition{
+			Type:               lbcfv1.LBReady,
+			Status:             lbcfv1.ConditionTrue,
+			LastTransitionTime: now,
+		})
+	}
+	return status
+}
+
+func attributesSynced(lb *v1beta1.LoadBalancer) bool {
+	for _, cond := range lb.Status.Conditions {
+		if cond.Type == v1beta1.LBAttributesSynced {
+			return cond.Status == v1beta1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func convertPodBackend(pods *v1beta1.PodBackend) *lbcfv1.PodBackend {
+	ret := &lbcfv1.PodBackend{
+		ByName:   pods.ByName,
+		Clusters: pods.Clusters,
+		Weight:   pods.Weight,
+	}
+	for _, port := range pods.GetPortSelectors() {
+		ret.Ports = append(ret.Ports, convertPortSelector(port))
+	}
+	if pods.ByLabel != nil {
+		ret.ByLabel = &lbcfv1.SelectPodByLabel{
+			Selector: pods.ByLabel.Selector,
+			Except:   pods.ByLabel.Except,
+		}
+	}
+	return ret
+}
+
+func convertPortSelector(port v1beta1.PortSelector) lbcfv1.PortSelector {
+	return lbcfv1.PortSelector{
+		Port:     port.GetPort(),
+		Protocol: port.Protocol,
+	}
+}
+
+func convertDeregisterWebhook(webhook *v1beta1.DeregisterWebhookSpec) *lbcfv1.DeregisterWebhookSpec {
+	if webhook == nil {
+		return nil
+	}
+	ret := &lbcfv1.DeregisterWebhookSpec{
+		DriverName: webhook.DriverName,
+	}
+	if webhook.FailurePolicy != nil {
+		policy := lbcfv1.FailurePolicyDoNothing
+		switch *webhook.FailurePolicy {
+		case v1beta1.FailurePolicyIfNotReady:
+			policy = lbcfv1.FailurePolicyIfNotReady
+		case v1beta1.FailurePolicyIfNotRunning:
+			policy = lbcfv1.FailurePolicyIfNotRunning
+		}
+		ret.FailurePolicy = &policy
+	}
+	return ret
+}
+
+// convertEnsurePolicy is the reverse of bind.ConvertEnsurePolicy
+func convertEnsurePolicy(policy *v1beta1.EnsurePolicyConfig) *lbcfv1.EnsurePolicyConfig {
+	if policy == nil {
+		return nil
+	}
+	ret := &lbcfv1.EnsurePolicyConfig{
+		Policy: lbcfv1.PolicyIfNotSucc,
+	}
+	if policy.Policy == v1beta1.PolicyAlways {
+		ret.Policy = lbcfv1.PolicyAlways
+	}
+	if policy.MinPeriod != nil {
+		seconds := int32(policy.MinPeriod.Seconds())
+		ret.ResyncPeriodInSeconds = &seconds
+	}
+	if policy.RetryPolicy != nil {
+		ret.RetryPolicy = &lbcfv1.RetryPolicyConfig{
+			MaxAttempts: policy.RetryPolicy.MaxAttempts,
+		}
+	}
+	return ret
+}
+
+// convertDrainPolicy is the reverse of bind.ConvertDrainPolicy
+func convertDrainPolicy(policy *v1beta1.DrainPolicyConfig) *lbcfv1.DrainPolicyConfig {
+	if policy == nil {
+		return nil
+	}
+	method := lbcfv1.DrainByWeight
+	if policy.Method == v1beta1.DrainByWebhook {
+		method = lbcfv1.DrainByWebhook
+	}
+	return &lbcfv1.DrainPolicyConfig{
+		Method:          method,
+		PeriodInSeconds: int32(policy.Period.Seconds()),
+	}
+}
+
+// hasMemberClusters returns true if group selects Pods in member clusters
+func hasMemberClusters(group *v1beta1.BackendGroup) bool {
+	if group.Spec.Pods == nil {
+		return false
+	}
+	for _, cluster := range util.PodClusters(group.Spec.Pods.Clusters) {
+		if cluster != "" {
+			return true
+		}
+	}
+	return false
+}
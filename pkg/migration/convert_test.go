@@ -0,0 +1,89 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package migration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	"tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertToBindService(t *testing.T) {
+	failurePolicy := v1beta1.FailurePolicyIfNotReady
+	// deprecated portNumber is converted to port
+	var portNumber int32 = 80
+	group := &v1beta1.BackendGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "group", Namespace: "default"},
+		Spec: v1beta1.BackendGroupSpec{
+			LoadBalancers: []string{"lb"},
+			Service: &v1beta1.ServiceBackend{
+				Name:         "svc",
+				Port:         v1beta1.PortSelector{PortNumber: &portNumber, Protocol: "TCP"},
+				NodeSelector: map[string]string{"role": "lb"},
+			},
+			DeregisterWebhook: &v1beta1.DeregisterWebhookSpec{
+				DriverName:    "dereg-driver",
+				FailurePolicy: &failurePolicy,
+			},
+			EnsurePolicy: &v1beta1.EnsurePolicyConfig{
+				Policy:      v1beta1.PolicyAlways,
+				MinPeriod:   &v1beta1.Duration{Duration: time.Minute},
+				RetryPolicy: &v1beta1.RetryPolicyConfig{MaxAttempts: 3},
+			},
+		},
+	}
+	lb := &v1beta1.LoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+		Spec: v1beta1.LoadBalancerSpec{
+			LBDriver:   "driver",
+			Attributes: map[string]string{"a1": "v1"},
+		},
+		Status: v1beta1.LoadBalancerStatus{
+			Conditions: []v1beta1.LoadBalancerCondition{
+				{Type: v1beta1.LBCreated, Status: v1beta1.ConditionTrue},
+				{Type: v1beta1.LBAttributesSynced, Status: v1beta1.ConditionFalse},
+			},
+		},
+	}
+	bind := ConvertToBind(group, []*v1beta1.LoadBalancer{lb})
+
+	expectService := &lbcfv1.ServiceBackend{
+		Name:         "svc",
+		Port:         lbcfv1.PortSelector{Port: 80, Protocol: "TCP"},
+		NodeSelector: map[string]string{"role": "lb"},
+	}
+	if !reflect.DeepEqual(bind.Spec.Service, expectService) || bind.Spec.Pods != nil {
+		t.Errorf("expect service %+v, get %+v", expectService, bind.Spec.Service)
+	}
+	if *bind.Spec.DeregisterWebhook.FailurePolicy != lbcfv1.FailurePolicyIfNotReady {
+		t.Errorf("unexpected failurePolicy %v", *bind.Spec.DeregisterWebhook.FailurePolicy)
+	}
+	policy := bind.Spec.EnsurePolicy
+	if policy.Policy != lbcfv1.PolicyAlways || *policy.ResyncPeriodInSeconds != 60 || policy.RetryPolicy.MaxAttempts != 3 {
+		t.Errorf("unexpected ensurePolicy %+v", policy)
+	}
+	status := bind.Status.LoadBalancerStatuses[0]
+	if status.LastSyncedAttributes != nil || len(status.Conditions) != 1 {
+		t.Errorf("expect attributes not synced, get %+v", status)
+	}
+}
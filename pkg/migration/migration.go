@@ -0,0 +1,450 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package migration
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	"tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	lbcfclient "tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/bindcontroller"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+// Result is the result of migrating a BackendGroup
+type Result struct {
+	Namespace    string
+	BackendGroup string
+	// Skipped is the reason why the BackendGroup is not migrated
+	Skipped string
+	Err     error
+}
+
+// Migrator migrates v1beta1 BackendGroups and LoadBalancers to v1 Binds.
+//
+// For each BackendGroup, a Bind of the same name is created with the LoadBalancers it uses and their LBInfo,
+// the BackendGroup is deleted without deleting its BackendRecords, which are then adopted by the Bind,
+// and at last the LoadBalancers are deleted without calling deleteLoadBalancer.
+// The Bind is not synced by lbcf-controller until all steps are finished,
+// and the migration can be resumed by running again if it is interrupted.
+type Migrator struct {
+	k8sClient  kubernetes.Interface
+	lbcfClient lbcfclient.Interface
+	dryRun     bool
+	out        io.Writer
+
+	// deleteTimeout is how long to wait for BackendGroups to be deleted
+	deleteTimeout time.Duration
+}
+
+// NewMigrator returns a Migrator, plans are written to out instead of being executed if dryRun is true
+func NewMigrator(k8sClient kubernetes.Interface, lbcfClient lbcfclient.Interface, dryRun bool, out io.Writer) *Migrator {
+	return &Migrator{
+		k8sClient:     k8sClient,
+		lbcfClient:    lbcfClient,
+		dryRun:        dryRun,
+		out:           out,
+		deleteTimeout: 2 * time.Minute,
+	}
+}
+
+// Run migrates BackendGroups in namespace, all namespaces are migrated if namespace is empty.
+// Only the BackendGroup with the given name is migrated if name is not empty.
+func (m *Migrator) Run(namespace, name string) ([]Result, error) {
+	// LoadBalancers in kube-system can be used by BackendGroups in all namespaces,
+	// so all of them are listed to find out which LoadBalancers are shared
+	allLBs, err := m.lbcfClient.LbcfV1beta1().LoadBalancers(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list LoadBalancers failed: %v", err)
+	}
+	allGroups, err := m.lbcfClient.LbcfV1beta1().BackendGroups(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list BackendGroups failed: %v", err)
+	}
+	binds, err := m.lbcfClient.LbcfV1().Binds(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list Binds failed: %v", err)
+	}
+
+	lbs := make(map[string]*v1beta1.LoadBalancer)
+	for i := range allLBs.Items {
+		lb := &allLBs.Items[i]
+		lbs[util.NamespacedNameKeyFunc(lb.Namespace, lb.Name)] = lb
+	}
+	// users is the BackendGroups that use each LoadBalancer
+	users := make(map[string][]string)
+	for _, group := range allGroups.Items {
+		for _, lbName := range group.Spec.GetLoadBalancers() {
+			if lb := findLoadBalancer(lbs, group.Namespace, lbName); lb != nil {
+				key := util.NamespacedNameKeyFunc(lb.Namespace, lb.Name)
+				users[key] = append(users[key], util.NamespacedNameKeyFunc(group.Namespace, group.Name))
+			}
+		}
+	}
+	existingBinds := make(map[string]*lbcfv1.Bind)
+	for i := range binds.Items {
+		bind := &binds.Items[i]
+		existingBinds[util.NamespacedNameKeyFunc(bind.Namespace, bind.Name)] = bind
+	}
+
+	var results []Result
+	migrating := make(map[string]bool)
+	for i := range allGroups.Items {
+		group := &allGroups.Items[i]
+		if (namespace != "" && group.Namespace != namespace) || (name != "" && group.Name != name) {
+			continue
+		}
+		key := util.NamespacedNameKeyFunc(group.Namespace, group.Name)
+		migrating[key] = true
+		result := Result{Namespace: group.Namespace, BackendGroup: group.Name}
+		groupLBs, reason := m.check(group, lbs, users, existingBinds[key])
+		if reason != "" {
+			result.Skipped = reason
+		} else {
+			result.Err = m.migrate(group, groupLBs, existingBinds[key])
+		}
+		results = append(results, result)
+	}
+
+	// Binds whose BackendGroup is already deleted are left by interrupted migrations
+	for _, bind := range binds.Items {
+		from, ok := bind.Annotations[lbcfv1.AnnotationMigratingFrom]
+		key := util.NamespacedNameKeyFunc(bind.Namespace, from)
+		if !ok || migrating[key] || (name != "" && from != name) {
+			continue
+		}
+		var bindLBs []*v1beta1.LoadBalancer
+		for _, lb := range bind.Spec.LoadBalancers {
+			if found := findLoadBalancer(lbs, bind.Namespace, lb.Name); found != nil {
+				bindLBs = append(bindLBs, found)
+			}
+		}
+		b := bind
+		results = append(results, Result{
+			Namespace:    bind.Namespace,
+			BackendGroup: from,
+			Err:          m.finish(&b, nil, bindLBs),
+		})
+	}
+	return results, nil
+}
+
+// check returns the LoadBalancers used by group, or the reason why group can not be migrated
+func (m *Migrator) check(
+	group *v1beta1.BackendGroup,
+	lbs map[string]*v1beta1.LoadBalancer,
+	users map[string][]string,
+	existingBind *lbcfv1.Bind) ([]*v1beta1.LoadBalancer, string) {
+	if group.DeletionTimestamp != nil {
+		return nil, "BackendGroup is deleting"
+	}
+	if _, ok := group.Labels[v1beta1.LabelDoNotDelete]; ok {
+		return nil, fmt.Sprintf("BackendGroup has label %s", v1beta1.LabelDoNotDelete)
+	}
+	if hasMemberClusters(group) {
+		return nil, "BackendGroup selects Pods in member clusters"
+	}
+	if existingBind != nil && existingBind.Annotations[lbcfv1.AnnotationMigratingFrom] != group.Name {
+		return nil, "Bind with the same name already exists"
+	}
+	groupLBs := make([]*v1beta1.LoadBalancer, 0)
+	for _, lbName := range group.Spec.GetLoadBalancers() {
+		lb := findLoadBalancer(lbs, group.Namespace, lbName)
+		if lb == nil {
+			return nil, fmt.Sprintf("LoadBalancer %s not found", lbName)
+		}
+		if lb.DeletionTimestamp != nil {
+			return nil, fmt.Sprintf("LoadBalancer %s/%s is deleting", lb.Namespace, lb.Name)
+		}
+		if !util.LBCreated(lb) {
+			return nil, fmt.Sprintf("LoadBalancer %s/%s is not created", lb.Namespace, lb.Name)
+		}
+		if _, ok := lb.Labels[v1beta1.LabelDoNotDelete]; ok {
+			return nil, fmt.Sprintf("LoadBalancer %s/%s has label %s", lb.Namespace, lb.Name, v1beta1.LabelDoNotDelete)
+		}
+		// drivers used by Binds are searched in the namespace of Bind and kube-system
+		if util.NamespaceOfSharedObj(lb.Spec.LBDriver, group.Namespace) != util.NamespaceOfSharedObj(lb.Spec.LBDriver, lb.Namespace) {
+			return nil, fmt.Sprintf("LBDriver %s of LoadBalancer %s/%s is not accessible in namespace %s",
+				lb.Spec.LBDriver, lb.Namespace, lb.Name, group.Namespace)
+		}
+		if others := users[util.NamespacedNameKeyFunc(lb.Namespace, lb.Name)]; len(others) > 1 {
+			return nil, fmt.Sprintf("LoadBalancer %s/%s is shared by BackendGroups %s",
+				lb.Namespace, lb.Name, strings.Join(others, ","))
+		}
+		groupLBs = append(groupLBs, lb)
+	}
+	if len(groupLBs) == 0 {
+		return nil, "BackendGroup has no LoadBalancer"
+	}
+	return groupLBs, ""
+}
+
+// migrate creates the Bind converted from group, or reuses the one created by an interrupted migration
+func (m *Migrator) migrate(group *v1beta1.BackendGroup, lbs []*v1beta1.LoadBalancer, existingBind *lbcfv1.Bind) error {
+	expect := ConvertToBind(group, lbs)
+	if m.dryRun {
+		if existingBind != nil {
+			return m.finish(existingBind, group, lbs)
+		}
+		data, err := yaml.Marshal(expect)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(m.out, "# BackendGroup %s/%s is converted to:\n%s", group.Namespace, group.Name, data)
+		return m.finish(expect, group, lbs)
+	}
+
+	bind := existingBind
+	if bind == nil {
+		created, err := m.lbcfClient.LbcfV1().Binds(expect.Namespace).Create(expect)
+		if err != nil {
+			return fmt.Errorf("create Bind failed: %v", err)
+		}
+		m.printf("Bind %s/%s created\n", created.Namespace, created.Name)
+		bind = created
+	}
+	// the status is empty if the migration is interrupted right after the Bind is created
+	if len(bind.Status.LoadBalancerStatuses) == 0 {
+		cpy := bind.DeepCopy()
+		cpy.Status = expect.Status
+		updated, err := m.lbcfClient.LbcfV1().Binds(cpy.Namespace).UpdateStatus(cpy)
+		if err != nil {
+			return fmt.Errorf("update status of Bind failed: %v", err)
+		}
+		bind = updated
+	}
+	return m.finish(bind, group, lbs)
+}
+
+// finish deletes group without deleting its BackendRecords, adopts the BackendRecords,
+// deletes LoadBalancers without calling deleteLoadBalancer, and then enables the Bind
+func (m *Migrator) finish(bind *lbcfv1.Bind, group *v1beta1.BackendGroup, lbs []*v1beta1.LoadBalancer) error {
+	groupName := bind.Annotations[lbcfv1.AnnotationMigratingFrom]
+	if group != nil {
+		if err := m.deleteBackendGroup(group); err != nil {
+			return err
+		}
+	}
+	if err := m.adoptBackendRecords(bind, groupName, lbs); err != nil {
+		return err
+	}
+	for _, lb := range lbs {
+		if err := m.deleteLoadBalancer(lb); err != nil {
+			return err
+		}
+	}
+	if m.dryRun {
+		m.printf("Bind %s/%s will be synced by lbcf-controller\n", bind.Namespace, bind.Name)
+		return nil
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, lbcfv1.AnnotationMigratingFrom)
+	if _, err := m.lbcfClient.LbcfV1().Binds(bind.Namespace).Patch(bind.Name, types.MergePatchType, []byte(patch)); err != nil {
+		return fmt.Errorf("remove annotation %s from Bind failed: %v", lbcfv1.AnnotationMigratingFrom, err)
+	}
+	m.printf("Bind %s/%s migrated from BackendGroup %s\n", bind.Namespace, bind.Name, groupName)
+	return nil
+}
+
+// deleteBackendGroup deletes group with the BackendRecords orphaned, and waits until it is deleted.
+// BackendRecords can only be adopted after that, otherwise they are recreated by the BackendGroup.
+func (m *Migrator) deleteBackendGroup(group *v1beta1.BackendGroup) error {
+	if m.dryRun {
+		m.printf("BackendGroup %s/%s will be deleted, its BackendRecords are orphaned\n", group.Namespace, group.Name)
+		return nil
+	}
+	orphan := metav1.DeletePropagationOrphan
+	err := m.lbcfClient.LbcfV1beta1().BackendGroups(group.Namespace).Delete(group.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &orphan,
+		Preconditions:     &metav1.Preconditions{UID: &group.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete BackendGroup failed: %v", err)
+	}
+	err = wait.PollImmediate(time.Second, m.deleteTimeout, func() (bool, error) {
+		_, err := m.lbcfClient.LbcfV1beta1().BackendGroups(group.Namespace).Get(group.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("wait for BackendGroup to be deleted failed: %v", err)
+	}
+	m.printf("BackendGroup %s/%s deleted\n", group.Namespace, group.Name)
+	return nil
+}
+
+// adoptBackendRecords relabels the BackendRecords of BackendGroup groupName and makes bind their controller.
+// The records keep their names, the names generated by bind are saved in annotation lbcf.tkestack.io/bind-record-name,
+// so that they are not deregistered and registered again by bind.
+func (m *Migrator) adoptBackendRecords(bind *lbcfv1.Bind, groupName string, lbs []*v1beta1.LoadBalancer) error {
+	records, err := m.lbcfClient.LbcfV1beta1().BackendRecords(bind.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{v1beta1.LabelGroupName: groupName}).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("list BackendRecords failed: %v", err)
+	}
+	adopted := 0
+	for i := range records.Items {
+		record := &records.Items[i]
+		if record.DeletionTimestamp != nil {
+			continue
+		}
+		if owner := metav1.GetControllerOf(record); owner != nil && owner.UID == bind.UID && owner.Kind == "Bind" {
+			continue
+		}
+		if m.dryRun {
+			adopted++
+			continue
+		}
+		recordName := m.bindRecordName(bind, groupName, record)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest, err := m.lbcfClient.LbcfV1beta1().BackendRecords(record.Namespace).Get(record.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			_, err = m.lbcfClient.LbcfV1beta1().BackendRecords(latest.Namespace).Update(adoptBackendRecord(latest, bind, recordName))
+			return err
+		})
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("adopt BackendRecord %s/%s failed: %v", record.Namespace, record.Name, err)
+		}
+		adopted++
+	}
+	if m.dryRun {
+		m.printf("%d BackendRecords will be adopted by Bind %s/%s\n", adopted, bind.Namespace, bind.Name)
+	} else {
+		m.printf("%d BackendRecords adopted by Bind %s/%s\n", adopted, bind.Namespace, bind.Name)
+	}
+	return nil
+}
+
+// bindRecordName returns the name generated by bind for the same backend as record.
+// An empty string is returned if the backend no longer exists, in which case record is deleted by bind.
+func (m *Migrator) bindRecordName(bind *lbcfv1.Bind, groupName string, record *v1beta1.BackendRecord) string {
+	lbName := record.Spec.LBName
+	if info := record.Spec.PodBackendInfo; info != nil {
+		if info.Cluster != "" {
+			return ""
+		}
+		pod, err := m.k8sClient.CoreV1().Pods(record.Namespace).Get(info.Name, metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		// the Pod may be recreated with the same name
+		if util.MakePodBackendName(lbName, groupName, pod.UID, info.Port) != record.Name {
+			return ""
+		}
+		return bindcontroller.PodBackendRecordName(bind.Name, lbName, pod.Name, pod.Status.PodIP, convertPortSelector(info.Port), "")
+	} else if info := record.Spec.ServiceBackendInfo; info != nil {
+		return bindcontroller.ServiceBackendRecordName(bind.Name, lbName, info.Name, convertPortSelector(info.Port), info.NodePort, info.NodeName)
+	} else if record.Spec.StaticAddr != nil {
+		return bindcontroller.StaticBackendRecordName(bind.Name, lbName, *record.Spec.StaticAddr)
+	}
+	return ""
+}
+
+// adoptBackendRecord returns a copy of record that is controlled by bind
+func adoptBackendRecord(record *v1beta1.BackendRecord, bind *lbcfv1.Bind, recordName string) *v1beta1.BackendRecord {
+	cpy := record.DeepCopy()
+	var svcName, podName string
+	if info := cpy.Spec.PodBackendInfo; info != nil {
+		podName = info.Name
+	} else if info := cpy.Spec.ServiceBackendInfo; info != nil {
+		svcName = info.Name
+	}
+	cpy.Labels = bindcontroller.MakeBackendLabels(cpy.Spec.LBDriver, bind.Name, cpy.Spec.LBName, svcName, podName)
+	if recordName != "" {
+		if cpy.Annotations == nil {
+			cpy.Annotations = make(map[string]string)
+		}
+		cpy.Annotations[lbcfv1.AnnotationBindRecordName] = recordName
+	}
+	valueTrue := true
+	owners := []metav1.OwnerReference{
+		{
+			APIVersion:         lbcfv1.APIVersion,
+			BlockOwnerDeletion: &valueTrue,
+			Controller:         &valueTrue,
+			Kind:               "Bind",
+			Name:               bind.Name,
+			UID:                bind.UID,
+		},
+	}
+	for _, owner := range cpy.OwnerReferences {
+		if owner.Kind != "BackendGroup" && (owner.Controller == nil || !*owner.Controller) {
+			owners = append(owners, owner)
+		}
+	}
+	cpy.OwnerReferences = owners
+	return cpy
+}
+
+// deleteLoadBalancer removes the finalizer of lb before deleting it, so that deleteLoadBalancer is not called
+func (m *Migrator) deleteLoadBalancer(lb *v1beta1.LoadBalancer) error {
+	if m.dryRun {
+		m.printf("LoadBalancer %s/%s will be deleted without calling deleteLoadBalancer\n", lb.Namespace, lb.Name)
+		return nil
+	}
+	err := util.PatchRemoveFinalizer("LoadBalancer", lb.Finalizers, v1beta1.FinalizerDeleteLB, func(pt types.PatchType, data []byte) error {
+		_, err := m.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Patch(lb.Name, pt, data)
+		return err
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	err = m.lbcfClient.LbcfV1beta1().LoadBalancers(lb.Namespace).Delete(lb.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lb.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete LoadBalancer %s/%s failed: %v", lb.Namespace, lb.Name, err)
+	}
+	m.printf("LoadBalancer %s/%s deleted\n", lb.Namespace, lb.Name)
+	return nil
+}
+
+func (m *Migrator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(m.out, format, args...)
+}
+
+// findLoadBalancer finds the LoadBalancer used by BackendGroups in namespace the same way as lbcf-controller,
+// LoadBalancers with prefix lbcf- are searched in kube-system if not found in namespace
+func findLoadBalancer(lbs map[string]*v1beta1.LoadBalancer, namespace, name string) *v1beta1.LoadBalancer {
+	if lb, ok := lbs[util.NamespacedNameKeyFunc(namespace, name)]; ok {
+		return lb
+	}
+	if strings.HasPrefix(name, v1beta1.SystemDriverPrefix) {
+		return lbs[util.NamespacedNameKeyFunc("kube-system", name)]
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package migration
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	lbcfv1 "tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1"
+	"tkestack.io/lb-controlling-framework/pkg/apis/lbcf.tkestack.io/v1beta1"
+	lbcffake "tkestack.io/lb-controlling-framework/pkg/client-go/clientset/versioned/fake"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/bindcontroller"
+	"tkestack.io/lb-controlling-framework/pkg/lbcfcontroller/util"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newMigrationObjects(groupName, lbName string) (*v1beta1.BackendGroup, *v1beta1.LoadBalancer, *v1.Pod, *v1beta1.BackendRecord) {
+	valueTrue := true
+	lb := &v1beta1.LoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       lbName,
+			Namespace:  "default",
+			UID:        types.UID(lbName),
+			Finalizers: []string{v1beta1.FinalizerDeleteLB},
+		},
+		Spec: v1beta1.LoadBalancerSpec{
+			LBDriver:   "test-driver",
+			LBSpec:     map[string]string{"vip": "1.1.1.1"},
+			Attributes: map[string]string{"a1": "v1"},
+		},
+		Status: v1beta1.LoadBalancerStatus{
+			LBInfo: map[string]string{"lbID": "lb-1234"},
+			Conditions: []v1beta1.LoadBalancerCondition{
+				{Type: v1beta1.LBCreated, Status: v1beta1.ConditionTrue},
+				{Type: v1beta1.LBAttributesSynced, Status: v1beta1.ConditionTrue},
+			},
+		},
+	}
+	group := &v1beta1.BackendGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      groupName,
+			Namespace: "default",
+			UID:       types.UID(groupName),
+		},
+		Spec: v1beta1.BackendGroupSpec{
+			LoadBalancers: []string{lbName},
+			Pods: &v1beta1.PodBackend{
+				Ports:  []v1beta1.PortSelector{{Port: 80, Protocol: "TCP"}},
+				ByName: []string{"pod-0"},
+			},
+			Parameters: map[string]string{"p1": "v1"},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-0",
+			Namespace: "default",
+			UID:       "pod-0-uid",
+		},
+		Status: v1.PodStatus{PodIP: "10.0.0.1"},
+	}
+	port := v1beta1.PortSelector{Port: 80, Protocol: "TCP"}
+	record := &v1beta1.BackendRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       util.MakePodBackendName(lbName, groupName, pod.UID, port),
+			Namespace:  "default",
+			Labels:     util.MakeBackendLabels(lb.Spec.LBDriver, lbName, groupName, "", pod.Name),
+			Finalizers: []string{v1beta1.FinalizerDeregisterBackend},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         v1beta1.APIVersion,
+					BlockOwnerDeletion: &valueTrue,
+					Controller:         &valueTrue,
+					Kind:               "BackendGroup",
+					Name:               groupName,
+					UID:                group.UID,
+				},
+			},
+		},
+		Spec: v1beta1.BackendRecordSpec{
+			LBName:         lbName,
+			LBDriver:       lb.Spec.LBDriver,
+			LBInfo:         lb.Status.LBInfo,
+			LBAttributes:   lb.Spec.Attributes,
+			Parameters:     group.Spec.Parameters,
+			PodBackendInfo: &v1beta1.PodBackendRecord{Name: pod.Name, Port: port},
+		},
+	}
+	return group, lb, pod, record
+}
+
+func TestMigrate(t *testing.T) {
+	group, lb, pod, record := newMigrationObjects("group", "lb")
+	lbcfClient := lbcffake.NewSimpleClientset(group, lb, record)
+	out := &bytes.Buffer{}
+	m := NewMigrator(k8sfake.NewSimpleClientset(pod), lbcfClient, false, out)
+
+	results, err := m.Run("default", "")
+	if err != nil {
+		t.Fatalf("expect no error, get %v", err)
+	}
+	if len(results) != 1 || results[0].Skipped != "" || results[0].Err != nil {
+		t.Fatalf("expect migrated, get %+v, output:\n%s", results, out.String())
+	}
+
+	bind, err := lbcfClient.LbcfV1().Binds("default").Get("group", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expect Bind created, get %v", err)
+	}
+	if _, ok := bind.Annotations[lbcfv1.AnnotationMigratingFrom]; ok {
+		t.Errorf("expect annotation %s removed", lbcfv1.AnnotationMigratingFrom)
+	}
+	if len(bind.Spec.LoadBalancers) != 1 || bind.Spec.LoadBalancers[0].Driver != "test-driver" {
+		t.Errorf("unexpected loadBalancers %+v", bind.Spec.LoadBalancers)
+	}
+	if len(bind.Status.LoadBalancerStatuses) != 1 || bind.Status.LoadBalancerStatuses[0].LBInfo["lbID"] != "lb-1234" {
+		t.Fatalf("expect LBInfo carried over, get %+v", bind.Status.LoadBalancerStatuses)
+	}
+	if bind.Status.LoadBalancerStatuses[0].LastSyncedAttributes["a1"] != "v1" {
+		t.Errorf("expect attributes synced, get %+v", bind.Status.LoadBalancerStatuses[0])
+	}
+
+	adopted, err := lbcfClient.LbcfV1beta1().BackendRecords("default").Get(record.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expect BackendRecord kept, get %v", err)
+	}
+	if adopted.Labels[lbcfv1.LabelBindName] != "group" || adopted.Labels[v1beta1.LabelGroupName] != "" {
+		t.Errorf("unexpected labels %v", adopted.Labels)
+	}
+	expectName := bindcontroller.PodBackendRecordName("group", "lb", pod.Name, pod.Status.PodIP, lbcfv1.PortSelector{Port: 80, Protocol: "TCP"}, "")
+	if adopted.Annotations[lbcfv1.AnnotationBindRecordName] != expectName {
+		t.Errorf("expect annotation %s, get %v", expectName, adopted.Annotations)
+	}
+	if owner := metav1.GetControllerOf(adopted); owner == nil || owner.Kind != "Bind" || len(adopted.OwnerReferences) != 1 {
+		t.Errorf("expect controlled by Bind, get %+v", adopted.OwnerReferences)
+	}
+	if len(adopted.Finalizers) != 1 {
+		t.Errorf("expect finalizer kept, get %v", adopted.Finalizers)
+	}
+
+	if _, err := lbcfClient.LbcfV1beta1().BackendGroups("default").Get("group", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expect BackendGroup deleted, get %v", err)
+	}
+	if _, err := lbcfClient.LbcfV1beta1().LoadBalancers("default").Get("lb", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expect LoadBalancer deleted, get %v", err)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	group, lb, pod, record := newMigrationObjects("group", "lb")
+	lbcfClient := lbcffake.NewSimpleClientset(group, lb, record)
+	out := &bytes.Buffer{}
+	m := NewMigrator(k8sfake.NewSimpleClientset(pod), lbcfClient, true, out)
+
+	results, err := m.Run("default", "group")
+	if err != nil {
+		t.Fatalf("expect no error, get %v", err)
+	}
+	if len(results) != 1 || results[0].Skipped != "" || results[0].Err != nil {
+		t.Fatalf("expect migrated, get %+v", results)
+	}
+	for _, action := range lbcfClient.Actions() {
+		if action.GetVerb() != "list" {
+			t.Errorf("expect no writes in dry-run, get %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	for _, expect := range []string{"kind: Bind", "1 BackendRecords will be adopted", "LoadBalancer default/lb will be deleted"} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("expect %q in output:\n%s", expect, out.String())
+		}
+	}
+}
+
+func TestMigrateResume(t *testing.T) {
+	group, lb, pod, record := newMigrationObjects("group", "lb")
+	// the BackendGroup is deleted before the migration is interrupted
+	bind := ConvertToBind(group, []*v1beta1.LoadBalancer{lb})
+	bind.UID = "bind-uid"
+	lbcfClient := lbcffake.NewSimpleClientset(bind, lb, record)
+	m := NewMigrator(k8sfake.NewSimpleClientset(pod), lbcfClient, false, &bytes.Buffer{})
+
+	results, err := m.Run("default", "")
+	if err != nil {
+		t.Fatalf("expect no error, get %v", err)
+	}
+	if len(results) != 1 || results[0].BackendGroup != "group" || results[0].Err != nil {
+		t.Fatalf("expect resumed, get %+v", results)
+	}
+	adopted, _ := lbcfClient.LbcfV1beta1().BackendRecords("default").Get(record.Name, metav1.GetOptions{})
+	if owner := metav1.GetControllerOf(adopted); owner == nil || owner.UID != bind.UID {
+		t.Errorf("expect controlled by Bind, get %+v", adopted.OwnerReferences)
+	}
+	if _, err := lbcfClient.LbcfV1beta1().LoadBalancers("default").Get("lb", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expect LoadBalancer deleted, get %v", err)
+	}
+	get, _ := lbcfClient.LbcfV1().Binds("default").Get("group", metav1.GetOptions{})
+	if _, ok := get.Annotations[lbcfv1.AnnotationMigratingFrom]; ok {
+		t.Errorf("expect annotation %s removed", lbcfv1.AnnotationMigratingFrom)
+	}
+}
+
+func TestMigrateSkip(t *testing.T) {
+	group, lb, _, _ := newMigrationObjects("group", "lb")
+	shared := group.DeepCopy()
+	shared.Name = "shared"
+	notCreated := lb.DeepCopy()
+	notCreated.Name = "not-created"
+	notCreated.Status = v1beta1.LoadBalancerStatus{}
+	pending, _, _, _ := newMigrationObjects("pending", "not-created")
+	existing, _, _, _ := newMigrationObjects("existing", "lb-2")
+	lb2 := lb.DeepCopy()
+	lb2.Name = "lb-2"
+	bind := &lbcfv1.Bind{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}}
+
+	objs := []runtime.Object{group, shared, lb, notCreated, pending, existing, lb2, bind}
+	lbcfClient := lbcffake.NewSimpleClientset(objs...)
+	m := NewMigrator(k8sfake.NewSimpleClientset(), lbcfClient, false, &bytes.Buffer{})
+	results, err := m.Run("", "")
+	if err != nil {
+		t.Fatalf("expect no error, get %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expect 4 results, get %+v", results)
+	}
+	for _, r := range results {
+		if r.Skipped == "" {
+			t.Errorf("expect BackendGroup %s skipped", r.BackendGroup)
+		}
+	}
+	for _, action := range lbcfClient.Actions() {
+		if action.GetVerb() != "list" {
+			t.Errorf("expect no writes, get %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}